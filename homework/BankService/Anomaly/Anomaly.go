@@ -0,0 +1,181 @@
+package anomaly
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type Kind string
+
+const (
+	Outlier     Kind = "outlier"
+	Duplicate   Kind = "duplicate"
+	NewCategory Kind = "new_category"
+)
+
+type Anomaly struct {
+	OperationID service.ObjectID
+	Kind        Kind
+	Score       float64
+	Explanation string
+}
+
+type Config struct {
+	ZThreshold      float64       // порог robust z-score
+	MinSamples      int           // сколько операций категории нужно для оценки типичной суммы
+	DuplicateWindow time.Duration // окно поиска повторного списания
+}
+
+func DefaultConfig() Config {
+	return Config{ZThreshold: 3.5, MinSamples: 5, DuplicateWindow: 48 * time.Hour}
+}
+
+type Notifier interface {
+	Notify(a Anomaly) error
+}
+
+type WriterNotifier struct {
+	w io.Writer
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+func (n *WriterNotifier) Notify(a Anomaly) error {
+	_, err := fmt.Fprintf(n.w, "ALERT [%s] %s: %s\n", a.Kind, a.OperationID, a.Explanation)
+	return err
+}
+
+func median(vals []float64) float64 {
+	s := make([]float64, len(vals))
+	copy(s, vals)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// Масштаб разброса: MAD, а если он нулевой — среднее абсолютное отклонение.
+func robustScale(vals []float64, med float64) float64 {
+	dev := make([]float64, len(vals))
+	var sum float64
+	for i, v := range vals {
+		dev[i] = math.Abs(v - med)
+		sum += dev[i]
+	}
+	if mad := median(dev); mad > 0 {
+		return mad / 0.6745
+	}
+	return sum / float64(len(vals)) * 1.2533
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// history — все известные операции (включая период), period — проверяемые операции.
+// Типичная сумма для операции считается только по операциям категории, сделанным раньше неё:
+// более поздние траты не должны ни маскировать, ни раздувать выброс задним числом.
+// Повторное списание ищется и среди операций истории за DuplicateWindow до начала периода.
+func Detect(history, period []operation.IOperation, cfg Config) []Anomaly {
+	var res []Anomaly
+
+	type catKey struct {
+		account service.ObjectID
+		cat     service.ObjectID
+		otype   operation.OperationType
+	}
+	byCat := make(map[catKey][]operation.IOperation)
+	for _, op := range history {
+		k := catKey{op.BankAccountID(), op.CategoryID(), op.Type()}
+		byCat[k] = append(byCat[k], op)
+	}
+
+	for _, op := range period {
+		k := catKey{op.BankAccountID(), op.CategoryID(), op.Type()}
+		var amounts []float64
+		for _, h := range byCat[k] {
+			if h.ID() != op.ID() && h.Date().Before(op.Date()) {
+				amounts = append(amounts, h.Amount())
+			}
+		}
+
+		if len(amounts) == 0 {
+			res = append(res, Anomaly{
+				OperationID: op.ID(),
+				Kind:        NewCategory,
+				Score:       1,
+				Explanation: fmt.Sprintf("first operation in category %s on this account", op.CategoryID()),
+			})
+		}
+
+		if len(amounts) >= cfg.MinSamples {
+			med := median(amounts)
+			scale := robustScale(amounts, med)
+			if scale > 0 {
+				z := (op.Amount() - med) / scale
+				if z > cfg.ZThreshold {
+					res = append(res, Anomaly{
+						OperationID: op.ID(),
+						Kind:        Outlier,
+						Score:       z,
+						Explanation: fmt.Sprintf("amount %.2f is far above typical %.2f for this category (robust z=%.1f)", op.Amount(), med, z),
+					})
+				}
+			}
+		}
+	}
+
+	if len(period) == 0 {
+		return res
+	}
+	inPeriod := make(map[service.ObjectID]bool, len(period))
+	start := period[0].Date()
+	for _, op := range period {
+		inPeriod[op.ID()] = true
+		if op.Date().Before(start) {
+			start = op.Date()
+		}
+	}
+	sorted := append([]operation.IOperation(nil), period...)
+	for _, op := range history {
+		if !inPeriod[op.ID()] && op.Date().Before(start) && start.Sub(op.Date()) <= cfg.DuplicateWindow {
+			sorted = append(sorted, op)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date().Before(sorted[j].Date()) })
+	for i, op := range sorted {
+		if !inPeriod[op.ID()] {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			prev := sorted[j]
+			if op.Date().Sub(prev.Date()) > cfg.DuplicateWindow {
+				break
+			}
+			if prev.BankAccountID() == op.BankAccountID() &&
+				prev.Type() == op.Type() &&
+				prev.Amount() == op.Amount() &&
+				normalize(prev.Description()) == normalize(op.Description()) {
+				res = append(res, Anomaly{
+					OperationID: op.ID(),
+					Kind:        Duplicate,
+					Score:       1,
+					Explanation: fmt.Sprintf("same amount %.2f and description as %s made %s earlier", op.Amount(), prev.ID(), op.Date().Sub(prev.Date()).Round(time.Minute)),
+				})
+				break
+			}
+		}
+	}
+	return res
+}
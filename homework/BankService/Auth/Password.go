@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Рекомендация OWASP для PBKDF2-HMAC-SHA256.
+const DefaultIterations = 600_000
+
+const (
+	minPasswordLen = 8
+	saltLen        = 16
+	keyLen         = 32
+)
+
+var ErrBadCredentials = errors.New("invalid user name or password")
+
+// Хеш в формате pbkdf2-sha256$<итерации>$<соль>$<ключ>, соль и ключ — base64 без дополнения.
+// Число итераций хранится в хеше: его можно поднять, не ломая старые пароли.
+func HashPassword(password string, iterations int) (string, error) {
+	if len(password) < minPasswordLen {
+		return "", service.Invalid("password", "password must be at least %d characters", minPasswordLen)
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, keyLen)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func VerifyPassword(hash, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return ErrBadCredentials
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return ErrBadCredentials
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return ErrBadCredentials
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return ErrBadCredentials
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrBadCredentials
+	}
+	return nil
+}
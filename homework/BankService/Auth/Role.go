@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+var (
+	ErrUnauthenticated = errors.New("not logged in")
+	ErrForbidden       = errors.New("permission denied")
+)
+
+type principalKey struct{}
+
+type systemKey struct{}
+
+// Вошедший пользователь; кладётся в контекст, чтобы его видели проверки прав.
+func WithPrincipal(ctx context.Context, u user.IUser) context.Context {
+	return context.WithValue(ctx, principalKey{}, u)
+}
+
+func PrincipalFrom(ctx context.Context) (user.IUser, bool) {
+	u, ok := ctx.Value(principalKey{}).(user.IUser)
+	return u, ok
+}
+
+// Системное задание (запуск, фоновые работы, тесты) действует без пользователя.
+// Обход проверок ролей объявляется явно: контекст без пользователя не проходит Require.
+// Хранилища в таком контексте тоже работают без владельца (service.Unscoped).
+func System(ctx context.Context) context.Context {
+	return context.WithValue(service.Unscoped(ctx), systemKey{}, true)
+}
+
+func IsSystem(ctx context.Context) bool {
+	v, _ := ctx.Value(systemKey{}).(bool)
+	return v
+}
+
+// Ошибка, если у пользователя из контекста нет роли required.
+func Require(ctx context.Context, required user.Role) error {
+	u, ok := PrincipalFrom(ctx)
+	if !ok {
+		if IsSystem(ctx) {
+			return nil
+		}
+		return ErrUnauthenticated
+	}
+	if !u.Role().Allows(required) {
+		return fmt.Errorf("%w: %s role required, %s is %s", ErrForbidden, required, u.Name(), u.Role())
+	}
+	return nil
+}
+
+// Действие над пользователем id: сам пользователь или администратор.
+func RequireSelfOr(ctx context.Context, id service.ObjectID, required user.Role) error {
+	if u, ok := PrincipalFrom(ctx); ok && u.ID() == id {
+		return nil
+	}
+	return Require(ctx, required)
+}
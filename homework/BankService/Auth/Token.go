@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Полезная нагрузка JWT; имена полей — зарегистрированные claims из RFC 7519.
+type Claims struct {
+	Subject   string    `json:"sub"`
+	Name      string    `json:"name"`
+	Role      user.Role `json:"role"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+	// Непустое назначение — токен не для входа, а, например, для установки пароля.
+	Purpose string `json:"pur,omitempty"`
+	// Отпечаток хеша пароля на момент выпуска: после смены пароля токен установки недействителен.
+	PasswordState string `json:"pst,omitempty"`
+}
+
+func (c Claims) UserID() (service.ObjectID, error) {
+	var id service.ObjectID
+	if err := id.Scan(c.Subject); err != nil {
+		return service.ObjectID{}, ErrInvalidToken
+	}
+	return id, nil
+}
+
+// Выпускает и проверяет JWT с подписью HS256; внешний сервер авторизации не нужен.
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewTokens(secret []byte, ttl time.Duration) (*Tokens, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("token secret must be at least 32 bytes")
+	}
+	return &Tokens{secret: secret, ttl: ttl, now: time.Now}, nil
+}
+
+var jwtHeader = b64(`{"alg":"HS256","typ":"JWT"}`)
+
+// Назначение одноразового токена установки пароля.
+const PurposePasswordSetup = "password_setup"
+
+func (t *Tokens) Issue(u user.IUser) (string, error) {
+	now := t.now()
+	return t.issue(Claims{
+		Subject:   u.ID().String(),
+		Name:      u.Name(),
+		Role:      u.Role(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.ttl).Unix(),
+	})
+}
+
+// Одноразовый токен установки пароля: годен, пока пароль пользователя не сменился.
+func (t *Tokens) IssuePasswordSetup(u user.IUser, ttl time.Duration) (string, error) {
+	now := t.now()
+	return t.issue(Claims{
+		Subject:       u.ID().String(),
+		Name:          u.Name(),
+		IssuedAt:      now.Unix(),
+		ExpiresAt:     now.Add(ttl).Unix(),
+		Purpose:       PurposePasswordSetup,
+		PasswordState: PasswordState(u.PasswordHash()),
+	})
+}
+
+// Проверяет токен установки пароля для пользователя u в его текущем состоянии.
+func (t *Tokens) VerifyPasswordSetup(token string, u user.IUser) error {
+	c, err := t.Verify(token)
+	if err != nil {
+		return err
+	}
+	if c.Purpose != PurposePasswordSetup || c.Subject != u.ID().String() ||
+		!hmac.Equal([]byte(c.PasswordState), []byte(PasswordState(u.PasswordHash()))) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func PasswordState(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (t *Tokens) issue(c Claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtHeader + "." + b64(string(payload))
+	return signed + "." + t.sign(signed), nil
+}
+
+// Проверяет алгоритм, подпись и срок действия.
+func (t *Tokens) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Claims{}, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(parts[0]+"."+parts[1]))) {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if t.now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+	return c, nil
+}
+
+func (t *Tokens) sign(s string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func b64(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,94 @@
+package categorization
+
+import (
+	"context"
+	"sort"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+)
+
+type Categorizer struct {
+	rules repository.ICommonRepo
+}
+
+func NewCategorizer(rules repository.ICommonRepo) *Categorizer {
+	return &Categorizer{rules: rules}
+}
+
+// Правила проверяются по возрастанию priority, побеждает первое совпавшее.
+func (c *Categorizer) Rules(ctx context.Context) ([]rule.IRule, error) {
+	objs, err := c.rules.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]rule.IRule, 0, len(objs))
+	for _, obj := range objs {
+		if r, ok := obj.(rule.IRule); ok {
+			rules = append(rules, r)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority() != rules[j].Priority() {
+			return rules[i].Priority() < rules[j].Priority()
+		}
+		return rules[i].Name() < rules[j].Name()
+	})
+	return rules, nil
+}
+
+func (c *Categorizer) Match(ctx context.Context, op operation.IOperation) (service.ObjectID, bool, error) {
+	rules, err := c.Rules(ctx)
+	if err != nil {
+		return service.ObjectID{}, false, err
+	}
+	return matchFirst(rules, op)
+}
+
+func matchFirst(rules []rule.IRule, op operation.IOperation) (service.ObjectID, bool, error) {
+	for _, r := range rules {
+		if r.Matches(op) {
+			return r.CategoryID(), true, nil
+		}
+	}
+	return service.ObjectID{}, false, nil
+}
+
+// Возвращает новые категории только для операций, у которых они меняются.
+func (c *Categorizer) Categorize(ctx context.Context, ops []operation.IOperation, onlyUncategorized bool) (map[service.ObjectID]service.ObjectID, error) {
+	rules, err := c.Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[service.ObjectID]service.ObjectID)
+	for _, op := range ops {
+		if onlyUncategorized && op.CategoryID() != (service.ObjectID{}) {
+			continue
+		}
+		catID, found, _ := matchFirst(rules, op)
+		if found && catID != op.CategoryID() {
+			changes[op.ID()] = catID
+		}
+	}
+	return changes, nil
+}
+
+// Хук импортёра: проставляет категорию операциям, пришедшим без неё.
+// Если ни одно правило не подошло, строка отклоняется, как и в CreateOperation.
+func (c *Categorizer) Apply(ctx context.Context, obj service.ICommonObject) error {
+	op, ok := obj.(*operation.Operation)
+	if !ok || op.CategoryID() != (service.ObjectID{}) {
+		return nil
+	}
+	catID, found, err := c.Match(ctx, op)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return service.Invalid("category_id", "category is required: no categorization rule matched")
+	}
+	op.SetCategoryID(catID)
+	return nil
+}
@@ -0,0 +1,142 @@
+package categorization
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type Suggestion struct {
+	CategoryID service.ObjectID
+	Confidence float64
+}
+
+// Мультиномиальный наивный байес по словам описания и типу операции.
+type NaiveBayesModel struct {
+	Docs        int                       `json:"docs"`
+	ClassDocs   map[string]int            `json:"class_docs"`
+	ClassTokens map[string]int            `json:"class_tokens"`
+	TokenCounts map[string]map[string]int `json:"token_counts"`
+	Vocabulary  map[string]int            `json:"vocabulary"`
+}
+
+func NewNaiveBayesModel() *NaiveBayesModel {
+	return &NaiveBayesModel{
+		ClassDocs:   make(map[string]int),
+		ClassTokens: make(map[string]int),
+		TokenCounts: make(map[string]map[string]int),
+		Vocabulary:  make(map[string]int),
+	}
+}
+
+func tokenize(description string, opType operation.OperationType) []string {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, 0, len(words)+1)
+	for _, w := range words {
+		if len([]rune(w)) < 2 {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+	return append(tokens, fmt.Sprintf("type:%d", int(opType)))
+}
+
+// Обучается только на операциях с категорией из categories — видимых пользователю: операции
+// общих счетов несут категории владельца счёта, и подсказывать их нельзя.
+func TrainNaiveBayes(ops []operation.IOperation, categories map[service.ObjectID]bool) *NaiveBayesModel {
+	m := NewNaiveBayesModel()
+	for _, op := range ops {
+		if !categories[op.CategoryID()] {
+			continue
+		}
+		class := op.CategoryID().String()
+		m.Docs++
+		m.ClassDocs[class]++
+		if m.TokenCounts[class] == nil {
+			m.TokenCounts[class] = make(map[string]int)
+		}
+		for _, tok := range tokenize(op.Description(), op.Type()) {
+			m.TokenCounts[class][tok]++
+			m.ClassTokens[class]++
+			m.Vocabulary[tok]++
+		}
+	}
+	return m
+}
+
+func (m *NaiveBayesModel) Suggest(description string, opType operation.OperationType, limit int) []Suggestion {
+	if m.Docs == 0 {
+		return nil
+	}
+	tokens := tokenize(description, opType)
+	vocab := float64(len(m.Vocabulary))
+	scores := make(map[string]float64, len(m.ClassDocs))
+	maxScore := math.Inf(-1)
+	for class, docs := range m.ClassDocs {
+		score := math.Log(float64(docs) / float64(m.Docs))
+		for _, tok := range tokens {
+			// сглаживание Лапласа
+			score += math.Log((float64(m.TokenCounts[class][tok]) + 1) / (float64(m.ClassTokens[class]) + vocab))
+		}
+		scores[class] = score
+		if score > maxScore {
+			maxScore = score
+		}
+	}
+	var total float64
+	for class, score := range scores {
+		scores[class] = math.Exp(score - maxScore)
+		total += scores[class]
+	}
+	res := make([]Suggestion, 0, len(scores))
+	for class, p := range scores {
+		id, err := uuid.Parse(class)
+		if err != nil {
+			continue
+		}
+		res = append(res, Suggestion{CategoryID: service.ObjectID(id), Confidence: p / total})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Confidence != res[j].Confidence {
+			return res[i].Confidence > res[j].Confidence
+		}
+		return res[i].CategoryID.String() < res[j].CategoryID.String()
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+func (m *NaiveBayesModel) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write model: %w", err)
+	}
+	return nil
+}
+
+func LoadNaiveBayesModel(path string) (*NaiveBayesModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read model: %w", err)
+	}
+	m := NewNaiveBayesModel()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse model: %w", err)
+	}
+	return m, nil
+}
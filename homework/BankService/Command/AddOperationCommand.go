@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+
 	"time"
 
 	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
@@ -16,11 +18,12 @@ type AddOperationCommand struct {
 	Date        time.Time
 	CategoryID  service.ObjectID
 	Description string
+	Tags        []string
 	CreatedID   service.ObjectID
 }
 
-func (c *AddOperationCommand) Execute() error {
-	id, err := c.Facade.CreateOperation(c.Type, c.AccountID, c.Amount, c.Date, c.CategoryID, c.Description)
+func (c *AddOperationCommand) Execute(ctx context.Context) error {
+	id, err := c.Facade.CreateTaggedOperation(ctx, c.Type, c.AccountID, c.Amount, c.Date, c.CategoryID, c.Description, c.Tags)
 	if err != nil {
 		return err
 	}
@@ -1,9 +1,11 @@
 package command
 
+import "context"
+
 type Command interface {
-	Execute() error
+	Execute(ctx context.Context) error
 }
 
-type CommandFunc func() error
+type CommandFunc func(ctx context.Context) error
 
-func (f CommandFunc) Execute() error { return f() }
+func (f CommandFunc) Execute(ctx context.Context) error { return f(ctx) }
@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+
 	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 )
@@ -12,8 +14,8 @@ type CreateAccountCommand struct {
 	CreatedID service.ObjectID
 }
 
-func (c *CreateAccountCommand) Execute() error {
-	id, err := c.Facade.CreateAccount(c.Name, c.Balance)
+func (c *CreateAccountCommand) Execute(ctx context.Context) error {
+	id, err := c.Facade.CreateAccount(ctx, c.Name, c.Balance)
 	if err != nil {
 		return err
 	}
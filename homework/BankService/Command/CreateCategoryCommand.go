@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+
 	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
@@ -10,11 +12,12 @@ type CreateCategoryCommand struct {
 	Facade    *facade.CategoryFacade
 	Name      string
 	Type      category.CategoryType
+	ParentID  service.ObjectID // необязательный
 	CreatedID service.ObjectID
 }
 
-func (c *CreateCategoryCommand) Execute() error {
-	id, err := c.Facade.CreateCategory(c.Name, c.Type)
+func (c *CreateCategoryCommand) Execute(ctx context.Context) error {
+	id, err := c.Facade.CreateCategory(ctx, c.Name, c.Type, c.ParentID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"context"
+
+	dedupe "github.com/ilyaytrewq/kpo-sb/homework/BankService/Dedupe"
+	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Находит дубликаты среди сохранённых операций и сливает каждую группу в самую раннюю операцию.
+// Confirm решает по каждой группе; без него группы только подсчитываются.
+type DedupeCommand struct {
+	Facade  *facade.OperationFacade
+	Options dedupe.Options
+	Confirm func(group []operation.IOperation) bool
+	Groups  int
+	Merged  int
+}
+
+func (c *DedupeCommand) Execute(ctx context.Context) error {
+	groups, err := c.Facade.FindDuplicates(ctx, c.Options)
+	if err != nil {
+		return err
+	}
+	c.Groups = len(groups)
+	for _, g := range groups {
+		if c.Confirm == nil || !c.Confirm(g) {
+			continue
+		}
+		dupIDs := make([]service.ObjectID, 0, len(g)-1)
+		for _, op := range g[1:] {
+			dupIDs = append(dupIDs, op.ID())
+		}
+		if err := c.Facade.MergeDuplicates(ctx, g[0].ID(), dupIDs, c.Options); err != nil {
+			return err
+		}
+		c.Merged += len(dupIDs)
+	}
+	return nil
+}
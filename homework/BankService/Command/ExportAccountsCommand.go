@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+
 	exporterCsv "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/CsvExporter"
 	exporterJson "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/JsonExporter"
 	exporterYaml "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/YamlExporter"
@@ -13,14 +15,14 @@ type ExportAccountsCommand struct {
 	Format   string
 }
 
-func (c *ExportAccountsCommand) Execute() error {
+func (c *ExportAccountsCommand) Execute(ctx context.Context) error {
 	switch c.Format {
 	case "csv":
-		return exporterCsv.NewCSVBankAccountExporter(c.Filepath).Export(c.Data)
+		return exporterCsv.NewCSVBankAccountExporter(c.Filepath).Export(ctx, c.Data)
 	case "json":
-		return exporterJson.NewJSONBankAccountExporter(c.Filepath).Export(c.Data)
+		return exporterJson.NewJSONBankAccountExporter(c.Filepath).Export(ctx, c.Data)
 	case "yaml":
-		return exporterYaml.NewYAMLBankAccountExporter(c.Filepath).Export(c.Data)
+		return exporterYaml.NewYAMLBankAccountExporter(c.Filepath).Export(ctx, c.Data)
 	default:
 		return nil
 	}
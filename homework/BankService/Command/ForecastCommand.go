@@ -0,0 +1,30 @@
+package command
+
+import (
+	"context"
+
+	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+	forecast "github.com/ilyaytrewq/kpo-sb/homework/BankService/Forecast"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+type ForecastCommand struct {
+	Facade      *facade.ForecastFacade
+	AccountID   service.ObjectID
+	HorizonDays int
+	HistoryDays int
+	Filepath    string // если задан, ряд сохраняется в JSON
+	Result      *forecast.Forecast
+}
+
+func (c *ForecastCommand) Execute(ctx context.Context) error {
+	fc, err := c.Facade.Forecast(ctx, c.AccountID, c.HorizonDays, c.HistoryDays)
+	if err != nil {
+		return err
+	}
+	c.Result = fc
+	if c.Filepath != "" {
+		return forecast.NewJSONForecastExporter(c.Filepath).Export(ctx, fc)
+	}
+	return nil
+}
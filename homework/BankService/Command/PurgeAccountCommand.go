@@ -0,0 +1,37 @@
+package command
+
+import (
+	"context"
+
+	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+)
+
+// Окончательное удаление архивного счёта. Confirm получает счёт и число операций,
+// которые будут потеряны; без подтверждения ничего не удаляется.
+type PurgeAccountCommand struct {
+	Facade    *facade.BankAccountFacade
+	AccountID service.ObjectID
+	Confirm   func(acc bankaccount.IBankAccount, operations int) bool
+	Purged    int
+	Cancelled bool
+}
+
+func (c *PurgeAccountCommand) Execute(ctx context.Context) error {
+	acc, err := c.Facade.GetAccount(ctx, c.AccountID)
+	if err != nil {
+		return err
+	}
+	ids, err := c.Facade.AccountOperationIDs(ctx, c.AccountID)
+	if err != nil {
+		return err
+	}
+	if c.Confirm == nil || !c.Confirm(acc, len(ids)) {
+		c.Cancelled = true
+		return nil
+	}
+	n, err := c.Facade.PurgeAccount(ctx, c.AccountID)
+	c.Purged = n
+	return err
+}
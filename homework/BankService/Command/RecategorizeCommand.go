@@ -0,0 +1,19 @@
+package command
+
+import (
+	"context"
+
+	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+)
+
+type RecategorizeCommand struct {
+	Facade            *facade.RuleFacade
+	OnlyUncategorized bool
+	Changed           int
+}
+
+func (c *RecategorizeCommand) Execute(ctx context.Context) error {
+	n, err := c.Facade.Recategorize(ctx, c.OnlyUncategorized)
+	c.Changed = n
+	return err
+}
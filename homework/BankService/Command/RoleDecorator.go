@@ -0,0 +1,26 @@
+package command
+
+import (
+	"context"
+
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+// Команда выполняется, только если у пользователя из контекста есть роль required.
+// Нужна для действий в обход фасадов: импорт пишет прямо в репозитории, снимок журнала — в хранилище.
+type RoleDecorator struct {
+	command  Command
+	required user.Role
+}
+
+func NewRoleDecorator(cmd Command, required user.Role) *RoleDecorator {
+	return &RoleDecorator{command: cmd, required: required}
+}
+
+func (d *RoleDecorator) Execute(ctx context.Context) error {
+	if err := auth.Require(ctx, d.required); err != nil {
+		return err
+	}
+	return d.command.Execute(ctx)
+}
@@ -21,7 +21,7 @@ func (f *csvBankAccountFormatter) FormatData(data interface{}) ([]byte, error) {
 	}
 	buf := &bytes.Buffer{}
 	w := csv.NewWriter(buf)
-	if err := w.Write([]string{"id", "name", "balance"}); err != nil {
+	if err := w.Write([]string{"id", "name", "balance", "deleted_at"}); err != nil {
 		return nil, err
 	}
 	for _, o := range objs {
@@ -33,6 +33,7 @@ func (f *csvBankAccountFormatter) FormatData(data interface{}) ([]byte, error) {
 			uuid.UUID(acc.ID()).String(),
 			acc.Name(),
 			strconv.FormatFloat(acc.Balance(), 'f', -1, 64),
+			service.FormatDeletedAt(acc.DeletedAt()),
 		}); err != nil {
 			return nil, err
 		}
@@ -20,7 +20,7 @@ func (f *csvCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 	}
 	buf := &bytes.Buffer{}
 	w := csv.NewWriter(buf)
-	if err := w.Write([]string{"id", "name", "type"}); err != nil {
+	if err := w.Write([]string{"id", "name", "type", "parent_id", "deleted_at"}); err != nil {
 		return nil, err
 	}
 	for _, o := range objs {
@@ -28,10 +28,16 @@ func (f *csvCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
+		parent := ""
+		if c.ParentID() != (service.ObjectID{}) {
+			parent = uuid.UUID(c.ParentID()).String()
+		}
 		if err := w.Write([]string{
 			uuid.UUID(c.ID()).String(),
 			c.Name(),
 			fmt.Sprintf("%d", int(c.Type())),
+			parent,
+			service.FormatDeletedAt(c.DeletedAt()),
 		}); err != nil {
 			return nil, err
 		}
@@ -3,8 +3,10 @@ package csvexporter
 import (
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +15,13 @@ import (
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
 )
 
+// Разбивка операции кладётся в одну ячейку как JSON-массив.
+type csvSplit struct {
+	CategoryID string  `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note,omitempty"`
+}
+
 type csvOperationFormatter struct{}
 
 func (f *csvOperationFormatter) FormatData(data interface{}) ([]byte, error) {
@@ -22,7 +31,7 @@ func (f *csvOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 	}
 	buf := &bytes.Buffer{}
 	w := csv.NewWriter(buf)
-	if err := w.Write([]string{"id", "type", "bank_account_id", "amount", "date", "description", "category_id"}); err != nil {
+	if err := w.Write([]string{"id", "type", "bank_account_id", "amount", "date", "description", "category_id", "tags", "splits"}); err != nil {
 		return nil, err
 	}
 	for _, o := range objs {
@@ -30,6 +39,10 @@ func (f *csvOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
+		splits, err := formatSplits(op.Splits())
+		if err != nil {
+			return nil, err
+		}
 		if err := w.Write([]string{
 			uuid.UUID(op.ID()).String(),
 			fmt.Sprintf("%d", int(op.Type())),
@@ -38,6 +51,8 @@ func (f *csvOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 			op.Date().Format(time.RFC3339),
 			op.Description(),
 			uuid.UUID(op.CategoryID()).String(),
+			strings.Join(op.Tags(), ";"),
+			splits,
 		}); err != nil {
 			return nil, err
 		}
@@ -49,6 +64,18 @@ func (f *csvOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func formatSplits(lines []operation.SplitLine) (string, error) {
+	if len(lines) == 0 {
+		return "", nil
+	}
+	out := make([]csvSplit, 0, len(lines))
+	for _, l := range lines {
+		out = append(out, csvSplit{CategoryID: uuid.UUID(l.CategoryID).String(), Amount: l.Amount, Note: l.Note})
+	}
+	b, err := json.Marshal(out)
+	return string(b), err
+}
+
 func NewCSVOperationExporter(filepath string) *exporter.BaseExporter {
 	return exporter.NewExporter(filepath, &csvOperationFormatter{})
 }
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"fmt"
 	"os"
 )
@@ -18,11 +19,15 @@ func NewExporter(filepath string, formatter DataFormatter) *BaseExporter {
 	return &BaseExporter{filepath: filepath, formatter: formatter}
 }
 
-func (e *BaseExporter) Export(data interface{}) error {
+// Отменённый контекст не даёт записать файл: прерванный экспорт не оставляет результата.
+func (e *BaseExporter) Export(ctx context.Context, data interface{}) error {
 	bytes, err := e.formatter.FormatData(data)
 	if err != nil {
 		return fmt.Errorf("format data: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if err := os.WriteFile(e.filepath, bytes, 0644); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
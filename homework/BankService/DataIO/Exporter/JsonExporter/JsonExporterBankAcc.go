@@ -18,9 +18,10 @@ func (f *jsonBankAccountFormatter) FormatData(data interface{}) ([]byte, error)
 		return nil, fmt.Errorf("invalid data type: expected []service.ICommonObject")
 	}
 	type out struct {
-		ID      string  `json:"id"`
-		Name    string  `json:"name"`
-		Balance float64 `json:"balance"`
+		ID        string  `json:"id"`
+		Name      string  `json:"name"`
+		Balance   float64 `json:"balance"`
+		DeletedAt string  `json:"deleted_at,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -30,9 +31,10 @@ func (f *jsonBankAccountFormatter) FormatData(data interface{}) ([]byte, error)
 			continue
 		}
 		res = append(res, out{
-			ID:      uuid.UUID(acc.ID()).String(),
-			Name:    acc.Name(),
-			Balance: acc.Balance(),
+			ID:        uuid.UUID(acc.ID()).String(),
+			Name:      acc.Name(),
+			Balance:   acc.Balance(),
+			DeletedAt: service.FormatDeletedAt(acc.DeletedAt()),
 		})
 	}
 	return json.MarshalIndent(res, "", "\t")
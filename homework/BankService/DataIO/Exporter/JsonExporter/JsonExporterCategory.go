@@ -18,9 +18,11 @@ func (f *jsonCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("invalid data type: expected []service.ICommonObject")
 	}
 	type out struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-		Type int    `json:"type"`
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Type      int    `json:"type"`
+		ParentID  string `json:"parent_id,omitempty"`
+		DeletedAt string `json:"deleted_at,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -28,11 +30,16 @@ func (f *jsonCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
-		res = append(res, out{
+		el := out{
 			ID:   uuid.UUID(c.ID()).String(),
 			Name: c.Name(),
 			Type: int(c.Type()),
-		})
+		}
+		if c.ParentID() != (service.ObjectID{}) {
+			el.ParentID = uuid.UUID(c.ParentID()).String()
+		}
+		el.DeletedAt = service.FormatDeletedAt(c.DeletedAt())
+		res = append(res, el)
 	}
 	return json.MarshalIndent(res, "", "\t")
 }
@@ -18,14 +18,21 @@ func (f *jsonOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf("invalid data type: expected []service.ICommonObject")
 	}
+	type splitOut struct {
+		CategoryID string  `json:"category_id"`
+		Amount     float64 `json:"amount"`
+		Note       string  `json:"note,omitempty"`
+	}
 	type out struct {
-		ID            string  `json:"id"`
-		Type          int     `json:"type"`
-		BankAccountID string  `json:"bank_account_id"`
-		Amount        float64 `json:"amount"`
-		Date          string  `json:"date"`
-		Description   string  `json:"description"`
-		CategoryID    string  `json:"category_id"`
+		ID            string     `json:"id"`
+		Type          int        `json:"type"`
+		BankAccountID string     `json:"bank_account_id"`
+		Amount        float64    `json:"amount"`
+		Date          string     `json:"date"`
+		Description   string     `json:"description"`
+		CategoryID    string     `json:"category_id"`
+		Tags          []string   `json:"tags,omitempty"`
+		Splits        []splitOut `json:"splits,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -33,6 +40,10 @@ func (f *jsonOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
+		var splits []splitOut
+		for _, l := range op.Splits() {
+			splits = append(splits, splitOut{CategoryID: uuid.UUID(l.CategoryID).String(), Amount: l.Amount, Note: l.Note})
+		}
 		res = append(res, out{
 			ID:            uuid.UUID(op.ID()).String(),
 			Type:          int(op.Type()),
@@ -41,6 +52,8 @@ func (f *jsonOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 			Date:          op.Date().Format(time.RFC3339),
 			Description:   op.Description(),
 			CategoryID:    uuid.UUID(op.CategoryID()).String(),
+			Tags:          op.Tags(),
+			Splits:        splits,
 		})
 	}
 	return json.MarshalIndent(res, "", "\t")
@@ -0,0 +1,77 @@
+package parquetexporter
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	exporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	"github.com/parquet-go/parquet-go"
+)
+
+type SplitRow struct {
+	CategoryID uuid.UUID `parquet:"category_id,uuid"`
+	Amount     int64     `parquet:"amount,decimal(2:18)"`
+	Note       string    `parquet:"note"`
+}
+
+type OperationRow struct {
+	ID            uuid.UUID  `parquet:"id,uuid"`
+	Type          int32      `parquet:"type"`
+	BankAccountID uuid.UUID  `parquet:"bank_account_id,uuid"`
+	Amount        int64      `parquet:"amount,decimal(2:18)"` // в копейках
+	Date          int64      `parquet:"date,timestamp(microsecond:utc)"`
+	Description   string     `parquet:"description"`
+	CategoryID    uuid.UUID  `parquet:"category_id,uuid"`
+	Splits        []SplitRow `parquet:"splits,list"`
+}
+
+type parquetOperationFormatter struct{}
+
+func (f *parquetOperationFormatter) FormatData(data interface{}) ([]byte, error) {
+	objs, ok := data.([]service.ICommonObject)
+	if !ok {
+		return nil, fmt.Errorf("invalid data type: expected []service.ICommonObject")
+	}
+	rows := make([]OperationRow, 0, len(objs))
+	for _, o := range objs {
+		op, ok := o.(operation.IOperation)
+		if !ok {
+			continue
+		}
+		var splits []SplitRow
+		for _, l := range op.Splits() {
+			splits = append(splits, SplitRow{CategoryID: uuid.UUID(l.CategoryID), Amount: int64(math.Round(l.Amount * 100)), Note: l.Note})
+		}
+		rows = append(rows, OperationRow{
+			ID:            uuid.UUID(op.ID()),
+			Type:          int32(op.Type()),
+			BankAccountID: uuid.UUID(op.BankAccountID()),
+			Amount:        int64(math.Round(op.Amount() * 100)),
+			Date:          op.Date().UTC().UnixMicro(),
+			Description:   op.Description(),
+			CategoryID:    uuid.UUID(op.CategoryID()),
+			Splits:        splits,
+		})
+	}
+	// одинаковые данные должны давать одинаковый файл, иначе партиции будут перезаписываться зря
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date < rows[j].Date
+		}
+		return bytes.Compare(rows[i].ID[:], rows[j].ID[:]) < 0
+	})
+	buf := &bytes.Buffer{}
+	if err := parquet.Write(buf, rows, parquet.CreatedBy("bankservice", "", "")); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func NewParquetOperationExporter(filepath string) *exporter.BaseExporter {
+	return exporter.NewExporter(filepath, &parquetOperationFormatter{})
+}
@@ -0,0 +1,121 @@
+package parquetexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type PartitionedOperationExporter struct {
+	dir       string
+	formatter *parquetOperationFormatter
+
+	Written   int
+	Unchanged int
+	Removed   int // разделы, для которых больше нет операций
+}
+
+func NewParquetPartitionedOperationExporter(dir string) *PartitionedOperationExporter {
+	return &PartitionedOperationExporter{dir: dir, formatter: &parquetOperationFormatter{}}
+}
+
+func PartitionPath(dir string, accountID service.ObjectID, month string) string {
+	return filepath.Join(dir, "account_id="+uuid.UUID(accountID).String(), "month="+month, "operations.parquet")
+}
+
+// Изменившийся раздел перезаписывается в очищенный каталог, так что посторонних файлов в нём
+// не остаётся; каталоги разделов без операций удаляются.
+// Отмена прерывает выгрузку между разделами: записанные разделы остаются целыми.
+func (e *PartitionedOperationExporter) Export(ctx context.Context, data interface{}) error {
+	objs, ok := data.([]service.ICommonObject)
+	if !ok {
+		return fmt.Errorf("invalid data type: expected []service.ICommonObject")
+	}
+	type partitionKey struct {
+		account service.ObjectID
+		month   string
+	}
+	parts := make(map[partitionKey][]service.ICommonObject)
+	for _, o := range objs {
+		op, ok := o.(operation.IOperation)
+		if !ok {
+			continue
+		}
+		key := partitionKey{account: op.BankAccountID(), month: op.Date().UTC().Format("2006-01")}
+		parts[key] = append(parts[key], o)
+	}
+
+	e.Written, e.Unchanged, e.Removed = 0, 0, 0
+	for key, ops := range parts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bytesData, err := e.formatter.FormatData(ops)
+		if err != nil {
+			return fmt.Errorf("format partition %s/%s: %w", key.account, key.month, err)
+		}
+		path := PartitionPath(e.dir, key.account, key.month)
+		if partitionHolds(path, bytesData) {
+			e.Unchanged++
+			continue
+		}
+		if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("clear partition dir: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("create partition dir: %w", err)
+		}
+		if err := os.WriteFile(path, bytesData, 0644); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+		e.Written++
+	}
+
+	live := make(map[string]bool, len(parts))
+	for key := range parts {
+		live[filepath.Dir(PartitionPath(e.dir, key.account, key.month))] = true
+	}
+	return e.removeStale(ctx, live)
+}
+
+// Раздел не изменился, если в каталоге лежит только файл с теми же байтами.
+func partitionHolds(path string, data []byte) bool {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 || entries[0].Name() != filepath.Base(path) {
+		return false
+	}
+	old, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(old, data)
+}
+
+// Удаляет каталоги month=… без операций и опустевшие каталоги account_id=….
+func (e *PartitionedOperationExporter) removeStale(ctx context.Context, live map[string]bool) error {
+	months, err := filepath.Glob(filepath.Join(e.dir, "account_id=*", "month=*"))
+	if err != nil {
+		return err
+	}
+	for _, m := range months {
+		if live[m] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(m); err != nil {
+			return fmt.Errorf("remove stale partition: %w", err)
+		}
+		e.Removed++
+		if rest, err := os.ReadDir(filepath.Dir(m)); err == nil && len(rest) == 0 {
+			if err := os.Remove(filepath.Dir(m)); err != nil {
+				return fmt.Errorf("remove stale partition: %w", err)
+			}
+		}
+	}
+	return nil
+}
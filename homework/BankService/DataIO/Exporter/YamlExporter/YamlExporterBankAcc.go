@@ -17,9 +17,10 @@ func (f *yamlBankAccountFormatter) FormatData(data interface{}) ([]byte, error)
 		return yaml.Marshal([]any{})
 	}
 	type out struct {
-		ID      string  `yaml:"id"`
-		Name    string  `yaml:"name"`
-		Balance float64 `yaml:"balance"`
+		ID        string  `yaml:"id"`
+		Name      string  `yaml:"name"`
+		Balance   float64 `yaml:"balance"`
+		DeletedAt string  `yaml:"deleted_at,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -27,7 +28,7 @@ func (f *yamlBankAccountFormatter) FormatData(data interface{}) ([]byte, error)
 		if !ok {
 			continue
 		}
-		res = append(res, out{ID: uuid.UUID(acc.ID()).String(), Name: acc.Name(), Balance: acc.Balance()})
+		res = append(res, out{ID: uuid.UUID(acc.ID()).String(), Name: acc.Name(), Balance: acc.Balance(), DeletedAt: service.FormatDeletedAt(acc.DeletedAt())})
 	}
 	return yaml.Marshal(res)
 }
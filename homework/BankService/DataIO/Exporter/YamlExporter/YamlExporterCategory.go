@@ -14,9 +14,11 @@ type yamlCategoryFormatter struct{}
 func (f *yamlCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 	objs, _ := data.([]service.ICommonObject)
 	type out struct {
-		ID   string `yaml:"id"`
-		Name string `yaml:"name"`
-		Type int    `yaml:"type"`
+		ID        string `yaml:"id"`
+		Name      string `yaml:"name"`
+		Type      int    `yaml:"type"`
+		ParentID  string `yaml:"parent_id,omitempty"`
+		DeletedAt string `yaml:"deleted_at,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -24,7 +26,12 @@ func (f *yamlCategoryFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
-		res = append(res, out{ID: uuid.UUID(c.ID()).String(), Name: c.Name(), Type: int(c.Type())})
+		el := out{ID: uuid.UUID(c.ID()).String(), Name: c.Name(), Type: int(c.Type())}
+		if c.ParentID() != (service.ObjectID{}) {
+			el.ParentID = uuid.UUID(c.ParentID()).String()
+		}
+		el.DeletedAt = service.FormatDeletedAt(c.DeletedAt())
+		res = append(res, el)
 	}
 	return yaml.Marshal(res)
 }
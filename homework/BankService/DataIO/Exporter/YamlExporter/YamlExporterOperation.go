@@ -15,14 +15,21 @@ type yamlOperationFormatter struct{}
 
 func (f *yamlOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 	objs, _ := data.([]service.ICommonObject)
+	type splitOut struct {
+		CategoryID string  `yaml:"category_id"`
+		Amount     float64 `yaml:"amount"`
+		Note       string  `yaml:"note,omitempty"`
+	}
 	type out struct {
-		ID            string  `yaml:"id"`
-		Type          int     `yaml:"type"`
-		BankAccountID string  `yaml:"bank_account_id"`
-		Amount        float64 `yaml:"amount"`
-		Date          string  `yaml:"date"`
-		Description   string  `yaml:"description"`
-		CategoryID    string  `yaml:"category_id"`
+		ID            string     `yaml:"id"`
+		Type          int        `yaml:"type"`
+		BankAccountID string     `yaml:"bank_account_id"`
+		Amount        float64    `yaml:"amount"`
+		Date          string     `yaml:"date"`
+		Description   string     `yaml:"description"`
+		CategoryID    string     `yaml:"category_id"`
+		Tags          []string   `yaml:"tags,omitempty"`
+		Splits        []splitOut `yaml:"splits,omitempty"`
 	}
 	res := make([]out, 0, len(objs))
 	for _, o := range objs {
@@ -30,6 +37,10 @@ func (f *yamlOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 		if !ok {
 			continue
 		}
+		var splits []splitOut
+		for _, l := range op.Splits() {
+			splits = append(splits, splitOut{CategoryID: uuid.UUID(l.CategoryID).String(), Amount: l.Amount, Note: l.Note})
+		}
 		res = append(res, out{
 			ID:            uuid.UUID(op.ID()).String(),
 			Type:          int(op.Type()),
@@ -38,6 +49,8 @@ func (f *yamlOperationFormatter) FormatData(data interface{}) ([]byte, error) {
 			Date:          op.Date().Format(time.RFC3339),
 			Description:   op.Description(),
 			CategoryID:    uuid.UUID(op.CategoryID()).String(),
+			Tags:          op.Tags(),
+			Splits:        splits,
 		})
 	}
 	return yaml.Marshal(res)
@@ -48,6 +48,14 @@ func (p *csvBankParser) Parse(data []byte) ([]service.ICommonObject, error) {
 			errs = append(errs, fmt.Sprintf("row %d: %v", i, err))
 			continue
 		}
+		if len(rec) > 3 {
+			deletedAt, err := service.ParseDeletedAt(rec[3])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid deleted_at '%s'", i, rec[3]))
+				continue
+			}
+			acc.Archive(deletedAt)
+		}
 		result = append(result, acc)
 	}
 	if len(errs) > 0 {
@@ -43,11 +43,27 @@ func (p *csvCategoryParser) Parse(data []byte) ([]service.ICommonObject, error)
 			errs = append(errs, fmt.Sprintf("row %d: invalid type '%s'", i, rec[2]))
 			continue
 		}
-		obj, err := category.NewCopyCategory(service.ObjectID(id), rec[1], category.CategoryType(t))
+		var parentID uuid.UUID
+		if len(rec) > 3 && rec[3] != "" {
+			parentID, err = uuid.Parse(rec[3])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid parent_id '%s'", i, rec[3]))
+				continue
+			}
+		}
+		obj, err := category.NewCopyCategory(service.ObjectID(id), rec[1], category.CategoryType(t), service.ObjectID(parentID))
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("row %d: %v", i, err))
 			continue
 		}
+		if len(rec) > 4 {
+			deletedAt, err := service.ParseDeletedAt(rec[4])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid deleted_at '%s'", i, rec[4]))
+				continue
+			}
+			obj.Archive(deletedAt)
+		}
 		result = append(result, obj)
 	}
 	if len(errs) > 0 {
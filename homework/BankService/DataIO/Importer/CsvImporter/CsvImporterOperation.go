@@ -2,6 +2,7 @@ package csvimporter
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,10 +15,17 @@ import (
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
 )
 
+type csvSplit struct {
+	CategoryID string  `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note"`
+}
+
 type csvOperationParser struct{}
 
 func (p *csvOperationParser) Parse(data []byte) ([]service.ICommonObject, error) {
 	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1 // колонки tags и splits необязательны
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, err
@@ -60,10 +68,13 @@ func (p *csvOperationParser) Parse(data []byte) ([]service.ICommonObject, error)
 			continue
 		}
 		descr := rec[5]
-		catID, err := uuid.Parse(rec[6])
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("row %d: invalid category_id '%s'", i, rec[6]))
-			continue
+		var catID uuid.UUID // пустая категория проставится правилами
+		if rec[6] != "" {
+			catID, err = uuid.Parse(rec[6])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid category_id '%s'", i, rec[6]))
+				continue
+			}
 		}
 		obj, err := operation.NewCopyOperation(
 			service.ObjectID(id),
@@ -78,6 +89,22 @@ func (p *csvOperationParser) Parse(data []byte) ([]service.ICommonObject, error)
 			errs = append(errs, fmt.Sprintf("row %d: %v", i, err))
 			continue
 		}
+		if len(rec) > 7 {
+			if err := obj.SetTags(operation.ParseTagList(rec[7], ";")...); err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: %v", i, err))
+				continue
+			}
+		}
+		if len(rec) > 8 && rec[8] != "" {
+			lines, err := parseSplits(rec[8])
+			if err == nil {
+				err = obj.SetSplits(lines)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid splits: %v", i, err))
+				continue
+			}
+		}
 		result = append(result, obj)
 	}
 	if len(errs) > 0 {
@@ -86,6 +113,22 @@ func (p *csvOperationParser) Parse(data []byte) ([]service.ICommonObject, error)
 	return result, nil
 }
 
+func parseSplits(cell string) ([]operation.SplitLine, error) {
+	var raw []csvSplit
+	if err := json.Unmarshal([]byte(cell), &raw); err != nil {
+		return nil, err
+	}
+	lines := make([]operation.SplitLine, 0, len(raw))
+	for _, sp := range raw {
+		catID, err := uuid.Parse(sp.CategoryID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category_id '%s'", sp.CategoryID)
+		}
+		lines = append(lines, operation.SplitLine{CategoryID: service.ObjectID(catID), Amount: sp.Amount, Note: sp.Note})
+	}
+	return lines, nil
+}
+
 func NewCSVOperationImporter(filepath string) *importer.BaseImporter {
 	return importer.NewImporter(filepath, operationrepo.NewOperationRepo(), &csvOperationParser{})
 }
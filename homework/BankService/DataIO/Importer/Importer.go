@@ -2,6 +2,7 @@ package importer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -11,7 +12,7 @@ import (
 )
 
 type Importer interface {
-	Read() error
+	Read(ctx context.Context) error
 	Data() repository.ICommonRepo
 }
 
@@ -19,17 +20,29 @@ type DataParser interface {
 	Parse(data []byte) ([]service.ICommonObject, error)
 }
 
+type ObjectHook interface {
+	Apply(ctx context.Context, obj service.ICommonObject) error
+}
+
+// Хук возвращает ошибку, обёрнутую в ErrSkip, чтобы молча пропустить объект: это не ошибка импорта.
+var ErrSkip = errors.New("skipped")
+
 type BaseImporter struct {
 	filepath string
 	repo     repository.ICommonRepo
 	parser   DataParser
+	hooks    []ObjectHook
+	skipped  int
 }
 
 func NewImporter(filepath string, repo repository.ICommonRepo, parser DataParser) *BaseImporter {
 	return &BaseImporter{filepath: filepath, repo: repo, parser: parser}
 }
 
-func (b *BaseImporter) Read() error {
+func (b *BaseImporter) AddHook(h ObjectHook) { b.hooks = append(b.hooks, h) }
+
+// Отмена контекста останавливает импорт между объектами; уже сохранённые объекты остаются.
+func (b *BaseImporter) Read(ctx context.Context) error {
 	data, err := os.ReadFile(b.filepath)
 	if err != nil {
 		return err
@@ -39,8 +52,19 @@ func (b *BaseImporter) Read() error {
 		return err
 	}
 	var errs []string
+	b.skipped = 0
 	for _, obj := range objs {
-		if err := b.repo.Save(context.Background(), obj); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.applyHooks(ctx, obj); errors.Is(err, ErrSkip) {
+			b.skipped++
+			continue
+		} else if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := b.repo.Save(ctx, obj); err != nil {
 			errs = append(errs, err.Error())
 		}
 	}
@@ -50,4 +74,16 @@ func (b *BaseImporter) Read() error {
 	return nil
 }
 
+func (b *BaseImporter) applyHooks(ctx context.Context, obj service.ICommonObject) error {
+	for _, h := range b.hooks {
+		if err := h.Apply(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *BaseImporter) Data() repository.ICommonRepo { return b.repo }
+
+// Сколько объектов пропустили хуки при последнем Read.
+func (b *BaseImporter) Skipped() int { return b.skipped }
@@ -13,9 +13,10 @@ import (
 )
 
 type bankAccountJSON struct {
-	ID      string  `json:"id"`
-	Name    string  `json:"name"`
-	Balance float64 `json:"balance"`
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Balance   float64 `json:"balance"`
+	DeletedAt string  `json:"deleted_at"`
 }
 
 // jsonBankParser implements DataParser for bank accounts in JSON
@@ -41,6 +42,12 @@ func (p *jsonBankParser) Parse(data []byte) ([]service.ICommonObject, error) {
 			errs = append(errs, err.Error())
 			continue
 		}
+		deletedAt, err := service.ParseDeletedAt(acc.DeletedAt)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid deleted_at '%s'", acc.DeletedAt))
+			continue
+		}
+		el.Archive(deletedAt)
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
@@ -13,9 +13,11 @@ import (
 )
 
 type categoryJSON struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Type int    `json:"type"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      int    `json:"type"`
+	ParentID  string `json:"parent_id"`
+	DeletedAt string `json:"deleted_at"`
 }
 
 type jsonCategoryParser struct{}
@@ -35,11 +37,25 @@ func (p *jsonCategoryParser) Parse(data []byte) ([]service.ICommonObject, error)
 			errs = append(errs, fmt.Sprintf("invalid id '%s'", c.ID))
 			continue
 		}
-		el, err := category.NewCopyCategory(service.ObjectID(id), c.Name, category.CategoryType(c.Type))
+		var parentID uuid.UUID
+		if c.ParentID != "" {
+			parentID, err = uuid.Parse(c.ParentID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid parent_id '%s'", c.ParentID))
+				continue
+			}
+		}
+		el, err := category.NewCopyCategory(service.ObjectID(id), c.Name, category.CategoryType(c.Type), service.ObjectID(parentID))
 		if err != nil {
 			errs = append(errs, err.Error())
 			continue
 		}
+		deletedAt, err := service.ParseDeletedAt(c.DeletedAt)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid deleted_at '%s'", c.DeletedAt))
+			continue
+		}
+		el.Archive(deletedAt)
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
@@ -14,13 +14,21 @@ import (
 )
 
 type operationJSON struct {
-	ID            string  `json:"id"`
-	Type          int     `json:"type"` // 0 - Spending, 1 - Income
-	BankAccountID string  `json:"bank_account_id"`
-	Amount        float64 `json:"amount"`
-	Date          string  `json:"date"` // RFC3339 format
-	Description   string  `json:"description"`
-	CategoryID    string  `json:"category_id"`
+	ID            string      `json:"id"`
+	Type          int         `json:"type"` // 0 - Spending, 1 - Income
+	BankAccountID string      `json:"bank_account_id"`
+	Amount        float64     `json:"amount"`
+	Date          string      `json:"date"` // RFC3339 format
+	Description   string      `json:"description"`
+	CategoryID    string      `json:"category_id"`
+	Tags          []string    `json:"tags"`
+	Splits        []splitJSON `json:"splits"`
+}
+
+type splitJSON struct {
+	CategoryID string  `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note"`
 }
 
 type jsonOperationParser struct{}
@@ -45,10 +53,13 @@ func (p *jsonOperationParser) Parse(data []byte) ([]service.ICommonObject, error
 			errs = append(errs, fmt.Sprintf("invalid bank_account_id '%s'", op.BankAccountID))
 			continue
 		}
-		catID, err := uuid.Parse(op.CategoryID)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("invalid category_id '%s'", op.CategoryID))
-			continue
+		var catID uuid.UUID // пустая категория проставится правилами
+		if op.CategoryID != "" {
+			catID, err = uuid.Parse(op.CategoryID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid category_id '%s'", op.CategoryID))
+				continue
+			}
 		}
 		dt, err := time.Parse(time.RFC3339, op.Date)
 		if err != nil {
@@ -68,6 +79,26 @@ func (p *jsonOperationParser) Parse(data []byte) ([]service.ICommonObject, error
 			errs = append(errs, err.Error())
 			continue
 		}
+		if err := el.SetTags(op.Tags...); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		lines := make([]operation.SplitLine, 0, len(op.Splits))
+		for _, sp := range op.Splits {
+			lineCat, err := uuid.Parse(sp.CategoryID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid split category_id '%s'", sp.CategoryID))
+				break
+			}
+			lines = append(lines, operation.SplitLine{CategoryID: service.ObjectID(lineCat), Amount: sp.Amount, Note: sp.Note})
+		}
+		if len(lines) != len(op.Splits) {
+			continue
+		}
+		if err := el.SetSplits(lines); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
@@ -14,9 +14,10 @@ import (
 )
 
 type bankAccountYAML struct {
-	ID      string  `yaml:"id"`
-	Name    string  `yaml:"name"`
-	Balance float64 `yaml:"balance"`
+	ID        string  `yaml:"id"`
+	Name      string  `yaml:"name"`
+	Balance   float64 `yaml:"balance"`
+	DeletedAt string  `yaml:"deleted_at"`
 }
 
 type yamlBankParser struct{}
@@ -41,6 +42,12 @@ func (p *yamlBankParser) Parse(data []byte) ([]service.ICommonObject, error) {
 			errs = append(errs, err.Error())
 			continue
 		}
+		deletedAt, err := service.ParseDeletedAt(acc.DeletedAt)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid deleted_at '%s'", acc.DeletedAt))
+			continue
+		}
+		el.Archive(deletedAt)
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
@@ -14,9 +14,11 @@ import (
 )
 
 type categoryYAML struct {
-	ID   string `yaml:"id"`
-	Name string `yaml:"name"`
-	Type int    `yaml:"type"`
+	ID        string `yaml:"id"`
+	Name      string `yaml:"name"`
+	Type      int    `yaml:"type"`
+	ParentID  string `yaml:"parent_id"`
+	DeletedAt string `yaml:"deleted_at"`
 }
 
 type yamlCategoryParser struct{}
@@ -36,11 +38,25 @@ func (p *yamlCategoryParser) Parse(data []byte) ([]service.ICommonObject, error)
 			errs = append(errs, fmt.Sprintf("invalid id '%s'", c.ID))
 			continue
 		}
-		el, err := category.NewCopyCategory(service.ObjectID(id), c.Name, category.CategoryType(c.Type))
+		var parentID uuid.UUID
+		if c.ParentID != "" {
+			parentID, err = uuid.Parse(c.ParentID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid parent_id '%s'", c.ParentID))
+				continue
+			}
+		}
+		el, err := category.NewCopyCategory(service.ObjectID(id), c.Name, category.CategoryType(c.Type), service.ObjectID(parentID))
 		if err != nil {
 			errs = append(errs, err.Error())
 			continue
 		}
+		deletedAt, err := service.ParseDeletedAt(c.DeletedAt)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid deleted_at '%s'", c.DeletedAt))
+			continue
+		}
+		el.Archive(deletedAt)
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
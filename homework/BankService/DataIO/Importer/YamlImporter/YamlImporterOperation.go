@@ -15,13 +15,21 @@ import (
 )
 
 type operationYAML struct {
-	ID            string  `yaml:"id"`
-	Type          int     `yaml:"type"`
-	BankAccountID string  `yaml:"bank_account_id"`
-	Amount        float64 `yaml:"amount"`
-	Date          string  `yaml:"date"`
-	Description   string  `yaml:"description"`
-	CategoryID    string  `yaml:"category_id"`
+	ID            string      `yaml:"id"`
+	Type          int         `yaml:"type"`
+	BankAccountID string      `yaml:"bank_account_id"`
+	Amount        float64     `yaml:"amount"`
+	Date          string      `yaml:"date"`
+	Description   string      `yaml:"description"`
+	CategoryID    string      `yaml:"category_id"`
+	Tags          []string    `yaml:"tags"`
+	Splits        []splitYAML `yaml:"splits"`
+}
+
+type splitYAML struct {
+	CategoryID string  `yaml:"category_id"`
+	Amount     float64 `yaml:"amount"`
+	Note       string  `yaml:"note"`
 }
 
 type yamlOperationParser struct{}
@@ -46,10 +54,13 @@ func (p *yamlOperationParser) Parse(data []byte) ([]service.ICommonObject, error
 			errs = append(errs, fmt.Sprintf("invalid bank_account_id '%s'", op.BankAccountID))
 			continue
 		}
-		catID, err := uuid.Parse(op.CategoryID)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("invalid category_id '%s'", op.CategoryID))
-			continue
+		var catID uuid.UUID // пустая категория проставится правилами
+		if op.CategoryID != "" {
+			catID, err = uuid.Parse(op.CategoryID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid category_id '%s'", op.CategoryID))
+				continue
+			}
 		}
 		dt, err := time.Parse(time.RFC3339, op.Date)
 		if err != nil {
@@ -61,6 +72,26 @@ func (p *yamlOperationParser) Parse(data []byte) ([]service.ICommonObject, error
 			errs = append(errs, err.Error())
 			continue
 		}
+		if err := el.SetTags(op.Tags...); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		lines := make([]operation.SplitLine, 0, len(op.Splits))
+		for _, sp := range op.Splits {
+			lineCat, err := uuid.Parse(sp.CategoryID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid split category_id '%s'", sp.CategoryID))
+				break
+			}
+			lines = append(lines, operation.SplitLine{CategoryID: service.ObjectID(lineCat), Amount: sp.Amount, Note: sp.Note})
+		}
+		if len(lines) != len(op.Splits) {
+			continue
+		}
+		if err := el.SetSplits(lines); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
 		result = append(result, el)
 	}
 	if len(errs) > 0 {
@@ -0,0 +1,127 @@
+package dedupe
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type Options struct {
+	// Насколько могут расходиться даты у одной и той же операции из разных выписок.
+	DateTolerance time.Duration
+	// Порог похожести описаний от 0 до 1.
+	MinSimilarity float64
+}
+
+var DefaultOptions = Options{DateTolerance: 48 * time.Hour, MinSimilarity: 0.6}
+
+// Дубликаты: тот же счёт, тип и сумма до копейки, даты рядом и похожие описания.
+func IsDuplicate(a, b operation.IOperation, opts Options) bool {
+	if a.ID() == b.ID() || a.BankAccountID() != b.BankAccountID() || a.Type() != b.Type() {
+		return false
+	}
+	if math.Round(a.Amount()*100) != math.Round(b.Amount()*100) {
+		return false
+	}
+	if a.Date().Sub(b.Date()).Abs() > opts.DateTolerance {
+		return false
+	}
+	return Similarity(a.Description(), b.Description()) >= opts.MinSimilarity
+}
+
+// Коэффициент Дайса по парам соседних символов; регистр, пробелы и знаки препинания не важны.
+func Similarity(a, b string) float64 {
+	a, b = normalize(a), normalize(b)
+	if a == b {
+		return 1
+	}
+	ba, bb := bigrams(a), bigrams(b)
+	if len(ba) == 0 || len(bb) == 0 {
+		return 0
+	}
+	common := 0
+	for g, n := range ba {
+		common += min(n, bb[g])
+	}
+	total := 0
+	for _, n := range ba {
+		total += n
+	}
+	for _, n := range bb {
+		total += n
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func bigrams(s string) map[string]int {
+	runes := []rune(s)
+	out := make(map[string]int)
+	if len(runes) == 1 {
+		out[s]++
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		out[string(runes[i:i+2])]++
+	}
+	return out
+}
+
+// Группы дубликатов: операции перебираются по дате и id, первая ещё не попавшая в группу
+// становится якорем, и в её группу идут только похожие именно на неё. Сходство не транзитивно:
+// цепочка «a похожа на b, b похожа на c» не сливает a и c.
+func FindGroups(ops []operation.IOperation, opts Options) [][]operation.IOperation {
+	sorted := append([]operation.IOperation(nil), ops...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Date().Equal(sorted[j].Date()) {
+			return sorted[i].Date().Before(sorted[j].Date())
+		}
+		return sorted[i].ID().String() < sorted[j].ID().String()
+	})
+	grouped := make([]bool, len(sorted))
+	var groups [][]operation.IOperation
+	for i, anchor := range sorted {
+		if grouped[i] {
+			continue
+		}
+		g := []operation.IOperation{anchor}
+		for j := i + 1; j < len(sorted); j++ {
+			if !grouped[j] && IsDuplicate(anchor, sorted[j], opts) {
+				grouped[j] = true
+				g = append(g, sorted[j])
+			}
+		}
+		if len(g) > 1 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// Переносит в keep метки дубликатов и категорию, если у keep её нет.
+// Метка FlagTag снимается: после слияния дубликатов не осталось.
+func Merge(keep *operation.Operation, dups []operation.IOperation) error {
+	for _, d := range dups {
+		if err := keep.AddTags(d.Tags()...); err != nil {
+			return err
+		}
+		if keep.CategoryID() == (service.ObjectID{}) {
+			keep.SetCategoryID(d.CategoryID())
+		}
+	}
+	keep.RemoveTag(FlagTag)
+	return nil
+}
@@ -0,0 +1,103 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+
+	importer "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Что делать с импортируемой операцией, похожей на уже сохранённую.
+type Policy string
+
+const (
+	PolicySkip Policy = "skip"
+	PolicyFlag Policy = "flag"
+	PolicyAsk  Policy = "ask"
+)
+
+// Метка, которой при PolicyFlag помечаются вероятные дубликаты.
+const FlagTag = "duplicate"
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicySkip, PolicyFlag, PolicyAsk:
+		return p, nil
+	}
+	return "", service.Invalid("policy", "unknown duplicate policy %q (expected skip, flag or ask)", s)
+}
+
+// Хук импорта: сравнивает операцию с уже сохранёнными в existing.
+// Ask получает новую операцию и найденную похожую и возвращает true, если импортировать всё равно;
+// без Ask политика ask ведёт себя как skip.
+type ImportHook struct {
+	existing repository.ICommonRepo
+	policy   Policy
+	opts     Options
+	Ask      func(op, match operation.IOperation) bool
+	ops      []operation.IOperation
+	loaded   bool
+	Found    int
+}
+
+func NewImportHook(existing repository.ICommonRepo, policy Policy, opts Options) *ImportHook {
+	return &ImportHook{existing: existing, policy: policy, opts: opts}
+}
+
+func (h *ImportHook) Apply(ctx context.Context, obj service.ICommonObject) error {
+	op, ok := obj.(*operation.Operation)
+	if !ok {
+		return nil
+	}
+	match, err := h.match(ctx, op)
+	if err != nil {
+		return err
+	}
+	if match != nil {
+		h.Found++
+		switch h.policy {
+		case PolicyFlag:
+			if err := op.AddTags(FlagTag); err != nil {
+				return err
+			}
+		case PolicyAsk:
+			if h.Ask == nil || !h.Ask(op, match) {
+				return h.skip(match)
+			}
+		default:
+			return h.skip(match)
+		}
+	}
+	// принятая операция сравнивается с остальными строками того же файла
+	h.ops = append(h.ops, op)
+	return nil
+}
+
+func (h *ImportHook) skip(match operation.IOperation) error {
+	return fmt.Errorf("%w: duplicate of %s", importer.ErrSkip, match.ID().String())
+}
+
+// Сохранённые операции читаются один раз за импорт, к ним добавляются уже принятые из файла.
+func (h *ImportHook) match(ctx context.Context, op operation.IOperation) (operation.IOperation, error) {
+	if !h.loaded {
+		objs, err := h.existing.All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			if o, ok := obj.(operation.IOperation); ok {
+				h.ops = append(h.ops, o)
+			}
+		}
+		h.loaded = true
+	}
+	for _, o := range h.ops {
+		if IsDuplicate(op, o, h.opts) {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
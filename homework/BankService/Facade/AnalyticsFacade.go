@@ -7,12 +7,15 @@ import (
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
 )
 
 type AnalyticsFacade struct {
-	ops operationrepo.IOperationRepo
+	ops      operationrepo.IOperationRepo
+	accounts repository.ICommonRepo
+	authors  []service.ObjectID
 }
 
 func NewAnalyticsFacade(ops repository.ICommonRepo) *AnalyticsFacade {
@@ -23,14 +26,49 @@ func NewAnalyticsFacade(ops repository.ICommonRepo) *AnalyticsFacade {
 	return &AnalyticsFacade{ops: r}
 }
 
-func (a *AnalyticsFacade) IncomeExpenseDelta(accountID service.ObjectID, from, to time.Time) (float64, float64, float64, error) {
-	objs, err := a.ops.SliceByAccountAndPeriod(context.Background(), accountID, from, to)
+// Со счетами временной ряд получает остаток (SeriesPoint.Balance).
+func (a *AnalyticsFacade) SetAccountRepo(accounts repository.ICommonRepo) { a.accounts = accounts }
+
+// Та же аналитика только по операциям, заведённым этими пользователями (на общих счетах).
+// Остаток счёта по части операций не считается, поэтому в ряду его нет.
+func (a *AnalyticsFacade) ByAuthor(ids ...service.ObjectID) *AnalyticsFacade {
+	return &AnalyticsFacade{ops: a.ops, authors: ids}
+}
+
+func (a *AnalyticsFacade) byAuthor(op operation.IOperation) bool {
+	if len(a.authors) == 0 {
+		return true
+	}
+	author := operation.Author(op)
+	for _, id := range a.authors {
+		if id == author {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AnalyticsFacade) slice(ctx context.Context, accountID service.ObjectID, from, to time.Time) ([]operation.IOperation, error) {
+	objs, err := a.ops.SliceByAccountAndPeriod(ctx, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]operation.IOperation, 0, len(objs))
+	for _, obj := range objs {
+		if op := obj.(operation.IOperation); a.byAuthor(op) {
+			out = append(out, op)
+		}
+	}
+	return out, nil
+}
+
+func (a *AnalyticsFacade) IncomeExpenseDelta(ctx context.Context, accountID service.ObjectID, from, to time.Time) (float64, float64, float64, error) {
+	ops, err := a.slice(ctx, accountID, from, to)
 	if err != nil {
 		return 0, 0, 0, err
 	}
 	var income, expense float64
-	for _, obj := range objs {
-		op := obj.(operation.IOperation)
+	for _, op := range ops {
 		if op.Type() == operation.Income {
 			income += op.Amount()
 		} else {
@@ -40,21 +78,39 @@ func (a *AnalyticsFacade) IncomeExpenseDelta(accountID service.ObjectID, from, t
 	return income, expense, income - expense, nil
 }
 
-func (a *AnalyticsFacade) GroupByCategory(accountID service.ObjectID, from, to time.Time) (map[service.ObjectID]float64, error) {
-	objs, err := a.ops.SliceByAccountAndPeriod(context.Background(), accountID, from, to)
+func (a *AnalyticsFacade) GroupByCategory(ctx context.Context, accountID service.ObjectID, from, to time.Time) (map[service.ObjectID]float64, error) {
+	ops, err := a.slice(ctx, accountID, from, to)
 	if err != nil {
 		return nil, err
 	}
 	res := make(map[service.ObjectID]float64)
-	for _, obj := range objs {
-		op := obj.(operation.IOperation)
-		res[op.CategoryID()] += op.Amount()
+	for _, op := range ops {
+		for catID, amount := range operation.CategoryAmounts(op) {
+			res[catID] += amount
+		}
+	}
+	return res, nil
+}
+
+// Суммы сворачиваются к предкам на уровне level дерева категорий (0 — корни).
+func (a *AnalyticsFacade) GroupByCategoryRollup(ctx context.Context, accountID service.ObjectID, from, to time.Time, tree *category.Tree, level int) (map[service.ObjectID]float64, error) {
+	byCat, err := a.GroupByCategory(ctx, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[service.ObjectID]float64)
+	for catID, sum := range byCat {
+		target, err := tree.AncestorAtLevel(catID, level)
+		if err != nil {
+			return nil, err
+		}
+		res[target] += sum
 	}
 	return res, nil
 }
 
-func (a *AnalyticsFacade) SplitByCategoryType(accountID service.ObjectID, from, to time.Time, categories map[service.ObjectID]category.CategoryType) (map[category.CategoryType]float64, error) {
-	objs, err := a.ops.SliceByAccountAndPeriod(context.Background(), accountID, from, to)
+func (a *AnalyticsFacade) SplitByCategoryType(ctx context.Context, accountID service.ObjectID, from, to time.Time, categories map[service.ObjectID]category.CategoryType) (map[category.CategoryType]float64, error) {
+	ops, err := a.slice(ctx, accountID, from, to)
 	if err != nil {
 		return nil, err
 	}
@@ -62,13 +118,150 @@ func (a *AnalyticsFacade) SplitByCategoryType(accountID service.ObjectID, from,
 		category.Spending: 0,
 		category.Income:   0,
 	}
-	for _, obj := range objs {
-		op := obj.(operation.IOperation)
-		ctype, ok := categories[op.CategoryID()]
+	for _, op := range ops {
+		for catID, amount := range operation.CategoryAmounts(op) {
+			if ctype, ok := categories[catID]; ok {
+				res[ctype] += amount
+			}
+		}
+	}
+	return res, nil
+}
+
+func (a *AnalyticsFacade) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var points []operationrepo.SeriesPoint
+	// отбор по автору хранилище не умеет — ряд строится по самим операциям
+	if ts, ok := a.ops.(operationrepo.ITimeSeriesRepo); ok && len(a.authors) == 0 {
+		var err error
+		points, err = ts.TimeSeries(ctx, accountID, from, to, g, loc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var (
+			objs []service.ICommonObject
+			err  error
+		)
+		if accountID != nil {
+			objs, err = a.ops.SliceByAccountAndPeriod(ctx, *accountID, from, to)
+		} else {
+			objs, err = a.ops.All(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ops []operation.IOperation
+		for _, obj := range objs {
+			op := obj.(operation.IOperation)
+			if op.Date().Before(from) || op.Date().After(to) || !a.byAuthor(op) {
+				continue
+			}
+			ops = append(ops, op)
+		}
+		points = operationrepo.BuildTimeSeries(ops, g, loc)
+	}
+	points = operationrepo.FillGaps(points, from, to, g, loc)
+	if a.accounts == nil || len(a.authors) > 0 {
+		return points, nil
+	}
+	opening, err := a.balanceBefore(ctx, accountID, from)
+	if err != nil {
+		return nil, err
+	}
+	for i := range points {
+		points[i].Balance = opening + points[i].CumulativeNet
+	}
+	return points, nil
+}
+
+// Остаток счёта (accountID == nil — всех видимых счетов) до from: баланс плюс операции раньше него.
+func (a *AnalyticsFacade) balanceBefore(ctx context.Context, accountID *service.ObjectID, from time.Time) (float64, error) {
+	var accs []service.ICommonObject
+	if accountID != nil {
+		obj, err := a.accounts.ByID(ctx, *accountID)
+		if err != nil {
+			return 0, err
+		}
+		accs = []service.ICommonObject{obj}
+	} else {
+		var err error
+		if accs, err = a.accounts.All(ctx); err != nil {
+			return 0, err
+		}
+	}
+	var total float64
+	for _, obj := range accs {
+		acc, ok := obj.(bankaccount.IBankAccount)
 		if !ok {
-			continue
+			return 0, service.Invariant("invalid type")
+		}
+		earlier, err := a.ops.SliceByAccountAndPeriod(ctx, acc.ID(), farPast, from.Add(-time.Nanosecond))
+		if err != nil {
+			return 0, err
+		}
+		total += operation.CurrentBalance(acc.Balance(), toOperations(earlier))
+	}
+	return total, nil
+}
+
+// Доходы и расходы по операциям со всеми метками tags; accountID == nil — по всем счетам.
+func (a *AnalyticsFacade) IncomeExpenseDeltaByTags(ctx context.Context, accountID *service.ObjectID, from, to time.Time, tags ...string) (float64, float64, float64, error) {
+	ops, err := a.taggedSlice(ctx, accountID, from, to, tags)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var income, expense float64
+	for _, op := range ops {
+		if op.Type() == operation.Income {
+			income += op.Amount()
+		} else {
+			expense += op.Amount()
+		}
+	}
+	return income, expense, income - expense, nil
+}
+
+func (a *AnalyticsFacade) GroupByCategoryByTags(ctx context.Context, accountID *service.ObjectID, from, to time.Time, tags ...string) (map[service.ObjectID]float64, error) {
+	ops, err := a.taggedSlice(ctx, accountID, from, to, tags)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[service.ObjectID]float64)
+	for _, op := range ops {
+		for catID, amount := range operation.CategoryAmounts(op) {
+			res[catID] += amount
 		}
-		res[ctype] += op.Amount()
 	}
 	return res, nil
 }
+
+func (a *AnalyticsFacade) taggedSlice(ctx context.Context, accountID *service.ObjectID, from, to time.Time, tags []string) ([]operation.IOperation, error) {
+	norm, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	var objs []service.ICommonObject
+	tagRepo, canFilter := a.ops.(operationrepo.ITagRepo)
+	if accountID != nil {
+		objs, err = a.ops.SliceByAccountAndPeriod(ctx, *accountID, from, to)
+	} else if canFilter && len(norm) > 0 {
+		objs, err = tagRepo.ByTags(ctx, norm)
+	} else {
+		objs, err = a.ops.All(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []operation.IOperation
+	for _, obj := range objs {
+		op, ok := obj.(operation.IOperation)
+		if !ok || op.Date().Before(from) || op.Date().After(to) || !operation.HasAllTags(op, norm) || !a.byAuthor(op) {
+			continue
+		}
+		out = append(out, op)
+	}
+	return out, nil
+}
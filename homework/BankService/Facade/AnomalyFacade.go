@@ -0,0 +1,54 @@
+package facade
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	anomaly "github.com/ilyaytrewq/kpo-sb/homework/BankService/Anomaly"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type AnomalyFacade struct {
+	ops      *OperationFacade
+	notifier anomaly.Notifier
+	cfg      anomaly.Config
+}
+
+// notifier может быть nil — тогда аномалии только возвращаются.
+func NewAnomalyFacade(ops *OperationFacade, notifier anomaly.Notifier) *AnomalyFacade {
+	return &AnomalyFacade{ops: ops, notifier: notifier, cfg: anomaly.DefaultConfig()}
+}
+
+func (f *AnomalyFacade) SetConfig(cfg anomaly.Config) { f.cfg = cfg }
+
+// История — операции счёта не позже to одной выборкой по спецификации Query; период — её часть с from.
+func (f *AnomalyFacade) Scan(ctx context.Context, accountID service.ObjectID, from, to time.Time) ([]anomaly.Anomaly, error) {
+	history, _, err := f.ops.QueryOperations(ctx, operationrepo.Query{
+		AccountIDs: []service.ObjectID{accountID},
+		To:         to,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var period []operation.IOperation
+	for _, op := range history {
+		if !op.Date().Before(from) {
+			period = append(period, op)
+		}
+	}
+
+	found := anomaly.Detect(history, period, f.cfg)
+	if f.notifier == nil {
+		return found, nil
+	}
+	var errs []error
+	for _, a := range found {
+		if err := f.notifier.Notify(a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return found, errors.Join(errs...)
+}
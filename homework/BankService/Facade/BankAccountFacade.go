@@ -2,83 +2,197 @@ package facade
 
 import (
 	"context"
-	"errors"
+	"time"
 
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
 )
 
 type BankAccountFacade struct {
 	repo repository.ICommonRepo
+	ops  repository.ICommonRepo
 }
 
 func NewBankAccountFacade(repo repository.ICommonRepo) *BankAccountFacade {
 	return &BankAccountFacade{repo: repo}
 }
 
-func (f *BankAccountFacade) CreateAccount(name string, balance float64) (service.ObjectID, error) {
+// Нужен для окончательного удаления счёта вместе с операциями.
+func (f *BankAccountFacade) SetOperationRepo(ops repository.ICommonRepo) { f.ops = ops }
+
+func (f *BankAccountFacade) CreateAccount(ctx context.Context, name string, balance float64) (service.ObjectID, error) {
 	acc, err := bankaccount.NewBankAccount(name, balance)
 	if err != nil {
 		return service.ObjectID{}, err
 	}
-	if err := f.repo.Save(context.Background(), acc); err != nil {
+	if err := f.repo.Save(ctx, acc); err != nil {
 		return service.ObjectID{}, err
 	}
 	return acc.ID(), nil
 }
 
-func (f *BankAccountFacade) GetAccount(id service.ObjectID) (bankaccount.IBankAccount, error) {
-	obj, err := f.repo.ByID(context.Background(), id)
+func (f *BankAccountFacade) GetAccount(ctx context.Context, id service.ObjectID) (bankaccount.IBankAccount, error) {
+	obj, err := f.repo.ByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	acc, ok := obj.(bankaccount.IBankAccount)
 	if !ok {
-		return nil, errors.New("invalid type")
+		return nil, service.Invariant("invalid type")
 	}
 	return acc, nil
 }
 
-func (f *BankAccountFacade) UpdateAccountName(id service.ObjectID, newName string) error {
-	obj, err := f.repo.ByID(context.Background(), id)
+func (f *BankAccountFacade) UpdateAccountName(ctx context.Context, id service.ObjectID, newName string) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
 	if err != nil {
 		return err
 	}
 	acc, ok := obj.(*bankaccount.BankAccount)
 	if !ok {
-		return errors.New("invalid type")
+		return service.Invariant("invalid type")
+	}
+	if acc.IsArchived() {
+		return service.Conflict("account is archived")
 	}
 	acc.SetName(newName)
-	return nil
+	return f.repo.Update(ctx, acc)
 }
 
-func (f *BankAccountFacade) UpdateAccountBalance(id service.ObjectID, newBalance float64) error {
-	obj, err := f.repo.ByID(context.Background(), id)
+func (f *BankAccountFacade) UpdateAccountBalance(ctx context.Context, id service.ObjectID, newBalance float64) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
 	if err != nil {
 		return err
 	}
 	acc, ok := obj.(*bankaccount.BankAccount)
 	if !ok {
-		return errors.New("invalid type")
+		return service.Invariant("invalid type")
+	}
+	if acc.IsArchived() {
+		return service.Conflict("account is archived")
 	}
-	return acc.SetBalance(newBalance)
+	if err := acc.SetBalance(newBalance); err != nil {
+		return err
+	}
+	return f.repo.Update(ctx, acc)
+}
+
+// Архивные счета в список не попадают — см. ListArchivedAccounts.
+func (f *BankAccountFacade) ListAllAccounts(ctx context.Context) ([]bankaccount.IBankAccount, error) {
+	return f.listAccounts(ctx, false)
+}
+
+func (f *BankAccountFacade) ListArchivedAccounts(ctx context.Context) ([]bankaccount.IBankAccount, error) {
+	return f.listAccounts(ctx, true)
 }
 
-func (f *BankAccountFacade) ListAllAccounts() ([]bankaccount.IBankAccount, error) {
-	objs, err := f.repo.All(context.Background())
+func (f *BankAccountFacade) listAccounts(ctx context.Context, archived bool) ([]bankaccount.IBankAccount, error) {
+	objs, err := f.repo.All(ctx)
 	if err != nil {
 		return nil, err
 	}
 	var accounts []bankaccount.IBankAccount
 	for _, obj := range objs {
-		if acc, ok := obj.(bankaccount.IBankAccount); ok {
+		if acc, ok := obj.(bankaccount.IBankAccount); ok && acc.IsArchived() == archived {
 			accounts = append(accounts, acc)
 		}
 	}
 	return accounts, nil
 }
 
-func (f *BankAccountFacade) DeleteAccount(id service.ObjectID) error {
-	return f.repo.Delete(context.Background(), id)
+// Счёт архивируется: пропадает из списков, но его операции остаются в аналитике.
+// Архивирование, восстановление и окончательное удаление — только для администратора.
+func (f *BankAccountFacade) DeleteAccount(ctx context.Context, id service.ObjectID) error {
+	return f.setArchived(ctx, id, true)
+}
+
+func (f *BankAccountFacade) RestoreAccount(ctx context.Context, id service.ObjectID) error {
+	return f.setArchived(ctx, id, false)
+}
+
+func (f *BankAccountFacade) setArchived(ctx context.Context, id service.ObjectID, archived bool) error {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return err
+	}
+	obj, err := byIDForEdit(ctx, f.repo, id)
+	if err != nil {
+		return err
+	}
+	acc, ok := obj.(*bankaccount.BankAccount)
+	if !ok {
+		return service.Invariant("invalid type")
+	}
+	if acc.IsArchived() == archived {
+		if archived {
+			return service.Conflict("account is already archived")
+		}
+		return service.Conflict("account is not archived")
+	}
+	if archived {
+		acc.Archive(time.Now())
+	} else {
+		acc.Restore()
+	}
+	return f.repo.Update(ctx, acc)
+}
+
+// Операции счёта — именно они пропадут при окончательном удалении.
+func (f *BankAccountFacade) AccountOperationIDs(ctx context.Context, id service.ObjectID) ([]service.ObjectID, error) {
+	if f.ops == nil {
+		return nil, service.Invariant("operation repo is not configured")
+	}
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []service.ObjectID
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok && op.BankAccountID() == id {
+			ids = append(ids, op.ID())
+		}
+	}
+	return ids, nil
+}
+
+// Окончательно удаляет архивный счёт и все его операции; возвращает число удалённых операций.
+// Закрытые для правки операции проверяются заранее, удаление идёт одной единицей работы:
+// счёт и операции удаляются вместе или не удаляются вовсе.
+func (f *BankAccountFacade) PurgeAccount(ctx context.Context, id service.ObjectID) (int, error) {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return 0, err
+	}
+	acc, err := f.GetAccount(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if !acc.IsArchived() {
+		return 0, service.Conflict("only archived accounts can be purged")
+	}
+	ids, err := f.AccountOperationIDs(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if g, ok := f.ops.(repository.IEditGuard); ok {
+		for _, opID := range ids {
+			if err := g.CheckEditable(ctx, opID); err != nil {
+				return 0, err
+			}
+		}
+	}
+	err = repository.Atomic(ctx, func(ctx context.Context) error {
+		for _, opID := range ids {
+			if err := f.ops.Delete(ctx, opID); err != nil {
+				return err
+			}
+		}
+		return f.repo.Delete(ctx, id)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
 }
@@ -2,59 +2,111 @@ package facade
 
 import (
 	"context"
-	"errors"
+	"time"
 
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
 )
 
 type CategoryFacade struct {
 	repo repository.ICommonRepo
+	ops  repository.ICommonRepo
 }
 
 func NewCategoryFacade(repo repository.ICommonRepo) *CategoryFacade {
 	return &CategoryFacade{repo: repo}
 }
 
-func (f *CategoryFacade) CreateCategory(name string, ctype category.CategoryType) (service.ObjectID, error) {
-	cat, err := category.NewCategory(name, ctype)
+// Нужен для окончательного удаления категории: операции проверяются, что она не используется.
+func (f *CategoryFacade) SetOperationRepo(ops repository.ICommonRepo) { f.ops = ops }
+
+func (f *CategoryFacade) CreateCategory(ctx context.Context, name string, ctype category.CategoryType, parentID ...service.ObjectID) (service.ObjectID, error) {
+	cat, err := category.NewCategory(name, ctype, parentID...)
 	if err != nil {
 		return service.ObjectID{}, err
 	}
-	if err := f.repo.Save(context.Background(), cat); err != nil {
+	if err := f.checkParent(ctx, cat, cat.ParentID()); err != nil {
+		return service.ObjectID{}, err
+	}
+	if err := f.repo.Save(ctx, cat); err != nil {
 		return service.ObjectID{}, err
 	}
 	return cat.ID(), nil
 }
 
-func (f *CategoryFacade) GetCategory(id service.ObjectID) (category.ICategory, error) {
-	obj, err := f.repo.ByID(context.Background(), id)
+// Сохраняет категорию из файла импорта с теми же проверками родителя, что и CreateCategory.
+// Родители должны сохраняться раньше детей — см. category.SortParentsFirst.
+func (f *CategoryFacade) ImportCategory(ctx context.Context, obj service.ICommonObject) error {
+	cat, ok := obj.(category.ICategory)
+	if !ok {
+		return service.Invariant("invalid type")
+	}
+	if err := f.checkParent(ctx, cat, cat.ParentID()); err != nil {
+		return err
+	}
+	return f.repo.Save(ctx, cat)
+}
+
+func (f *CategoryFacade) GetCategory(ctx context.Context, id service.ObjectID) (category.ICategory, error) {
+	obj, err := f.repo.ByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	cat, ok := obj.(category.ICategory)
 	if !ok {
-		return nil, errors.New("invalid type")
+		return nil, service.Invariant("invalid type")
 	}
 	return cat, nil
 }
 
-func (f *CategoryFacade) UpdateCategoryName(id service.ObjectID, newName string) error {
-	obj, err := f.repo.ByID(context.Background(), id)
+func (f *CategoryFacade) UpdateCategoryName(ctx context.Context, id service.ObjectID, newName string) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
 	if err != nil {
 		return err
 	}
 	cat, ok := obj.(*category.Category)
 	if !ok {
-		return errors.New("invalid type")
+		return service.Invariant("invalid type")
 	}
 	cat.SetName(newName)
-	return nil
+	return f.repo.Update(ctx, cat)
+}
+
+// Архивные категории в список не попадают — см. ListArchivedCategories.
+func (f *CategoryFacade) ListAllCategories(ctx context.Context) ([]category.ICategory, error) {
+	cats, err := f.allCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var active []category.ICategory
+	for _, c := range cats {
+		if !c.IsArchived() {
+			active = append(active, c)
+		}
+	}
+	return active, nil
 }
 
-func (f *CategoryFacade) ListAllCategories() ([]category.ICategory, error) {
-	objs, err := f.repo.All(context.Background())
+func (f *CategoryFacade) ListArchivedCategories(ctx context.Context) ([]category.ICategory, error) {
+	cats, err := f.allCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var archived []category.ICategory
+	for _, c := range cats {
+		if c.IsArchived() {
+			archived = append(archived, c)
+		}
+	}
+	return archived, nil
+}
+
+func (f *CategoryFacade) allCategories(ctx context.Context) ([]category.ICategory, error) {
+	objs, err := f.repo.All(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +119,204 @@ func (f *CategoryFacade) ListAllCategories() ([]category.ICategory, error) {
 	return categories, nil
 }
 
-func (f *CategoryFacade) DeleteCategory(id service.ObjectID) error {
-	return f.repo.Delete(context.Background(), id)
+// В дерево входят и архивные категории: по ним есть исторические операции.
+func (f *CategoryFacade) Tree(ctx context.Context) (*category.Tree, error) {
+	cats, err := f.allCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return category.NewTree(cats), nil
+}
+
+func (f *CategoryFacade) checkParent(ctx context.Context, cat category.ICategory, parentID service.ObjectID) error {
+	if parentID == (service.ObjectID{}) {
+		return nil
+	}
+	parent, err := f.GetCategory(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	if parent.Type() != cat.Type() {
+		return service.Invariant("parent category must have the same type")
+	}
+	if parent.IsArchived() {
+		return service.Conflict("parent category is archived")
+	}
+	tree, err := f.Tree(ctx)
+	if err != nil {
+		return err
+	}
+	return tree.CanSetParent(cat.ID(), parentID)
+}
+
+// Нулевой parentID делает категорию корневой.
+func (f *CategoryFacade) SetCategoryParent(ctx context.Context, id, parentID service.ObjectID) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
+	if err != nil {
+		return err
+	}
+	cat, ok := obj.(*category.Category)
+	if !ok {
+		return service.Invariant("invalid type")
+	}
+	if err := f.checkParent(ctx, cat, parentID); err != nil {
+		return err
+	}
+	if err := cat.SetParentID(parentID); err != nil {
+		return err
+	}
+	return f.repo.Update(ctx, cat)
+}
+
+// Категория архивируется; с активными дочерними её удалить нельзя — см. DeleteCategoryReparent.
+func (f *CategoryFacade) DeleteCategory(ctx context.Context, id service.ObjectID) error {
+	children, err := f.activeChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return service.Conflict("category has child categories")
+	}
+	return f.setArchived(ctx, id, true)
+}
+
+func (f *CategoryFacade) RestoreCategory(ctx context.Context, id service.ObjectID) error {
+	cat, err := f.GetCategory(ctx, id)
+	if err != nil {
+		return err
+	}
+	if cat.ParentID() != (service.ObjectID{}) {
+		parent, err := f.GetCategory(ctx, cat.ParentID())
+		if err != nil {
+			return err
+		}
+		if parent.IsArchived() {
+			return service.Conflict("parent category is archived, restore it first")
+		}
+	}
+	return f.setArchived(ctx, id, false)
+}
+
+func (f *CategoryFacade) setArchived(ctx context.Context, id service.ObjectID, archived bool) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
+	if err != nil {
+		return err
+	}
+	cat, ok := obj.(*category.Category)
+	if !ok {
+		return service.Invariant("invalid type")
+	}
+	if cat.IsArchived() == archived {
+		if archived {
+			return service.Conflict("category is already archived")
+		}
+		return service.Conflict("category is not archived")
+	}
+	if archived {
+		cat.Archive(time.Now())
+	} else {
+		cat.Restore()
+	}
+	return f.repo.Update(ctx, cat)
+}
+
+func (f *CategoryFacade) activeChildren(ctx context.Context, id service.ObjectID) ([]service.ObjectID, error) {
+	tree, err := f.Tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []service.ObjectID
+	for _, childID := range tree.Children(id) {
+		child, err := f.GetCategory(ctx, childID)
+		if err != nil {
+			return nil, err
+		}
+		if !child.IsArchived() {
+			out = append(out, childID)
+		}
+	}
+	return out, nil
+}
+
+// Окончательно удаляет архивную категорию. Операции на неё ссылаются, поэтому удалить можно
+// только категорию без операций (в том числе частей разбивки) и без дочерних категорий.
+func (f *CategoryFacade) PurgeCategory(ctx context.Context, id service.ObjectID) error {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return err
+	}
+	if f.ops == nil {
+		return service.Invariant("operation repo is not configured")
+	}
+	cat, err := f.GetCategory(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !cat.IsArchived() {
+		return service.Conflict("only archived categories can be purged")
+	}
+	tree, err := f.Tree(ctx)
+	if err != nil {
+		return err
+	}
+	if len(tree.Children(id)) > 0 {
+		return service.Conflict("category has child categories")
+	}
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return err
+	}
+	used := 0
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok && usesCategory(op, id) {
+			used++
+		}
+	}
+	if used > 0 {
+		return service.Conflict("category is used by %d operation(s)", used)
+	}
+	return f.repo.Delete(ctx, id)
+}
+
+func usesCategory(op operation.IOperation, id service.ObjectID) bool {
+	if op.CategoryID() == id {
+		return true
+	}
+	for _, l := range op.Splits() {
+		if l.CategoryID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Дочерние категории переносятся к родителю удаляемой (или становятся корневыми).
+// Перенос и архивирование идут одной единицей работы: при сбое дети остаются на месте.
+func (f *CategoryFacade) DeleteCategoryReparent(ctx context.Context, id service.ObjectID) error {
+	deleted, err := f.GetCategory(ctx, id)
+	if err != nil {
+		return err
+	}
+	children, err := f.activeChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repository.Atomic(ctx, func(ctx context.Context) error {
+		for _, childID := range children {
+			obj, err := byIDForEdit(ctx, f.repo, childID)
+			if err != nil {
+				return err
+			}
+			child, ok := obj.(*category.Category)
+			if !ok {
+				return service.Invariant("invalid type")
+			}
+			if err := child.SetParentID(deleted.ParentID()); err != nil {
+				return err
+			}
+			if err := f.repo.Update(ctx, child); err != nil {
+				return err
+			}
+		}
+		return f.setArchived(ctx, id, true)
+	})
 }
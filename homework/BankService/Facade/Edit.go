@@ -0,0 +1,19 @@
+package facade
+
+import (
+	"context"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Объект для правки на месте: хранилище сначала подтверждает, что его можно менять
+// (общий счёт только на чтение, закрытый период), и только потом объект читается.
+func byIDForEdit(ctx context.Context, repo repository.ICommonRepo, id service.ObjectID) (service.ICommonObject, error) {
+	if g, ok := repo.(repository.IEditGuard); ok {
+		if err := g.CheckEditable(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return repo.ByID(ctx, id)
+}
@@ -0,0 +1,61 @@
+package facade
+
+import (
+	"context"
+	"time"
+
+	forecast "github.com/ilyaytrewq/kpo-sb/homework/BankService/Forecast"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type ForecastFacade struct {
+	accounts repository.ICommonRepo
+	ops      operationrepo.IOperationRepo
+}
+
+func NewForecastFacade(accounts, ops repository.ICommonRepo) *ForecastFacade {
+	var r operationrepo.IOperationRepo
+	if casted, ok := ops.(operationrepo.IOperationRepo); ok {
+		r = casted
+	}
+	return &ForecastFacade{accounts: accounts, ops: r}
+}
+
+func (f *ForecastFacade) Forecast(ctx context.Context, accountID service.ObjectID, horizonDays, historyDays int) (*forecast.Forecast, error) {
+	if f.ops == nil {
+		return nil, service.Invariant("operation repo does not support period slicing")
+	}
+	if horizonDays <= 0 {
+		return nil, service.Invalid("horizon", "horizon should be > 0")
+	}
+	if historyDays <= 0 {
+		historyDays = forecast.DefaultHistoryDays
+	}
+	obj, err := f.accounts.ByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	acc, ok := obj.(bankaccount.IBankAccount)
+	if !ok {
+		return nil, service.Invariant("invalid type")
+	}
+	now := time.Now()
+	from := now.AddDate(0, 0, -historyDays)
+	// остаток на сейчас — начальный баланс плюс все операции по сегодня, история — только окно
+	objs, err := f.ops.SliceByAccountAndPeriod(ctx, accountID, farPast, now)
+	if err != nil {
+		return nil, err
+	}
+	ops := toOperations(objs)
+	history := make([]operation.IOperation, 0, len(ops))
+	for _, op := range ops {
+		if !op.Date().Before(from) {
+			history = append(history, op)
+		}
+	}
+	return forecast.Project(accountID, operation.BalanceAt(acc.Balance(), ops, now), history, from, now, horizonDays), nil
+}
@@ -0,0 +1,77 @@
+package facade
+
+import (
+	"context"
+	"time"
+
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// История пользователя: хранилище с его областью видимости (OwnedHistoryRepo)
+// само не отдаёт версии чужих сущностей.
+type HistoryFacade struct {
+	history historyrepo.IHistoryRepo
+}
+
+func NewHistoryFacade(history historyrepo.IHistoryRepo) *HistoryFacade {
+	return &HistoryFacade{history: history}
+}
+
+func (f *HistoryFacade) AccountHistory(ctx context.Context, id service.ObjectID) ([]historyrepo.Version, error) {
+	return f.versions(ctx, historyrepo.AccountCodec.Entity, id)
+}
+
+func (f *HistoryFacade) CategoryHistory(ctx context.Context, id service.ObjectID) ([]historyrepo.Version, error) {
+	return f.versions(ctx, historyrepo.CategoryCodec.Entity, id)
+}
+
+func (f *HistoryFacade) OperationHistory(ctx context.Context, id service.ObjectID) ([]historyrepo.Version, error) {
+	return f.versions(ctx, historyrepo.OperationCodec.Entity, id)
+}
+
+func (f *HistoryFacade) versions(ctx context.Context, entity string, id service.ObjectID) ([]historyrepo.Version, error) {
+	return f.history.History(ctx, entity, id)
+}
+
+// Счёт в том виде, в каком он был в момент at.
+func (f *HistoryFacade) AccountAsOf(ctx context.Context, id service.ObjectID, at time.Time) (bankaccount.IBankAccount, error) {
+	obj, err := f.asOf(ctx, historyrepo.AccountCodec, id, at)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(bankaccount.IBankAccount), nil
+}
+
+func (f *HistoryFacade) CategoryAsOf(ctx context.Context, id service.ObjectID, at time.Time) (category.ICategory, error) {
+	obj, err := f.asOf(ctx, historyrepo.CategoryCodec, id, at)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(category.ICategory), nil
+}
+
+func (f *HistoryFacade) OperationAsOf(ctx context.Context, id service.ObjectID, at time.Time) (operation.IOperation, error) {
+	obj, err := f.asOf(ctx, historyrepo.OperationCodec, id, at)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(operation.IOperation), nil
+}
+
+func (f *HistoryFacade) asOf(ctx context.Context, codec historyrepo.Codec, id service.ObjectID, at time.Time) (service.ICommonObject, error) {
+	v, ok, err := f.history.AsOf(ctx, codec.Entity, id, at)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, service.NotFound("%s %s has no history before %s", codec.Entity, id, at.Format(time.RFC3339))
+	}
+	if len(v.After) == 0 {
+		return nil, service.NotFound("%s %s was deleted at %s", codec.Entity, id, v.ChangedAt.Format(time.RFC3339))
+	}
+	return codec.Decode(v.After)
+}
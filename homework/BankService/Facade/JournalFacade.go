@@ -0,0 +1,62 @@
+package facade
+
+import (
+	"context"
+
+	journal "github.com/ilyaytrewq/kpo-sb/homework/BankService/Journal"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type JournalFacade struct {
+	accounts   repository.ICommonRepo
+	categories repository.ICommonRepo
+	ops        repository.ICommonRepo
+}
+
+func NewJournalFacade(accounts, categories, ops repository.ICommonRepo) *JournalFacade {
+	return &JournalFacade{accounts: accounts, categories: categories, ops: ops}
+}
+
+// Проводки строятся по текущим данным при каждом вызове, поэтому книги не расходятся с операциями.
+// Архивные счета и категории остаются в плане счетов: по ним есть история.
+// Если какая-то проводка не сходится, возвращается ошибка со списком нарушений.
+func (f *JournalFacade) Build(ctx context.Context) (*journal.Journal, error) {
+	accObjs, err := f.accounts.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catObjs, err := f.categories.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opObjs, err := f.ops.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]bankaccount.IBankAccount, 0, len(accObjs))
+	for _, o := range accObjs {
+		if acc, ok := o.(bankaccount.IBankAccount); ok {
+			accounts = append(accounts, acc)
+		}
+	}
+	categories := make([]category.ICategory, 0, len(catObjs))
+	for _, o := range catObjs {
+		if c, ok := o.(category.ICategory); ok {
+			categories = append(categories, c)
+		}
+	}
+	ops := make([]operation.IOperation, 0, len(opObjs))
+	for _, o := range opObjs {
+		if op, ok := o.(operation.IOperation); ok {
+			ops = append(ops, op)
+		}
+	}
+	chart, err := journal.NewChart(accounts, categories)
+	if err != nil {
+		return nil, err
+	}
+	return journal.Build(chart, accounts, ops)
+}
@@ -2,18 +2,22 @@ package facade
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	categorization "github.com/ilyaytrewq/kpo-sb/homework/BankService/Categorization"
+	dedupe "github.com/ilyaytrewq/kpo-sb/homework/BankService/Dedupe"
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
 )
 
 type OperationFacade struct {
-	repo   repository.ICommonRepo
-	opRepo operationrepo.IOperationRepo
+	repo        repository.ICommonRepo
+	opRepo      operationrepo.IOperationRepo
+	categorizer *categorization.Categorizer
+	accounts    repository.ICommonRepo
 }
 
 func NewOperationFacade(repo repository.ICommonRepo) *OperationFacade {
@@ -24,7 +28,14 @@ func NewOperationFacade(repo repository.ICommonRepo) *OperationFacade {
 	return &OperationFacade{repo: repo, opRepo: op}
 }
 
+// С категоризатором операции без категории получают её по правилам.
+func (f *OperationFacade) SetCategorizer(c *categorization.Categorizer) { f.categorizer = c }
+
+// Со счетами новые операции на архивный счёт отклоняются.
+func (f *OperationFacade) SetAccountRepo(accounts repository.ICommonRepo) { f.accounts = accounts }
+
 func (f *OperationFacade) CreateOperation(
+	ctx context.Context,
 	opType operation.OperationType,
 	accountID service.ObjectID,
 	amount float64,
@@ -36,26 +47,69 @@ func (f *OperationFacade) CreateOperation(
 	if err != nil {
 		return service.ObjectID{}, err
 	}
-	if err := f.repo.Save(context.Background(), op); err != nil {
+	return f.create(ctx, op)
+}
+
+func (f *OperationFacade) CreateTaggedOperation(
+	ctx context.Context,
+	opType operation.OperationType,
+	accountID service.ObjectID,
+	amount float64,
+	date time.Time,
+	categoryID service.ObjectID,
+	description string,
+	tags []string,
+) (service.ObjectID, error) {
+	op, err := operation.NewOperation(opType, accountID, amount, date, categoryID, description)
+	if err != nil {
+		return service.ObjectID{}, err
+	}
+	if err := op.SetTags(tags...); err != nil {
+		return service.ObjectID{}, err
+	}
+	return f.create(ctx, op)
+}
+
+func (f *OperationFacade) create(ctx context.Context, op *operation.Operation) (service.ObjectID, error) {
+	if f.accounts != nil {
+		obj, err := f.accounts.ByID(ctx, op.BankAccountID())
+		if err != nil {
+			return service.ObjectID{}, err
+		}
+		if acc, ok := obj.(bankaccount.IBankAccount); ok && acc.IsArchived() {
+			return service.ObjectID{}, service.Conflict("account is archived")
+		}
+	}
+	if op.CategoryID() == (service.ObjectID{}) && f.categorizer != nil {
+		catID, found, err := f.categorizer.Match(ctx, op)
+		if err != nil {
+			return service.ObjectID{}, err
+		}
+		if !found {
+			return service.ObjectID{}, service.Invalid("category_id", "category is required: no categorization rule matched")
+		}
+		op.SetCategoryID(catID)
+	}
+	if err := f.repo.Save(ctx, op); err != nil {
 		return service.ObjectID{}, err
 	}
 	return op.ID(), nil
 }
 
-func (f *OperationFacade) GetOperation(id service.ObjectID) (operation.IOperation, error) {
-	obj, err := f.repo.ByID(context.Background(), id)
+func (f *OperationFacade) GetOperation(ctx context.Context, id service.ObjectID) (operation.IOperation, error) {
+	obj, err := f.repo.ByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	op, ok := obj.(operation.IOperation)
 	if !ok {
-		return nil, errors.New("invalid type")
+		return nil, service.Invariant("invalid type")
 	}
 	return op, nil
 }
 
-func (f *OperationFacade) ListAllOperations() ([]operation.IOperation, error) {
-	objs, err := f.repo.All(context.Background())
+func (f *OperationFacade) ListAllOperations(ctx context.Context) ([]operation.IOperation, error) {
+	objs, err := f.repo.All(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +122,11 @@ func (f *OperationFacade) ListAllOperations() ([]operation.IOperation, error) {
 	return operations, nil
 }
 
-func (f *OperationFacade) GetOperationsByPeriod(accountID service.ObjectID, from, to time.Time) ([]operation.IOperation, error) {
+func (f *OperationFacade) GetOperationsByPeriod(ctx context.Context, accountID service.ObjectID, from, to time.Time) ([]operation.IOperation, error) {
 	if f.opRepo == nil {
-		return nil, errors.New("operation repo does not support period slicing")
+		return nil, service.Invariant("operation repo does not support period slicing")
 	}
-	objs, err := f.opRepo.SliceByAccountAndPeriod(context.Background(), accountID, from, to)
+	objs, err := f.opRepo.SliceByAccountAndPeriod(ctx, accountID, from, to)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +139,169 @@ func (f *OperationFacade) GetOperationsByPeriod(accountID service.ObjectID, from
 	return operations, nil
 }
 
-func (f *OperationFacade) DeleteOperation(id service.ObjectID) error {
-	return f.repo.Delete(context.Background(), id)
+func (f *OperationFacade) DeleteOperation(ctx context.Context, id service.ObjectID) error {
+	return f.repo.Delete(ctx, id)
+}
+
+// Добавляет метки к операции; уже стоящие метки не дублируются.
+func (f *OperationFacade) TagOperation(ctx context.Context, id service.ObjectID, tags ...string) error {
+	return f.edit(ctx, id, func(op *operation.Operation) error { return op.AddTags(tags...) })
+}
+
+func (f *OperationFacade) UntagOperation(ctx context.Context, id service.ObjectID, tags ...string) error {
+	return f.edit(ctx, id, func(op *operation.Operation) error {
+		for _, t := range tags {
+			op.RemoveTag(t)
+		}
+		return nil
+	})
+}
+
+// Разбивает операцию по категориям; пустой список убирает разбивку.
+func (f *OperationFacade) SplitOperation(ctx context.Context, id service.ObjectID, lines []operation.SplitLine) error {
+	return f.edit(ctx, id, func(op *operation.Operation) error { return op.SetSplits(lines) })
+}
+
+func (f *OperationFacade) edit(ctx context.Context, id service.ObjectID, edit func(op *operation.Operation) error) error {
+	obj, err := byIDForEdit(ctx, f.repo, id)
+	if err != nil {
+		return err
+	}
+	op, ok := obj.(*operation.Operation)
+	if !ok {
+		return service.Invariant("invalid type")
+	}
+	if err := edit(op); err != nil {
+		return err
+	}
+	return f.repo.Update(ctx, op)
+}
+
+// Группы вероятных дубликатов среди сохранённых операций.
+func (f *OperationFacade) FindDuplicates(ctx context.Context, opts dedupe.Options) ([][]operation.IOperation, error) {
+	ops, err := f.ListAllOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dedupe.FindGroups(ops, opts), nil
+}
+
+// Сливает дубликаты в keep и удаляет их. Повторы в dupIDs отбрасываются, каждая операция должна
+// быть дубликатом keep по opts (тот же счёт, тип и сумма), закрытые для правки операции
+// проверяются заранее, а слияние и удаления идут одной единицей работы.
+func (f *OperationFacade) MergeDuplicates(ctx context.Context, keepID service.ObjectID, dupIDs []service.ObjectID, opts dedupe.Options) error {
+	seen := make(map[service.ObjectID]bool, len(dupIDs))
+	unique := make([]service.ObjectID, 0, len(dupIDs))
+	for _, id := range dupIDs {
+		if id == keepID {
+			return service.Invalid("id", "operation cannot be merged into itself")
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if g, ok := f.repo.(repository.IEditGuard); ok {
+		for _, id := range append([]service.ObjectID{keepID}, unique...) {
+			if err := g.CheckEditable(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+	keep, err := f.GetOperation(ctx, keepID)
+	if err != nil {
+		return err
+	}
+	dups := make([]operation.IOperation, 0, len(unique))
+	for _, id := range unique {
+		op, err := f.GetOperation(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !dedupe.IsDuplicate(keep, op, opts) {
+			return service.Invalid("id", "operation %s is not a duplicate of %s", id, keepID)
+		}
+		dups = append(dups, op)
+	}
+	return repository.Atomic(ctx, func(ctx context.Context) error {
+		if err := f.edit(ctx, keepID, func(keep *operation.Operation) error { return dedupe.Merge(keep, dups) }); err != nil {
+			return err
+		}
+		for _, id := range unique {
+			if err := f.repo.Delete(ctx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Операции, у которых есть все перечисленные метки; без меток — все операции.
+func (f *OperationFacade) ListOperationsByTags(ctx context.Context, tags ...string) ([]operation.IOperation, error) {
+	if len(tags) == 0 {
+		return f.ListAllOperations(ctx)
+	}
+	norm, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	var objs []service.ICommonObject
+	if r, ok := f.repo.(operationrepo.ITagRepo); ok {
+		objs, err = r.ByTags(ctx, norm)
+	} else {
+		objs, err = f.repo.All(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var operations []operation.IOperation
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok && operation.HasAllTags(op, norm) {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+// Повторы после нормализации отбрасываются: ByTags в Postgres сравнивает число меток с длиной списка.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		norm, err := operation.NormalizeTag(t)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[norm] {
+			seen[norm] = true
+			out = append(out, norm)
+		}
+	}
+	return out, nil
+}
+
+// Выборка по спецификации с сортировкой и постраничной выдачей; второй результат — курсор следующей страницы.
+func (f *OperationFacade) QueryOperations(ctx context.Context, q operationrepo.Query) ([]operation.IOperation, string, error) {
+	var (
+		page operationrepo.Page
+		err  error
+	)
+	if r, ok := f.repo.(operationrepo.IQueryRepo); ok {
+		page, err = r.Query(ctx, q)
+	} else {
+		var objs []service.ICommonObject
+		if objs, err = f.repo.All(ctx); err == nil {
+			page, err = operationrepo.ApplyQuery(objs, q)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	operations := make([]operation.IOperation, 0, len(page.Items))
+	for _, obj := range page.Items {
+		if op, ok := obj.(operation.IOperation); ok {
+			operations = append(operations, op)
+		}
+	}
+	return operations, page.Next, nil
 }
@@ -0,0 +1,131 @@
+package facade
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
+	periods "github.com/ilyaytrewq/kpo-sb/homework/BankService/Periods"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	period "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Period"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+type PeriodStatus struct {
+	Month      time.Time
+	Closed     bool
+	Operations int
+	Log        []period.LogEntry
+}
+
+type PeriodFacade struct {
+	periods repository.ICommonRepo
+	ops     repository.ICommonRepo
+}
+
+func NewPeriodFacade(periodRepo, ops repository.ICommonRepo) *PeriodFacade {
+	return &PeriodFacade{periods: periodRepo, ops: ops}
+}
+
+// Месяцы от первой операции до текущего, а также все когда-либо закрывавшиеся.
+func (f *PeriodFacade) ListPeriods(ctx context.Context) ([]PeriodStatus, error) {
+	byMonth := make(map[time.Time]*PeriodStatus)
+	status := func(t time.Time) *PeriodStatus {
+		m := period.MonthStart(t)
+		s, ok := byMonth[m]
+		if !ok {
+			s = &PeriodStatus{Month: m}
+			byMonth[m] = s
+		}
+		return s
+	}
+	opObjs, err := f.ops.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	first := period.MonthStart(time.Now())
+	for _, obj := range opObjs {
+		if op, ok := obj.(operation.IOperation); ok {
+			status(op.Date()).Operations++
+			if m := period.MonthStart(op.Date()); m.Before(first) {
+				first = m
+			}
+		}
+	}
+	for m := first; !m.After(time.Now()); m = m.AddDate(0, 1, 0) {
+		status(m)
+	}
+	periodObjs, err := f.periods.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range periodObjs {
+		if p, ok := obj.(period.IPeriod); ok {
+			s := status(p.Month())
+			s.Closed, s.Log = p.IsClosed(), p.Log()
+		}
+	}
+	out := make([]PeriodStatus, 0, len(byMonth))
+	for _, s := range byMonth {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Month.Before(out[j].Month) })
+	return out, nil
+}
+
+// Закрыть можно только завершившийся месяц.
+func (f *PeriodFacade) ClosePeriod(ctx context.Context, month time.Time) error {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return err
+	}
+	now := time.Now()
+	if period.MonthStart(month).AddDate(0, 1, 0).After(now) {
+		return service.Conflict("period is not over yet")
+	}
+	p, isNew, err := f.find(ctx, month)
+	if err != nil {
+		return err
+	}
+	if err := p.Close(now); err != nil {
+		return err
+	}
+	if isNew {
+		return f.periods.Save(ctx, p)
+	}
+	return f.periods.Update(ctx, p)
+}
+
+func (f *PeriodFacade) ReopenPeriod(ctx context.Context, month time.Time, reason string) error {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return err
+	}
+	p, isNew, err := f.find(ctx, month)
+	if err != nil {
+		return err
+	}
+	if isNew {
+		return service.Conflict("period is not closed")
+	}
+	if err := p.Reopen(time.Now(), reason); err != nil {
+		return err
+	}
+	return f.periods.Update(ctx, p)
+}
+
+func (f *PeriodFacade) find(ctx context.Context, month time.Time) (*period.Period, bool, error) {
+	found, err := periods.ByMonth(ctx, f.periods, month)
+	if err != nil {
+		return nil, false, err
+	}
+	if found == nil {
+		return period.NewPeriod(month), true, nil
+	}
+	p, ok := found.(*period.Period)
+	if !ok {
+		return nil, false, service.Invariant("invalid type")
+	}
+	return p, false, nil
+}
@@ -0,0 +1,114 @@
+package facade
+
+import (
+	"context"
+	"time"
+
+	reconcile "github.com/ilyaytrewq/kpo-sb/homework/BankService/Reconcile"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	reconciliation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Reconciliation"
+)
+
+type ReconciliationFacade struct {
+	accounts repository.ICommonRepo
+	ops      repository.ICommonRepo
+	recs     repository.ICommonRepo
+}
+
+func NewReconciliationFacade(accounts, ops, recs repository.ICommonRepo) *ReconciliationFacade {
+	return &ReconciliationFacade{accounts: accounts, ops: ops, recs: recs}
+}
+
+// Balance() — начальный остаток, поэтому остаток на дату получается прибавлением операций по неё.
+func (f *ReconciliationFacade) LedgerBalanceAt(ctx context.Context, accountID service.ObjectID, at time.Time) (float64, error) {
+	acc, ops, err := f.accountOperations(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return balanceAt(acc, ops, at), nil
+}
+
+// Начинает сверку по дату выписки. Операции, не отмеченные в прошлых сверках, снова предлагаются к отметке.
+func (f *ReconciliationFacade) Start(ctx context.Context, accountID service.ObjectID, date time.Time, statementBalance float64, lines []reconcile.StatementLine) (*reconcile.Session, error) {
+	acc, ops, err := f.accountOperations(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := reconcile.ForAccount(ctx, f.recs, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var start float64
+	if len(recs) > 0 {
+		last := recs[len(recs)-1]
+		if !date.After(last.StatementDate()) {
+			return nil, service.Conflict("account is already reconciled through %s", last.StatementDate().Format(time.RFC3339))
+		}
+		start = last.StatementBalance()
+	} else {
+		start = balanceAt(acc, ops, time.Time{})
+	}
+	var candidates []operation.IOperation
+	for _, op := range ops {
+		if op.Date().After(date) || reconciled(recs, op.ID()) {
+			continue
+		}
+		candidates = append(candidates, op)
+	}
+	return reconcile.NewSession(accountID, date, statementBalance, start, balanceAt(acc, ops, date), candidates, lines), nil
+}
+
+// Сохраняет сверку; после этого операции по дату выписки закрыты для правок.
+func (f *ReconciliationFacade) Finish(ctx context.Context, s *reconcile.Session) (reconciliation.IReconciliation, error) {
+	rec, err := s.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.recs.Save(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (f *ReconciliationFacade) ListReconciliations(ctx context.Context, accountID service.ObjectID) ([]reconciliation.IReconciliation, error) {
+	return reconcile.ForAccount(ctx, f.recs, accountID)
+}
+
+func (f *ReconciliationFacade) accountOperations(ctx context.Context, accountID service.ObjectID) (bankaccount.IBankAccount, []operation.IOperation, error) {
+	obj, err := f.accounts.ByID(ctx, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	acc, ok := obj.(bankaccount.IBankAccount)
+	if !ok {
+		return nil, nil, service.Invariant("invalid type")
+	}
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ops []operation.IOperation
+	for _, o := range objs {
+		if op, ok := o.(operation.IOperation); ok && op.BankAccountID() == accountID {
+			ops = append(ops, op)
+		}
+	}
+	return acc, ops, nil
+}
+
+// Нулевая дата — остаток до первой операции.
+func balanceAt(acc bankaccount.IBankAccount, ops []operation.IOperation, at time.Time) float64 {
+	return operation.BalanceAt(acc.Balance(), ops, at)
+}
+
+func reconciled(recs []reconciliation.IReconciliation, id service.ObjectID) bool {
+	for _, r := range recs {
+		if r.HasItem(id) {
+			return true
+		}
+	}
+	return false
+}
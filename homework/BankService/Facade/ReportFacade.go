@@ -0,0 +1,77 @@
+package facade
+
+import (
+	"context"
+	"time"
+
+	report "github.com/ilyaytrewq/kpo-sb/homework/BankService/Report"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+var farPast = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type ReportFacade struct {
+	accounts   repository.ICommonRepo
+	categories repository.ICommonRepo
+	ops        operationrepo.IOperationRepo
+}
+
+func NewReportFacade(accounts, categories, ops repository.ICommonRepo) *ReportFacade {
+	var r operationrepo.IOperationRepo
+	if casted, ok := ops.(operationrepo.IOperationRepo); ok {
+		r = casted
+	}
+	return &ReportFacade{accounts: accounts, categories: categories, ops: r}
+}
+
+func (f *ReportFacade) MonthlyStatement(ctx context.Context, accountID service.ObjectID, year int, month time.Month, loc *time.Location) (*report.Statement, error) {
+	if f.ops == nil {
+		return nil, service.Invariant("operation repo does not support period slicing")
+	}
+	obj, err := f.accounts.ByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	acc, ok := obj.(bankaccount.IBankAccount)
+	if !ok {
+		return nil, service.Invariant("invalid type")
+	}
+
+	from, to := report.MonthBounds(year, month, loc)
+	inPeriod, err := f.ops.SliceByAccountAndPeriod(ctx, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	earlier, err := f.ops.SliceByAccountAndPeriod(ctx, accountID, farPast, from.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	cats, err := f.categories.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[service.ObjectID]string, len(cats))
+	for _, obj := range cats {
+		if c, ok := obj.(category.ICategory); ok {
+			names[c.ID()] = c.Name()
+		}
+	}
+
+	return report.BuildStatement(acc, from, to, toOperations(inPeriod), toOperations(earlier), names), nil
+}
+
+func toOperations(objs []service.ICommonObject) []operation.IOperation {
+	out := make([]operation.IOperation, 0, len(objs))
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok {
+			out = append(out, op)
+		}
+	}
+	return out
+}
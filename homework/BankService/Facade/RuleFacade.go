@@ -0,0 +1,94 @@
+package facade
+
+import (
+	"context"
+	"errors"
+
+	categorization "github.com/ilyaytrewq/kpo-sb/homework/BankService/Categorization"
+	periods "github.com/ilyaytrewq/kpo-sb/homework/BankService/Periods"
+	reconcile "github.com/ilyaytrewq/kpo-sb/homework/BankService/Reconcile"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+)
+
+type RuleFacade struct {
+	rules       repository.ICommonRepo
+	ops         repository.ICommonRepo
+	categorizer *categorization.Categorizer
+}
+
+func NewRuleFacade(rules, ops repository.ICommonRepo) *RuleFacade {
+	return &RuleFacade{rules: rules, ops: ops, categorizer: categorization.NewCategorizer(rules)}
+}
+
+func (f *RuleFacade) Categorizer() *categorization.Categorizer { return f.categorizer }
+
+func (f *RuleFacade) CreateRule(ctx context.Context, name string, priority int, cond rule.Conditions, categoryID service.ObjectID) (service.ObjectID, error) {
+	r, err := rule.NewRule(name, priority, cond, categoryID)
+	if err != nil {
+		return service.ObjectID{}, err
+	}
+	if err := f.rules.Save(ctx, r); err != nil {
+		return service.ObjectID{}, err
+	}
+	return r.ID(), nil
+}
+
+func (f *RuleFacade) ListRules(ctx context.Context) ([]rule.IRule, error) {
+	return f.categorizer.Rules(ctx)
+}
+
+func (f *RuleFacade) DeleteRule(ctx context.Context, id service.ObjectID) error {
+	return f.rules.Delete(ctx, id)
+}
+
+// Прогоняет правила по всей истории; onlyUncategorized — не трогать уже размеченные операции.
+// Закрытые операции (сверенные или в закрытом периоде) пропускаются, любая другая ошибка
+// прерывает прогон; изменения сохраняются одной единицей работы.
+func (f *RuleFacade) Recategorize(ctx context.Context, onlyUncategorized bool) (int, error) {
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ops := make([]operation.IOperation, 0, len(objs))
+	byID := make(map[service.ObjectID]*operation.Operation, len(objs))
+	for _, obj := range objs {
+		op, ok := obj.(*operation.Operation)
+		if !ok {
+			return 0, service.Invariant("invalid type")
+		}
+		ops = append(ops, op)
+		byID[op.ID()] = op
+	}
+	changes, err := f.categorizer.Categorize(ctx, ops, onlyUncategorized)
+	if err != nil {
+		return 0, err
+	}
+	guard, _ := f.ops.(repository.IEditGuard)
+	changed := 0
+	err = repository.Atomic(ctx, func(ctx context.Context) error {
+		for id, catID := range changes {
+			if guard != nil {
+				// закрытые операции сохраняют прежнюю категорию
+				if err := guard.CheckEditable(ctx, id); errors.Is(err, reconcile.ErrLocked) || errors.Is(err, periods.ErrClosed) {
+					continue
+				} else if err != nil {
+					return err
+				}
+			}
+			op := byID[id]
+			op.SetCategoryID(catID)
+			if err := f.ops.Update(ctx, op); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,86 @@
+package facade
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	search "github.com/ilyaytrewq/kpo-sb/homework/BankService/Search"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+)
+
+type SearchFacade struct {
+	accounts   repository.ICommonRepo
+	categories repository.ICommonRepo
+	ops        repository.ICommonRepo
+
+	mu    sync.Mutex
+	index *search.Index // для in-memory хранилищ; сверяется с данными перед каждым поиском
+}
+
+func NewSearchFacade(accounts, categories, ops repository.ICommonRepo) *SearchFacade {
+	return &SearchFacade{accounts: accounts, categories: categories, ops: ops}
+}
+
+// Ранжированный поиск по описанию операции и названиям её категории и счёта.
+// Postgres ищет сам; для in-memory хранилищ индекс хранится между запросами и перед поиском
+// переиндексирует только изменившиеся, новые и удалённые операции.
+func (f *SearchFacade) Search(ctx context.Context, query string, limit int) ([]search.Hit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if r, ok := f.ops.(operationrepo.ISearchRepo); ok {
+		return r.Search(ctx, query, limit)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.syncIndex(ctx); err != nil {
+		return nil, err
+	}
+	return f.index.Search(query, limit), nil
+}
+
+// Вызывается под f.mu.
+func (f *SearchFacade) syncIndex(ctx context.Context) error {
+	names := make(map[service.ObjectID]string)
+	accs, err := f.accounts.All(ctx)
+	if err != nil {
+		return err
+	}
+	for _, obj := range accs {
+		if a, ok := obj.(bankaccount.IBankAccount); ok {
+			names[a.ID()] = a.Name()
+		}
+	}
+	cats, err := f.categories.All(ctx)
+	if err != nil {
+		return err
+	}
+	for _, obj := range cats {
+		if c, ok := obj.(category.ICategory); ok {
+			names[c.ID()] = c.Name()
+		}
+	}
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return err
+	}
+	if f.index == nil {
+		f.index = search.NewIndex()
+	}
+	present := make(map[service.ObjectID]bool, len(objs))
+	for _, op := range toOperations(objs) {
+		f.index.Add(op, names[op.CategoryID()], names[op.BankAccountID()])
+		present[op.ID()] = true
+	}
+	for _, id := range f.index.IDs() {
+		if !present[id] {
+			f.index.Remove(id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package facade
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	share "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Share"
+)
+
+// Общие счета: владелец открывает свой счёт другому пользователю на чтение или запись
+// и может в любой момент отозвать доступ.
+type ShareFacade struct {
+	accounts repository.ICommonRepo
+	shares   repository.ICommonRepo
+	owner    service.ObjectID
+}
+
+func NewShareFacade(accounts, shares repository.ICommonRepo, owner service.ObjectID) *ShareFacade {
+	return &ShareFacade{accounts: accounts, shares: shares, owner: owner}
+}
+
+// Повторная выдача тому же пользователю меняет уровень доступа.
+func (f *ShareFacade) ShareAccount(ctx context.Context, accountID, userID service.ObjectID, level service.AccessLevel) (share.IAccountShare, error) {
+	if err := f.checkOwnAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	if userID == f.owner {
+		return nil, service.Invalid("user_id", "cannot share an account with its owner")
+	}
+	if cur, err := f.find(ctx, accountID, userID); err != nil {
+		return nil, err
+	} else if cur != nil {
+		if err := cur.SetLevel(level); err != nil {
+			return nil, err
+		}
+		return cur, f.shares.Update(ctx, cur)
+	}
+	sh, err := share.NewAccountShare(accountID, userID, level, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return sh, f.shares.Save(ctx, sh)
+}
+
+func (f *ShareFacade) RevokeShare(ctx context.Context, accountID, userID service.ObjectID) error {
+	if err := f.checkOwnAccount(ctx, accountID); err != nil {
+		return err
+	}
+	cur, err := f.find(ctx, accountID, userID)
+	if err != nil {
+		return err
+	}
+	if cur == nil {
+		return service.NotFound("account %s is not shared with user %s", accountID, userID)
+	}
+	return f.shares.Delete(ctx, cur.ID())
+}
+
+// Все выданные доступы к счетам пользователя, по счёту и дате выдачи.
+func (f *ShareFacade) ListShares(ctx context.Context) ([]share.IAccountShare, error) {
+	objs, err := f.shares.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]share.IAccountShare, 0, len(objs))
+	for _, obj := range objs {
+		if sh, ok := obj.(share.IAccountShare); ok {
+			out = append(out, sh)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AccountID() != out[j].AccountID() {
+			return out[i].AccountID().String() < out[j].AccountID().String()
+		}
+		return out[i].CreatedAt().Before(out[j].CreatedAt())
+	})
+	return out, nil
+}
+
+func (f *ShareFacade) find(ctx context.Context, accountID, userID service.ObjectID) (*share.AccountShare, error) {
+	shares, err := f.ListShares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shares {
+		if sh.AccountID() == accountID && sh.UserID() == userID {
+			if cur, ok := sh.(*share.AccountShare); ok {
+				return cur, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Делиться можно только своим счётом, общий счёт дальше не передаётся.
+func (f *ShareFacade) checkOwnAccount(ctx context.Context, accountID service.ObjectID) error {
+	acc, err := f.accounts.ByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if owner, _ := service.OwnerOf(acc); owner != f.owner {
+		return service.NotFound("account %s belongs to another user", accountID)
+	}
+	return nil
+}
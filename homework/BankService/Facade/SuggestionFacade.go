@@ -0,0 +1,59 @@
+package facade
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	categorization "github.com/ilyaytrewq/kpo-sb/homework/BankService/Categorization"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type SuggestionFacade struct {
+	ops        repository.ICommonRepo
+	categories repository.ICommonRepo
+	modelPath  string
+	model      *categorization.NaiveBayesModel
+}
+
+func NewSuggestionFacade(ops, categories repository.ICommonRepo, modelPath string) *SuggestionFacade {
+	return &SuggestionFacade{ops: ops, categories: categories, modelPath: modelPath}
+}
+
+// Модель учится только на категориях, которые видит пользователь.
+func (f *SuggestionFacade) Retrain(ctx context.Context) (int, error) {
+	objs, err := f.ops.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cats, err := f.categories.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	visible := make(map[service.ObjectID]bool, len(cats))
+	for _, c := range cats {
+		visible[c.ID()] = true
+	}
+	m := categorization.TrainNaiveBayes(toOperations(objs), visible)
+	if err := m.Save(f.modelPath); err != nil {
+		return 0, err
+	}
+	f.model = m
+	return m.Docs, nil
+}
+
+func (f *SuggestionFacade) Suggest(ctx context.Context, description string, opType operation.OperationType, limit int) ([]categorization.Suggestion, error) {
+	if f.model == nil {
+		m, err := categorization.LoadNaiveBayesModel(f.modelPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, service.Conflict("suggestion model is not trained yet")
+			}
+			return nil, err
+		}
+		f.model = m
+	}
+	return f.model.Suggest(description, opType, limit), nil
+}
@@ -0,0 +1,249 @@
+package facade
+
+import (
+	"context"
+	"crypto/subtle"
+	"sort"
+	"strings"
+	"time"
+
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+// У пользователя ещё нет пароля — его нужно задать кодом установки (SetPasswordWithCode).
+var ErrPasswordNotSet = service.Conflict("password is not set")
+
+// Сколько действует одноразовый код установки пароля, выданный администратором.
+const SetupCodeTTL = 24 * time.Hour
+
+type UserFacade struct {
+	repo   repository.ICommonRepo
+	tokens *auth.Tokens
+	// Секрет первого входа: им задаётся пароль default, пока пароля нет. Пустой — только код от администратора.
+	bootstrap string
+}
+
+// Пользователь default (владелец старых данных, администратор) заводится при первом запуске.
+func NewUserFacade(ctx context.Context, repo repository.ICommonRepo, tokens *auth.Tokens) (*UserFacade, error) {
+	f := &UserFacade{repo: repo, tokens: tokens}
+	if _, err := repo.ByID(ctx, service.LegacyOwnerID); err == nil {
+		return f, nil
+	}
+	u, err := user.NewCopyUser(service.LegacyOwnerID, user.DefaultName, user.Admin, "", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return f, repo.Save(ctx, u)
+}
+
+func (f *UserFacade) SetBootstrapSecret(secret string) {
+	f.bootstrap = secret
+}
+
+// Пользователями управляет только администратор.
+func (f *UserFacade) CreateUser(ctx context.Context, name, password string, role user.Role) (user.IUser, error) {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return nil, err
+	}
+	if _, err := f.FindUser(ctx, name); err == nil {
+		return nil, service.Conflict("user %q already exists", strings.TrimSpace(name))
+	}
+	u, err := user.NewUser(name, role, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(password, auth.DefaultIterations)
+	if err != nil {
+		return nil, err
+	}
+	u.SetPasswordHash(hash)
+	if err := f.repo.Save(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Неизвестное имя и неверный пароль неразличимы для вызывающего.
+func (f *UserFacade) Login(ctx context.Context, name, password string) (user.IUser, error) {
+	u, err := f.FindUser(ctx, name)
+	if err != nil {
+		return nil, auth.ErrBadCredentials
+	}
+	if u.PasswordHash() == "" {
+		return u, ErrPasswordNotSet
+	}
+	if err := auth.VerifyPassword(u.PasswordHash(), password); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Смена пароля самим пользователем: нужен текущий пароль.
+func (f *UserFacade) ChangePassword(ctx context.Context, id service.ObjectID, current, password string) error {
+	if err := auth.RequireSelfOr(ctx, id, user.Admin); err != nil {
+		return err
+	}
+	u, err := f.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.PasswordHash() == "" {
+		return ErrPasswordNotSet
+	}
+	if err := auth.VerifyPassword(u.PasswordHash(), current); err != nil {
+		return err
+	}
+	return f.setPassword(ctx, u, password)
+}
+
+// Пароль по коду установки: одноразовому токену от администратора или, для default
+// без пароля, секрету первого входа. Без кода пароль пользователя не перехватить.
+func (f *UserFacade) SetPasswordWithCode(ctx context.Context, name, code, password string) (user.IUser, error) {
+	found, err := f.FindUser(ctx, name)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	u, err := f.GetUser(ctx, found.ID())
+	if err != nil {
+		return nil, err
+	}
+	if err := f.tokens.VerifyPasswordSetup(code, u); err != nil && !f.isBootstrap(u, code) {
+		return nil, err
+	}
+	if err := f.setPassword(ctx, u, password); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Одноразовый код установки (сброса) пароля; выдаёт только администратор.
+func (f *UserFacade) IssueSetupCode(ctx context.Context, id service.ObjectID) (string, error) {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return "", err
+	}
+	u, err := f.GetUser(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return f.tokens.IssuePasswordSetup(u, SetupCodeTTL)
+}
+
+func (f *UserFacade) isBootstrap(u user.IUser, code string) bool {
+	return f.bootstrap != "" && u.ID() == service.LegacyOwnerID && u.PasswordHash() == "" &&
+		subtle.ConstantTimeCompare([]byte(code), []byte(f.bootstrap)) == 1
+}
+
+func (f *UserFacade) setPassword(ctx context.Context, u *user.User, password string) error {
+	hash, err := auth.HashPassword(password, auth.DefaultIterations)
+	if err != nil {
+		return err
+	}
+	u.SetPasswordHash(hash)
+	return f.repo.Update(ctx, u)
+}
+
+// Последнего администратора понизить нельзя: управлять пользователями станет некому.
+func (f *UserFacade) SetRole(ctx context.Context, id service.ObjectID, role user.Role) error {
+	if err := auth.Require(ctx, user.Admin); err != nil {
+		return err
+	}
+	u, err := f.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Role() == user.Admin && role != user.Admin {
+		admins := 0
+		users, err := f.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		for _, other := range users {
+			if other.Role() == user.Admin {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return service.Invariant("cannot demote the last admin")
+		}
+	}
+	if err := u.SetRole(role); err != nil {
+		return err
+	}
+	return f.repo.Update(ctx, u)
+}
+
+// JWT для API и входа без пароля (BANK_TOKEN): себе или, администратором, кому угодно.
+func (f *UserFacade) IssueToken(ctx context.Context, id service.ObjectID) (string, error) {
+	if err := auth.RequireSelfOr(ctx, id, user.Admin); err != nil {
+		return "", err
+	}
+	u, err := f.GetUser(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return f.tokens.Issue(u)
+}
+
+// Пользователь по токену; роль берётся текущая, а не записанная в токен.
+func (f *UserFacade) Authenticate(ctx context.Context, token string) (user.IUser, error) {
+	claims, err := f.tokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, auth.ErrInvalidToken
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+	u, err := f.GetUser(ctx, id)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return u, nil
+}
+
+func (f *UserFacade) GetUser(ctx context.Context, id service.ObjectID) (*user.User, error) {
+	obj, err := f.repo.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*user.User)
+	if !ok {
+		return nil, service.Invariant("invalid type")
+	}
+	return u, nil
+}
+
+// Имена сравниваются без учёта регистра.
+func (f *UserFacade) FindUser(ctx context.Context, name string) (user.IUser, error) {
+	users, err := f.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Name(), strings.TrimSpace(name)) {
+			return u, nil
+		}
+	}
+	return nil, service.NotFound("user not found")
+}
+
+func (f *UserFacade) ListUsers(ctx context.Context) ([]user.IUser, error) {
+	objs, err := f.repo.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var users []user.IUser
+	for _, obj := range objs {
+		if u, ok := obj.(user.IUser); ok {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name() < users[j].Name() })
+	return users, nil
+}
@@ -0,0 +1,265 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+const (
+	minOccurrences    = 3
+	intervalTolerance = 0.2   // допустимое отклонение интервала от медианы
+	amountTolerance   = 0.15  // допустимое отклонение суммы от медианы
+	confidenceZ       = 1.645 // 90% доверительный интервал
+
+	DefaultHistoryDays = 90
+)
+
+type RecurringItem struct {
+	Type         operation.OperationType
+	CategoryID   service.ObjectID
+	Description  string
+	Amount       float64
+	IntervalDays int
+	Monthly      bool // интервал 28–31 день: следующий платёж в то же число месяца
+	DayOfMonth   int  // число ежемесячного платежа; в коротких месяцах — последний день месяца
+	LastDate     time.Time
+}
+
+func (r RecurringItem) Next(t time.Time) time.Time {
+	if r.Monthly {
+		day := r.DayOfMonth
+		if day == 0 {
+			day = t.Day()
+		}
+		// AddDate(0, 1, 0) с 31 января уводит в март; число прижимается к концу месяца
+		last := time.Date(t.Year(), t.Month()+2, 0, 0, 0, 0, 0, t.Location()).Day()
+		return time.Date(t.Year(), t.Month()+1, min(day, last), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	}
+	return t.AddDate(0, 0, r.IntervalDays)
+}
+
+// Средний дневной оборот нерегулярных операций одной категории.
+type CategoryTrend struct {
+	CategoryID  service.ObjectID
+	DailyNet    float64
+	DailyStdDev float64
+}
+
+type Point struct {
+	Date     time.Time `json:"date"`
+	Expected float64   `json:"expected"`
+	Lower    float64   `json:"lower"`
+	Upper    float64   `json:"upper"`
+}
+
+type Forecast struct {
+	AccountID     service.ObjectID
+	StartBalance  float64
+	DailyNet      float64 // сумма средних по категориям
+	DailyStdDev   float64
+	Categories    []CategoryTrend
+	Recurring     []RecurringItem
+	Points        []Point
+	FirstNegative *time.Time // первый день, когда ожидаемый остаток < 0
+	LowerNegative *time.Time // первый день, когда нижняя граница < 0
+	HistoryFrom   time.Time
+	HistoryTo     time.Time
+	HorizonInDays int
+}
+
+func median(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	s := make([]float64, len(vals))
+	copy(s, vals)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func recurringKey(op operation.IOperation) string {
+	return fmt.Sprintf("%s|%d|%s", op.CategoryID(), int(op.Type()), strings.ToLower(strings.TrimSpace(op.Description())))
+}
+
+// Регулярными считаем операции одной категории и типа с одинаковым описанием,
+// которые повторяются не меньше minOccurrences раз с примерно равным интервалом и суммой.
+func DetectRecurring(ops []operation.IOperation) ([]RecurringItem, map[service.ObjectID]bool) {
+	groups := make(map[string][]operation.IOperation)
+	for _, op := range ops {
+		groups[recurringKey(op)] = append(groups[recurringKey(op)], op)
+	}
+	var items []RecurringItem
+	members := make(map[service.ObjectID]bool)
+	for _, g := range groups {
+		if len(g) < minOccurrences {
+			continue
+		}
+		sort.Slice(g, func(i, j int) bool { return g[i].Date().Before(g[j].Date()) })
+		intervals := make([]float64, 0, len(g)-1)
+		amounts := make([]float64, 0, len(g))
+		for i, op := range g {
+			amounts = append(amounts, op.Amount())
+			if i > 0 {
+				intervals = append(intervals, g[i].Date().Sub(g[i-1].Date()).Hours()/24)
+			}
+		}
+		medInterval := median(intervals)
+		medAmount := median(amounts)
+		if medInterval < 1 {
+			continue
+		}
+		regular := true
+		for _, iv := range intervals {
+			if math.Abs(iv-medInterval) > medInterval*intervalTolerance {
+				regular = false
+				break
+			}
+		}
+		for _, a := range amounts {
+			if math.Abs(a-medAmount) > medAmount*amountTolerance {
+				regular = false
+				break
+			}
+		}
+		if !regular {
+			continue
+		}
+		last := g[len(g)-1]
+		interval := int(math.Round(medInterval))
+		// наибольшее число в группе: платёж «31-го» в феврале приходит 28-го
+		day := 0
+		for _, op := range g {
+			day = max(day, op.Date().Day())
+		}
+		items = append(items, RecurringItem{
+			Type:         last.Type(),
+			CategoryID:   last.CategoryID(),
+			Description:  last.Description(),
+			Amount:       medAmount,
+			IntervalDays: interval,
+			Monthly:      interval >= 28 && interval <= 31,
+			DayOfMonth:   day,
+			LastDate:     last.Date(),
+		})
+		for _, op := range g {
+			members[op.ID()] = true
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].LastDate.Before(items[j].LastDate) })
+	return items, members
+}
+
+func Project(
+	accountID service.ObjectID,
+	balance float64,
+	history []operation.IOperation,
+	historyFrom, now time.Time,
+	horizonDays int,
+) *Forecast {
+	recurring, members := DetectRecurring(history)
+
+	historyDays := int(math.Ceil(now.Sub(historyFrom).Hours() / 24))
+	if historyDays < 1 {
+		historyDays = 1
+	}
+	// нерегулярные траты — средним за день по каждой категории; прогноз складывает средние,
+	// а дисперсии категорий считаются независимыми и тоже складываются
+	daily := make(map[service.ObjectID][]float64)
+	for _, op := range history {
+		if members[op.ID()] {
+			continue
+		}
+		idx := int(op.Date().Sub(historyFrom).Hours() / 24)
+		if idx < 0 || idx >= historyDays {
+			continue
+		}
+		if daily[op.CategoryID()] == nil {
+			daily[op.CategoryID()] = make([]float64, historyDays)
+		}
+		daily[op.CategoryID()][idx] += operation.SignedAmount(op)
+	}
+	var trends []CategoryTrend
+	var mean, variance float64
+	for id, days := range daily {
+		var m float64
+		for _, v := range days {
+			m += v
+		}
+		m /= float64(historyDays)
+		var vr float64
+		for _, v := range days {
+			vr += (v - m) * (v - m)
+		}
+		vr /= float64(historyDays)
+		trends = append(trends, CategoryTrend{CategoryID: id, DailyNet: m, DailyStdDev: math.Sqrt(vr)})
+		mean += m
+		variance += vr
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].DailyNet < trends[j].DailyNet })
+	std := math.Sqrt(variance)
+
+	f := &Forecast{
+		AccountID:     accountID,
+		StartBalance:  balance,
+		DailyNet:      mean,
+		DailyStdDev:   std,
+		Categories:    trends,
+		Recurring:     recurring,
+		HistoryFrom:   historyFrom,
+		HistoryTo:     now,
+		HorizonInDays: horizonDays,
+	}
+
+	// ожидаемые даты регулярных операций в горизонте прогноза; точка дня d (now + d дней)
+	// включает платежи после точки d-1 и не позже своей даты, поэтому d не выходит за горизонт
+	scheduled := make(map[int]float64)
+	end := now.AddDate(0, 0, horizonDays)
+	for _, item := range recurring {
+		for next := item.Next(item.LastDate); !next.After(end); next = item.Next(next) {
+			if next.Before(now) {
+				continue
+			}
+			day := int(math.Ceil(next.Sub(now).Hours() / 24))
+			if day < 1 {
+				day = 1
+			}
+			if item.Type == operation.Income {
+				scheduled[day] += item.Amount
+			} else {
+				scheduled[day] -= item.Amount
+			}
+		}
+	}
+
+	expected := balance
+	for d := 1; d <= horizonDays; d++ {
+		expected += mean + scheduled[d]
+		band := confidenceZ * std * math.Sqrt(float64(d))
+		p := Point{
+			Date:     now.AddDate(0, 0, d),
+			Expected: expected,
+			Lower:    expected - band,
+			Upper:    expected + band,
+		}
+		if f.FirstNegative == nil && p.Expected < 0 {
+			date := p.Date
+			f.FirstNegative = &date
+		}
+		if f.LowerNegative == nil && p.Lower < 0 {
+			date := p.Date
+			f.LowerNegative = &date
+		}
+		f.Points = append(f.Points, p)
+	}
+	return f
+}
@@ -0,0 +1,94 @@
+package forecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	exporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter"
+)
+
+type jsonForecastFormatter struct{}
+
+func (f *jsonForecastFormatter) FormatData(data interface{}) ([]byte, error) {
+	fc, ok := data.(*Forecast)
+	if !ok {
+		return nil, fmt.Errorf("invalid data type: expected *forecast.Forecast")
+	}
+	type recurringOut struct {
+		Type         int     `json:"type"`
+		CategoryID   string  `json:"category_id"`
+		Description  string  `json:"description"`
+		Amount       float64 `json:"amount"`
+		IntervalDays int     `json:"interval_days"`
+		Monthly      bool    `json:"monthly"`
+		DayOfMonth   int     `json:"day_of_month,omitempty"`
+		LastDate     string  `json:"last_date"`
+	}
+	type categoryOut struct {
+		CategoryID  string  `json:"category_id"`
+		DailyNet    float64 `json:"daily_net"`
+		DailyStdDev float64 `json:"daily_std_dev"`
+	}
+	type out struct {
+		AccountID     string         `json:"account_id"`
+		StartBalance  float64        `json:"start_balance"`
+		DailyNet      float64        `json:"daily_net"`
+		DailyStdDev   float64        `json:"daily_std_dev"`
+		HistoryFrom   string         `json:"history_from"`
+		HistoryTo     string         `json:"history_to"`
+		FirstNegative *string        `json:"first_negative,omitempty"`
+		LowerNegative *string        `json:"lower_negative,omitempty"`
+		Categories    []categoryOut  `json:"categories"`
+		Recurring     []recurringOut `json:"recurring"`
+		Series        []Point        `json:"series"`
+	}
+	res := out{
+		AccountID:    uuid.UUID(fc.AccountID).String(),
+		StartBalance: fc.StartBalance,
+		DailyNet:     fc.DailyNet,
+		DailyStdDev:  fc.DailyStdDev,
+		HistoryFrom:  fc.HistoryFrom.Format(time.RFC3339),
+		HistoryTo:    fc.HistoryTo.Format(time.RFC3339),
+		Categories:   make([]categoryOut, 0, len(fc.Categories)),
+		Recurring:    make([]recurringOut, 0, len(fc.Recurring)),
+		Series:       fc.Points,
+	}
+	if fc.FirstNegative != nil {
+		s := fc.FirstNegative.Format(time.RFC3339)
+		res.FirstNegative = &s
+	}
+	if fc.LowerNegative != nil {
+		s := fc.LowerNegative.Format(time.RFC3339)
+		res.LowerNegative = &s
+	}
+	for _, c := range fc.Categories {
+		res.Categories = append(res.Categories, categoryOut{
+			CategoryID:  uuid.UUID(c.CategoryID).String(),
+			DailyNet:    c.DailyNet,
+			DailyStdDev: c.DailyStdDev,
+		})
+	}
+	for _, r := range fc.Recurring {
+		res.Recurring = append(res.Recurring, recurringOut{
+			Type:         int(r.Type),
+			CategoryID:   uuid.UUID(r.CategoryID).String(),
+			Description:  r.Description,
+			Amount:       r.Amount,
+			IntervalDays: r.IntervalDays,
+			Monthly:      r.Monthly,
+			DayOfMonth:   r.DayOfMonth,
+			LastDate:     r.LastDate.Format(time.RFC3339),
+		})
+	}
+	return json.MarshalIndent(res, "", "\t")
+}
+
+func NewJSONForecastExporter(filepath string) *exporter.BaseExporter {
+	return exporter.NewExporter(filepath, &jsonForecastFormatter{})
+}
+
+func FormatJSON(fc *Forecast) ([]byte, error) {
+	return (&jsonForecastFormatter{}).FormatData(fc)
+}
@@ -0,0 +1,161 @@
+package journal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+)
+
+// Сумма в копейках: проводки сходятся точно, без ошибок округления float64.
+type Amount int64
+
+func AmountOf(x float64) Amount { return Amount(math.Round(x * 100)) }
+
+func (a Amount) Float() float64 { return float64(a) / 100 }
+
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, a/100, a%100)
+}
+
+type Kind int
+
+const (
+	Asset Kind = iota
+	Liability
+	Equity
+	Income
+	Expense
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Asset:
+		return "Asset"
+	case Liability:
+		return "Liability"
+	case Equity:
+		return "Equity"
+	case Income:
+		return "Income"
+	case Expense:
+		return "Expense"
+	}
+	return "Unknown"
+}
+
+// Активы и расходы растут по дебету, остальные счета — по кредиту.
+func (k Kind) DebitNormal() bool { return k == Asset || k == Expense }
+
+// Счёт плана счетов. SourceID — банковский счёт или категория, из которых он получен.
+type Account struct {
+	Code     string
+	Name     string
+	Kind     Kind
+	SourceID service.ObjectID
+}
+
+// Служебные счета.
+const (
+	UnknownAssetCode         = "1999"
+	OpeningEquityCode        = "3000"
+	UncategorizedIncomeCode  = "4999"
+	UncategorizedExpenseCode = "5999"
+)
+
+// План счетов: банковские счета — активы (1001–1998), категории доходов — 4001–4998, расходов — 5001–5998.
+// Номер выводится из ID источника, поэтому не меняется, когда появляются или переименовываются
+// другие счета и категории; при совпадении берётся следующий свободный номер диапазона.
+type Chart struct {
+	accounts map[string]Account
+	bySource map[service.ObjectID]string
+}
+
+// Первый номер диапазона и число номеров в нём; x999 занят служебным счётом.
+const rangeSize = 998
+
+var rangeStart = map[Kind]int{Asset: 1001, Income: 4001, Expense: 5001}
+
+func NewChart(accounts []bankaccount.IBankAccount, categories []category.ICategory) (*Chart, error) {
+	c := &Chart{accounts: make(map[string]Account), bySource: make(map[service.ObjectID]string)}
+	c.add(Account{Code: UnknownAssetCode, Name: "Unknown bank account", Kind: Asset})
+	c.add(Account{Code: OpeningEquityCode, Name: "Opening balance equity", Kind: Equity})
+	c.add(Account{Code: UncategorizedIncomeCode, Name: "Uncategorized income", Kind: Income})
+	c.add(Account{Code: UncategorizedExpenseCode, Name: "Uncategorized expenses", Kind: Expense})
+
+	sources := make([]Account, 0, len(accounts)+len(categories))
+	for _, acc := range accounts {
+		sources = append(sources, Account{Name: acc.Name(), Kind: Asset, SourceID: acc.ID()})
+	}
+	for _, cat := range categories {
+		kind := Expense
+		if cat.Type() == category.Income {
+			kind = Income
+		}
+		sources = append(sources, Account{Name: cat.Name(), Kind: kind, SourceID: cat.ID()})
+	}
+	// совпадения разрешаются в порядке ID, чтобы номера не зависели от порядка загрузки
+	sort.Slice(sources, func(i, j int) bool { return sources[i].SourceID.String() < sources[j].SourceID.String() })
+	used := make(map[Kind]int)
+	for _, a := range sources {
+		if used[a.Kind] == rangeSize {
+			return nil, fmt.Errorf("chart of accounts: %s range is full (%d accounts)", a.Kind, rangeSize)
+		}
+		a.Code = c.freeCode(a.Kind, a.SourceID)
+		c.add(a)
+		used[a.Kind]++
+	}
+	return c, nil
+}
+
+func (c *Chart) freeCode(kind Kind, id service.ObjectID) string {
+	h := fnv.New32a()
+	h.Write([]byte(id.String()))
+	slot := int(h.Sum32() % rangeSize)
+	for {
+		code := fmt.Sprintf("%d", rangeStart[kind]+slot)
+		if _, taken := c.accounts[code]; !taken {
+			return code
+		}
+		slot = (slot + 1) % rangeSize
+	}
+}
+
+func (c *Chart) add(a Account) {
+	c.accounts[a.Code] = a
+	if a.SourceID != (service.ObjectID{}) {
+		c.bySource[a.SourceID] = a.Code
+	}
+}
+
+func (c *Chart) Account(code string) (Account, bool) {
+	a, ok := c.accounts[code]
+	return a, ok
+}
+
+// Счёт плана, полученный из банковского счёта или категории.
+func (c *Chart) BySource(id service.ObjectID) (Account, bool) {
+	code, ok := c.bySource[id]
+	if !ok {
+		return Account{}, false
+	}
+	return c.accounts[code], true
+}
+
+// Все счета по возрастанию кода.
+func (c *Chart) Accounts() []Account {
+	out := make([]Account, 0, len(c.accounts))
+	for _, a := range c.accounts {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
@@ -0,0 +1,192 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Строка проводки: ровно одна из сторон больше нуля.
+type Line struct {
+	Account string // код счёта плана
+	Debit   Amount
+	Credit  Amount
+}
+
+type Entry struct {
+	Number      int
+	Date        time.Time
+	OperationID service.ObjectID // нулевой у проводок начальных остатков
+	Description string
+	Lines       []Line
+}
+
+// Инвариант двойной записи: дебет равен кредиту.
+func (e Entry) Validate() error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("entry %d: needs at least two lines", e.Number)
+	}
+	var debit, credit Amount
+	for _, l := range e.Lines {
+		if l.Debit < 0 || l.Credit < 0 || (l.Debit == 0) == (l.Credit == 0) {
+			return fmt.Errorf("entry %d: line %s should have exactly one positive side", e.Number, l.Account)
+		}
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if debit != credit {
+		return fmt.Errorf("entry %d: debits %s != credits %s", e.Number, debit, credit)
+	}
+	return nil
+}
+
+// Проводка по операции. Расход: Дт расходы (по частям разбивки) — Кт актив;
+// доход: Дт актив — Кт доходы. Копейки, потерянные при округлении частей, относятся к самой крупной части.
+func (c *Chart) Post(op operation.IOperation) Entry {
+	// операция без существующего счёта (например, после импорта) не теряется, а попадает на отдельный актив
+	asset, ok := c.BySource(op.BankAccountID())
+	if !ok || asset.Kind != Asset {
+		asset, _ = c.Account(UnknownAssetCode)
+	}
+	total := AmountOf(op.Amount())
+	e := Entry{Date: op.Date(), OperationID: op.ID(), Description: op.Description()}
+	if total == 0 {
+		return e
+	}
+
+	type part struct {
+		code   string
+		amount Amount
+	}
+	byCode := make(map[string]Amount)
+	for catID, amount := range operation.CategoryAmounts(op) {
+		byCode[c.categoryCode(catID, op.Type())] += AmountOf(amount)
+	}
+	parts := make([]part, 0, len(byCode))
+	var sum Amount
+	for code, amount := range byCode {
+		parts = append(parts, part{code, amount})
+		sum += amount
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].amount != parts[j].amount {
+			return parts[i].amount > parts[j].amount
+		}
+		return parts[i].code < parts[j].code
+	})
+	parts[0].amount += total - sum
+
+	income := op.Type() == operation.Income
+	if income {
+		e.Lines = append(e.Lines, Line{Account: asset.Code, Debit: total})
+	}
+	for _, p := range parts {
+		if p.amount == 0 {
+			continue
+		}
+		if income {
+			e.Lines = append(e.Lines, Line{Account: p.code, Credit: p.amount})
+		} else {
+			e.Lines = append(e.Lines, Line{Account: p.code, Debit: p.amount})
+		}
+	}
+	if !income {
+		e.Lines = append(e.Lines, Line{Account: asset.Code, Credit: total})
+	}
+	return e
+}
+
+// Категория без счёта в плане (не задана или удалена) уходит на «неразнесённые» доходы или расходы.
+func (c *Chart) categoryCode(catID service.ObjectID, t operation.OperationType) string {
+	if a, ok := c.BySource(catID); ok && (a.Kind == Income || a.Kind == Expense) {
+		return a.Code
+	}
+	if t == operation.Income {
+		return UncategorizedIncomeCode
+	}
+	return UncategorizedExpenseCode
+}
+
+// Журнал: проводки начальных остатков и по каждой операции, по возрастанию даты.
+type Journal struct {
+	Chart   *Chart
+	Entries []Entry
+}
+
+// Баланс банковского счёта — начальный остаток до первой операции (см. operation.BalanceAt);
+// он проводится против капитала нулевой датой, и остатки журнала на любую дату совпадают
+// с остатками сверки и выписки.
+func Build(chart *Chart, accounts []bankaccount.IBankAccount, ops []operation.IOperation) (*Journal, error) {
+	sorted := make([]operation.IOperation, len(ops))
+	copy(sorted, ops)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date().Before(sorted[j].Date()) })
+
+	var entries []Entry
+	for _, op := range sorted {
+		if e := chart.Post(op); len(e.Lines) > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	var openings []Entry
+	for _, acc := range accounts {
+		asset, ok := chart.BySource(acc.ID())
+		if !ok {
+			return nil, fmt.Errorf("bank account %s is not in the chart", acc.ID())
+		}
+		opening := AmountOf(acc.Balance())
+		if opening == 0 {
+			continue
+		}
+		// начальный остаток действует с начала учёта, как в operation.BalanceAt
+		e := Entry{Description: "Opening balance: " + acc.Name()}
+		if opening > 0 {
+			e.Lines = []Line{{Account: asset.Code, Debit: opening}, {Account: OpeningEquityCode, Credit: opening}}
+		} else {
+			e.Lines = []Line{{Account: OpeningEquityCode, Debit: -opening}, {Account: asset.Code, Credit: -opening}}
+		}
+		openings = append(openings, e)
+	}
+	// начальные остатки идут первыми, по коду счёта
+	sort.SliceStable(openings, func(i, j int) bool { return assetCode(openings[i]) < assetCode(openings[j]) })
+	all := append(openings, entries...)
+	for n := range all {
+		all[n].Number = n + 1
+	}
+	j := &Journal{Chart: chart, Entries: all}
+	if errs := j.Check(); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return j, nil
+}
+
+// Счёт актива в проводке начального остатка.
+func assetCode(e Entry) string {
+	for _, l := range e.Lines {
+		if l.Account != OpeningEquityCode {
+			return l.Account
+		}
+	}
+	return ""
+}
+
+// Проверка инварианта: каждая проводка сходится и ссылается только на счета плана.
+func (j *Journal) Check() []error {
+	var errs []error
+	for _, e := range j.Entries {
+		if err := e.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, l := range e.Lines {
+			if _, ok := j.Chart.Account(l.Account); !ok {
+				errs = append(errs, fmt.Errorf("entry %d: unknown account %s", e.Number, l.Account))
+			}
+		}
+	}
+	return errs
+}
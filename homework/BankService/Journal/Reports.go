@@ -0,0 +1,200 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+)
+
+// Обороты по счетам за проводки, попавшие в [from, to]; нулевые границы не ограничивают.
+func (j *Journal) turnover(from, to time.Time) map[string][2]Amount {
+	out := make(map[string][2]Amount)
+	for _, e := range j.Entries {
+		if !inPeriod(e.Date, from, to) {
+			continue
+		}
+		for _, l := range e.Lines {
+			t := out[l.Account]
+			t[0] += l.Debit
+			t[1] += l.Credit
+			out[l.Account] = t
+		}
+	}
+	return out
+}
+
+func inPeriod(d, from, to time.Time) bool {
+	return (from.IsZero() || !d.Before(from)) && (to.IsZero() || !d.After(to))
+}
+
+// Остаток на нормальной стороне счёта: для активов и расходов — дебет минус кредит.
+func balanceOf(a Account, debit, credit Amount) Amount {
+	if a.Kind.DebitNormal() {
+		return debit - credit
+	}
+	return credit - debit
+}
+
+type TrialRow struct {
+	Account Account
+	Debit   Amount
+	Credit  Amount
+}
+
+type TrialBalance struct {
+	AsOf        time.Time
+	Rows        []TrialRow
+	TotalDebit  Amount
+	TotalCredit Amount
+}
+
+// Оборотно-сальдовая ведомость на дату: сальдо каждого счёта по дебету или кредиту.
+func (j *Journal) TrialBalance(asOf time.Time) TrialBalance {
+	tb := TrialBalance{AsOf: asOf}
+	turnover := j.turnover(time.Time{}, asOf)
+	for _, a := range j.Chart.Accounts() {
+		t, ok := turnover[a.Code]
+		if !ok {
+			continue
+		}
+		row := TrialRow{Account: a}
+		if net := t[0] - t[1]; net > 0 {
+			row.Debit = net
+		} else {
+			row.Credit = -net
+		}
+		tb.TotalDebit += row.Debit
+		tb.TotalCredit += row.Credit
+		tb.Rows = append(tb.Rows, row)
+	}
+	return tb
+}
+
+func (tb TrialBalance) Balanced() bool { return tb.TotalDebit == tb.TotalCredit }
+
+type LedgerLine struct {
+	Entry       int
+	Date        time.Time
+	Description string
+	Debit       Amount
+	Credit      Amount
+	Balance     Amount // нарастающий остаток на нормальной стороне счёта
+}
+
+type GeneralLedger struct {
+	Account Account
+	From    time.Time
+	To      time.Time
+	Opening Amount
+	Lines   []LedgerLine
+	Closing Amount
+}
+
+// Главная книга по одному счёту: остаток на начало периода, движения и остаток на конец.
+func (j *Journal) GeneralLedger(code string, from, to time.Time) (GeneralLedger, error) {
+	a, ok := j.Chart.Account(code)
+	if !ok {
+		return GeneralLedger{}, fmt.Errorf("account %s is not in the chart", code)
+	}
+	gl := GeneralLedger{Account: a, From: from, To: to}
+	for _, e := range j.Entries {
+		for _, l := range e.Lines {
+			if l.Account != code {
+				continue
+			}
+			switch {
+			case !from.IsZero() && e.Date.Before(from):
+				gl.Opening += balanceOf(a, l.Debit, l.Credit)
+			case inPeriod(e.Date, from, to):
+				gl.Lines = append(gl.Lines, LedgerLine{Entry: e.Number, Date: e.Date, Description: e.Description, Debit: l.Debit, Credit: l.Credit})
+			}
+		}
+	}
+	running := gl.Opening
+	for i := range gl.Lines {
+		running += balanceOf(a, gl.Lines[i].Debit, gl.Lines[i].Credit)
+		gl.Lines[i].Balance = running
+	}
+	gl.Closing = running
+	return gl, nil
+}
+
+type ReportRow struct {
+	Account Account
+	Amount  Amount
+}
+
+type BalanceSheet struct {
+	AsOf        time.Time
+	Assets      []ReportRow
+	Liabilities []ReportRow
+	Equity      []ReportRow // включая нераспределённую прибыль
+	TotalAssets Amount
+	// Должно совпасть с TotalAssets.
+	TotalLiabilitiesAndEquity Amount
+}
+
+// Баланс на дату: доходы и расходы за всё время сворачиваются в нераспределённую прибыль.
+func (j *Journal) BalanceSheet(asOf time.Time) BalanceSheet {
+	bs := BalanceSheet{AsOf: asOf}
+	turnover := j.turnover(time.Time{}, asOf)
+	var earnings Amount
+	for _, a := range j.Chart.Accounts() {
+		t, ok := turnover[a.Code]
+		if !ok {
+			continue
+		}
+		row := ReportRow{Account: a, Amount: balanceOf(a, t[0], t[1])}
+		switch a.Kind {
+		case Asset:
+			bs.Assets = append(bs.Assets, row)
+			bs.TotalAssets += row.Amount
+		case Liability:
+			bs.Liabilities = append(bs.Liabilities, row)
+			bs.TotalLiabilitiesAndEquity += row.Amount
+		case Equity:
+			bs.Equity = append(bs.Equity, row)
+			bs.TotalLiabilitiesAndEquity += row.Amount
+		case Income:
+			earnings += row.Amount
+		case Expense:
+			earnings -= row.Amount
+		}
+	}
+	bs.Equity = append(bs.Equity, ReportRow{Account: Account{Code: "3900", Name: "Retained earnings", Kind: Equity}, Amount: earnings})
+	bs.TotalLiabilitiesAndEquity += earnings
+	return bs
+}
+
+func (bs BalanceSheet) Balanced() bool { return bs.TotalAssets == bs.TotalLiabilitiesAndEquity }
+
+type ProfitAndLoss struct {
+	From, To      time.Time
+	Income        []ReportRow
+	Expenses      []ReportRow
+	TotalIncome   Amount
+	TotalExpenses Amount
+	NetIncome     Amount
+}
+
+// Отчёт о прибылях и убытках за период.
+func (j *Journal) ProfitAndLoss(from, to time.Time) ProfitAndLoss {
+	pl := ProfitAndLoss{From: from, To: to}
+	turnover := j.turnover(from, to)
+	for _, a := range j.Chart.Accounts() {
+		t, ok := turnover[a.Code]
+		if !ok {
+			continue
+		}
+		row := ReportRow{Account: a, Amount: balanceOf(a, t[0], t[1])}
+		switch a.Kind {
+		case Income:
+			pl.Income = append(pl.Income, row)
+			pl.TotalIncome += row.Amount
+		case Expense:
+			pl.Expenses = append(pl.Expenses, row)
+			pl.TotalExpenses += row.Amount
+		}
+	}
+	pl.NetIncome = pl.TotalIncome - pl.TotalExpenses
+	return pl
+}
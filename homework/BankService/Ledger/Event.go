@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	AccountOpened         EventType = "AccountOpened"
+	AccountRenamed        EventType = "AccountRenamed"
+	AccountBalanceChanged EventType = "AccountBalanceChanged"
+	AccountArchived       EventType = "AccountArchived"
+	AccountRestored       EventType = "AccountRestored"
+	AccountDeleted        EventType = "AccountDeleted"
+
+	CategoryCreated  EventType = "CategoryCreated"
+	CategoryRenamed  EventType = "CategoryRenamed"
+	CategoryMoved    EventType = "CategoryMoved"
+	CategoryArchived EventType = "CategoryArchived"
+	CategoryRestored EventType = "CategoryRestored"
+	CategoryDeleted  EventType = "CategoryDeleted"
+
+	OperationRecorded EventType = "OperationRecorded"
+	OperationAmended  EventType = "OperationAmended"
+	OperationDeleted  EventType = "OperationDeleted"
+)
+
+// Факт об изменении одной сущности. События только дописываются; состояние — их свёртка.
+type Event struct {
+	Seq        int64           `json:"seq"` // проставляет хранилище, по возрастанию
+	Type       EventType       `json:"type"`
+	StreamID   string          `json:"stream_id"` // id счёта, категории или операции
+	Data       json.RawMessage `json:"data,omitempty"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	OwnerID    string          `json:"owner_id,omitempty"` // владелец сущности; журнал в БД отдаёт пользователю только его события
+}
+
+// Данные событий. *Opened, *Created, OperationRecorded и OperationAmended несут полный снимок
+// сущности в формате historyrepo; остальные — только изменившееся поле.
+type renamedData struct {
+	Name string `json:"name"`
+}
+
+type balanceChangedData struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+type archivedData struct {
+	At time.Time `json:"at"`
+}
+
+type movedData struct {
+	ParentID string `json:"parent_id,omitempty"` // пусто — категория верхнего уровня
+}
+
+type IEventStore interface {
+	// Дописывает события одной пачкой и проставляет им Seq.
+	Append(ctx context.Context, events []Event) error
+	// События с номером больше after по возрастанию.
+	Load(ctx context.Context, after int64) ([]Event, error)
+	// Все события одной сущности.
+	Stream(ctx context.Context, streamID string) ([]Event, error)
+}
+
+// Свёрнутое состояние после события Seq: при старте читаются только более поздние события.
+type Snapshot struct {
+	Seq        int64             `json:"seq"`
+	TakenAt    time.Time         `json:"taken_at"`
+	Accounts   []json.RawMessage `json:"accounts"`
+	Categories []json.RawMessage `json:"categories"`
+	Operations []json.RawMessage `json:"operations"`
+}
+
+type ISnapshotStore interface {
+	// Последний снимок или nil, если снимков ещё нет.
+	LoadSnapshot(ctx context.Context) (*Snapshot, error)
+	SaveSnapshot(ctx context.Context, s Snapshot) error
+}
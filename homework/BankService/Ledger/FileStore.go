@@ -0,0 +1,210 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+)
+
+// Журнал событий в файле: одно событие JSON на строку, запись только в конец.
+type FileEventStore struct {
+	path string
+
+	mu   sync.Mutex
+	last int64
+}
+
+// Недописанная последняя строка (сбой посреди записи) отрезается при открытии.
+func NewFileEventStore(path string) (*FileEventStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if i := bytes.LastIndexByte(data, '\n'); i+1 < len(data) {
+		if err := os.Truncate(path, int64(i+1)); err != nil {
+			return nil, err
+		}
+		data = data[:i+1]
+	}
+	s := &FileEventStore{path: path}
+	events, err := decodeEvents(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		s.last = events[len(events)-1].Seq
+	}
+	return s, nil
+}
+
+// В единице работы (repository.Atomic) строки пишутся в файл при её фиксации:
+// откаченная единица не оставляет в журнале событий, которых нет в проекциях.
+func (s *FileEventStore) Append(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf bytes.Buffer
+	for i := range events {
+		events[i].Seq = s.last + int64(i) + 1
+		line, err := json.Marshal(events[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if u, ok := repository.UnitFrom(ctx); ok {
+		s.appendOnCommit(u, buf.Bytes())
+		s.last += int64(len(events))
+		return nil
+	}
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.last += int64(len(events))
+	return nil
+}
+
+// Вызывается под s.mu. Номера событий выданы сразу; при откате они освобождаются.
+func (s *FileEventStore) appendOnCommit(u *repository.Unit, lines []byte) {
+	if pending, ok := u.Resource(s); ok {
+		pending.(*bytes.Buffer).Write(lines)
+		return
+	}
+	pending := bytes.NewBuffer(append([]byte(nil), lines...))
+	start := s.last
+	release := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.last = start
+	}
+	u.Attach(s, pending, func() error {
+		s.mu.Lock()
+		err := s.write(pending.Bytes())
+		s.mu.Unlock()
+		if err != nil {
+			release()
+		}
+		return err
+	}, release)
+}
+
+// Вызывается под s.mu.
+func (s *FileEventStore) write(lines []byte) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(lines); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (s *FileEventStore) Load(ctx context.Context, after int64) ([]Event, error) {
+	all, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Event, 0, len(all))
+	for _, e := range all {
+		if e.Seq > after {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *FileEventStore) Stream(ctx context.Context, streamID string) ([]Event, error) {
+	all, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	for _, e := range all {
+		if e.StreamID == streamID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *FileEventStore) readAll() ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEvents(data)
+}
+
+func decodeEvents(data []byte) ([]Event, error) {
+	var out []Event
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("event log line %d: %w", line, err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// Снимок в файле; новый пишется во временный файл и подменяет старый целиком.
+type FileSnapshotStore struct {
+	path string
+}
+
+func NewFileSnapshotStore(path string) *FileSnapshotStore {
+	return &FileSnapshotStore{path: path}
+}
+
+func (s *FileSnapshotStore) LoadSnapshot(ctx context.Context) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (s *FileSnapshotStore) SaveSnapshot(ctx context.Context, snap Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,494 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	bankaccountrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/BankAccountRepo"
+	categoryrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/CategoryRepo"
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+)
+
+// Проекция одной сущности: объекты для чтения и их последние снимки для сравнения при записи.
+type projection struct {
+	name  string // для сообщений об ошибках: account, category, operation
+	codec historyrepo.Codec
+	repo  repository.ICommonRepo
+	state map[service.ObjectID]json.RawMessage
+}
+
+func newProjection(name string, codec historyrepo.Codec, repo repository.ICommonRepo) *projection {
+	return &projection{name: name, codec: codec, repo: repo, state: make(map[service.ObjectID]json.RawMessage)}
+}
+
+// Объект всегда собирается заново из снимка: фасады меняют полученный указатель до Update,
+// и проекция не должна видеть правку, пока событие не записано.
+// Проекция — внутреннее состояние журнала: права проверены выше, поэтому пишется без области
+// видимости; в единице работы (см. Ledger.write) изменения откатываются вместе с ней.
+func (p *projection) put(ctx context.Context, raw json.RawMessage) error {
+	obj, err := p.codec.Decode(raw)
+	if err != nil {
+		return err
+	}
+	ctx = service.Unscoped(ctx)
+	if _, ok := p.state[obj.ID()]; ok {
+		err = p.repo.Update(ctx, obj)
+	} else {
+		err = p.repo.Save(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	repository.Put(ctx, p.state, obj.ID(), raw)
+	return nil
+}
+
+func (p *projection) current(id service.ObjectID) (service.ICommonObject, error) {
+	raw, ok := p.state[id]
+	if !ok {
+		return nil, service.NotFound("%s not found", p.name)
+	}
+	return p.codec.Decode(raw)
+}
+
+func (p *projection) mutate(ctx context.Context, id service.ObjectID, fn func(obj service.ICommonObject) error) error {
+	obj, err := p.current(id)
+	if err != nil {
+		return err
+	}
+	if err := fn(obj); err != nil {
+		return err
+	}
+	raw, err := p.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	return p.put(ctx, raw)
+}
+
+func (p *projection) remove(ctx context.Context, id service.ObjectID) error {
+	if _, ok := p.state[id]; !ok {
+		return service.NotFound("%s not found", p.name)
+	}
+	ctx = service.Unscoped(ctx)
+	if err := p.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	repository.Remove(ctx, p.state, id)
+	return nil
+}
+
+// Хранилище в режиме журнала событий: запись превращается в события, чтение идёт из проекций.
+// Рассчитано на одного пишущего: события других процессов видны только после перезапуска.
+type Ledger struct {
+	store         IEventStore
+	snapshots     ISnapshotStore
+	snapshotEvery int
+	now           func() time.Time
+
+	mu            sync.RWMutex
+	seq           int64
+	sinceSnapshot int
+
+	accounts   *projection
+	categories *projection
+	operations *projection
+	ops        *operationrepo.OperationRepo
+}
+
+// Восстанавливает проекции: последний снимок плюс события после него.
+// snapshots может быть nil; snapshotEvery > 0 — снимок после каждых snapshotEvery событий.
+func Open(ctx context.Context, store IEventStore, snapshots ISnapshotStore, snapshotEvery int) (*Ledger, error) {
+	// проекции собираются из событий всех пользователей
+	ctx = service.Unscoped(ctx)
+	ops := operationrepo.NewOperationRepo()
+	l := &Ledger{
+		store:         store,
+		snapshots:     snapshots,
+		snapshotEvery: snapshotEvery,
+		now:           time.Now,
+		accounts:      newProjection("account", historyrepo.AccountCodec, bankaccountrepo.NewBankAccountRepo()),
+		categories:    newProjection("category", historyrepo.CategoryCodec, categoryrepo.NewCategoryRepo()),
+		operations:    newProjection("operation", historyrepo.OperationCodec, ops),
+		ops:           ops,
+	}
+	if snapshots != nil {
+		s, err := snapshots.LoadSnapshot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load snapshot: %w", err)
+		}
+		if s != nil {
+			if err := l.restore(ctx, *s); err != nil {
+				return nil, fmt.Errorf("restore snapshot %d: %w", s.Seq, err)
+			}
+		}
+	}
+	events, err := store.Load(ctx, l.seq)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if err := l.apply(ctx, e); err != nil {
+			return nil, fmt.Errorf("replay event %d (%s): %w", e.Seq, e.Type, err)
+		}
+		l.seq = e.Seq
+	}
+	l.sinceSnapshot = len(events)
+	if l.snapshotDue() {
+		if err := l.snapshot(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *Ledger) restore(ctx context.Context, s Snapshot) error {
+	for _, part := range []struct {
+		p    *projection
+		rows []json.RawMessage
+	}{{l.accounts, s.Accounts}, {l.categories, s.Categories}, {l.operations, s.Operations}} {
+		for _, raw := range part.rows {
+			if err := part.p.put(ctx, raw); err != nil {
+				return err
+			}
+		}
+	}
+	l.seq = s.Seq
+	return nil
+}
+
+func (l *Ledger) Accounts() repository.ICommonRepo   { return &entityRepo{l: l, p: l.accounts} }
+func (l *Ledger) Categories() repository.ICommonRepo { return &entityRepo{l: l, p: l.categories} }
+func (l *Ledger) Operations() *OperationRepo {
+	return &OperationRepo{entityRepo: &entityRepo{l: l, p: l.operations}}
+}
+
+// Номер последнего применённого события.
+func (l *Ledger) Seq() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.seq
+}
+
+// История одной сущности — как счёт пришёл к текущему состоянию.
+func (l *Ledger) Stream(ctx context.Context, id service.ObjectID) ([]Event, error) {
+	return l.store.Stream(ctx, id.String())
+}
+
+func (l *Ledger) Snapshot(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshot(ctx)
+}
+
+func (l *Ledger) snapshot(ctx context.Context) error {
+	if l.snapshots == nil {
+		return errors.New("snapshot store is not configured")
+	}
+	s := Snapshot{Seq: l.seq, TakenAt: l.now()}
+	for _, part := range []struct {
+		p   *projection
+		dst *[]json.RawMessage
+	}{{l.accounts, &s.Accounts}, {l.categories, &s.Categories}, {l.operations, &s.Operations}} {
+		for _, raw := range part.p.state {
+			*part.dst = append(*part.dst, raw)
+		}
+	}
+	if err := l.snapshots.SaveSnapshot(ctx, s); err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	l.sinceSnapshot = 0
+	return nil
+}
+
+func (l *Ledger) snapshotDue() bool {
+	return l.snapshots != nil && l.snapshotEvery > 0 && l.sinceSnapshot >= l.snapshotEvery
+}
+
+// Применяет события к проекциям и дописывает их в журнал; вызывается под l.mu.
+// Сначала проекции: событие, которое не применяется, в журнал не попадает (иначе журнал
+// не проиграть при следующем запуске). Проекции и запись журнала — одна единица работы:
+// не записался журнал — проекции откатываются.
+func (l *Ledger) write(ctx context.Context, owner service.ObjectID, events []Event) error {
+	now := l.now()
+	for i := range events {
+		events[i].RecordedAt = now
+		events[i].OwnerID = owner.String()
+	}
+	err := repository.Atomic(ctx, func(ctx context.Context) error {
+		for _, e := range events {
+			if err := l.apply(ctx, e); err != nil {
+				return fmt.Errorf("apply event %s: %w", e.Type, err)
+			}
+		}
+		if err := l.store.Append(ctx, events); err != nil {
+			return err
+		}
+		seq, since := l.seq, l.sinceSnapshot
+		repository.OnRollback(ctx, func() { l.seq, l.sinceSnapshot = seq, since })
+		l.seq = events[len(events)-1].Seq
+		l.sinceSnapshot += len(events)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// внутри чужой единицы работы проекции ещё могут откатиться — снимок подождёт
+	if _, nested := repository.UnitFrom(ctx); !nested && l.snapshotDue() {
+		// события уже записаны; неудачный снимок повторится после следующей записи
+		_ = l.snapshot(ctx)
+	}
+	return nil
+}
+
+// Применяет событие к проекциям; номер последнего события ведут вызывающие.
+func (l *Ledger) apply(ctx context.Context, e Event) error {
+	id, err := uuid.Parse(e.StreamID)
+	if err != nil {
+		return fmt.Errorf("invalid stream id '%s'", e.StreamID)
+	}
+	oid := service.ObjectID(id)
+	switch e.Type {
+	case AccountOpened:
+		err = l.accounts.put(ctx, e.Data)
+	case CategoryCreated:
+		err = l.categories.put(ctx, e.Data)
+	case OperationRecorded, OperationAmended:
+		err = l.operations.put(ctx, e.Data)
+
+	case AccountRenamed:
+		var d renamedData
+		if err = json.Unmarshal(e.Data, &d); err == nil {
+			err = l.accounts.mutate(ctx, oid, func(obj service.ICommonObject) error {
+				obj.(*bankaccount.BankAccount).SetName(d.Name)
+				return nil
+			})
+		}
+	case AccountBalanceChanged:
+		var d balanceChangedData
+		if err = json.Unmarshal(e.Data, &d); err == nil {
+			err = l.accounts.mutate(ctx, oid, func(obj service.ICommonObject) error {
+				return obj.(*bankaccount.BankAccount).SetBalance(d.To)
+			})
+		}
+	case AccountArchived, CategoryArchived:
+		var d archivedData
+		if err = json.Unmarshal(e.Data, &d); err == nil {
+			err = l.projectionOf(e.Type).mutate(ctx, oid, func(obj service.ICommonObject) error {
+				obj.(interface{ Archive(time.Time) }).Archive(d.At)
+				return nil
+			})
+		}
+	case AccountRestored, CategoryRestored:
+		err = l.projectionOf(e.Type).mutate(ctx, oid, func(obj service.ICommonObject) error {
+			obj.(interface{ Restore() }).Restore()
+			return nil
+		})
+	case CategoryRenamed:
+		var d renamedData
+		if err = json.Unmarshal(e.Data, &d); err == nil {
+			err = l.categories.mutate(ctx, oid, func(obj service.ICommonObject) error {
+				obj.(*category.Category).SetName(d.Name)
+				return nil
+			})
+		}
+	case CategoryMoved:
+		var d movedData
+		if err = json.Unmarshal(e.Data, &d); err == nil {
+			err = l.categories.mutate(ctx, oid, func(obj service.ICommonObject) error {
+				var parent uuid.UUID
+				if d.ParentID != "" {
+					var perr error
+					if parent, perr = uuid.Parse(d.ParentID); perr != nil {
+						return perr
+					}
+				}
+				return obj.(*category.Category).SetParentID(service.ObjectID(parent))
+			})
+		}
+
+	case AccountDeleted, CategoryDeleted, OperationDeleted:
+		err = l.projectionOf(e.Type).remove(ctx, oid)
+	default:
+		err = fmt.Errorf("unknown event type %q", e.Type)
+	}
+	return err
+}
+
+func (l *Ledger) projectionOf(t EventType) *projection {
+	switch {
+	case strings.HasPrefix(string(t), "Account"):
+		return l.accounts
+	case strings.HasPrefix(string(t), "Category"):
+		return l.categories
+	default:
+		return l.operations
+	}
+}
+
+// События, переводящие сущность из old в obj.
+func (l *Ledger) diff(p *projection, old, obj service.ICommonObject) ([]Event, error) {
+	id := obj.ID().String()
+	switch p {
+	case l.accounts:
+		was, now := old.(bankaccount.IBankAccount), obj.(bankaccount.IBankAccount)
+		var events []Event
+		if was.Name() != now.Name() {
+			events = append(events, newEvent(AccountRenamed, id, renamedData{Name: now.Name()}))
+		}
+		if was.Balance() != now.Balance() {
+			events = append(events, newEvent(AccountBalanceChanged, id, balanceChangedData{From: was.Balance(), To: now.Balance()}))
+		}
+		events = append(events, archiveEvents(AccountArchived, AccountRestored, id, was, now)...)
+		return events, nil
+	case l.categories:
+		was, now := old.(category.ICategory), obj.(category.ICategory)
+		if was.Type() != now.Type() {
+			return nil, service.Invariant("category type cannot be changed")
+		}
+		var events []Event
+		if was.Name() != now.Name() {
+			events = append(events, newEvent(CategoryRenamed, id, renamedData{Name: now.Name()}))
+		}
+		if was.ParentID() != now.ParentID() {
+			var d movedData
+			if now.ParentID() != (service.ObjectID{}) {
+				d.ParentID = now.ParentID().String()
+			}
+			events = append(events, newEvent(CategoryMoved, id, d))
+		}
+		events = append(events, archiveEvents(CategoryArchived, CategoryRestored, id, was, now)...)
+		return events, nil
+	default:
+		raw, err := p.codec.Encode(obj)
+		if err != nil {
+			return nil, err
+		}
+		return []Event{{Type: OperationAmended, StreamID: id, Data: raw}}, nil
+	}
+}
+
+func archiveEvents(archived, restored EventType, id string, was, now service.IArchivable) []Event {
+	switch {
+	case now.IsArchived() && !was.DeletedAt().Equal(now.DeletedAt()):
+		return []Event{newEvent(archived, id, archivedData{At: now.DeletedAt()})}
+	case was.IsArchived() && !now.IsArchived():
+		return []Event{newEvent(restored, id, nil)}
+	}
+	return nil
+}
+
+func newEvent(t EventType, streamID string, data any) Event {
+	e := Event{Type: t, StreamID: streamID}
+	if data != nil {
+		e.Data, _ = json.Marshal(data)
+	}
+	return e
+}
+
+// ICommonRepo поверх проекции: Save/Update/Delete пишут события. Чужую строку, как и хранилища
+// в памяти и в Postgres, Update и Delete не трогают: для них её нет.
+type entityRepo struct {
+	l *Ledger
+	p *projection
+}
+
+func (r *entityRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.p.repo.ByID(ctx, id)
+}
+
+func (r *entityRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.p.repo.All(ctx)
+}
+
+func (r *entityRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	raw, err := r.p.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+	if _, ok := r.p.state[obj.ID()]; ok {
+		return service.Conflict("%s already saved", r.p.name)
+	}
+	var t EventType
+	switch r.p {
+	case r.l.accounts:
+		t = AccountOpened
+	case r.l.categories:
+		t = CategoryCreated
+	default:
+		t = OperationRecorded
+	}
+	return r.l.write(ctx, ownerOf(obj), []Event{{Type: t, StreamID: obj.ID().String(), Data: raw}})
+}
+
+func (r *entityRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	raw, err := r.p.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+	old, err := r.p.current(obj.ID())
+	if err != nil {
+		return err
+	}
+	if !service.WritableTo(ctx, old) {
+		return service.NotFound("%s not found", r.p.name)
+	}
+	if bytes.Equal(r.p.state[obj.ID()], raw) {
+		return nil
+	}
+	events, err := r.l.diff(r.p, old, obj)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	return r.l.write(ctx, ownerOf(old), events)
+}
+
+func (r *entityRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+	old, err := r.p.current(id)
+	if err != nil {
+		return err
+	}
+	if !service.WritableTo(ctx, old) {
+		return service.NotFound("%s not found", r.p.name)
+	}
+	var t EventType
+	switch r.p {
+	case r.l.accounts:
+		t = AccountDeleted
+	case r.l.categories:
+		t = CategoryDeleted
+	default:
+		t = OperationDeleted
+	}
+	return r.l.write(ctx, ownerOf(old), []Event{{Type: t, StreamID: id.String()}})
+}
+
+// Владелец сущности, к которой относятся события; строки без владельца — пользователя по умолчанию.
+func ownerOf(obj service.ICommonObject) service.ObjectID {
+	if id, ok := service.OwnerOf(obj); ok && id != (service.ObjectID{}) {
+		return id
+	}
+	return service.LegacyOwnerID
+}
@@ -0,0 +1,38 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Операции журнала: выборки и агрегаты считаются по проекции в памяти.
+type OperationRepo struct {
+	*entityRepo
+}
+
+func (r *OperationRepo) SliceByAccountAndPeriod(ctx context.Context, id service.ObjectID, from time.Time, to time.Time) ([]service.ICommonObject, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.l.ops.SliceByAccountAndPeriod(ctx, id, from, to)
+}
+
+func (r *OperationRepo) Query(ctx context.Context, q operationrepo.Query) (operationrepo.Page, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.l.ops.Query(ctx, q)
+}
+
+func (r *OperationRepo) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.l.ops.ByTags(ctx, tags)
+}
+
+func (r *OperationRepo) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	r.l.mu.RLock()
+	defer r.l.mu.RUnlock()
+	return r.l.ops.TimeSeries(ctx, accountID, from, to, g, loc)
+}
@@ -0,0 +1,66 @@
+package periods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	periodrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/PeriodRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	period "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Period"
+)
+
+var ErrClosed = service.Invariant("accounting period is closed")
+
+// Запрет записи операций с датой в закрытом периоде.
+type Locks struct {
+	repo repository.ICommonRepo
+}
+
+func NewLocks(repo repository.ICommonRepo) *Locks {
+	return &Locks{repo: repo}
+}
+
+func (l *Locks) Locked(ctx context.Context, op operation.IOperation) error {
+	p, err := ByMonth(service.AsOwnerOf(ctx, op), l.repo, op.Date())
+	if err != nil {
+		return err
+	}
+	if p != nil && p.IsClosed() {
+		return fmt.Errorf("%w: %s", ErrClosed, p.Month().Format("2006-01"))
+	}
+	return nil
+}
+
+// Период, в который попадает момент t; nil, если по этому месяцу ещё ничего не делали.
+// Проверяется при каждой записи операции, поэтому хранилище с выборкой по месяцу не перебирается целиком.
+func ByMonth(ctx context.Context, repo repository.ICommonRepo, t time.Time) (period.IPeriod, error) {
+	month := period.MonthStart(t)
+	if r, ok := repo.(periodrepo.IMonthRepo); ok {
+		obj, err := r.ByMonth(ctx, month)
+		if errors.Is(err, service.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, ok := obj.(period.IPeriod)
+		if !ok {
+			return nil, service.Invariant("expected IPeriod")
+		}
+		return p, nil
+	}
+	objs, err := repo.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if p, ok := obj.(period.IPeriod); ok && p.Month().Equal(month) {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
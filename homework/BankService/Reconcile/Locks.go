@@ -0,0 +1,35 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+var ErrLocked = service.Invariant("operation belongs to a reconciled period")
+
+// Запрет правок в сверенных периодах: операция закрыта, если её дата не позже
+// даты какой-либо сверки счёта или она отмечена в сверке.
+type Locks struct {
+	repo repository.ICommonRepo
+}
+
+func NewLocks(repo repository.ICommonRepo) *Locks {
+	return &Locks{repo: repo}
+}
+
+func (l *Locks) Locked(ctx context.Context, op operation.IOperation) error {
+	recs, err := ForAccount(service.AsOwnerOf(ctx, op), l.repo, op.BankAccountID())
+	if err != nil {
+		return err
+	}
+	for _, r := range recs {
+		if !op.Date().After(r.StatementDate()) || r.HasItem(op.ID()) {
+			return fmt.Errorf("%w (reconciled on %s)", ErrLocked, r.StatementDate().Format("2006-01-02"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package reconcile
+
+import (
+	"sort"
+	"time"
+
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Банк может провести операцию на пару дней позже, чем она записана у нас.
+const MatchWindow = 3 * 24 * time.Hour
+
+type Pair struct {
+	Line      StatementLine
+	Operation operation.IOperation
+}
+
+type MatchResult struct {
+	Matched []Pair
+	// Есть в выписке, но нет в учёте.
+	MissingInBooks []StatementLine
+	// Есть в учёте, но нет в выписке.
+	ExtraInBooks []operation.IOperation
+}
+
+// Строка выписки сопоставляется с операцией той же суммы в пределах MatchWindow,
+// из нескольких подходящих берётся ближайшая по дате. Каждая операция используется один раз.
+func Match(lines []StatementLine, ops []operation.IOperation) MatchResult {
+	lines = append([]StatementLine(nil), lines...)
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Date.Before(lines[j].Date) })
+	used := make([]bool, len(ops))
+	var res MatchResult
+	for _, l := range lines {
+		best := -1
+		var bestGap time.Duration
+		for i, op := range ops {
+			if used[i] || cents(operation.SignedAmount(op)) != cents(l.Amount) {
+				continue
+			}
+			gap := op.Date().Sub(l.Date).Abs()
+			if gap > MatchWindow {
+				continue
+			}
+			if best < 0 || gap < bestGap {
+				best, bestGap = i, gap
+			}
+		}
+		if best < 0 {
+			res.MissingInBooks = append(res.MissingInBooks, l)
+			continue
+		}
+		used[best] = true
+		res.Matched = append(res.Matched, Pair{Line: l, Operation: ops[best]})
+	}
+	for i, op := range ops {
+		if !used[i] {
+			res.ExtraInBooks = append(res.ExtraInBooks, op)
+		}
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+package reconcile
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	reconciliationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ReconciliationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	reconciliation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Reconciliation"
+)
+
+// Незавершённая сверка. Отмеченные операции считаются прошедшими по банку;
+// сверку можно закрыть, когда остаток на начало плюс отмеченные операции равен остатку по выписке.
+type Session struct {
+	AccountID        service.ObjectID
+	Date             time.Time
+	StatementBalance float64
+	// Остаток по прошлой сверке или, если её не было, остаток до первой операции.
+	StartBalance float64
+	// Остаток по учёту на дату выписки: все операции по эту дату, отмеченные или нет.
+	LedgerBalance float64
+	// Операции по дату выписки, не вошедшие в прошлые сверки.
+	Candidates []operation.IOperation
+	Result     MatchResult
+	marked     map[service.ObjectID]bool
+}
+
+// Строки выписки позже даты сверки не учитываются; совпавшие с выпиской операции сразу отмечены.
+func NewSession(
+	accountID service.ObjectID,
+	date time.Time,
+	statementBalance, startBalance, ledgerBalance float64,
+	candidates []operation.IOperation,
+	lines []StatementLine,
+) *Session {
+	candidates = append([]operation.IOperation(nil), candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Date().Before(candidates[j].Date()) })
+	var inPeriod []StatementLine
+	for _, l := range lines {
+		if !l.Date.After(date) {
+			inPeriod = append(inPeriod, l)
+		}
+	}
+	s := &Session{
+		AccountID:        accountID,
+		Date:             date,
+		StatementBalance: statementBalance,
+		StartBalance:     startBalance,
+		LedgerBalance:    ledgerBalance,
+		Candidates:       candidates,
+		marked:           make(map[service.ObjectID]bool),
+	}
+	if len(inPeriod) > 0 {
+		s.Result = Match(inPeriod, candidates)
+		for _, p := range s.Result.Matched {
+			s.marked[p.Operation.ID()] = true
+		}
+	}
+	return s
+}
+
+func (s *Session) Mark(id service.ObjectID) error {
+	for _, op := range s.Candidates {
+		if op.ID() == id {
+			s.marked[id] = true
+			return nil
+		}
+	}
+	return service.Conflict("operation %s is not open for reconciliation", id)
+}
+
+func (s *Session) Unmark(id service.ObjectID) { delete(s.marked, id) }
+
+func (s *Session) IsMarked(id service.ObjectID) bool { return s.marked[id] }
+
+func (s *Session) Marked() []service.ObjectID {
+	out := make([]service.ObjectID, 0, len(s.marked))
+	for _, op := range s.Candidates {
+		if s.marked[op.ID()] {
+			out = append(out, op.ID())
+		}
+	}
+	return out
+}
+
+func (s *Session) Cleared() float64 {
+	total := cents(s.StartBalance)
+	for _, op := range s.Candidates {
+		if s.marked[op.ID()] {
+			total += cents(operation.SignedAmount(op))
+		}
+	}
+	return float64(total) / 100
+}
+
+func (s *Session) Difference() float64 {
+	return float64(cents(s.StatementBalance)-cents(s.Cleared())) / 100
+}
+
+func (s *Session) Balanced() bool { return cents(s.StatementBalance) == cents(s.Cleared()) }
+
+func (s *Session) Finish() (*reconciliation.Reconciliation, error) {
+	if !s.Balanced() {
+		return nil, service.Invariant("reconciliation is off by %.2f", s.Difference())
+	}
+	return reconciliation.NewReconciliation(s.AccountID, s.Date, s.StatementBalance, s.LedgerBalance, s.Marked())
+}
+
+// Сверки счёта по возрастанию даты выписки.
+func ForAccount(ctx context.Context, repo repository.ICommonRepo, accountID service.ObjectID) ([]reconciliation.IReconciliation, error) {
+	var objs []service.ICommonObject
+	var err error
+	if r, ok := repo.(reconciliationrepo.IAccountRepo); ok {
+		objs, err = r.ByAccount(ctx, accountID)
+	} else {
+		objs, err = repo.All(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []reconciliation.IReconciliation
+	for _, obj := range objs {
+		if r, ok := obj.(reconciliation.IReconciliation); ok && r.AccountID() == accountID {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StatementDate().Before(out[j].StatementDate()) })
+	return out, nil
+}
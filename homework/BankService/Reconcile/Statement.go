@@ -0,0 +1,82 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Строка банковской выписки: приход положительный, расход отрицательный.
+type StatementLine struct {
+	Date        time.Time
+	Amount      float64
+	Description string
+}
+
+type Statement struct {
+	Lines []StatementLine
+	// Остаток из последней строки, если в выписке есть колонка balance.
+	Balance    float64
+	HasBalance bool
+}
+
+// CSV с заголовком: date,amount,description[,balance]. Дата в RFC3339 или YYYY-MM-DD.
+func ParseStatementCSV(r io.Reader) (Statement, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return Statement{}, err
+	}
+	var (
+		st   Statement
+		errs []string
+	)
+	for i, rec := range records {
+		if i == 0 { // header
+			continue
+		}
+		if len(rec) < 3 {
+			errs = append(errs, fmt.Sprintf("row %d: expected at least 3 columns, got %d", i, len(rec)))
+			continue
+		}
+		date, err := parseDate(strings.TrimSpace(rec[0]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: invalid date '%s'", i, rec[0]))
+			continue
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: invalid amount '%s'", i, rec[1]))
+			continue
+		}
+		st.Lines = append(st.Lines, StatementLine{Date: date, Amount: amount, Description: rec[2]})
+		if len(rec) > 3 && strings.TrimSpace(rec[3]) != "" {
+			bal, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: invalid balance '%s'", i, rec[3]))
+				continue
+			}
+			st.Balance, st.HasBalance = bal, true
+		}
+	}
+	if len(errs) > 0 {
+		return Statement{}, errors.New(strings.Join(errs, "; "))
+	}
+	return st, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// Суммы сравниваются в копейках, чтобы не зависеть от погрешности float64.
+func cents(v float64) int64 { return int64(math.Round(v * 100)) }
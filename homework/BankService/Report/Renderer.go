@@ -0,0 +1,125 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	texttemplate "text/template"
+	"time"
+
+	exporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+const (
+	chartBarMaxWidth = 300.0
+	chartBarHeight   = 18
+	chartLabelWidth  = 160
+)
+
+var templateFuncs = map[string]any{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"pct":   func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+	"month": func(t time.Time) string { return t.Format("2006-01") },
+	"isIncome": func(t operation.OperationType) bool {
+		return t == operation.Income
+	},
+	"spending": func(shares []CategoryShare) []CategoryShare {
+		var out []CategoryShare
+		for _, s := range shares {
+			if s.Type != operation.Income {
+				out = append(out, s)
+			}
+		}
+		return out
+	},
+	"barWidth": func(pct float64) float64 { return pct / 100 * chartBarMaxWidth },
+	"barY":     func(i int) int { return i * (chartBarHeight + 6) },
+	"chartHeight": func(shares []CategoryShare) int {
+		return len(shares)*(chartBarHeight+6) + 6
+	},
+	"chartWidth":  func() float64 { return chartLabelWidth + chartBarMaxWidth + 70 },
+	"labelWidth":  func() int { return chartLabelWidth },
+	"barHeight":   func() int { return chartBarHeight },
+	"add":         func(a, b float64) float64 { return a + b },
+	"addInt":      func(a, b int) int { return a + b },
+	"labelOffset": func() float64 { return chartLabelWidth + 6 },
+}
+
+func loadTemplate(defaultName, templatePath string) (string, error) {
+	if templatePath != "" {
+		b, err := os.ReadFile(templatePath)
+		if err != nil {
+			return "", fmt.Errorf("read template: %w", err)
+		}
+		return string(b), nil
+	}
+	b, err := defaultTemplates.ReadFile("templates/" + defaultName)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+type htmlStatementFormatter struct {
+	templatePath string
+}
+
+func (f *htmlStatementFormatter) FormatData(data interface{}) ([]byte, error) {
+	st, ok := data.(*Statement)
+	if !ok {
+		return nil, fmt.Errorf("invalid data type: expected *report.Statement")
+	}
+	src, err := loadTemplate("statement.html.tmpl", f.templatePath)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := htmltemplate.New("statement").Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, st); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type markdownStatementFormatter struct {
+	templatePath string
+}
+
+func (f *markdownStatementFormatter) FormatData(data interface{}) ([]byte, error) {
+	st, ok := data.(*Statement)
+	if !ok {
+		return nil, fmt.Errorf("invalid data type: expected *report.Statement")
+	}
+	src, err := loadTemplate("statement.md.tmpl", f.templatePath)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := texttemplate.New("statement").Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, st); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templatePath пустой — используется встроенный шаблон.
+func NewHTMLStatementExporter(filepath, templatePath string) *exporter.BaseExporter {
+	return exporter.NewExporter(filepath, &htmlStatementFormatter{templatePath: templatePath})
+}
+
+func NewMarkdownStatementExporter(filepath, templatePath string) *exporter.BaseExporter {
+	return exporter.NewExporter(filepath, &markdownStatementFormatter{templatePath: templatePath})
+}
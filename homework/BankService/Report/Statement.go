@@ -0,0 +1,130 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type StatementLine struct {
+	ID           service.ObjectID
+	Date         time.Time
+	Type         operation.OperationType
+	CategoryName string
+	Description  string
+	Amount       float64 // со знаком: расход отрицательный
+	Balance      float64
+}
+
+type CategoryShare struct {
+	Name    string
+	Type    operation.OperationType
+	Amount  float64
+	Percent float64 // доля внутри своего типа (расходы или доходы)
+}
+
+type Statement struct {
+	AccountID      service.ObjectID
+	AccountName    string
+	From           time.Time
+	To             time.Time
+	OpeningBalance float64
+	ClosingBalance float64
+	TotalIncome    float64
+	TotalExpense   float64
+	Lines          []StatementLine
+	Breakdown      []CategoryShare
+}
+
+// Баланс счёта — начальный остаток до первой операции, поэтому остаток на начало периода
+// получаем прибавлением операций, совершённых до него (operation.BalanceAt).
+func BuildStatement(
+	acc bankaccount.IBankAccount,
+	from, to time.Time,
+	ops []operation.IOperation,
+	earlier []operation.IOperation,
+	categoryNames map[service.ObjectID]string,
+) *Statement {
+	st := &Statement{
+		AccountID:   acc.ID(),
+		AccountName: acc.Name(),
+		From:        from,
+		To:          to,
+	}
+
+	opening := operation.CurrentBalance(acc.Balance(), earlier)
+
+	sorted := make([]operation.IOperation, len(ops))
+	copy(sorted, ops)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date().Before(sorted[j].Date()) })
+
+	st.OpeningBalance = opening
+	st.ClosingBalance = operation.CurrentBalance(opening, sorted)
+
+	type shareKey struct {
+		name  string
+		otype operation.OperationType
+	}
+	shares := make(map[shareKey]float64)
+	running := st.OpeningBalance
+	for _, op := range sorted {
+		name, ok := categoryNames[op.CategoryID()]
+		if !ok {
+			name = op.CategoryID().String()
+		}
+		running += operation.SignedAmount(op)
+		st.Lines = append(st.Lines, StatementLine{
+			ID:           op.ID(),
+			Date:         op.Date(),
+			Type:         op.Type(),
+			CategoryName: name,
+			Description:  op.Description(),
+			Amount:       operation.SignedAmount(op),
+			Balance:      running,
+		})
+		if op.Type() == operation.Income {
+			st.TotalIncome += op.Amount()
+		} else {
+			st.TotalExpense += op.Amount()
+		}
+		for catID, amount := range operation.CategoryAmounts(op) {
+			lineName, ok := categoryNames[catID]
+			if !ok {
+				lineName = catID.String()
+			}
+			shares[shareKey{name: lineName, otype: op.Type()}] += amount
+		}
+	}
+
+	for k, v := range shares {
+		total := st.TotalExpense
+		if k.otype == operation.Income {
+			total = st.TotalIncome
+		}
+		var pct float64
+		if total > 0 {
+			pct = v / total * 100
+		}
+		st.Breakdown = append(st.Breakdown, CategoryShare{Name: k.name, Type: k.otype, Amount: v, Percent: pct})
+	}
+	sort.Slice(st.Breakdown, func(i, j int) bool {
+		a, b := st.Breakdown[i], st.Breakdown[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Name < b.Name
+	})
+	return st
+}
+
+func MonthBounds(year int, month time.Month, loc *time.Location) (time.Time, time.Time) {
+	from := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	return from, to
+}
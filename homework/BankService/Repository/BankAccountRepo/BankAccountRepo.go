@@ -2,8 +2,8 @@ package bankaccountrepo
 
 import (
 	"context"
-	"errors"
 
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
 )
@@ -26,36 +26,50 @@ func NewCopyBankAccountRepo(repo map[service.ObjectID]*bankaccount.BankAccount)
 
 func (r *BankAccountRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
 	acc, ok := r.repo[id]
-	if !ok {
-		return nil, errors.New("account not found")
+	if !ok || !service.VisibleTo(ctx, acc) {
+		return nil, service.NotFound("account not found")
 	}
-	return acc, nil
+	return service.CopyOf(acc), nil
 }
 
 func (r *BankAccountRepo) Save(ctx context.Context, acc service.ICommonObject) error {
 	if _, ok := r.repo[acc.ID()]; ok {
-		return errors.New("account already saved")
+		return service.Conflict("account already saved")
+	}
+	i, ok := acc.(*bankaccount.BankAccount)
+	if !ok {
+		return service.Invariant("invalid account type")
+	}
+	repository.Put(ctx, r.repo, acc.ID(), service.CopyOf(i).(*bankaccount.BankAccount))
+	return nil
+}
+
+func (r *BankAccountRepo) Update(ctx context.Context, acc service.ICommonObject) error {
+	if cur, ok := r.repo[acc.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("account not found")
 	}
 	i, ok := acc.(*bankaccount.BankAccount)
 	if !ok {
-		return errors.New("invalid account type")
+		return service.Invariant("invalid account type")
 	}
-	r.repo[acc.ID()] = i
+	repository.Put(ctx, r.repo, acc.ID(), service.CopyOf(i).(*bankaccount.BankAccount))
 	return nil
 }
 
 func (r *BankAccountRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
 	accs := make([]service.ICommonObject, 0, len(r.repo))
 	for _, acc := range r.repo {
-		accs = append(accs, acc)
+		if service.VisibleTo(ctx, acc) {
+			accs = append(accs, service.CopyOf(acc))
+		}
 	}
 	return accs, nil
 }
 
 func (r *BankAccountRepo) Delete(ctx context.Context, id service.ObjectID) error {
-	if _, ok := r.repo[id]; !ok {
-		return errors.New("account not found")
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("account not found")
 	}
-	delete(r.repo, id)
+	repository.Remove(ctx, r.repo, id)
 	return nil
 }
@@ -2,8 +2,8 @@ package categoryrepo
 
 import (
 	"context"
-	"errors"
 
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
 )
@@ -26,36 +26,50 @@ func NewCopyCategoryRepo(repo map[service.ObjectID]*category.Category) *Category
 
 func (r *CategoryRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
 	cat, ok := r.repo[id]
-	if !ok {
-		return nil, errors.New("category not found")
+	if !ok || !service.VisibleTo(ctx, cat) {
+		return nil, service.NotFound("category not found")
 	}
-	return cat, nil
+	return service.CopyOf(cat), nil
 }
 
 func (r *CategoryRepo) Save(ctx context.Context, cat service.ICommonObject) error {
 	if _, ok := r.repo[cat.ID()]; ok {
-		return errors.New("category already saved")
+		return service.Conflict("category already saved")
+	}
+	i, ok := cat.(*category.Category)
+	if !ok {
+		return service.Invariant("invalid category type")
+	}
+	repository.Put(ctx, r.repo, cat.ID(), service.CopyOf(i).(*category.Category))
+	return nil
+}
+
+func (r *CategoryRepo) Update(ctx context.Context, cat service.ICommonObject) error {
+	if cur, ok := r.repo[cat.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("category not found")
 	}
 	i, ok := cat.(*category.Category)
 	if !ok {
-		return errors.New("invalid category type")
+		return service.Invariant("invalid category type")
 	}
-	r.repo[cat.ID()] = i
+	repository.Put(ctx, r.repo, cat.ID(), service.CopyOf(i).(*category.Category))
 	return nil
 }
 
 func (r *CategoryRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
 	cats := make([]service.ICommonObject, 0, len(r.repo))
 	for _, cat := range r.repo {
-		cats = append(cats, cat)
+		if service.VisibleTo(ctx, cat) {
+			cats = append(cats, service.CopyOf(cat))
+		}
 	}
 	return cats, nil
 }
 
 func (r *CategoryRepo) Delete(ctx context.Context, id service.ObjectID) error {
-	if _, ok := r.repo[id]; !ok {
-		return errors.New("category not found")
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("category not found")
 	}
-	delete(r.repo, id)
+	repository.Remove(ctx, r.repo, id)
 	return nil
 }
@@ -2,7 +2,6 @@ package dbrepo
 
 import (
 	"database/sql"
-	"errors"
 
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
@@ -11,29 +10,34 @@ import (
 func NewBankAccountDBRepo(db *sql.DB) *CommonDBRepo {
 	m := entityMapper{
 		table:     "bank_accounts",
-		byIDQuery: `SELECT id, name, balance FROM bank_accounts WHERE id = $1`,
-		allQuery:  `SELECT id, name, balance FROM bank_accounts`,
-		insertSQL: `INSERT INTO bank_accounts(id,name,balance) VALUES($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name, balance=EXCLUDED.balance`,
-		deleteSQL: `DELETE FROM bank_accounts WHERE id = $1`,
+		byIDQuery: `SELECT id, name, balance, deleted_at, owner_id FROM bank_accounts WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2 OR id IN (SELECT account_id FROM account_shares WHERE user_id = $2))`,
+		allQuery:  `SELECT id, name, balance, deleted_at, owner_id FROM bank_accounts WHERE ($1::text IS NULL OR owner_id = $1 OR id IN (SELECT account_id FROM account_shares WHERE user_id = $1))`,
+		insertSQL: `INSERT INTO bank_accounts(id,name,balance,deleted_at,owner_id) VALUES($1,$2,$3,$4,$5)`,
+		updateSQL: `UPDATE bank_accounts SET name = $2, balance = $3, deleted_at = $4 WHERE id = $1 AND owner_id = $5`,
+		deleteSQL: `DELETE FROM bank_accounts WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
 		scanOne: func(s scanner) (service.ICommonObject, error) {
 			var id service.ObjectID
 			var name string
 			var balance float64
-			if err := s.Scan(&id, &name, &balance); err != nil {
+			var deletedAt sql.NullTime
+			if err := s.Scan(&id, &name, &balance, &deletedAt); err != nil {
 				return nil, err
 			}
 			acc, err := bankaccount.NewCopyBankAccount(id, name, balance)
 			if err != nil {
 				return nil, err
 			}
+			if deletedAt.Valid {
+				acc.Archive(deletedAt.Time)
+			}
 			return acc, nil
 		},
 		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
 			acc, ok := obj.(bankaccount.IBankAccount)
 			if !ok {
-				return nil, errors.New("expected IBankAccount")
+				return nil, service.Invariant("expected IBankAccount")
 			}
-			return []any{acc.ID(), acc.Name(), acc.Balance()}, nil
+			return []any{acc.ID(), acc.Name(), acc.Balance(), nullTime(acc.DeletedAt())}, nil
 		},
 	}
 	return NewCommonDBRepo(db, m)
@@ -2,7 +2,6 @@ package dbrepo
 
 import (
 	"database/sql"
-	"errors"
 
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
@@ -11,29 +10,45 @@ import (
 func NewCategoryDBRepo(db *sql.DB) *CommonDBRepo {
 	m := entityMapper{
 		table:     "categories",
-		byIDQuery: `SELECT id, name, ctype FROM categories WHERE id = $1`,
-		allQuery:  `SELECT id, name, ctype FROM categories`,
-		insertSQL: `INSERT INTO categories(id,name,ctype) VALUES($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name, ctype=EXCLUDED.ctype`,
-		deleteSQL: `DELETE FROM categories WHERE id = $1`,
+		byIDQuery: `SELECT id, name, ctype, parent_id, deleted_at, owner_id FROM categories WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2 OR owner_id IN (SELECT owner_id FROM account_shares WHERE user_id = $2))`,
+		allQuery:  `SELECT id, name, ctype, parent_id, deleted_at, owner_id FROM categories WHERE ($1::text IS NULL OR owner_id = $1 OR owner_id IN (SELECT owner_id FROM account_shares WHERE user_id = $1))`,
+		insertSQL: `INSERT INTO categories(id,name,ctype,parent_id,deleted_at,owner_id) VALUES($1,$2,$3,$4,$5,$6)`,
+		updateSQL: `UPDATE categories SET name = $2, ctype = $3, parent_id = $4, deleted_at = $5 WHERE id = $1 AND owner_id = $6`,
+		deleteSQL: `DELETE FROM categories WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
 		scanOne: func(s scanner) (service.ICommonObject, error) {
 			var id service.ObjectID
 			var name string
 			var t int
-			if err := s.Scan(&id, &name, &t); err != nil {
+			var parent sql.NullString
+			var deletedAt sql.NullTime
+			if err := s.Scan(&id, &name, &t, &parent, &deletedAt); err != nil {
 				return nil, err
 			}
-			cat, err := category.NewCopyCategory(id, name, category.CategoryType(t))
+			var parentID service.ObjectID
+			if parent.Valid {
+				if err := parentID.Scan(parent.String); err != nil {
+					return nil, err
+				}
+			}
+			cat, err := category.NewCopyCategory(id, name, category.CategoryType(t), parentID)
 			if err != nil {
 				return nil, err
 			}
+			if deletedAt.Valid {
+				cat.Archive(deletedAt.Time)
+			}
 			return cat, nil
 		},
 		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
 			cat, ok := obj.(category.ICategory)
 			if !ok {
-				return nil, errors.New("expected ICategory")
+				return nil, service.Invariant("expected ICategory")
+			}
+			var parent any
+			if cat.ParentID() != (service.ObjectID{}) {
+				parent = cat.ParentID()
 			}
-			return []any{cat.ID(), cat.Name(), int(cat.Type())}, nil
+			return []any{cat.ID(), cat.Name(), int(cat.Type()), parent, nullTime(cat.DeletedAt())}, nil
 		},
 	}
 	return NewCommonDBRepo(db, m)
@@ -0,0 +1,100 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Номера из BIGSERIAL выдаются до коммита, поэтому транзакция с меньшим seq может стать видна
+// позже соседней. Каждый опрос заново читает последние changeLookback номеров,
+// а уже обработанные отбрасываются по seen.
+const changeLookback = 1000
+
+// Лента изменений из таблицы cache_changes, которую заполняют триггеры (см. createTables).
+type ChangeFeed struct {
+	db        *sql.DB
+	watermark int64
+	seen      map[int64]struct{}
+}
+
+// Лента начинается с текущего момента: более ранние изменения уже видны при загрузке кэша.
+func NewChangeFeed(ctx context.Context, db *sql.DB) (*ChangeFeed, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	f := &ChangeFeed{db: db, seen: make(map[int64]struct{})}
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM cache_changes`).Scan(&f.watermark); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT seq FROM cache_changes WHERE seq > $1`, f.watermark-changeLookback)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		f.seen[seq] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// старый журнал никому не нужен
+	if _, err := db.ExecContext(ctx, `DELETE FROM cache_changes WHERE changed_at < now() - interval '1 day'`); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *ChangeFeed) Poll(ctx context.Context) ([]repository.Change, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	rows, err := f.db.QueryContext(ctx,
+		`SELECT seq, table_name, row_id, op
+           FROM cache_changes
+          WHERE seq > $1
+          ORDER BY seq`,
+		f.watermark-changeLookback,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []repository.Change
+	for rows.Next() {
+		var (
+			ch    repository.Change
+			rowID string
+		)
+		if err := rows.Scan(&ch.Seq, &ch.Table, &rowID, &ch.Op); err != nil {
+			return nil, err
+		}
+		if _, ok := f.seen[ch.Seq]; ok {
+			continue
+		}
+		id, err := uuid.Parse(rowID)
+		if err != nil {
+			return nil, err
+		}
+		ch.ID = service.ObjectID(id)
+		f.seen[ch.Seq] = struct{}{}
+		if ch.Seq > f.watermark {
+			f.watermark = ch.Seq
+		}
+		out = append(out, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for seq := range f.seen {
+		if seq <= f.watermark-changeLookback {
+			delete(f.seen, seq)
+		}
+	}
+	return out, nil
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"time"
 
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 )
@@ -19,12 +19,20 @@ type entityMapper struct {
 	byIDQuery string
 	allQuery  string
 	insertSQL string
+	updateSQL string // те же параметры, что у insertSQL; владелец — в условии WHERE
 	deleteSQL string
+	// Таблица без owner_id (пользователи): параметра владельца и колонки owner_id в запросах нет.
+	unowned bool
 
 	scanOne       func(s scanner) (service.ICommonObject, error)
 	argsForInsert func(obj service.ICommonObject) ([]any, error)
 }
 
+// Нулевое время пишется как NULL (например, deleted_at у активных объектов).
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 type CommonDBRepo struct {
 	db     *sql.DB
 	mapper entityMapper
@@ -34,12 +42,20 @@ func NewCommonDBRepo(db *sql.DB, m entityMapper) *CommonDBRepo {
 	return &CommonDBRepo{db: db, mapper: m}
 }
 
+// Запросы маппера принимают владельца последним параметром ($n::text IS NULL — системный доступ)
+// и возвращают owner_id последней колонкой.
 func (r *CommonDBRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
-	row := r.db.QueryRowContext(ctx, r.mapper.byIDQuery, id)
-	obj, err := r.mapper.scanOne(row)
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var obj service.ICommonObject
+	err := r.scoped(ctx, func(q querier) error {
+		var err error
+		obj, err = r.scan(q.QueryRowContext(ctx, r.mapper.byIDQuery, append([]any{id}, r.ownerArgs(ctx)...)...))
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("%s not found: %w", r.mapper.table, err)
+			return nil, service.NotFound("%s not found: %w", r.mapper.table, err)
 		}
 		return nil, err
 	}
@@ -47,7 +63,116 @@ func (r *CommonDBRepo) ByID(ctx context.Context, id service.ObjectID) (service.I
 }
 
 func (r *CommonDBRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
-	rows, err := r.db.QueryContext(ctx, r.mapper.allQuery)
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var out []service.ICommonObject
+	err := r.scoped(ctx, func(q querier) error {
+		var err error
+		out, err = r.queryAll(ctx, q, r.mapper.allQuery, r.ownerArgs(ctx)...)
+		return err
+	})
+	return out, err
+}
+
+func (r *CommonDBRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.scoped(ctx, func(q querier) error { return r.insert(ctx, q, obj) })
+}
+
+func (r *CommonDBRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.scoped(ctx, func(q querier) error { return r.update(ctx, q, obj) })
+}
+
+func (r *CommonDBRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.scoped(ctx, func(q querier) error {
+		res, err := q.ExecContext(ctx, r.mapper.deleteSQL, append([]any{id}, r.ownerArgs(ctx)...)...)
+		if err != nil {
+			return err
+		}
+		// как и в памяти: удаление отсутствующей или чужой строки — ошибка
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return service.NotFound("%s not found", r.mapper.table)
+		}
+		return nil
+	})
+}
+
+// Как и в памяти: повторная вставка того же id — конфликт, а не перезапись.
+func (r *CommonDBRepo) insert(ctx context.Context, q querier, obj service.ICommonObject) error {
+	args, err := r.writeArgs(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if _, err := q.ExecContext(ctx, r.mapper.insertSQL, args...); err != nil {
+		if isUniqueViolation(err) {
+			return service.Conflict("%s %s already saved", r.mapper.table, obj.ID())
+		}
+		return err
+	}
+	return nil
+}
+
+// Обновляется только существующая строка того же владельца; отсутствующий или чужой id — NotFound.
+func (r *CommonDBRepo) update(ctx context.Context, q querier, obj service.ICommonObject) error {
+	args, err := r.writeArgs(ctx, obj)
+	if err != nil {
+		return err
+	}
+	res, err := q.ExecContext(ctx, r.mapper.updateSQL, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return service.NotFound("%s %s not found", r.mapper.table, obj.ID())
+	}
+	return nil
+}
+
+func (r *CommonDBRepo) writeArgs(ctx context.Context, obj service.ICommonObject) ([]any, error) {
+	args, err := r.mapper.argsForInsert(obj)
+	if err != nil {
+		return nil, err
+	}
+	if !r.mapper.unowned {
+		owner, err := stampOwner(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, owner)
+	}
+	return args, nil
+}
+
+// 23505 — unique_violation; драйвер отдаёт код через SQLState.
+func isUniqueViolation(err error) bool {
+	var e interface{ SQLState() string }
+	return errors.As(err, &e) && e.SQLState() == "23505"
+}
+
+// Строки выборки по одной передаются в each; запрос идёт в транзакции с настройками RLS.
+func (r *CommonDBRepo) queryRows(ctx context.Context, each func(rows *sql.Rows) error, query string, args ...any) error {
+	return r.scoped(ctx, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := each(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+func (r *CommonDBRepo) queryAll(ctx context.Context, q querier, query string, args ...any) ([]service.ICommonObject, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +180,7 @@ func (r *CommonDBRepo) All(ctx context.Context) ([]service.ICommonObject, error)
 
 	var out []service.ICommonObject
 	for rows.Next() {
-		obj, err := r.mapper.scanOne(rows)
+		obj, err := r.scan(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -64,16 +189,29 @@ func (r *CommonDBRepo) All(ctx context.Context) ([]service.ICommonObject, error)
 	return out, rows.Err()
 }
 
-func (r *CommonDBRepo) Save(ctx context.Context, obj service.ICommonObject) error {
-	args, err := r.mapper.argsForInsert(obj)
+// scanOne читает колонки сущности, owner_id идёт после них.
+func (r *CommonDBRepo) scan(s scanner) (service.ICommonObject, error) {
+	if r.mapper.unowned {
+		return r.mapper.scanOne(s)
+	}
+	var owner sql.NullString
+	obj, err := r.mapper.scanOne(scanWithTail{s, []any{&owner}})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if o, ok := obj.(service.IOwned); ok && owner.Valid {
+		var id service.ObjectID
+		if err := id.Scan(owner.String); err != nil {
+			return nil, err
+		}
+		o.SetOwnerID(id)
 	}
-	_, err = r.db.ExecContext(ctx, r.mapper.insertSQL, args...)
-	return err
+	return obj, nil
 }
 
-func (r *CommonDBRepo) Delete(ctx context.Context, id service.ObjectID) error {
-	_, err := r.db.ExecContext(ctx, r.mapper.deleteSQL, id)
-	return err
+func (r *CommonDBRepo) ownerArgs(ctx context.Context) []any {
+	if r.mapper.unowned {
+		return nil
+	}
+	return []any{ownerArg(ctx)}
 }
@@ -0,0 +1,137 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+const versionColumns = `entity, entity_id, version, op, before, after, changed_at, owner_id`
+
+// История изменений в таблице entity_versions.
+type HistoryDBRepo struct {
+	db *sql.DB
+}
+
+func NewHistoryDBRepo(db *sql.DB) *HistoryDBRepo {
+	return &HistoryDBRepo{db: db}
+}
+
+// Номер версии считается в том же INSERT; при гонке двух записей одной сущности
+// вторая получит ошибку первичного ключа, а не дубль номера.
+// Версию пишет сама цепочка репозиториев после проверки прав, поэтому запись системная:
+// номер считается по всем версиям сущности, а владельцем ставится владелец сущности.
+func (r *HistoryDBRepo) Append(ctx context.Context, v *historyrepo.Version) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	owner := v.OwnerID
+	if owner == (service.ObjectID{}) {
+		owner = service.LegacyOwnerID
+	}
+	return inScope(service.Unscoped(ctx), r.db, func(q querier) error {
+		return q.QueryRowContext(ctx,
+			`INSERT INTO entity_versions (`+versionColumns+`)
+             SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4::jsonb, $5::jsonb, $6, $7
+               FROM entity_versions
+              WHERE entity = $1 AND entity_id = $2
+             RETURNING version`,
+			v.Entity, v.EntityID, v.Op, nullJSON(v.Before), nullJSON(v.After), v.ChangedAt, owner,
+		).Scan(&v.Version)
+	})
+}
+
+// Чтение идёт с областью пользователя из контекста: политика RLS отдаёт только версии его сущностей.
+func (r *HistoryDBRepo) History(ctx context.Context, entity string, id service.ObjectID) ([]historyrepo.Version, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var out []historyrepo.Version
+	err := inScope(ctx, r.db, func(q querier) error {
+		rows, err := q.QueryContext(ctx,
+			`SELECT `+versionColumns+`
+               FROM entity_versions
+              WHERE entity = $1 AND entity_id = $2
+              ORDER BY version`,
+			entity, id,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			v, err := scanVersion(rows)
+			if err != nil {
+				return err
+			}
+			out = append(out, v)
+		}
+		return rows.Err()
+	})
+	return out, err
+}
+
+func (r *HistoryDBRepo) AsOf(ctx context.Context, entity string, id service.ObjectID, at time.Time) (historyrepo.Version, bool, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.one(ctx,
+		`SELECT `+versionColumns+`
+           FROM entity_versions
+          WHERE entity = $1 AND entity_id = $2 AND changed_at <= $3
+          ORDER BY version DESC
+          LIMIT 1`,
+		entity, id, at,
+	)
+}
+
+func (r *HistoryDBRepo) Latest(ctx context.Context, entity string, id service.ObjectID) (historyrepo.Version, bool, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.one(ctx,
+		`SELECT `+versionColumns+`
+           FROM entity_versions
+          WHERE entity = $1 AND entity_id = $2
+          ORDER BY version DESC
+          LIMIT 1`,
+		entity, id,
+	)
+}
+
+func (r *HistoryDBRepo) one(ctx context.Context, query string, args ...any) (historyrepo.Version, bool, error) {
+	var v historyrepo.Version
+	err := inScope(ctx, r.db, func(q querier) error {
+		var err error
+		v, err = scanVersion(q.QueryRowContext(ctx, query, args...))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return historyrepo.Version{}, false, nil
+	}
+	if err != nil {
+		return historyrepo.Version{}, false, err
+	}
+	return v, true, nil
+}
+
+func scanVersion(s scanner) (historyrepo.Version, error) {
+	var (
+		v             historyrepo.Version
+		before, after []byte
+	)
+	if err := s.Scan(&v.Entity, &v.EntityID, &v.Version, &v.Op, &before, &after, &v.ChangedAt, &v.OwnerID); err != nil {
+		return v, err
+	}
+	v.Before, v.After = before, after
+	return v, nil
+}
+
+// Пустой снимок пишется как NULL.
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,148 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	ledger "github.com/ilyaytrewq/kpo-sb/homework/BankService/Ledger"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Сколько последних снимков журнала хранить.
+const keepLedgerSnapshots = 3
+
+// Журнал событий в таблице ledger_events; UPDATE и DELETE запрещены триггером.
+// У события есть владелец сущности: политика RLS отдаёт пользователю только его события.
+type LedgerEventStore struct {
+	db *sql.DB
+}
+
+func NewLedgerEventStore(db *sql.DB) *LedgerEventStore {
+	return &LedgerEventStore{db: db}
+}
+
+// Пачка событий пишется одной транзакцией (в единице работы — её транзакцией): либо все, либо ни одного.
+// Права проверены до журнала, поэтому запись системная: событие общего счёта принадлежит его владельцу.
+func (s *LedgerEventStore) Append(ctx context.Context, events []ledger.Event) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return inScope(service.Unscoped(ctx), s.db, func(q querier) error {
+		for i := range events {
+			e := &events[i]
+			if err := q.QueryRowContext(ctx,
+				`INSERT INTO ledger_events (stream_id, type, data, recorded_at, owner_id)
+                 VALUES ($1, $2, $3::jsonb, $4, COALESCE(NULLIF($5, ''), $6))
+                 RETURNING seq`,
+				e.StreamID, string(e.Type), nullJSON(e.Data), e.RecordedAt, e.OwnerID, service.LegacyOwnerID.String(),
+			).Scan(&e.Seq); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *LedgerEventStore) Load(ctx context.Context, after int64) ([]ledger.Event, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return s.query(ctx,
+		`SELECT seq, stream_id, type, data, recorded_at, owner_id
+           FROM ledger_events
+          WHERE seq > $1
+          ORDER BY seq`,
+		after,
+	)
+}
+
+func (s *LedgerEventStore) Stream(ctx context.Context, streamID string) ([]ledger.Event, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return s.query(ctx,
+		`SELECT seq, stream_id, type, data, recorded_at, owner_id
+           FROM ledger_events
+          WHERE stream_id = $1
+          ORDER BY seq`,
+		streamID,
+	)
+}
+
+func (s *LedgerEventStore) query(ctx context.Context, query string, args ...any) ([]ledger.Event, error) {
+	var out []ledger.Event
+	err := inScope(ctx, s.db, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				e     ledger.Event
+				eType string
+				data  []byte
+			)
+			if err := rows.Scan(&e.Seq, &e.StreamID, &eType, &data, &e.RecordedAt, &e.OwnerID); err != nil {
+				return err
+			}
+			e.Type, e.Data = ledger.EventType(eType), data
+			out = append(out, e)
+		}
+		return rows.Err()
+	})
+	return out, err
+}
+
+// Снимки журнала в таблице ledger_snapshots; старые удаляются, остаются последние keepLedgerSnapshots.
+// Снимок хранит проекции всех пользователей, поэтому политика RLS пускает к нему только системный доступ.
+type LedgerSnapshotStore struct {
+	db *sql.DB
+}
+
+func NewLedgerSnapshotStore(db *sql.DB) *LedgerSnapshotStore {
+	return &LedgerSnapshotStore{db: db}
+}
+
+func (s *LedgerSnapshotStore) LoadSnapshot(ctx context.Context) (*ledger.Snapshot, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var data []byte
+	err := inScope(service.Unscoped(ctx), s.db, func(q querier) error {
+		return q.QueryRowContext(ctx, `SELECT data FROM ledger_snapshots ORDER BY seq DESC LIMIT 1`).Scan(&data)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap ledger.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (s *LedgerSnapshotStore) SaveSnapshot(ctx context.Context, snap ledger.Snapshot) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return inScope(service.Unscoped(ctx), s.db, func(q querier) error {
+		if _, err := q.ExecContext(ctx,
+			`INSERT INTO ledger_snapshots (seq, data, taken_at) VALUES ($1, $2::jsonb, $3)
+             ON CONFLICT (seq) DO UPDATE SET data = EXCLUDED.data, taken_at = EXCLUDED.taken_at`,
+			snap.Seq, string(data), snap.TakenAt,
+		); err != nil {
+			return err
+		}
+		_, err := q.ExecContext(ctx,
+			`DELETE FROM ledger_snapshots
+              WHERE seq NOT IN (SELECT seq FROM ledger_snapshots ORDER BY seq DESC LIMIT $1)`,
+			keepLedgerSnapshots,
+		)
+		return err
+	})
+}
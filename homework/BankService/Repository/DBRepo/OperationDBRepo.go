@@ -3,39 +3,68 @@ package dbrepo
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
 )
 
 type OperationDBRepo struct{ *CommonDBRepo }
 
+// Метки (operation_tags) и строки разбивки (operation_splits) подтягиваются к строке операции подзапросами.
+const operationColumns = `id, op_type, account_id, amount, "timestamp", description, category_id,
+       COALESCE((SELECT string_agg(t.tag, ',' ORDER BY t.tag)
+                   FROM operation_tags t
+                  WHERE t.operation_id = operations.id), ''),
+       COALESCE((SELECT json_agg(json_build_object('category_id', s.category_id, 'amount', s.amount, 'note', s.note)
+                                 ORDER BY s.line_no)
+                   FROM operation_splits s
+                  WHERE s.operation_id = operations.id), '[]'),
+       COALESCE(created_by, ''),
+       operations.owner_id`
+
+type splitRow struct {
+	CategoryID string  `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note"`
+}
+
 func NewOperationDBRepo(db *sql.DB) *OperationDBRepo {
 	m := entityMapper{
 		table: "operations",
 
-		byIDQuery: `SELECT id, op_type, account_id, amount, "timestamp", description, category_id
+		byIDQuery: `SELECT ` + operationColumns + `
                       FROM operations
-                     WHERE id = $1`,
+                     WHERE id = $1
+                       AND ($2::text IS NULL OR owner_id = $2 OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $2))`,
 
-		allQuery: `SELECT id, op_type, account_id, amount, "timestamp", description, category_id
-                      FROM operations`,
+		allQuery: `SELECT ` + operationColumns + `
+                      FROM operations
+                     WHERE ($1::text IS NULL OR owner_id = $1 OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $1))`,
 
 		insertSQL: `INSERT INTO operations
-                        (id, op_type, account_id, amount, "timestamp", description, category_id)
-                    VALUES ($1, $2, $3, $4, $5, $6, $7)
-                    ON CONFLICT (id) DO UPDATE SET
-                        op_type     = EXCLUDED.op_type,
-                        account_id  = EXCLUDED.account_id,
-                        amount      = EXCLUDED.amount,
-                        "timestamp" = EXCLUDED."timestamp",
-                        description = EXCLUDED.description,
-                        category_id = EXCLUDED.category_id`,
-
-		deleteSQL: `DELETE FROM operations WHERE id = $1`,
+                        (id, op_type, account_id, amount, "timestamp", description, category_id, created_by, owner_id)
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)`,
+		updateSQL: `UPDATE operations SET
+                        op_type     = $2,
+                        account_id  = $3,
+                        amount      = $4,
+                        "timestamp" = $5,
+                        description = $6,
+                        category_id = $7,
+                        created_by  = COALESCE(created_by, NULLIF($8, ''))
+                    WHERE id = $1 AND owner_id = $9`,
+
+		deleteSQL: `DELETE FROM operations
+                     WHERE id = $1
+                       AND ($2::text IS NULL OR owner_id = $2
+                            OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $2 AND level = 'write'))`,
 
 		scanOne: func(s scanner) (service.ICommonObject, error) {
 			var (
@@ -43,10 +72,11 @@ func NewOperationDBRepo(db *sql.DB) *OperationDBRepo {
 				t                         int
 				amount                    float64
 				ts                        time.Time
-				desc                      string
+				desc, tags, createdBy     string
+				splitsJSON                []byte
 			)
 
-			if err := s.Scan(&idStr, &t, &accIDStr, &amount, &ts, &desc, &catIDStr); err != nil {
+			if err := s.Scan(&idStr, &t, &accIDStr, &amount, &ts, &desc, &catIDStr, &tags, &splitsJSON, &createdBy); err != nil {
 				return nil, err
 			}
 
@@ -75,13 +105,38 @@ func NewOperationDBRepo(db *sql.DB) *OperationDBRepo {
 			if err != nil {
 				return nil, err
 			}
+			if err := op.SetTags(operation.ParseTagList(tags, ",")...); err != nil {
+				return nil, err
+			}
+			var rows []splitRow
+			if err := json.Unmarshal(splitsJSON, &rows); err != nil {
+				return nil, err
+			}
+			lines := make([]operation.SplitLine, 0, len(rows))
+			for _, row := range rows {
+				lineCat, err := uuid.Parse(row.CategoryID)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, operation.SplitLine{CategoryID: service.ObjectID(lineCat), Amount: row.Amount, Note: row.Note})
+			}
+			if err := op.SetSplits(lines); err != nil {
+				return nil, err
+			}
+			if createdBy != "" {
+				author, err := uuid.Parse(createdBy)
+				if err != nil {
+					return nil, err
+				}
+				op.SetCreatedBy(service.ObjectID(author))
+			}
 			return op, nil
 		},
 
 		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
 			op, ok := obj.(operation.IOperation)
 			if !ok {
-				return nil, errors.New("expected IOperation")
+				return nil, service.Invariant("expected IOperation")
 			}
 			return []any{
 				op.ID(),
@@ -91,6 +146,7 @@ func NewOperationDBRepo(db *sql.DB) *OperationDBRepo {
 				op.Date(), // time.Time → timestamptz
 				op.Description(),
 				op.CategoryID(),
+				optionalAuthor(op),
 			}, nil
 		},
 	}
@@ -98,26 +154,193 @@ func NewOperationDBRepo(db *sql.DB) *OperationDBRepo {
 	return &OperationDBRepo{NewCommonDBRepo(db, m)}
 }
 
+func (r *OperationDBRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.saveWithChildren(ctx, obj, r.insert)
+}
+
+func (r *OperationDBRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	return r.saveWithChildren(ctx, obj, r.update)
+}
+
+// Строка операции, её метки и разбивка пишутся в одной транзакции; дочерние строки заменяются целиком.
+func (r *OperationDBRepo) saveWithChildren(ctx context.Context, obj service.ICommonObject,
+	write func(ctx context.Context, q querier, obj service.ICommonObject) error) error {
+	op, ok := obj.(operation.IOperation)
+	if !ok {
+		return service.Invariant("expected IOperation")
+	}
+	return inScope(ctx, r.db, func(q querier) error {
+		if err := write(ctx, q, obj); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, `DELETE FROM operation_tags WHERE operation_id = $1`, op.ID()); err != nil {
+			return err
+		}
+		for _, tag := range op.Tags() {
+			if _, err := q.ExecContext(ctx,
+				`INSERT INTO operation_tags (operation_id, tag) VALUES ($1, $2)`, op.ID(), tag); err != nil {
+				return err
+			}
+		}
+		if _, err := q.ExecContext(ctx, `DELETE FROM operation_splits WHERE operation_id = $1`, op.ID()); err != nil {
+			return err
+		}
+		for i, l := range op.Splits() {
+			if _, err := q.ExecContext(ctx,
+				`INSERT INTO operation_splits (operation_id, line_no, category_id, amount, note) VALUES ($1, $2, $3, $4, $5)`,
+				op.ID(), i+1, l.CategoryID, l.Amount, l.Note); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Операции, у которых есть все перечисленные метки.
+func (r *OperationDBRepo) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var out []service.ICommonObject
+	err := r.queryRows(ctx, func(rows *sql.Rows) error {
+		obj, err := r.scan(rows)
+		if err != nil {
+			return err
+		}
+		out = append(out, obj)
+		return nil
+	},
+		`SELECT `+operationColumns+`
+       FROM operations
+      WHERE id IN (SELECT operation_id
+                     FROM operation_tags
+                    WHERE tag = ANY($1::text[])
+                    GROUP BY operation_id
+                   HAVING COUNT(DISTINCT tag) = $2)
+        AND ($3::text IS NULL OR owner_id = $3 OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $3))`,
+		pgTextArray(tags), len(tags), ownerArg(ctx),
+	)
+	return out, err
+}
+
 func (r *OperationDBRepo) SliceByAccountAndPeriod(ctx context.Context, id service.ObjectID, from time.Time, to time.Time) ([]service.ICommonObject, error) {
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, op_type, account_id, amount, "timestamp", description, category_id
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var out []service.ICommonObject
+	err := r.queryRows(ctx, func(rows *sql.Rows) error {
+		obj, err := r.scan(rows)
+		if err != nil {
+			return err
+		}
+		out = append(out, obj)
+		return nil
+	},
+		`SELECT `+operationColumns+`
        FROM operations
       WHERE account_id = $1
         AND "timestamp" >= $2
-        AND "timestamp" <= $3`,
-		id, from, to,
+        AND "timestamp" <= $3
+        AND ($4::text IS NULL OR owner_id = $4 OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $4))`,
+		id, from, to, ownerArg(ctx),
 	)
+	return out, err
+}
+
+func (r *OperationDBRepo) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var acc any
+	if accountID != nil {
+		acc = *accountID
+	}
+	zone, err := pgZone(loc)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var out []service.ICommonObject
-	for rows.Next() {
-		obj, err := r.mapper.scanOne(rows)
-		if err != nil {
-			return nil, err
+	var out []operationrepo.SeriesPoint
+	err = r.queryRows(ctx, func(rows *sql.Rows) error {
+		var (
+			bucket time.Time
+			p      operationrepo.SeriesPoint
+		)
+		if err := rows.Scan(&bucket, &p.Income, &p.Expense, &p.Net, &p.CumulativeNet); err != nil {
+			return err
 		}
-		out = append(out, obj)
+		// date_trunc вернул локальное время зоны loc без смещения
+		p.Start = time.Date(bucket.Year(), bucket.Month(), bucket.Day(), bucket.Hour(), bucket.Minute(), bucket.Second(), 0, loc)
+		out = append(out, p)
+		return nil
+	},
+		`WITH buckets AS (
+             SELECT date_trunc($1, "timestamp" AT TIME ZONE $2)                 AS bucket,
+                    COALESCE(SUM(amount) FILTER (WHERE op_type = 1), 0)         AS income,
+                    COALESCE(SUM(amount) FILTER (WHERE op_type = 0), 0)         AS expense
+               FROM operations
+              WHERE "timestamp" >= $3
+                AND "timestamp" <= $4
+                AND ($5::text IS NULL OR account_id = $5)
+                AND ($6::text IS NULL OR owner_id = $6 OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = $6))
+              GROUP BY 1
+         )
+         SELECT bucket, income, expense, income - expense,
+                SUM(income - expense) OVER (ORDER BY bucket)
+           FROM buckets
+          ORDER BY bucket`,
+		string(g), zone, from, to, acc, ownerArg(ctx),
+	)
+	return out, err
+}
+
+// Имя зоны для AT TIME ZONE. "Local" Postgres не знает — берём IANA-имя из TZ или /etc/localtime.
+func pgZone(loc *time.Location) (string, error) {
+	name := loc.String()
+	if name == "Local" {
+		name = localZoneName()
+	}
+	if name == "" || name == "Local" {
+		return "", service.Invalid("timezone", "cannot resolve local time zone, pass an IANA name")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return "", service.Invalid("timezone", "unknown time zone %q", name)
+	}
+	return name, nil
+}
+
+func localZoneName() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if tz == "" {
+			return "UTC"
+		}
+		return tz
+	}
+	target, err := filepath.EvalSymlinks("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+		return name
+	}
+	return ""
+}
+
+// Пустая строка — автор не записан (операция заведена до общих счетов).
+func optionalAuthor(op operation.IOperation) string {
+	if id := op.CreatedBy(); id != (service.ObjectID{}) {
+		return id.String()
+	}
+	return ""
+}
+
+// Литерал text[] для драйвера, который не умеет передавать срезы как массивы.
+func pgTextArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, s := range items {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		quoted[i] = `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
 	}
-	return out, rows.Err()
+	return "{" + strings.Join(quoted, ",") + "}"
 }
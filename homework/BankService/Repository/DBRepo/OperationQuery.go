@@ -0,0 +1,133 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+func (r *OperationDBRepo) Query(ctx context.Context, q operationrepo.Query) (operationrepo.Page, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	after, err := q.Normalize()
+	if err != nil {
+		return operationrepo.Page{}, err
+	}
+	query, args := compileOperationQuery(q, after, ownerArg(ctx))
+	var page operationrepo.Page
+	err = r.queryRows(ctx, func(rows *sql.Rows) error {
+		obj, err := r.scan(rows)
+		if err != nil {
+			return err
+		}
+		page.Items = append(page.Items, obj)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return operationrepo.Page{}, err
+	}
+	// запрошена лишняя строка: если она пришла, есть следующая страница
+	if q.Limit > 0 && len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[q.Limit-1].(operation.IOperation)
+		page.Next = operationrepo.EncodeCursor(operationrepo.CursorFor(last, q.Sort, q.Desc))
+	}
+	return page, nil
+}
+
+// Собирает параметризованный SELECT; значения из запроса попадают только в args.
+func compileOperationQuery(q operationrepo.Query, after *operationrepo.Cursor, owner any) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if owner != nil {
+		p := arg(owner)
+		conds = append(conds, "(owner_id = "+p+" OR account_id IN (SELECT account_id FROM account_shares WHERE user_id = "+p+"))")
+	}
+	if len(q.AccountIDs) > 0 {
+		conds = append(conds, "account_id = ANY("+arg(pgTextArray(idStrings(q.AccountIDs)))+"::text[])")
+	}
+	if len(q.CategoryIDs) > 0 {
+		p := arg(pgTextArray(idStrings(q.CategoryIDs)))
+		conds = append(conds, `(category_id = ANY(`+p+`::text[])
+              OR EXISTS (SELECT 1 FROM operation_splits s
+                          WHERE s.operation_id = operations.id AND s.category_id = ANY(`+p+`::text[])))`)
+	}
+	if len(q.AuthorIDs) > 0 {
+		conds = append(conds, "COALESCE(created_by, owner_id) = ANY("+arg(pgTextArray(idStrings(q.AuthorIDs)))+"::text[])")
+	}
+	if q.Type != nil {
+		conds = append(conds, "op_type = "+arg(int(*q.Type)))
+	}
+	if q.MinAmount != nil {
+		conds = append(conds, "amount >= "+arg(*q.MinAmount))
+	}
+	if q.MaxAmount != nil {
+		conds = append(conds, "amount <= "+arg(*q.MaxAmount))
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, `"timestamp" >= `+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, `"timestamp" <= `+arg(q.To))
+	}
+	if q.Text != "" {
+		conds = append(conds, `description ILIKE `+arg("%"+escapeLike(q.Text)+"%")+` ESCAPE '\'`)
+	}
+	if len(q.Tags) > 0 {
+		conds = append(conds, `id IN (SELECT operation_id FROM operation_tags
+                     WHERE tag = ANY(`+arg(pgTextArray(q.Tags))+`::text[])
+                     GROUP BY operation_id
+                    HAVING COUNT(DISTINCT tag) = `+arg(len(q.Tags))+`)`)
+	}
+
+	col := `"timestamp"`
+	if q.Sort == operationrepo.SortByAmount {
+		col = "amount"
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if after != nil {
+		var v any = after.Date
+		if q.Sort == operationrepo.SortByAmount {
+			v = after.Amount
+		}
+		conds = append(conds, "("+col+`, id COLLATE "C") `+cmp+" ("+arg(v)+", "+arg(after.ID)+")")
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + operationColumns + "\n  FROM operations")
+	if len(conds) > 0 {
+		b.WriteString("\n WHERE " + strings.Join(conds, "\n   AND "))
+	}
+	b.WriteString("\n ORDER BY " + col + " " + dir + `, id COLLATE "C" ` + dir)
+	if q.Limit > 0 {
+		b.WriteString("\n LIMIT " + arg(q.Limit+1))
+	}
+	return b.String(), args
+}
+
+func idStrings(ids []service.ObjectID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
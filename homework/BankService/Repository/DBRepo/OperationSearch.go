@@ -0,0 +1,78 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+
+	search "github.com/ilyaytrewq/kpo-sb/homework/BankService/Search"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Полнотекстовый поиск по описанию операции и названиям её категории и счёта.
+// GIN-индексы отбирают кандидатов, у которых хоть одно поле содержит хоть одно слово ($2),
+// затем объединённый вектор проверяется на все слова сразу ($1).
+func (r *OperationDBRepo) Search(ctx context.Context, query string, limit int) ([]search.Hit, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	queryAll, queryAny := search.TSQuery(query, "&"), search.TSQuery(query, "|")
+	if queryAll == "" {
+		return nil, nil
+	}
+	var lim any
+	if limit > 0 {
+		lim = limit
+	}
+	var out []search.Hit
+	err := r.queryRows(ctx, func(rows *sql.Rows) error {
+		var h search.Hit
+		obj, err := r.scan(scanWithTail{rows, []any{&h.Rank, &h.Description, &h.Category, &h.Account}})
+		if err != nil {
+			return err
+		}
+		h.Operation = obj.(operation.IOperation)
+		out = append(out, h)
+		return nil
+	},
+		`WITH q AS (
+             SELECT to_tsquery('simple', $1) AS query_all,
+                    to_tsquery('simple', $2) AS query_any
+         ),
+         hits AS (
+             SELECT o.id AS op_id,
+                    ts_rank(setweight(o.search_vector, 'A') ||
+                            setweight(c.search_vector, 'B') ||
+                            setweight(a.search_vector, 'C'), q.query_all)               AS rank,
+                    ts_headline('simple', o.description, q.query_any, $4)                AS hl_description,
+                    ts_headline('simple', c.name,        q.query_any, $4)                AS hl_category,
+                    ts_headline('simple', a.name,        q.query_any, $4)                AS hl_account
+               FROM operations o
+               JOIN categories    c ON c.id = o.category_id
+               JOIN bank_accounts a ON a.id = o.account_id
+               CROSS JOIN q
+              WHERE (o.search_vector @@ q.query_any
+                     OR c.search_vector @@ q.query_any
+                     OR a.search_vector @@ q.query_any)
+                AND (o.search_vector || c.search_vector || a.search_vector) @@ q.query_all
+                AND ($5::text IS NULL OR o.owner_id = $5 OR o.account_id IN (SELECT account_id FROM account_shares WHERE user_id = $5))
+         )
+         SELECT `+operationColumns+`, hits.rank, hits.hl_description, hits.hl_category, hits.hl_account
+           FROM operations
+           JOIN hits ON hits.op_id = operations.id
+          ORDER BY hits.rank DESC, "timestamp" DESC
+          LIMIT $3`,
+		queryAll, queryAny, lim,
+		"StartSel="+search.HighlightStart+", StopSel="+search.HighlightStop+", HighlightAll=true",
+		ownerArg(ctx),
+	)
+	return out, err
+}
+
+// Дописывает к полям, которые читает scanOne, дополнительные колонки выборки.
+type scanWithTail struct {
+	s    scanner
+	tail []any
+}
+
+func (s scanWithTail) Scan(dest ...any) error {
+	return s.s.Scan(append(dest, s.tail...)...)
+}
@@ -0,0 +1,133 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Общее у *sql.DB и *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Параметр владельца для условия ($n::text IS NULL OR owner_id = $n).
+func ownerArg(ctx context.Context) any {
+	if id, ok := service.OwnerFrom(ctx); ok {
+		return id.String()
+	}
+	return nil
+}
+
+// Владелец новой строки: свой у объекта, иначе из контекста, иначе пользователь по умолчанию.
+// Чужой объект пишется, только если это операция счёта, открытого на запись.
+func stampOwner(ctx context.Context, obj service.ICommonObject) (string, error) {
+	ctxOwner, scoped := service.OwnerFrom(ctx)
+	o, ok := obj.(service.IOwned)
+	if !ok {
+		if scoped {
+			return ctxOwner.String(), nil
+		}
+		return service.LegacyOwnerID.String(), nil
+	}
+	if o.OwnerID() == (service.ObjectID{}) && scoped {
+		o.SetOwnerID(ctxOwner)
+	}
+	if !service.WritableTo(ctx, obj) {
+		return "", service.NotFound("object belongs to another user")
+	}
+	owner, _ := service.OwnerOf(obj)
+	return owner.String(), nil
+}
+
+// Транзакция с настройками RLS: app.user_id — политики пропускают строки этого пользователя
+// (и общих счетов). Системный доступ (загрузка кэша, журнал изменений, запись истории) включает
+// app.bypass, только если контекст помечен service.Unscoped; без обеих настроек политики
+// не отдают ничего, так что забытая область видимости ничего не читает и не пишет.
+func beginScoped(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := setScope(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Настройки действуют до конца транзакции; в единице работы они меняются перед каждым запросом,
+// потому что её хранилища пишут и с областью пользователя, и системно (история).
+func setScope(ctx context.Context, q querier) error {
+	user, bypass := "", ""
+	if owner, ok := service.OwnerFrom(ctx); ok {
+		user = owner.String()
+	} else if service.IsUnscoped(ctx) {
+		bypass = "on"
+	}
+	_, err := q.ExecContext(ctx, `SELECT set_config('app.user_id', $1, true), set_config('app.bypass', $2, true)`, user, bypass)
+	return err
+}
+
+// Каждый запрос идёт в своей транзакции с настройками RLS.
+func (r *CommonDBRepo) scoped(ctx context.Context, fn func(q querier) error) error {
+	return inScope(ctx, r.db, fn)
+}
+
+// Внутри единицы работы (repository.Atomic) запрос идёт в её транзакции под точкой сохранения:
+// ошибка, которую вызывающий обработал (например, Conflict), не обрывает всю транзакцию.
+func inScope(ctx context.Context, db *sql.DB, fn func(q querier) error) error {
+	if tx, ok, err := unitTx(ctx, db); ok {
+		if err != nil {
+			return err
+		}
+		return inSavepoint(ctx, tx, fn)
+	}
+	tx, err := beginScoped(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Транзакция единицы работы для этой БД; открывается при первом обращении
+// и фиксируется или откатывается вместе с единицей.
+func unitTx(ctx context.Context, db *sql.DB) (*sql.Tx, bool, error) {
+	u, ok := repository.UnitFrom(ctx)
+	if !ok {
+		return nil, false, nil
+	}
+	if tx, ok := u.Resource(db); ok {
+		return tx.(*sql.Tx), true, nil
+	}
+	tx, err := db.BeginTx(u.Context(), nil)
+	if err != nil {
+		return nil, true, err
+	}
+	u.Attach(db, tx, tx.Commit, func() { _ = tx.Rollback() })
+	return tx, true, nil
+}
+
+func inSavepoint(ctx context.Context, tx *sql.Tx, fn func(q querier) error) error {
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT unit_step`); err != nil {
+		return err
+	}
+	err := setScope(ctx, tx)
+	if err == nil {
+		err = fn(tx)
+	}
+	if err != nil {
+		_, _ = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT unit_step`)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT unit_step`)
+	return err
+}
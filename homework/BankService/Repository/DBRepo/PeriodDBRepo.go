@@ -0,0 +1,80 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	period "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Period"
+)
+
+const periodColumns = `id, month, log`
+
+type PeriodDBRepo struct {
+	*CommonDBRepo
+}
+
+func NewPeriodDBRepo(db *sql.DB) *PeriodDBRepo {
+	m := entityMapper{
+		table:     "accounting_periods",
+		byIDQuery: `SELECT ` + periodColumns + `, owner_id FROM accounting_periods WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		allQuery:  `SELECT ` + periodColumns + `, owner_id FROM accounting_periods WHERE ($1::text IS NULL OR owner_id = $1) ORDER BY month`,
+		insertSQL: `INSERT INTO accounting_periods(` + periodColumns + `, owner_id)
+                    VALUES ($1, $2, $3, $4)`,
+		updateSQL: `UPDATE accounting_periods SET
+                        month = $2,
+                        log   = $3
+                    WHERE id = $1 AND owner_id = $4`,
+		deleteSQL: `DELETE FROM accounting_periods WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		scanOne: func(s scanner) (service.ICommonObject, error) {
+			var (
+				id    service.ObjectID
+				month time.Time
+				raw   []byte
+			)
+			if err := s.Scan(&id, &month, &raw); err != nil {
+				return nil, err
+			}
+			var log []period.LogEntry
+			if err := json.Unmarshal(raw, &log); err != nil {
+				return nil, err
+			}
+			return period.NewCopyPeriod(id, month, log)
+		},
+		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
+			p, ok := obj.(period.IPeriod)
+			if !ok {
+				return nil, service.Invariant("expected IPeriod")
+			}
+			log := p.Log()
+			if log == nil {
+				log = []period.LogEntry{}
+			}
+			raw, err := json.Marshal(log)
+			if err != nil {
+				return nil, err
+			}
+			return []any{p.ID(), p.Month(), string(raw)}, nil
+		},
+	}
+	return &PeriodDBRepo{NewCommonDBRepo(db, m)}
+}
+
+func (r *PeriodDBRepo) ByMonth(ctx context.Context, month time.Time) (service.ICommonObject, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var obj service.ICommonObject
+	err := r.scoped(ctx, func(q querier) error {
+		var err error
+		obj, err = r.scan(q.QueryRowContext(ctx, `SELECT `+periodColumns+`, owner_id FROM accounting_periods
+                     WHERE month = $1 AND ($2::text IS NULL OR owner_id = $2)`, month, ownerArg(ctx)))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, service.NotFound("period not found")
+	}
+	return obj, err
+}
@@ -57,6 +57,9 @@ func createTables(db *sql.DB) error {
 		ctype SMALLINT  NOT NULL CHECK (ctype IN (0, 1))
 	);
 
+	ALTER TABLE categories
+		ADD COLUMN IF NOT EXISTS parent_id TEXT REFERENCES categories(id) ON DELETE RESTRICT;
+
 	CREATE TABLE IF NOT EXISTS bank_accounts (
 		id      TEXT PRIMARY KEY,
 		name    TEXT   NOT NULL,
@@ -64,6 +67,9 @@ func createTables(db *sql.DB) error {
 	);
 
 
+	ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	ALTER TABLE categories    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
 	CREATE TABLE IF NOT EXISTS operations (
 		id            TEXT PRIMARY KEY,
 		op_type       SMALLINT NOT NULL CHECK (op_type IN (0, 1)),
@@ -80,6 +86,270 @@ func createTables(db *sql.DB) error {
 		FOREIGN KEY (category_id) REFERENCES categories(id)   ON DELETE RESTRICT
 	);
 
+	CREATE TABLE IF NOT EXISTS categorization_rules (
+		id             TEXT PRIMARY KEY,
+		name           TEXT     NOT NULL,
+		priority       INTEGER  NOT NULL DEFAULT 0,
+		descr_regex    TEXT     NOT NULL DEFAULT '',
+		descr_contains TEXT     NOT NULL DEFAULT '',
+		min_amount     DOUBLE PRECISION,
+		max_amount     DOUBLE PRECISION,
+		account_id     TEXT REFERENCES bank_accounts(id) ON DELETE CASCADE,
+		op_type        SMALLINT CHECK (op_type IN (0, 1)),
+		category_id    TEXT     NOT NULL REFERENCES categories(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS operation_tags (
+		operation_id TEXT NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+		tag          TEXT NOT NULL,
+		PRIMARY KEY (operation_id, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_operation_tags_tag ON operation_tags (tag);
+
+	ALTER TABLE operations
+		ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', description)) STORED;
+	CREATE INDEX IF NOT EXISTS idx_operations_search ON operations USING GIN (search_vector);
+
+	ALTER TABLE categories
+		ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', name)) STORED;
+	CREATE INDEX IF NOT EXISTS idx_categories_search ON categories USING GIN (search_vector);
+
+	ALTER TABLE bank_accounts
+		ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', name)) STORED;
+	CREATE INDEX IF NOT EXISTS idx_bank_accounts_search ON bank_accounts USING GIN (search_vector);
+
+	CREATE TABLE IF NOT EXISTS operation_splits (
+		operation_id TEXT     NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+		line_no      INTEGER  NOT NULL,
+		category_id  TEXT     NOT NULL REFERENCES categories(id) ON DELETE RESTRICT,
+		amount       DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+		note         TEXT     NOT NULL DEFAULT '',
+		PRIMARY KEY (operation_id, line_no)
+	);
+
+	CREATE TABLE IF NOT EXISTS cache_changes (
+		seq        BIGSERIAL PRIMARY KEY,
+		table_name TEXT        NOT NULL,
+		row_id     TEXT        NOT NULL,
+		op         CHAR(1)     NOT NULL,
+		changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	-- TG_ARGV: таблица, кэш которой сбрасывается, и колонка с её id (для дочерних таблиц)
+	CREATE OR REPLACE FUNCTION log_cache_change() RETURNS trigger AS $$
+	DECLARE
+		changed jsonb;
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			changed := to_jsonb(OLD);
+		ELSE
+			changed := to_jsonb(NEW);
+		END IF;
+		INSERT INTO cache_changes (table_name, row_id, op)
+		VALUES (TG_ARGV[0], changed ->> TG_ARGV[1], left(TG_OP, 1));
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER trg_cache_bank_accounts
+		AFTER INSERT OR UPDATE OR DELETE ON bank_accounts
+		FOR EACH ROW EXECUTE FUNCTION log_cache_change('bank_accounts', 'id');
+	CREATE OR REPLACE TRIGGER trg_cache_categories
+		AFTER INSERT OR UPDATE OR DELETE ON categories
+		FOR EACH ROW EXECUTE FUNCTION log_cache_change('categories', 'id');
+	CREATE OR REPLACE TRIGGER trg_cache_operations
+		AFTER INSERT OR UPDATE OR DELETE ON operations
+		FOR EACH ROW EXECUTE FUNCTION log_cache_change('operations', 'id');
+	CREATE OR REPLACE TRIGGER trg_cache_operation_tags
+		AFTER INSERT OR UPDATE OR DELETE ON operation_tags
+		FOR EACH ROW EXECUTE FUNCTION log_cache_change('operations', 'operation_id');
+	CREATE OR REPLACE TRIGGER trg_cache_operation_splits
+		AFTER INSERT OR UPDATE OR DELETE ON operation_splits
+		FOR EACH ROW EXECUTE FUNCTION log_cache_change('operations', 'operation_id');
+
+	-- история изменений через репозитории: снимки сущности до и после
+	CREATE TABLE IF NOT EXISTS entity_versions (
+		entity     TEXT        NOT NULL,
+		entity_id  TEXT        NOT NULL,
+		version    INTEGER     NOT NULL,
+		op         CHAR(1)     NOT NULL,
+		before     JSONB,
+		after      JSONB,
+		changed_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (entity, entity_id, version)
+	);
+
+	-- режим журнала событий (STORAGE_MODE=ledger): события только дописываются
+	CREATE TABLE IF NOT EXISTS ledger_events (
+		seq         BIGSERIAL   PRIMARY KEY,
+		stream_id   TEXT        NOT NULL,
+		type        TEXT        NOT NULL,
+		data        JSONB,
+		recorded_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_ledger_events_stream ON ledger_events (stream_id, seq);
+
+	CREATE OR REPLACE FUNCTION ledger_events_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'ledger_events is append-only';
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER trg_ledger_events_append_only
+		BEFORE UPDATE OR DELETE ON ledger_events
+		FOR EACH ROW EXECUTE FUNCTION ledger_events_append_only();
+
+	-- без внешнего ключа на bank_accounts: в режиме журнала событий счета живут в ledger_events
+	CREATE TABLE IF NOT EXISTS reconciliations (
+		id                TEXT PRIMARY KEY,
+		account_id        TEXT             NOT NULL,
+		statement_date    TIMESTAMPTZ      NOT NULL,
+		statement_balance DOUBLE PRECISION NOT NULL,
+		ledger_balance    DOUBLE PRECISION NOT NULL,
+		operation_ids     TEXT[]           NOT NULL DEFAULT '{}',
+		created_at        TIMESTAMPTZ      NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_reconciliations_account ON reconciliations (account_id, statement_date);
+
+	-- закрытые учётные периоды (месяцы); log хранит все закрытия и повторные открытия
+	CREATE TABLE IF NOT EXISTS accounting_periods (
+		id    TEXT  PRIMARY KEY,
+		month DATE  NOT NULL UNIQUE,
+		log   JSONB NOT NULL DEFAULT '[]'
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_snapshots (
+		seq      BIGINT      PRIMARY KEY,
+		data     JSONB       NOT NULL,
+		taken_at TIMESTAMPTZ NOT NULL
+	);
+
+	-- пользователи; строки, созданные до их появления, принадлежат пользователю default
+	CREATE TABLE IF NOT EXISTS users (
+		id         TEXT PRIMARY KEY,
+		name       TEXT        NOT NULL UNIQUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	-- пароль — PBKDF2, пустой хеш задаётся при первом входе; default остаётся администратором
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'editor'
+		CHECK (role IN ('viewer', 'editor', 'admin'));
+	INSERT INTO users (id, name, role) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'admin')
+		ON CONFLICT DO NOTHING;
+	UPDATE users SET role = 'admin'
+	 WHERE id = '00000000-0000-0000-0000-000000000001' AND password_hash = '';
+
+	-- общие счета: владелец счёта выдаёт другому пользователю доступ на чтение или запись;
+	-- без внешнего ключа на bank_accounts по той же причине, что у reconciliations
+	CREATE TABLE IF NOT EXISTS account_shares (
+		id         TEXT PRIMARY KEY,
+		account_id TEXT        NOT NULL,
+		owner_id   TEXT        NOT NULL REFERENCES users(id),
+		user_id    TEXT        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		level      TEXT        NOT NULL CHECK (level IN ('read', 'write')),
+		created_at TIMESTAMPTZ NOT NULL,
+		UNIQUE (account_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_account_shares_user ON account_shares (user_id);
+
+	-- кто завёл операцию; NULL — владелец (операции до появления общих счетов)
+	ALTER TABLE operations ADD COLUMN IF NOT EXISTS created_by TEXT REFERENCES users(id);
+
+	CREATE OR REPLACE FUNCTION app_user_id() RETURNS TEXT AS $$
+		SELECT COALESCE(current_setting('app.user_id', true), '')
+	$$ LANGUAGE sql STABLE;
+
+	-- системный доступ (загрузка кэша, журнал изменений, запись истории) включается явно;
+	-- соединение без app.user_id и без app.bypass не видит ни одной строки
+	CREATE OR REPLACE FUNCTION app_bypass() RETURNS BOOLEAN AS $$
+		SELECT COALESCE(current_setting('app.bypass', true), '') = 'on'
+	$$ LANGUAGE sql STABLE;
+
+	-- событие журнала принадлежит владельцу сущности; старые события — пользователю default.
+	-- Значение по умолчанию заполняет старые строки без UPDATE, который запрещён триггером
+	ALTER TABLE ledger_events ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL
+		DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES users(id);
+	ALTER TABLE ledger_events ALTER COLUMN owner_id DROP DEFAULT;
+	CREATE INDEX IF NOT EXISTS idx_ledger_events_owner ON ledger_events (owner_id);
+	ALTER TABLE ledger_events ENABLE ROW LEVEL SECURITY;
+	ALTER TABLE ledger_events FORCE ROW LEVEL SECURITY;
+	DROP POLICY IF EXISTS owner_isolation ON ledger_events;
+	CREATE POLICY owner_isolation ON ledger_events
+		USING (app_bypass() OR owner_id = app_user_id());
+
+	-- снимок хранит проекции всех пользователей: читает и пишет его только системный доступ
+	ALTER TABLE ledger_snapshots ENABLE ROW LEVEL SECURITY;
+	ALTER TABLE ledger_snapshots FORCE ROW LEVEL SECURITY;
+	DROP POLICY IF EXISTS system_only ON ledger_snapshots;
+	CREATE POLICY system_only ON ledger_snapshots
+		USING (app_bypass());
+
+	-- версия принадлежит владельцу сущности: он записан в снимке
+	ALTER TABLE entity_versions ADD COLUMN IF NOT EXISTS owner_id TEXT REFERENCES users(id);
+	UPDATE entity_versions
+	   SET owner_id = COALESCE(NULLIF(after->>'owner_id', ''), NULLIF(before->>'owner_id', ''),
+	                           '00000000-0000-0000-0000-000000000001')
+	 WHERE owner_id IS NULL;
+
+	DO $$
+	DECLARE
+		t TEXT;
+	BEGIN
+		FOREACH t IN ARRAY ARRAY['bank_accounts', 'categories', 'operations', 'categorization_rules',
+		                         'reconciliations', 'accounting_periods', 'account_shares',
+		                         'entity_versions'] LOOP
+			EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS owner_id TEXT REFERENCES users(id)', t);
+			EXECUTE format('UPDATE %I SET owner_id = %L WHERE owner_id IS NULL', t, '00000000-0000-0000-0000-000000000001');
+			EXECUTE format('ALTER TABLE %I ALTER COLUMN owner_id SET NOT NULL', t);
+			EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (owner_id)', 'idx_' || t || '_owner', t);
+
+			-- app.user_id задаётся в транзакции запроса от имени пользователя, app.bypass — системной
+			EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+			EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+			EXECUTE format('DROP POLICY IF EXISTS owner_isolation ON %I', t);
+			EXECUTE format($p$CREATE POLICY owner_isolation ON %I
+				USING (app_bypass() OR owner_id = app_user_id())$p$, t);
+		END LOOP;
+
+		-- метки и разбивка видны вместе со своей операцией
+		FOREACH t IN ARRAY ARRAY['operation_tags', 'operation_splits'] LOOP
+			EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+			EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+			EXECUTE format('DROP POLICY IF EXISTS owner_isolation ON %I', t);
+			EXECUTE format($p$CREATE POLICY owner_isolation ON %I
+				USING (EXISTS (SELECT 1 FROM operations o WHERE o.id = operation_id))$p$, t);
+		END LOOP;
+	END
+	$$;
+
+	-- к политике владельца добавляются доступы через account_shares (политики объединяются по OR):
+	-- общий счёт и его операции видны получателю, с доступом write операции можно менять,
+	-- категории владельца читаются, чтобы операции общего счёта было с чем сопоставить
+	DROP POLICY IF EXISTS shared_read ON account_shares;
+	CREATE POLICY shared_read ON account_shares FOR SELECT
+		USING (user_id = app_user_id());
+	DROP POLICY IF EXISTS shared_read ON bank_accounts;
+	CREATE POLICY shared_read ON bank_accounts FOR SELECT
+		USING (id IN (SELECT account_id FROM account_shares WHERE user_id = app_user_id()));
+	DROP POLICY IF EXISTS shared_read ON categories;
+	CREATE POLICY shared_read ON categories FOR SELECT
+		USING (owner_id IN (SELECT owner_id FROM account_shares WHERE user_id = app_user_id()));
+	DROP POLICY IF EXISTS shared_read ON operations;
+	CREATE POLICY shared_read ON operations FOR SELECT
+		USING (account_id IN (SELECT account_id FROM account_shares WHERE user_id = app_user_id()));
+	DROP POLICY IF EXISTS shared_write ON operations;
+	CREATE POLICY shared_write ON operations
+		USING (account_id IN (SELECT account_id FROM account_shares
+		                       WHERE user_id = app_user_id() AND level = 'write'));
+
+	-- у каждого пользователя свои месяцы
+	ALTER TABLE accounting_periods DROP CONSTRAINT IF EXISTS accounting_periods_month_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_periods_owner_month ON accounting_periods (owner_id, month);
+
 	`
 	_, err := db.ExecContext(ctx, schema)
 	return err
@@ -0,0 +1,88 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	reconciliation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Reconciliation"
+)
+
+const reconciliationColumns = `id, account_id, statement_date, statement_balance, ledger_balance,
+       array_to_string(operation_ids, ','), created_at, owner_id`
+
+type ReconciliationDBRepo struct {
+	*CommonDBRepo
+}
+
+func NewReconciliationDBRepo(db *sql.DB) *ReconciliationDBRepo {
+	m := entityMapper{
+		table:     "reconciliations",
+		byIDQuery: `SELECT ` + reconciliationColumns + ` FROM reconciliations WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		allQuery:  `SELECT ` + reconciliationColumns + ` FROM reconciliations WHERE ($1::text IS NULL OR owner_id = $1) ORDER BY account_id, statement_date`,
+		insertSQL: `INSERT INTO reconciliations
+                        (id, account_id, statement_date, statement_balance, ledger_balance, operation_ids, created_at, owner_id)
+                    VALUES ($1, $2, $3, $4, $5, $6::text[], $7, $8)`,
+		updateSQL: `UPDATE reconciliations SET
+                        account_id        = $2,
+                        statement_date    = $3,
+                        statement_balance = $4,
+                        ledger_balance    = $5,
+                        operation_ids     = $6::text[],
+                        created_at        = $7
+                    WHERE id = $1 AND owner_id = $8`,
+		deleteSQL: `DELETE FROM reconciliations WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		scanOne: func(s scanner) (service.ICommonObject, error) {
+			var (
+				id, accID         service.ObjectID
+				date, createdAt   time.Time
+				statement, ledger float64
+				opIDs             string
+			)
+			if err := s.Scan(&id, &accID, &date, &statement, &ledger, &opIDs, &createdAt); err != nil {
+				return nil, err
+			}
+			var items []service.ObjectID
+			for _, raw := range strings.Split(opIDs, ",") {
+				if raw == "" {
+					continue
+				}
+				u, err := uuid.Parse(raw)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, service.ObjectID(u))
+			}
+			return reconciliation.NewCopyReconciliation(id, accID, date, statement, ledger, items, createdAt)
+		},
+		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
+			r, ok := obj.(reconciliation.IReconciliation)
+			if !ok {
+				return nil, service.Invariant("expected IReconciliation")
+			}
+			items := make([]string, 0, len(r.Items()))
+			for _, id := range r.Items() {
+				items = append(items, id.String())
+			}
+			return []any{r.ID(), r.AccountID(), r.StatementDate(), r.StatementBalance(), r.LedgerBalance(), pgTextArray(items), r.CreatedAt()}, nil
+		},
+	}
+	return &ReconciliationDBRepo{NewCommonDBRepo(db, m)}
+}
+
+func (r *ReconciliationDBRepo) ByAccount(ctx context.Context, accountID service.ObjectID) ([]service.ICommonObject, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	var out []service.ICommonObject
+	err := r.scoped(ctx, func(q querier) error {
+		var err error
+		out, err = r.queryAll(ctx, q, `SELECT `+reconciliationColumns+` FROM reconciliations
+                     WHERE account_id = $1 AND ($2::text IS NULL OR owner_id = $2)
+                     ORDER BY statement_date`, accountID, ownerArg(ctx))
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,89 @@
+package dbrepo
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+)
+
+const ruleColumns = `id, name, priority, descr_regex, descr_contains, min_amount, max_amount, account_id, op_type, category_id`
+
+func NewRuleDBRepo(db *sql.DB) *CommonDBRepo {
+	m := entityMapper{
+		table:     "categorization_rules",
+		byIDQuery: `SELECT ` + ruleColumns + `, owner_id FROM categorization_rules WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		allQuery:  `SELECT ` + ruleColumns + `, owner_id FROM categorization_rules WHERE ($1::text IS NULL OR owner_id = $1) ORDER BY priority, name`,
+		insertSQL: `INSERT INTO categorization_rules(` + ruleColumns + `, owner_id)
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		updateSQL: `UPDATE categorization_rules SET
+                        name           = $2,
+                        priority       = $3,
+                        descr_regex    = $4,
+                        descr_contains = $5,
+                        min_amount     = $6,
+                        max_amount     = $7,
+                        account_id     = $8,
+                        op_type        = $9,
+                        category_id    = $10
+                    WHERE id = $1 AND owner_id = $11`,
+		deleteSQL: `DELETE FROM categorization_rules WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		scanOne: func(s scanner) (service.ICommonObject, error) {
+			var (
+				id, catID          service.ObjectID
+				name, re, contains string
+				priority           int
+				minA, maxA         sql.NullFloat64
+				accID              sql.NullString
+				opType             sql.NullInt16
+			)
+			if err := s.Scan(&id, &name, &priority, &re, &contains, &minA, &maxA, &accID, &opType, &catID); err != nil {
+				return nil, err
+			}
+			cond := rule.Conditions{DescriptionRegex: re, DescriptionContains: contains}
+			if minA.Valid {
+				cond.MinAmount = &minA.Float64
+			}
+			if maxA.Valid {
+				cond.MaxAmount = &maxA.Float64
+			}
+			if accID.Valid {
+				u, err := uuid.Parse(accID.String)
+				if err != nil {
+					return nil, err
+				}
+				acc := service.ObjectID(u)
+				cond.AccountID = &acc
+			}
+			if opType.Valid {
+				t := operation.OperationType(opType.Int16)
+				cond.OpType = &t
+			}
+			return rule.NewCopyRule(id, name, priority, cond, catID)
+		},
+		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
+			r, ok := obj.(rule.IRule)
+			if !ok {
+				return nil, service.Invariant("expected IRule")
+			}
+			c := r.Conditions()
+			var minA, maxA, accID, opType any
+			if c.MinAmount != nil {
+				minA = *c.MinAmount
+			}
+			if c.MaxAmount != nil {
+				maxA = *c.MaxAmount
+			}
+			if c.AccountID != nil {
+				accID = *c.AccountID
+			}
+			if c.OpType != nil {
+				opType = int(*c.OpType)
+			}
+			return []any{r.ID(), r.Name(), r.Priority(), c.DescriptionRegex, c.DescriptionContains, minA, maxA, accID, opType, r.CategoryID()}, nil
+		},
+	}
+	return NewCommonDBRepo(db, m)
+}
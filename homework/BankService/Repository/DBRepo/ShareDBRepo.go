@@ -0,0 +1,66 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	share "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Share"
+)
+
+const shareColumns = `id, account_id, user_id, level, created_at`
+
+type ShareDBRepo struct {
+	*CommonDBRepo
+}
+
+func NewShareDBRepo(db *sql.DB) *ShareDBRepo {
+	m := entityMapper{
+		table:     "account_shares",
+		byIDQuery: `SELECT ` + shareColumns + `, owner_id FROM account_shares WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		allQuery:  `SELECT ` + shareColumns + `, owner_id FROM account_shares WHERE ($1::text IS NULL OR owner_id = $1) ORDER BY account_id, created_at`,
+		insertSQL: `INSERT INTO account_shares(` + shareColumns + `, owner_id)
+                    VALUES ($1, $2, $3, $4, $5, $6)`,
+		updateSQL: `UPDATE account_shares SET
+                        account_id = $2,
+                        user_id    = $3,
+                        level      = $4,
+                        created_at = $5
+                    WHERE id = $1 AND owner_id = $6`,
+		deleteSQL: `DELETE FROM account_shares WHERE id = $1 AND ($2::text IS NULL OR owner_id = $2)`,
+		scanOne: func(s scanner) (service.ICommonObject, error) {
+			var (
+				id, accountID, userID service.ObjectID
+				level                 string
+				createdAt             time.Time
+			)
+			if err := s.Scan(&id, &accountID, &userID, &level, &createdAt); err != nil {
+				return nil, err
+			}
+			return share.NewCopyAccountShare(id, accountID, userID, service.AccessLevel(level), createdAt)
+		},
+		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
+			sh, ok := obj.(share.IAccountShare)
+			if !ok {
+				return nil, service.Invariant("expected IAccountShare")
+			}
+			return []any{sh.ID(), sh.AccountID(), sh.UserID(), string(sh.Level()), sh.CreatedAt()}, nil
+		},
+	}
+	return &ShareDBRepo{NewCommonDBRepo(db, m)}
+}
+
+// Доступы получателя по индексу idx_account_shares_user. Запрос идёт от его имени:
+// политика shared_read открывает ему выданные доступы.
+func (r *ShareDBRepo) SharesFor(ctx context.Context, userID service.ObjectID) ([]service.ICommonObject, error) {
+	ctx, cancel := queryContext(service.WithOwner(ctx, userID))
+	defer cancel()
+	var out []service.ICommonObject
+	err := r.scoped(ctx, func(q querier) error {
+		var err error
+		out, err = r.queryAll(ctx, q, `SELECT `+shareColumns+`, owner_id FROM account_shares WHERE user_id = $1`, userID)
+		return err
+	})
+	return out, err
+}
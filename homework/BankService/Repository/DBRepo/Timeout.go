@@ -0,0 +1,20 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+)
+
+// Срок запроса по умолчанию: зависшая БД не держит CLI бесконечно.
+// Если у контекста вызывающего уже есть срок, действует он.
+var queryTimeout = 30 * time.Second
+
+// 0 — запросы без срока по умолчанию.
+func SetQueryTimeout(d time.Duration) { queryTimeout = d }
+
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
@@ -0,0 +1,45 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+func NewUserDBRepo(db *sql.DB) *CommonDBRepo {
+	m := entityMapper{
+		table:     "users",
+		unowned:   true,
+		byIDQuery: `SELECT id, name, role, password_hash, created_at FROM users WHERE id = $1`,
+		allQuery:  `SELECT id, name, role, password_hash, created_at FROM users ORDER BY name`,
+		insertSQL: `INSERT INTO users(id, name, role, password_hash, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		updateSQL: `UPDATE users SET
+                        name          = $2,
+                        role          = $3,
+                        password_hash = $4,
+                        created_at    = $5
+                    WHERE id = $1`,
+		deleteSQL: `DELETE FROM users WHERE id = $1`,
+		scanOne: func(s scanner) (service.ICommonObject, error) {
+			var (
+				id               service.ObjectID
+				name, role, hash string
+				createdAt        time.Time
+			)
+			if err := s.Scan(&id, &name, &role, &hash, &createdAt); err != nil {
+				return nil, err
+			}
+			return user.NewCopyUser(id, name, user.Role(role), hash, createdAt)
+		},
+		argsForInsert: func(obj service.ICommonObject) ([]any, error) {
+			u, ok := obj.(user.IUser)
+			if !ok {
+				return nil, service.Invariant("expected IUser")
+			}
+			return []any{u.ID(), u.Name(), string(u.Role()), u.PasswordHash(), u.CreatedAt()}, nil
+		},
+	}
+	return NewCommonDBRepo(db, m)
+}
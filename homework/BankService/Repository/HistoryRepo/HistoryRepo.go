@@ -0,0 +1,110 @@
+package historyrepo
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Версия сущности после одного изменения через репозиторий.
+// Before пуст у созданной сущности, After — у удалённой.
+type Version struct {
+	Entity    string // имя таблицы: bank_accounts, categories, operations
+	EntityID  service.ObjectID
+	Version   int    // с 1, по порядку изменений одной сущности
+	Op        string // "I", "U" или "D", как в repository.Change
+	Before    json.RawMessage
+	After     json.RawMessage
+	ChangedAt time.Time
+	OwnerID   service.ObjectID // владелец сущности: историю видит только он
+}
+
+// Версия видна, если в контексте нет владельца или это его сущность.
+func (v Version) VisibleTo(ctx context.Context) bool {
+	owner, scoped := service.OwnerFrom(ctx)
+	return !scoped || v.OwnerID == owner
+}
+
+type IHistoryRepo interface {
+	// Записывает версию, проставляя v.Version следующим номером для сущности.
+	Append(ctx context.Context, v *Version) error
+	// Все версии сущности по возрастанию номера.
+	History(ctx context.Context, entity string, id service.ObjectID) ([]Version, error)
+	// Последняя версия на момент at; ok == false, если сущности тогда ещё не было.
+	AsOf(ctx context.Context, entity string, id service.ObjectID, at time.Time) (v Version, ok bool, err error)
+	Latest(ctx context.Context, entity string, id service.ObjectID) (v Version, ok bool, err error)
+}
+
+type historyKey struct {
+	entity string
+	id     service.ObjectID
+}
+
+type HistoryRepo struct {
+	mu       sync.RWMutex
+	versions map[historyKey][]Version
+}
+
+func NewHistoryRepo() *HistoryRepo {
+	return &HistoryRepo{versions: make(map[historyKey][]Version)}
+}
+
+func (r *HistoryRepo) Append(ctx context.Context, v *Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := historyKey{v.Entity, v.EntityID}
+	v.Version = len(r.versions[key]) + 1
+	r.versions[key] = append(r.versions[key], *v)
+	repository.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.versions[key] = r.versions[key][:len(r.versions[key])-1]
+	})
+	return nil
+}
+
+func (r *HistoryRepo) History(ctx context.Context, entity string, id service.ObjectID) ([]Version, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vs := r.visible(ctx, entity, id)
+	out := make([]Version, len(vs))
+	copy(out, vs)
+	return out, nil
+}
+
+func (r *HistoryRepo) AsOf(ctx context.Context, entity string, id service.ObjectID, at time.Time) (Version, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vs := r.visible(ctx, entity, id)
+	// первая версия позже at; нужна предыдущая
+	i := sort.Search(len(vs), func(i int) bool { return vs[i].ChangedAt.After(at) })
+	if i == 0 {
+		return Version{}, false, nil
+	}
+	return vs[i-1], true, nil
+}
+
+func (r *HistoryRepo) Latest(ctx context.Context, entity string, id service.ObjectID) (Version, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vs := r.visible(ctx, entity, id)
+	if len(vs) == 0 {
+		return Version{}, false, nil
+	}
+	return vs[len(vs)-1], true, nil
+}
+
+// Вызывается под r.mu. Версии одной сущности принадлежат одному владельцу,
+// поэтому чужая сущность выглядит как сущность без истории.
+func (r *HistoryRepo) visible(ctx context.Context, entity string, id service.ObjectID) []Version {
+	vs := r.versions[historyKey{entity, id}]
+	if len(vs) > 0 && !vs[len(vs)-1].VisibleTo(ctx) {
+		return nil
+	}
+	return vs
+}
@@ -0,0 +1,286 @@
+package historyrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
+	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Перевод сущности в снимок JSON и обратно.
+type Codec struct {
+	Entity string
+	Encode func(obj service.ICommonObject) (json.RawMessage, error)
+	Decode func(data json.RawMessage) (service.ICommonObject, error)
+}
+
+type accountSnapshot struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Balance   float64 `json:"balance"`
+	DeletedAt string  `json:"deleted_at,omitempty"`
+	OwnerID   string  `json:"owner_id,omitempty"`
+}
+
+type categorySnapshot struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      int    `json:"type"`
+	ParentID  string `json:"parent_id,omitempty"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	OwnerID   string `json:"owner_id,omitempty"`
+}
+
+type splitSnapshot struct {
+	CategoryID string  `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note,omitempty"`
+}
+
+type operationSnapshot struct {
+	ID            string          `json:"id"`
+	Type          int             `json:"type"`
+	BankAccountID string          `json:"bank_account_id"`
+	Amount        float64         `json:"amount"`
+	Date          time.Time       `json:"date"`
+	Description   string          `json:"description,omitempty"`
+	CategoryID    string          `json:"category_id,omitempty"`
+	Tags          []string        `json:"tags,omitempty"`
+	Splits        []splitSnapshot `json:"splits,omitempty"`
+	OwnerID       string          `json:"owner_id,omitempty"`
+	CreatedBy     string          `json:"created_by,omitempty"`
+}
+
+var AccountCodec = Codec{
+	Entity: "bank_accounts",
+	Encode: func(obj service.ICommonObject) (json.RawMessage, error) {
+		acc, ok := obj.(bankaccount.IBankAccount)
+		if !ok {
+			return nil, service.Invariant("expected IBankAccount")
+		}
+		return json.Marshal(accountSnapshot{
+			ID:        acc.ID().String(),
+			Name:      acc.Name(),
+			Balance:   acc.Balance(),
+			DeletedAt: service.FormatDeletedAt(acc.DeletedAt()),
+			OwnerID:   optionalID(acc.OwnerID()),
+		})
+	},
+	Decode: func(data json.RawMessage) (service.ICommonObject, error) {
+		var s accountSnapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		id, err := parseID(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		acc, err := bankaccount.NewCopyBankAccount(id, s.Name, s.Balance)
+		if err != nil {
+			return nil, err
+		}
+		deletedAt, err := service.ParseDeletedAt(s.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		acc.Archive(deletedAt)
+		return acc, setOwner(acc, s.OwnerID)
+	},
+}
+
+var CategoryCodec = Codec{
+	Entity: "categories",
+	Encode: func(obj service.ICommonObject) (json.RawMessage, error) {
+		c, ok := obj.(category.ICategory)
+		if !ok {
+			return nil, service.Invariant("expected ICategory")
+		}
+		s := categorySnapshot{
+			ID:        c.ID().String(),
+			Name:      c.Name(),
+			Type:      int(c.Type()),
+			DeletedAt: service.FormatDeletedAt(c.DeletedAt()),
+		}
+		s.ParentID = optionalID(c.ParentID())
+		s.OwnerID = optionalID(c.OwnerID())
+		return json.Marshal(s)
+	},
+	Decode: func(data json.RawMessage) (service.ICommonObject, error) {
+		var s categorySnapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		id, err := parseID(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		parent, err := parseID(s.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		c, err := category.NewCopyCategory(id, s.Name, category.CategoryType(s.Type), parent)
+		if err != nil {
+			return nil, err
+		}
+		deletedAt, err := service.ParseDeletedAt(s.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.Archive(deletedAt)
+		return c, setOwner(c, s.OwnerID)
+	},
+}
+
+var OperationCodec = Codec{
+	Entity: "operations",
+	Encode: func(obj service.ICommonObject) (json.RawMessage, error) {
+		op, ok := obj.(operation.IOperation)
+		if !ok {
+			return nil, service.Invariant("expected IOperation")
+		}
+		s := operationSnapshot{
+			ID:            op.ID().String(),
+			Type:          int(op.Type()),
+			BankAccountID: op.BankAccountID().String(),
+			Amount:        op.Amount(),
+			Date:          op.Date(),
+			Description:   op.Description(),
+			CategoryID:    optionalID(op.CategoryID()),
+			Tags:          op.Tags(),
+			OwnerID:       optionalID(op.OwnerID()),
+			CreatedBy:     optionalID(op.CreatedBy()),
+		}
+		for _, l := range op.Splits() {
+			s.Splits = append(s.Splits, splitSnapshot{CategoryID: l.CategoryID.String(), Amount: l.Amount, Note: l.Note})
+		}
+		return json.Marshal(s)
+	},
+	Decode: func(data json.RawMessage) (service.ICommonObject, error) {
+		var s operationSnapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		id, err := parseID(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		accID, err := parseID(s.BankAccountID)
+		if err != nil {
+			return nil, err
+		}
+		catID, err := parseID(s.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		op, err := operation.NewCopyOperation(id, operation.OperationType(s.Type), accID, s.Amount, s.Date, catID, s.Description)
+		if err != nil {
+			return nil, err
+		}
+		if err := op.SetTags(s.Tags...); err != nil {
+			return nil, err
+		}
+		lines := make([]operation.SplitLine, 0, len(s.Splits))
+		for _, l := range s.Splits {
+			lineCat, err := parseID(l.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, operation.SplitLine{CategoryID: lineCat, Amount: l.Amount, Note: l.Note})
+		}
+		if err := op.SetSplits(lines); err != nil {
+			return nil, err
+		}
+		author, err := parseID(s.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		op.SetCreatedBy(author)
+		return op, setOwner(op, s.OwnerID)
+	},
+}
+
+// Владелец сущности по снимку; в снимках до появления пользователей его нет — это LegacyOwnerID.
+func SnapshotOwner(data json.RawMessage) (service.ObjectID, error) {
+	var s struct {
+		OwnerID string `json:"owner_id"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return service.ObjectID{}, fmt.Errorf("decode snapshot: %w", err)
+	}
+	id, err := parseID(s.OwnerID)
+	if err != nil || id != (service.ObjectID{}) {
+		return id, err
+	}
+	return service.LegacyOwnerID, nil
+}
+
+// Снимки, записанные до появления пользователей, остаются без владельца.
+func setOwner(obj service.IOwned, s string) error {
+	id, err := parseID(s)
+	if err != nil {
+		return err
+	}
+	obj.SetOwnerID(id)
+	return nil
+}
+
+// Пустая строка — нулевой id (нет родителя или категории).
+func parseID(s string) (service.ObjectID, error) {
+	if s == "" {
+		return service.ObjectID{}, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return service.ObjectID{}, fmt.Errorf("invalid id '%s' in snapshot", s)
+	}
+	return service.ObjectID(id), nil
+}
+
+func optionalID(id service.ObjectID) string {
+	if id == (service.ObjectID{}) {
+		return ""
+	}
+	return id.String()
+}
+
+// Изменившееся поле снимка; значения — в виде JSON.
+type FieldChange struct {
+	Field    string
+	Old, New string
+}
+
+// Поля, отличающиеся в двух снимках, по алфавиту.
+func Diff(before, after json.RawMessage) ([]FieldChange, error) {
+	var a, b map[string]json.RawMessage
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &a); err != nil {
+			return nil, fmt.Errorf("decode snapshot: %w", err)
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &b); err != nil {
+			return nil, fmt.Errorf("decode snapshot: %w", err)
+		}
+	}
+	fields := make(map[string]struct{})
+	for k := range a {
+		fields[k] = struct{}{}
+	}
+	for k := range b {
+		fields[k] = struct{}{}
+	}
+	var out []FieldChange
+	for k := range fields {
+		if string(a[k]) != string(b[k]) {
+			out = append(out, FieldChange{Field: k, Old: string(a[k]), New: string(b[k])})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out, nil
+}
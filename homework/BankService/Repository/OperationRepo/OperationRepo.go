@@ -2,7 +2,6 @@ package operationrepo
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
@@ -33,28 +32,42 @@ func NewCopyOperationRepo(repo map[service.ObjectID]*operation.Operation) *Opera
 
 func (r *OperationRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
 	op, ok := r.repo[id]
-	if !ok {
-		return nil, errors.New("operation not found")
+	if !ok || !service.VisibleTo(ctx, op) {
+		return nil, service.NotFound("operation not found")
 	}
-	return op, nil
+	return service.CopyOf(op), nil
 }
 
 func (r *OperationRepo) Save(ctx context.Context, op service.ICommonObject) error {
 	if _, ok := r.repo[op.ID()]; ok {
-		return errors.New("operation already saved")
+		return service.Conflict("operation already saved")
 	}
 	i, ok := op.(*operation.Operation)
 	if !ok {
-		return errors.New("invalid operation type")
+		return service.Invariant("invalid operation type")
 	}
-	r.repo[op.ID()] = i
+	repository.Put(ctx, r.repo, op.ID(), service.CopyOf(i).(*operation.Operation))
+	return nil
+}
+
+func (r *OperationRepo) Update(ctx context.Context, op service.ICommonObject) error {
+	if cur, ok := r.repo[op.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("operation not found")
+	}
+	i, ok := op.(*operation.Operation)
+	if !ok {
+		return service.Invariant("invalid operation type")
+	}
+	repository.Put(ctx, r.repo, op.ID(), service.CopyOf(i).(*operation.Operation))
 	return nil
 }
 
 func (r *OperationRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
 	ops := make([]service.ICommonObject, 0, len(r.repo))
 	for _, op := range r.repo {
-		ops = append(ops, op)
+		if service.VisibleTo(ctx, op) {
+			ops = append(ops, service.CopyOf(op))
+		}
 	}
 	return ops, nil
 }
@@ -63,17 +76,17 @@ func (r *OperationRepo) SliceByAccountAndPeriod(ctx context.Context, id service.
 	ops := make([]service.ICommonObject, 0)
 	for _, op := range r.repo {
 		d := op.Date()
-		if op.BankAccountID() == id && (d.Equal(from) || d.After(from)) && (d.Equal(to) || d.Before(to)) {
-			ops = append(ops, op)
+		if op.BankAccountID() == id && (d.Equal(from) || d.After(from)) && (d.Equal(to) || d.Before(to)) && service.VisibleTo(ctx, op) {
+			ops = append(ops, service.CopyOf(op))
 		}
 	}
 	return ops, nil
 }
 
 func (r *OperationRepo) Delete(ctx context.Context, id service.ObjectID) error {
-	if _, ok := r.repo[id]; !ok {
-		return errors.New("operation not found")
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("operation not found")
 	}
-	delete(r.repo, id)
+	repository.Remove(ctx, r.repo, id)
 	return nil
 }
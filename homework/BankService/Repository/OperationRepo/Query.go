@@ -0,0 +1,256 @@
+package operationrepo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type SortField string
+
+const (
+	SortByDate   SortField = "date"
+	SortByAmount SortField = "amount"
+)
+
+func ParseSortField(s string) (SortField, error) {
+	switch f := SortField(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", SortByDate:
+		return SortByDate, nil
+	case SortByAmount:
+		return f, nil
+	default:
+		return "", service.Invalid("sort", "unknown sort field %q (expected date or amount)", s)
+	}
+}
+
+// Спецификация выборки операций. Пустые поля не ограничивают выборку.
+type Query struct {
+	AccountIDs  []service.ObjectID
+	CategoryIDs []service.ObjectID // совпадение по категории операции или любой строки разбивки
+	Type        *operation.OperationType
+	MinAmount   *float64
+	MaxAmount   *float64
+	From, To    time.Time
+	Text        string // подстрока описания без учёта регистра
+	Tags        []string
+	AuthorIDs   []service.ObjectID // кто создал операцию (на общих счетах — не обязательно владелец)
+
+	Sort  SortField
+	Desc  bool
+	Limit int    // 0 — без ограничения
+	After string // курсор из Page.Next предыдущей страницы
+}
+
+type Page struct {
+	Items []service.ICommonObject
+	Next  string // пустой, если страниц больше нет
+}
+
+// Хранилища, которые сами выполняют Query (например, через SQL).
+type IQueryRepo interface {
+	Query(ctx context.Context, q Query) (Page, error)
+}
+
+// Позиция последней выданной строки: значение поля сортировки и id для однозначности.
+type Cursor struct {
+	Sort   SortField `json:"s"`
+	Desc   bool      `json:"d,omitempty"`
+	Date   time.Time `json:"t,omitempty"`
+	Amount float64   `json:"a,omitempty"`
+	ID     string    `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, service.Invalid("after", "invalid cursor")
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, service.Invalid("after", "invalid cursor")
+	}
+	return c, nil
+}
+
+func CursorFor(op operation.IOperation, sortField SortField, desc bool) Cursor {
+	return Cursor{Sort: sortField, Desc: desc, Date: op.Date(), Amount: op.Amount(), ID: op.ID().String()}
+}
+
+// Проверяет спецификацию и приводит её к каноническому виду; возвращает разобранный курсор.
+func (q *Query) Normalize() (*Cursor, error) {
+	sortField, err := ParseSortField(string(q.Sort))
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = sortField
+	if q.Limit < 0 {
+		return nil, service.Invalid("limit", "limit should be >= 0")
+	}
+	if q.MinAmount != nil && q.MaxAmount != nil && *q.MinAmount > *q.MaxAmount {
+		return nil, service.Invalid("min_amount", "min amount is greater than max amount")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return nil, service.Invalid("from", "'from' is after 'to'")
+	}
+	// метки копируются: срез мог прийти от вызывающего, его менять нельзя. Повторы после
+	// нормализации отбрасываются — SQL сравнивает число найденных меток с длиной списка
+	if len(q.Tags) > 0 {
+		tags := make([]string, 0, len(q.Tags))
+		seen := make(map[string]bool, len(q.Tags))
+		for _, t := range q.Tags {
+			norm, err := operation.NormalizeTag(t)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[norm] {
+				seen[norm] = true
+				tags = append(tags, norm)
+			}
+		}
+		q.Tags = tags
+	}
+	if q.After == "" {
+		return nil, nil
+	}
+	c, err := DecodeCursor(q.After)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, service.Invalid("after", "cursor was issued for a different sort order")
+	}
+	return &c, nil
+}
+
+func (q Query) Matches(op operation.IOperation) bool {
+	if len(q.AccountIDs) > 0 && !containsID(q.AccountIDs, op.BankAccountID()) {
+		return false
+	}
+	if len(q.AuthorIDs) > 0 && !containsID(q.AuthorIDs, operation.Author(op)) {
+		return false
+	}
+	if len(q.CategoryIDs) > 0 {
+		found := false
+		for catID := range operation.CategoryAmounts(op) {
+			if containsID(q.CategoryIDs, catID) {
+				found = true
+				break
+			}
+		}
+		if !found && !containsID(q.CategoryIDs, op.CategoryID()) {
+			return false
+		}
+	}
+	if q.Type != nil && op.Type() != *q.Type {
+		return false
+	}
+	if q.MinAmount != nil && op.Amount() < *q.MinAmount {
+		return false
+	}
+	if q.MaxAmount != nil && op.Amount() > *q.MaxAmount {
+		return false
+	}
+	if !q.From.IsZero() && op.Date().Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && op.Date().After(q.To) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(op.Description()), strings.ToLower(q.Text)) {
+		return false
+	}
+	return operation.HasAllTags(op, q.Tags)
+}
+
+// Сравнение в порядке сортировки запроса: <0 — a выдаётся раньше b.
+func compareByCursor(a, b Cursor) int {
+	var c int
+	switch a.Sort {
+	case SortByAmount:
+		c = compareFloat(a.Amount, b.Amount)
+	default:
+		c = a.Date.Compare(b.Date)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if a.Desc {
+		c = -c
+	}
+	return c
+}
+
+// Выполняет запрос над уже загруженными операциями: общий путь для in-memory хранилищ.
+func ApplyQuery(objs []service.ICommonObject, q Query) (Page, error) {
+	after, err := q.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	type keyed struct {
+		obj service.ICommonObject
+		key Cursor
+	}
+	var rows []keyed
+	for _, obj := range objs {
+		op, ok := obj.(operation.IOperation)
+		if !ok || !q.Matches(op) {
+			continue
+		}
+		key := CursorFor(op, q.Sort, q.Desc)
+		if after != nil && compareByCursor(key, *after) <= 0 {
+			continue
+		}
+		rows = append(rows, keyed{obj, key})
+	}
+	sort.Slice(rows, func(i, j int) bool { return compareByCursor(rows[i].key, rows[j].key) < 0 })
+
+	var page Page
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		page.Next = EncodeCursor(rows[len(rows)-1].key)
+	}
+	page.Items = make([]service.ICommonObject, 0, len(rows))
+	for _, r := range rows {
+		page.Items = append(page.Items, r.obj)
+	}
+	return page, nil
+}
+
+func (r *OperationRepo) Query(ctx context.Context, q Query) (Page, error) {
+	objs, err := r.All(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+	return ApplyQuery(objs, q)
+}
+
+func containsID(ids []service.ObjectID, id service.ObjectID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+package operationrepo
+
+import (
+	"context"
+
+	search "github.com/ilyaytrewq/kpo-sb/homework/BankService/Search"
+)
+
+// Хранилища с собственным полнотекстовым поиском (в Postgres — tsvector и GIN-индексы).
+type ISearchRepo interface {
+	Search(ctx context.Context, query string, limit int) ([]search.Hit, error)
+}
@@ -0,0 +1,24 @@
+package operationrepo
+
+import (
+	"context"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Хранилища, умеющие сами отбирать операции по меткам (например, через join-таблицу).
+type ITagRepo interface {
+	ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error)
+}
+
+// Операции, у которых есть все перечисленные метки.
+func (r *OperationRepo) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	out := make([]service.ICommonObject, 0)
+	for _, op := range r.repo {
+		if operation.HasAllTags(op, tags) && service.VisibleTo(ctx, op) {
+			out = append(out, service.CopyOf(op))
+		}
+	}
+	return out, nil
+}
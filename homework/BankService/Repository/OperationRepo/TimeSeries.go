@@ -0,0 +1,141 @@
+package operationrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type Granularity string
+
+const (
+	Day   Granularity = "day"
+	Week  Granularity = "week"
+	Month Granularity = "month"
+	Year  Granularity = "year"
+)
+
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case Day, Week, Month, Year:
+		return g, nil
+	default:
+		return "", service.Invalid("granularity", "invalid granularity %q", s)
+	}
+}
+
+type SeriesPoint struct {
+	Start   time.Time
+	Income  float64
+	Expense float64
+	Net     float64
+	// Накопленный Net с начала запрошенного периода.
+	CumulativeNet float64
+	// Остаток на конец корзины: остаток счёта (или всех счетов) на начало периода плюс CumulativeNet.
+	// Заполняет AnalyticsFacade, хранилища его не считают.
+	Balance float64
+}
+
+// accountID == nil — по всем счетам.
+type ITimeSeriesRepo interface {
+	TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g Granularity, loc *time.Location) ([]SeriesPoint, error)
+}
+
+// Неделя начинается с понедельника, как date_trunc('week') в Postgres.
+func TruncateTime(t time.Time, g Granularity, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch g {
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case Year:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+func NextBucket(t time.Time, g Granularity) time.Time {
+	switch g {
+	case Week:
+		return t.AddDate(0, 0, 7)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	case Year:
+		return t.AddDate(1, 0, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func BuildTimeSeries(ops []operation.IOperation, g Granularity, loc *time.Location) []SeriesPoint {
+	buckets := make(map[time.Time]*SeriesPoint)
+	for _, op := range ops {
+		start := TruncateTime(op.Date(), g, loc)
+		p, ok := buckets[start]
+		if !ok {
+			p = &SeriesPoint{Start: start}
+			buckets[start] = p
+		}
+		if op.Type() == operation.Income {
+			p.Income += op.Amount()
+		} else {
+			p.Expense += op.Amount()
+		}
+	}
+	out := make([]SeriesPoint, 0, len(buckets))
+	for _, p := range buckets {
+		p.Net = p.Income - p.Expense
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	var cumulative float64
+	for i := range out {
+		cumulative += out[i].Net
+		out[i].CumulativeNet = cumulative
+	}
+	return out
+}
+
+// Добавляет пустые корзины, чтобы ряд был непрерывным от from до to.
+func FillGaps(points []SeriesPoint, from, to time.Time, g Granularity, loc *time.Location) []SeriesPoint {
+	byStart := make(map[int64]SeriesPoint, len(points))
+	for _, p := range points {
+		byStart[p.Start.Unix()] = p
+	}
+	var (
+		out        []SeriesPoint
+		cumulative float64
+	)
+	for start := TruncateTime(from, g, loc); !start.After(to); start = NextBucket(start, g) {
+		p, ok := byStart[start.Unix()]
+		if !ok {
+			p = SeriesPoint{Start: start, CumulativeNet: cumulative}
+		}
+		p.Start = start
+		cumulative = p.CumulativeNet
+		out = append(out, p)
+	}
+	return out
+}
+
+func (r *OperationRepo) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g Granularity, loc *time.Location) ([]SeriesPoint, error) {
+	var ops []operation.IOperation
+	for _, op := range r.repo {
+		if (accountID != nil && op.BankAccountID() != *accountID) || !service.VisibleTo(ctx, op) {
+			continue
+		}
+		d := op.Date()
+		if d.Before(from) || d.After(to) {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return BuildTimeSeries(ops, g, loc), nil
+}
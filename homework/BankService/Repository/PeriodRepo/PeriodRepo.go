@@ -0,0 +1,84 @@
+package periodrepo
+
+import (
+	"context"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	period "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Period"
+)
+
+// Хранилища, которые находят период по месяцу сами (в БД — по индексу owner_id, month),
+// а не перебирают все периоды.
+type IMonthRepo interface {
+	// NotFound, если по этому месяцу ещё ничего не делали.
+	ByMonth(ctx context.Context, month time.Time) (service.ICommonObject, error)
+}
+
+type PeriodRepo struct {
+	repo map[service.ObjectID]*period.Period
+}
+
+func NewPeriodRepo() *PeriodRepo {
+	return &PeriodRepo{make(map[service.ObjectID]*period.Period)}
+}
+
+func (r *PeriodRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	p, ok := r.repo[id]
+	if !ok || !service.VisibleTo(ctx, p) {
+		return nil, service.NotFound("period not found")
+	}
+	return service.CopyOf(p), nil
+}
+
+func (r *PeriodRepo) Save(ctx context.Context, p service.ICommonObject) error {
+	if _, ok := r.repo[p.ID()]; ok {
+		return service.Conflict("period already saved")
+	}
+	i, ok := p.(*period.Period)
+	if !ok {
+		return service.Invariant("invalid period type")
+	}
+	repository.Put(ctx, r.repo, p.ID(), service.CopyOf(i).(*period.Period))
+	return nil
+}
+
+func (r *PeriodRepo) Update(ctx context.Context, p service.ICommonObject) error {
+	if cur, ok := r.repo[p.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("period not found")
+	}
+	i, ok := p.(*period.Period)
+	if !ok {
+		return service.Invariant("invalid period type")
+	}
+	repository.Put(ctx, r.repo, p.ID(), service.CopyOf(i).(*period.Period))
+	return nil
+}
+
+func (r *PeriodRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	periods := make([]service.ICommonObject, 0, len(r.repo))
+	for _, p := range r.repo {
+		if service.VisibleTo(ctx, p) {
+			periods = append(periods, service.CopyOf(p))
+		}
+	}
+	return periods, nil
+}
+
+func (r *PeriodRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("period not found")
+	}
+	repository.Remove(ctx, r.repo, id)
+	return nil
+}
+
+func (r *PeriodRepo) ByMonth(ctx context.Context, month time.Time) (service.ICommonObject, error) {
+	for _, p := range r.repo {
+		if p.Month().Equal(month) && service.VisibleTo(ctx, p) {
+			return service.CopyOf(p), nil
+		}
+	}
+	return nil, service.NotFound("period not found")
+}
@@ -0,0 +1,185 @@
+package proxyrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Сколько разных срезов по счёту и периоду держать одновременно.
+const maxCachedSlices = 128
+
+type sliceKey struct {
+	partition string
+	account   service.ObjectID
+	from, to  int64
+}
+
+type cachedSlice struct {
+	ids      []service.ObjectID
+	loadedAt time.Time
+}
+
+// Кэш операций: строки — как в CachedRepo, срезы по счёту и периоду хранятся списками id
+// отдельно для каждой области видимости.
+// Любое изменение операций сбрасывает все срезы: новая операция может попасть в любой из них.
+type CachedOperationRepo struct {
+	*CachedRepo
+	ops operationrepo.IOperationRepo
+
+	slicesMu sync.Mutex
+	slices   map[sliceKey]cachedSlice
+}
+
+func NewCachedOperationRepo(ctx context.Context, ops operationrepo.IOperationRepo, opts ...Options) (*CachedOperationRepo, error) {
+	c, err := NewCachedRepo(ctx, ops, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedOperationRepo{CachedRepo: c, ops: ops, slices: make(map[sliceKey]cachedSlice)}, nil
+}
+
+func (p *CachedOperationRepo) SliceByAccountAndPeriod(ctx context.Context, id service.ObjectID, from time.Time, to time.Time) ([]service.ICommonObject, error) {
+	key := sliceKey{partition: partitionKey(ctx), account: id, from: from.UnixNano(), to: to.UnixNano()}
+	p.slicesMu.Lock()
+	cached, ok := p.slices[key]
+	p.slicesMu.Unlock()
+	if ok && !p.expired(cached.loadedAt) {
+		out := make([]service.ICommonObject, 0, len(cached.ids))
+		for _, opID := range cached.ids {
+			obj, err := p.ByID(ctx, opID)
+			if err != nil {
+				// строку удалили в обход кэша — перечитываем срез
+				return p.loadSlice(ctx, key, id, from, to)
+			}
+			out = append(out, obj)
+		}
+		return out, nil
+	}
+	return p.loadSlice(ctx, key, id, from, to)
+}
+
+// Срез, прочитанный до записи или инвалидации, не сохраняется — как и в reload.
+func (p *CachedOperationRepo) loadSlice(ctx context.Context, key sliceKey, id service.ObjectID, from, to time.Time) ([]service.ICommonObject, error) {
+	p.mu.Lock()
+	gen := p.gen
+	p.mu.Unlock()
+	objs, err := p.ops.SliceByAccountAndPeriod(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]service.ObjectID, 0, len(objs))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gen != gen {
+		return objs, nil
+	}
+	pt := p.partition(ctx)
+	for _, o := range objs {
+		p.put(pt, o)
+		ids = append(ids, o.ID())
+	}
+
+	p.slicesMu.Lock()
+	if len(p.slices) >= maxCachedSlices {
+		p.slices = make(map[sliceKey]cachedSlice)
+	}
+	p.slices[key] = cachedSlice{ids: ids, loadedAt: p.now()}
+	p.slicesMu.Unlock()
+	return objs, nil
+}
+
+func (p *CachedOperationRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	defer p.dropSlices()
+	repository.OnRollback(ctx, p.dropSlices)
+	return p.CachedRepo.Save(ctx, obj)
+}
+
+func (p *CachedOperationRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	defer p.dropSlices()
+	repository.OnRollback(ctx, p.dropSlices)
+	return p.CachedRepo.Update(ctx, obj)
+}
+
+func (p *CachedOperationRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	defer p.dropSlices()
+	repository.OnRollback(ctx, p.dropSlices)
+	return p.CachedRepo.Delete(ctx, id)
+}
+
+func (p *CachedOperationRepo) Invalidate(id service.ObjectID) {
+	p.CachedRepo.Invalidate(id)
+	p.dropSlices()
+}
+
+func (p *CachedOperationRepo) InvalidateAll() {
+	p.CachedRepo.InvalidateAll()
+	p.dropSlices()
+}
+
+func (p *CachedOperationRepo) dropSlices() {
+	p.slicesMu.Lock()
+	p.slices = make(map[sliceKey]cachedSlice)
+	p.slicesMu.Unlock()
+}
+
+// Агрегаты не кэшируются: если хранилище считает их само (SQL), запрос уходит туда,
+// иначе считаются по закэшированным строкам.
+
+func (p *CachedOperationRepo) Query(ctx context.Context, q operationrepo.Query) (operationrepo.Page, error) {
+	if r, ok := p.ops.(operationrepo.IQueryRepo); ok {
+		return r.Query(ctx, q)
+	}
+	objs, err := p.All(ctx)
+	if err != nil {
+		return operationrepo.Page{}, err
+	}
+	return operationrepo.ApplyQuery(objs, q)
+}
+
+func (p *CachedOperationRepo) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	if r, ok := p.ops.(operationrepo.ITagRepo); ok {
+		return r.ByTags(ctx, tags)
+	}
+	objs, err := p.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []service.ICommonObject
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok && operation.HasAllTags(op, tags) {
+			out = append(out, obj)
+		}
+	}
+	return out, nil
+}
+
+func (p *CachedOperationRepo) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	if r, ok := p.ops.(operationrepo.ITimeSeriesRepo); ok {
+		return r.TimeSeries(ctx, accountID, from, to, g, loc)
+	}
+	var (
+		objs []service.ICommonObject
+		err  error
+	)
+	if accountID != nil {
+		objs, err = p.SliceByAccountAndPeriod(ctx, *accountID, from, to)
+	} else {
+		objs, err = p.All(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ops []operation.IOperation
+	for _, obj := range objs {
+		if op, ok := obj.(operation.IOperation); ok && !op.Date().Before(from) && !op.Date().After(to) {
+			ops = append(ops, op)
+		}
+	}
+	return operationrepo.BuildTimeSeries(ops, g, loc), nil
+}
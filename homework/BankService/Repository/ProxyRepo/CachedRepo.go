@@ -1,65 +1,246 @@
 package proxyrepo
 
 import (
+	"container/list"
 	"context"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 )
 
+type Options struct {
+	TTL     time.Duration // 0 — записи не устаревают
+	MaxSize int           // 0 — без ограничения, иначе вытесняются давно не читанные (LRU)
+}
+
+// Сколько разных областей видимости держать одновременно; при переполнении кэш сбрасывается.
+const maxPartitions = 64
+
+// Сколько строк из ленты изменений перечитывать по одной; при большем числе раздел загружается заново.
+const maxDirty = 32
+
+type entry struct {
+	obj      service.ICommonObject
+	loadedAt time.Time
+}
+
+// Строки, прочитанные с одной областью видимости. Хранилище отдаёт их уже отфильтрованными
+// (в БД — политиками RLS), поэтому чужие строки в раздел пользователя не попадают.
+type partition struct {
+	scope  service.Scope
+	scoped bool // false — системный раздел (контекст без владельца)
+	items  map[service.ObjectID]*list.Element
+	lru    *list.List // спереди — недавно использованные
+	// В разделе все видимые строки: All можно отдать из памяти. Сбрасывается при вытеснении.
+	complete   bool
+	completeAt time.Time
+	// Строки, изменённые в обход кэша: перед выдачей из памяти их перечитывают по одной.
+	dirty map[service.ObjectID]bool
+}
+
+func newPartition(ctx context.Context) *partition {
+	s, scoped := service.ScopeFrom(ctx)
+	return &partition{
+		scope:  s,
+		scoped: scoped,
+		items:  make(map[service.ObjectID]*list.Element),
+		lru:    list.New(),
+		dirty:  make(map[service.ObjectID]bool),
+	}
+}
+
+// Строка попала бы в этот раздел при чтении из хранилища.
+func (pt *partition) visible(obj service.ICommonObject) bool {
+	if !pt.scoped {
+		return true
+	}
+	return service.VisibleTo(service.WithScope(context.Background(), pt.scope), obj)
+}
+
+// Кэш поверх хранилища. Разделы ключуются областью видимости вызывающего: пользователь
+// с одними и теми же общими счетами получает свой раздел, и его строки загружаются
+// с его областью, а не в обход фильтров хранилища.
 type CachedRepo struct {
-	db    repository.ICommonRepo
-	mu    sync.RWMutex
-	cache map[service.ObjectID]service.ICommonObject
+	db   repository.ICommonRepo
+	opts Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	parts map[string]*partition
+	// Растёт при каждой записи и инвалидации. Чтение, начатое при другом поколении,
+	// не кладёт результат в кэш: он мог прочитать строку до изменения.
+	gen uint64
 }
 
-func NewCachedRepo(ctx context.Context, db repository.ICommonRepo) (*CachedRepo, error) {
-	all, err := db.All(ctx)
-	if err != nil {
+func NewCachedRepo(ctx context.Context, db repository.ICommonRepo, opts ...Options) (*CachedRepo, error) {
+	p := &CachedRepo{
+		db:    db,
+		now:   time.Now,
+		parts: make(map[string]*partition),
+	}
+	if len(opts) > 0 {
+		p.opts = opts[0]
+	}
+	if _, err := p.reload(ctx); err != nil {
 		return nil, err
 	}
-	c := make(map[service.ObjectID]service.ICommonObject, len(all))
-	for _, o := range all {
-		c[o.ID()] = o
+	return p, nil
+}
+
+// Ключ раздела: владелец и его доступы к общим счетам; пустой — системный доступ.
+// При выдаче или отзыве доступа ключ меняется, и раздел загружается заново.
+func partitionKey(ctx context.Context) string {
+	s, scoped := service.ScopeFrom(ctx)
+	if !scoped {
+		return ""
+	}
+	grants := make([]string, 0, len(s.Accounts))
+	for acc, level := range s.Accounts {
+		grants = append(grants, acc.String()+"="+string(level))
+	}
+	sort.Strings(grants)
+	return s.User.String() + "|" + strings.Join(grants, ",")
+}
+
+// Вызывается под p.mu.
+func (p *CachedRepo) partition(ctx context.Context) *partition {
+	key := partitionKey(ctx)
+	if pt, ok := p.parts[key]; ok {
+		return pt
+	}
+	if len(p.parts) >= maxPartitions {
+		p.parts = make(map[string]*partition)
 	}
-	return &CachedRepo{db: db, cache: c}, nil
+	pt := newPartition(ctx)
+	p.parts[key] = pt
+	return pt
 }
 
 func (p *CachedRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
-	p.mu.RLock()
-	obj, ok := p.cache[id]
-	p.mu.RUnlock()
-	if ok {
-		return obj, nil
+	p.mu.Lock()
+	pt := p.partition(ctx)
+	if el, ok := pt.items[id]; ok {
+		e := el.Value.(*entry)
+		if !p.expired(e.loadedAt) {
+			pt.lru.MoveToFront(el)
+			p.mu.Unlock()
+			return service.CopyOf(e.obj), nil
+		}
+		pt.remove(id)
+		pt.complete = false
 	}
+	gen := p.gen
+	p.mu.Unlock()
+
 	o, err := p.db.ByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	p.mu.Lock()
-	p.cache[id] = o
+	if p.gen == gen {
+		pt := p.partition(ctx)
+		p.put(pt, o)
+		delete(pt.dirty, id)
+	}
 	p.mu.Unlock()
-	return o, nil
+	return service.CopyOf(o), nil
 }
 
 func (p *CachedRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	out := make([]service.ICommonObject, 0, len(p.cache))
-	for _, o := range p.cache {
-		out = append(out, o)
+	p.mu.Lock()
+	pt := p.partition(ctx)
+	if !pt.complete || p.expired(pt.completeAt) || len(pt.dirty) > maxDirty {
+		p.mu.Unlock()
+		return p.reload(ctx)
+	}
+	if len(pt.dirty) > 0 {
+		p.mu.Unlock()
+		if err := p.refresh(ctx); err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		pt = p.partition(ctx)
+		if !pt.complete || len(pt.dirty) > 0 {
+			// пока перечитывали, кэш снова изменился
+			p.mu.Unlock()
+			return p.reload(ctx)
+		}
 	}
+	out := make([]service.ICommonObject, 0, len(pt.items))
+	for _, el := range pt.items {
+		out = append(out, service.CopyOf(el.Value.(*entry).obj))
+	}
+	p.mu.Unlock()
 	return out, nil
 }
 
+// Перечитывает по одной строки раздела вызывающего, изменённые в обход кэша.
+func (p *CachedRepo) refresh(ctx context.Context) error {
+	p.mu.Lock()
+	pt := p.partition(ctx)
+	ids := make([]service.ObjectID, 0, len(pt.dirty))
+	for id := range pt.dirty {
+		ids = append(ids, id)
+	}
+	gen := p.gen
+	p.mu.Unlock()
+
+	fresh := make(map[service.ObjectID]service.ICommonObject, len(ids))
+	for _, id := range ids {
+		o, err := p.db.ByID(ctx, id)
+		if errors.Is(err, service.ErrNotFound) {
+			fresh[id] = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fresh[id] = o
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gen != gen {
+		return nil
+	}
+	pt = p.partition(ctx)
+	for id, o := range fresh {
+		if o == nil {
+			pt.remove(id)
+		} else {
+			p.put(pt, o)
+		}
+		delete(pt.dirty, id)
+	}
+	return nil
+}
+
 func (p *CachedRepo) Save(ctx context.Context, obj service.ICommonObject) error {
 	if err := p.db.Save(ctx, obj); err != nil {
 		return err
 	}
 	p.mu.Lock()
-	p.cache[obj.ID()] = obj
+	p.gen++
+	p.spread(obj)
 	p.mu.Unlock()
+	p.forgetOnRollback(ctx, obj.ID())
+	return nil
+}
+
+func (p *CachedRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	if err := p.db.Update(ctx, obj); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.gen++
+	p.spread(obj)
+	p.mu.Unlock()
+	p.forgetOnRollback(ctx, obj.ID())
 	return nil
 }
 
@@ -68,7 +249,114 @@ func (p *CachedRepo) Delete(ctx context.Context, id service.ObjectID) error {
 		return err
 	}
 	p.mu.Lock()
-	delete(p.cache, id)
+	p.gen++
+	for _, pt := range p.parts {
+		pt.remove(id)
+	}
 	p.mu.Unlock()
+	p.forgetOnRollback(ctx, id)
 	return nil
 }
+
+// Строка изменилась в хранилище в обход этого кэша (или это эхо своей записи из ленты).
+// Раздел остаётся полным: строку перечитают по id при следующем чтении, в том числе
+// если это вставка, о которой кэш не знал.
+func (p *CachedRepo) Invalidate(id service.ObjectID) {
+	p.mu.Lock()
+	p.gen++
+	for _, pt := range p.parts {
+		pt.remove(id)
+		pt.dirty[id] = true
+	}
+	p.mu.Unlock()
+}
+
+// Запись внутри единицы работы может откатиться: тогда строка в кэше уже не совпадает с хранилищем.
+func (p *CachedRepo) forgetOnRollback(ctx context.Context, id service.ObjectID) {
+	repository.OnRollback(ctx, func() { p.Invalidate(id) })
+}
+
+func (p *CachedRepo) InvalidateAll() {
+	p.mu.Lock()
+	p.gen++
+	p.parts = make(map[string]*partition)
+	p.mu.Unlock()
+}
+
+// Число строк во всех разделах.
+func (p *CachedRepo) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, pt := range p.parts {
+		n += pt.lru.Len()
+	}
+	return n
+}
+
+// Перечитывает все видимые строки и заполняет раздел вызывающего.
+// Если за время чтения кэш изменился, результат отдаётся, но не сохраняется.
+func (p *CachedRepo) reload(ctx context.Context) ([]service.ICommonObject, error) {
+	p.mu.Lock()
+	gen := p.gen
+	p.mu.Unlock()
+	all, err := p.db.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gen != gen {
+		return all, nil
+	}
+	pt := newPartition(ctx)
+	p.parts[partitionKey(ctx)] = pt
+	for _, o := range all {
+		p.put(pt, o)
+	}
+	pt.complete = p.opts.MaxSize == 0 || len(all) <= p.opts.MaxSize
+	pt.completeAt = p.now()
+	return all, nil
+}
+
+func (p *CachedRepo) expired(loadedAt time.Time) bool {
+	return p.opts.TTL > 0 && p.now().Sub(loadedAt) > p.opts.TTL
+}
+
+// Вызывается под p.mu. Записанная строка попадает во все разделы, которым она видна,
+// и пропадает из тех, где её больше не видно.
+func (p *CachedRepo) spread(obj service.ICommonObject) {
+	for _, pt := range p.parts {
+		if pt.visible(obj) {
+			p.put(pt, obj)
+		} else {
+			pt.remove(obj.ID())
+		}
+	}
+}
+
+// Вызывается под p.mu. Кэш общий для всех пользователей, поэтому хранит свою копию
+// и отдаёт копии: чужая правка до проверки в Update сюда не попадает.
+func (p *CachedRepo) put(pt *partition, obj service.ICommonObject) {
+	e := &entry{obj: service.CopyOf(obj), loadedAt: p.now()}
+	if el, ok := pt.items[obj.ID()]; ok {
+		el.Value = e
+		pt.lru.MoveToFront(el)
+		return
+	}
+	pt.items[obj.ID()] = pt.lru.PushFront(e)
+	for p.opts.MaxSize > 0 && pt.lru.Len() > p.opts.MaxSize {
+		oldest := pt.lru.Back()
+		pt.lru.Remove(oldest)
+		delete(pt.items, oldest.Value.(*entry).obj.ID())
+		pt.complete = false
+	}
+}
+
+// Вызывается под p.mu.
+func (pt *partition) remove(id service.ObjectID) {
+	if el, ok := pt.items[id]; ok {
+		pt.lru.Remove(el)
+		delete(pt.items, id)
+	}
+}
@@ -0,0 +1,19 @@
+package proxyrepo
+
+import (
+	"context"
+
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Несколько запретов сразу: операция закрыта, если её закрыл хотя бы один.
+type OperationGuards []OperationGuard
+
+func (g OperationGuards) Locked(ctx context.Context, op operation.IOperation) error {
+	for _, guard := range g {
+		if err := guard.Locked(ctx, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
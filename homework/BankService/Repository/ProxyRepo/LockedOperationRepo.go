@@ -0,0 +1,74 @@
+package proxyrepo
+
+import (
+	"context"
+
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+type OperationGuard interface {
+	// Ошибка, если операцию нельзя создавать, менять или удалять.
+	Locked(ctx context.Context, op operation.IOperation) error
+}
+
+// Запрещает запись операций, которые закрыл guard (например, сверенный период).
+// Стоит поверх кэша, чтобы отказ случился до того, как кэш запомнит новую версию.
+type LockedOperationRepo struct {
+	operationReads
+	guard OperationGuard
+}
+
+func NewLockedOperationRepo(ops operationrepo.IOperationRepo, guard OperationGuard) *LockedOperationRepo {
+	return &LockedOperationRepo{operationReads: operationReads{ops: ops}, guard: guard}
+}
+
+func (p *LockedOperationRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	return p.ops.ByID(ctx, id)
+}
+
+func (p *LockedOperationRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	return p.ops.All(ctx)
+}
+
+func (p *LockedOperationRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	if err := p.check(ctx, obj); err != nil {
+		return err
+	}
+	return p.ops.Save(ctx, obj)
+}
+
+func (p *LockedOperationRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	if err := p.CheckEditable(ctx, obj.ID()); err != nil {
+		return err
+	}
+	if err := p.check(ctx, obj); err != nil {
+		return err
+	}
+	return p.ops.Update(ctx, obj)
+}
+
+func (p *LockedOperationRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if err := p.CheckEditable(ctx, id); err != nil {
+		return err
+	}
+	return p.ops.Delete(ctx, id)
+}
+
+// Проверка до правки на месте: если запись потом не пройдёт, объект в кэше уже будет изменён.
+func (p *LockedOperationRepo) CheckEditable(ctx context.Context, id service.ObjectID) error {
+	obj, err := p.ops.ByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.check(ctx, obj)
+}
+
+func (p *LockedOperationRepo) check(ctx context.Context, obj service.ICommonObject) error {
+	op, ok := obj.(operation.IOperation)
+	if !ok {
+		return service.Invariant("invalid operation type")
+	}
+	return p.guard.Locked(ctx, op)
+}
@@ -0,0 +1,58 @@
+package proxyrepo
+
+import (
+	"context"
+	"time"
+
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Выборки и агрегаты операций для прокси, которые меняют только запись:
+// уходят в хранилище как есть, а если оно их не умеет — считаются по All.
+type operationReads struct {
+	ops operationrepo.IOperationRepo
+}
+
+func (p operationReads) SliceByAccountAndPeriod(ctx context.Context, id service.ObjectID, from time.Time, to time.Time) ([]service.ICommonObject, error) {
+	return p.ops.SliceByAccountAndPeriod(ctx, id, from, to)
+}
+
+func (p operationReads) Query(ctx context.Context, q operationrepo.Query) (operationrepo.Page, error) {
+	if r, ok := p.ops.(operationrepo.IQueryRepo); ok {
+		return r.Query(ctx, q)
+	}
+	objs, err := p.ops.All(ctx)
+	if err != nil {
+		return operationrepo.Page{}, err
+	}
+	return operationrepo.ApplyQuery(objs, q)
+}
+
+func (p operationReads) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	if r, ok := p.ops.(operationrepo.ITagRepo); ok {
+		return r.ByTags(ctx, tags)
+	}
+	page, err := p.Query(ctx, operationrepo.Query{Tags: tags})
+	return page.Items, err
+}
+
+func (p operationReads) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	if r, ok := p.ops.(operationrepo.ITimeSeriesRepo); ok {
+		return r.TimeSeries(ctx, accountID, from, to, g, loc)
+	}
+	q := operationrepo.Query{From: from, To: to}
+	if accountID != nil {
+		q.AccountIDs = []service.ObjectID{*accountID}
+	}
+	page, err := p.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]operation.IOperation, 0, len(page.Items))
+	for _, obj := range page.Items {
+		ops = append(ops, obj.(operation.IOperation))
+	}
+	return operationrepo.BuildTimeSeries(ops, g, loc), nil
+}
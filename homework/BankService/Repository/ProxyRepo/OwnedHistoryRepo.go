@@ -0,0 +1,49 @@
+package proxyrepo
+
+import (
+	"context"
+	"time"
+
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// История сущностей одного пользователя: чтение идёт с его областью видимости,
+// поэтому хранилище (и политики RLS в БД) отдаёт только версии его сущностей.
+// Версии пишет VersionedRepo общей цепочки, сюда запись не проходит.
+type OwnedHistoryRepo struct {
+	history historyrepo.IHistoryRepo
+	access  *Access
+}
+
+func NewOwnedHistoryRepo(history historyrepo.IHistoryRepo, access *Access) *OwnedHistoryRepo {
+	return &OwnedHistoryRepo{history: history, access: access}
+}
+
+func (p *OwnedHistoryRepo) Append(ctx context.Context, v *historyrepo.Version) error {
+	return service.Invariant("history is written by the versioned repository")
+}
+
+func (p *OwnedHistoryRepo) History(ctx context.Context, entity string, id service.ObjectID) ([]historyrepo.Version, error) {
+	ctx, err := p.access.Scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.history.History(ctx, entity, id)
+}
+
+func (p *OwnedHistoryRepo) AsOf(ctx context.Context, entity string, id service.ObjectID, at time.Time) (historyrepo.Version, bool, error) {
+	ctx, err := p.access.Scope(ctx)
+	if err != nil {
+		return historyrepo.Version{}, false, err
+	}
+	return p.history.AsOf(ctx, entity, id, at)
+}
+
+func (p *OwnedHistoryRepo) Latest(ctx context.Context, entity string, id service.ObjectID) (historyrepo.Version, bool, error) {
+	ctx, err := p.access.Scope(ctx)
+	if err != nil {
+		return historyrepo.Version{}, false, err
+	}
+	return p.history.Latest(ctx, entity, id)
+}
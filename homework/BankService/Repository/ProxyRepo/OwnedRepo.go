@@ -0,0 +1,316 @@
+package proxyrepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	sharerepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ShareRepo"
+	search "github.com/ilyaytrewq/kpo-sb/homework/BankService/Search"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	share "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Share"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+// Кто работает с данными: пользователь и хранилище доступов к общим счетам (может быть nil).
+// Область видимости собирается при каждом обращении, если её ещё нет в контексте:
+// команда CLI собирает её один раз в начале, и отозванный доступ пропадает со следующей командой.
+type Access struct {
+	owner  service.ObjectID
+	shares repository.ICommonRepo
+}
+
+func NewAccess(owner service.ObjectID, shares repository.ICommonRepo) *Access {
+	return &Access{owner: owner, shares: shares}
+}
+
+func (a *Access) Owner() service.ObjectID { return a.owner }
+
+func (a *Access) Scope(ctx context.Context) (context.Context, error) {
+	if s, ok := service.ScopeFrom(ctx); ok && s.User == a.owner && (a.shares == nil || s.Accounts != nil) {
+		return ctx, nil
+	}
+	s := service.Scope{User: a.owner}
+	if a.shares == nil {
+		return service.WithScope(ctx, s), nil
+	}
+	objs, err := a.grants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.Accounts = make(map[service.ObjectID]service.AccessLevel)
+	s.Sharers = make(map[service.ObjectID]bool)
+	for _, obj := range objs {
+		sh, ok := obj.(share.IAccountShare)
+		if !ok || sh.UserID() != a.owner {
+			continue
+		}
+		s.Accounts[sh.AccountID()] = sh.Level()
+		owner, _ := service.OwnerOf(sh)
+		s.Sharers[owner] = true
+	}
+	return service.WithScope(ctx, s), nil
+}
+
+// Доступы, выданные пользователю; хранилище без выборки по получателю перебирается целиком.
+func (a *Access) grants(ctx context.Context) ([]service.ICommonObject, error) {
+	if r, ok := a.shares.(sharerepo.IGranteeRepo); ok {
+		return r.SharesFor(ctx, a.owner)
+	}
+	return a.shares.All(service.Unscoped(ctx))
+}
+
+// Роли, которые нужны вызывающему (пользователю из контекста) для чтения, записи и удаления.
+type Roles struct {
+	Read, Write, Delete user.Role
+}
+
+var DefaultRoles = Roles{Read: user.Viewer, Write: user.Editor, Delete: user.Editor}
+
+// Данные одного пользователя: каждый вызов идёт в хранилище с его областью видимости в контексте,
+// новые объекты получают этого владельца, чужие не читаются и не пишутся — кроме общих счетов.
+// Роль вызывающего проверяется здесь же, поэтому права не зависят от того, кто зовёт фасад.
+// Стоит поверх общей цепочки (кэш, версии, запреты), у каждого пользователя — свой экземпляр.
+type OwnedRepo struct {
+	repo   repository.ICommonRepo
+	access *Access
+	roles  Roles
+}
+
+func NewOwnedRepo(repo repository.ICommonRepo, access *Access) *OwnedRepo {
+	return &OwnedRepo{repo: repo, access: access, roles: DefaultRoles}
+}
+
+// Например, счета удаляет только администратор.
+func (p *OwnedRepo) WithRoles(r Roles) *OwnedRepo {
+	p.roles = r
+	return p
+}
+
+// Проверяет роль и возвращает контекст с областью видимости пользователя.
+func (p *OwnedRepo) scope(ctx context.Context, required user.Role) (context.Context, error) {
+	if err := auth.Require(ctx, required); err != nil {
+		return nil, err
+	}
+	return p.access.Scope(ctx)
+}
+
+func (p *OwnedRepo) Owner() service.ObjectID { return p.access.Owner() }
+
+func (p *OwnedRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return p.repo.ByID(ctx, id)
+}
+
+func (p *OwnedRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return p.repo.All(ctx)
+}
+
+func (p *OwnedRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	ctx, err := p.stamp(ctx, obj)
+	if err != nil {
+		return err
+	}
+	return p.repo.Save(ctx, obj)
+}
+
+func (p *OwnedRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	ctx, err := p.stamp(ctx, obj)
+	if err != nil {
+		return err
+	}
+	return p.repo.Update(ctx, obj)
+}
+
+func (p *OwnedRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	ctx, err := p.scope(ctx, p.roles.Delete)
+	if err != nil {
+		return err
+	}
+	return p.repo.Delete(ctx, id)
+}
+
+func (p *OwnedRepo) stamp(ctx context.Context, obj service.ICommonObject) (context.Context, error) {
+	ctx, err := p.scope(ctx, p.roles.Write)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := obj.(service.IOwned)
+	if !ok {
+		return ctx, nil
+	}
+	if o.OwnerID() == (service.ObjectID{}) {
+		o.SetOwnerID(p.Owner())
+		return ctx, nil
+	}
+	if err := writable(ctx, obj); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Проверка до правки на месте: фасад меняет полученный объект и только потом зовёт Update,
+// поэтому общий счёт, открытый только на чтение, отсекается заранее.
+func (p *OwnedRepo) CheckEditable(ctx context.Context, id service.ObjectID) error {
+	ctx, err := p.scope(ctx, p.roles.Write)
+	if err != nil {
+		return err
+	}
+	obj, err := p.repo.ByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return writable(ctx, obj)
+}
+
+func writable(ctx context.Context, obj service.ICommonObject) error {
+	if !service.VisibleTo(ctx, obj) {
+		return service.NotFound("object %s belongs to another user", obj.ID())
+	}
+	if !service.WritableTo(ctx, obj) {
+		return fmt.Errorf("%w: object %s is shared read-only", auth.ErrForbidden, obj.ID())
+	}
+	return nil
+}
+
+// Операции пользователя. Счёт и категории операции проверяются через его же репозитории:
+// сослаться можно только на свой счёт или общий счёт, открытый на запись.
+// Операция общего счёта принадлежит владельцу счёта, автор записывается в CreatedBy.
+type OwnedOperationRepo struct {
+	*OwnedRepo
+	ops        operationrepo.IOperationRepo
+	accounts   repository.ICommonRepo
+	categories repository.ICommonRepo
+}
+
+// Если хранилище умеет полнотекстовый поиск, у результата есть и Search.
+func NewOwnedOperationRepo(ops operationrepo.IOperationRepo, access *Access, accounts, categories repository.ICommonRepo) operationrepo.IOperationRepo {
+	p := &OwnedOperationRepo{OwnedRepo: NewOwnedRepo(ops, access), ops: ops, accounts: accounts, categories: categories}
+	if s, ok := ops.(operationrepo.ISearchRepo); ok {
+		return &ownedSearchRepo{OwnedOperationRepo: p, search: s}
+	}
+	return p
+}
+
+func (p *OwnedOperationRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	if err := p.checkRefs(ctx, obj); err != nil {
+		return err
+	}
+	if op, ok := obj.(*operation.Operation); ok && op.CreatedBy() == (service.ObjectID{}) {
+		op.SetCreatedBy(p.Owner())
+	}
+	return p.OwnedRepo.Save(ctx, obj)
+}
+
+func (p *OwnedOperationRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	if err := p.checkRefs(ctx, obj); err != nil {
+		return err
+	}
+	return p.OwnedRepo.Update(ctx, obj)
+}
+
+// Счёт должен быть виден, а категории — принадлежать владельцу счёта;
+// новая операция получает владельца счёта.
+func (p *OwnedOperationRepo) checkRefs(ctx context.Context, obj service.ICommonObject) error {
+	op, ok := obj.(operation.IOperation)
+	if !ok {
+		return service.Invariant("invalid operation type")
+	}
+	acc, err := p.accounts.ByID(ctx, op.BankAccountID())
+	if err != nil {
+		return fmt.Errorf("account %s: %w", op.BankAccountID(), err)
+	}
+	accOwner, _ := service.OwnerOf(acc)
+	if op.OwnerID() == (service.ObjectID{}) {
+		op.SetOwnerID(accOwner)
+	} else if owner, _ := service.OwnerOf(op); owner != accOwner {
+		return service.Invalid("account_id", "operation %s cannot be moved to an account of another user", op.ID())
+	}
+	cats := []service.ObjectID{op.CategoryID()}
+	for _, l := range op.Splits() {
+		cats = append(cats, l.CategoryID)
+	}
+	for _, id := range cats {
+		if id == (service.ObjectID{}) {
+			continue
+		}
+		cat, err := p.categories.ByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("category %s: %w", id, err)
+		}
+		if owner, _ := service.OwnerOf(cat); owner != accOwner {
+			return service.Invalid("category_id", "category %s: belongs to another user than account %s", id, op.BankAccountID())
+		}
+	}
+	return nil
+}
+
+func (p *OwnedOperationRepo) CheckEditable(ctx context.Context, id service.ObjectID) error {
+	if err := p.OwnedRepo.CheckEditable(ctx, id); err != nil {
+		return err
+	}
+	if g, ok := p.ops.(repository.IEditGuard); ok {
+		scoped, err := p.access.Scope(ctx)
+		if err != nil {
+			return err
+		}
+		return g.CheckEditable(scoped, id)
+	}
+	return nil
+}
+
+func (p *OwnedOperationRepo) SliceByAccountAndPeriod(ctx context.Context, id service.ObjectID, from time.Time, to time.Time) ([]service.ICommonObject, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return operationReads{ops: p.ops}.SliceByAccountAndPeriod(ctx, id, from, to)
+}
+
+func (p *OwnedOperationRepo) Query(ctx context.Context, q operationrepo.Query) (operationrepo.Page, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return operationrepo.Page{}, err
+	}
+	return operationReads{ops: p.ops}.Query(ctx, q)
+}
+
+func (p *OwnedOperationRepo) ByTags(ctx context.Context, tags []string) ([]service.ICommonObject, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return operationReads{ops: p.ops}.ByTags(ctx, tags)
+}
+
+func (p *OwnedOperationRepo) TimeSeries(ctx context.Context, accountID *service.ObjectID, from, to time.Time, g operationrepo.Granularity, loc *time.Location) ([]operationrepo.SeriesPoint, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return operationReads{ops: p.ops}.TimeSeries(ctx, accountID, from, to, g, loc)
+}
+
+type ownedSearchRepo struct {
+	*OwnedOperationRepo
+	search operationrepo.ISearchRepo
+}
+
+func (p *ownedSearchRepo) Search(ctx context.Context, query string, limit int) ([]search.Hit, error) {
+	ctx, err := p.scope(ctx, p.roles.Read)
+	if err != nil {
+		return nil, err
+	}
+	return p.search.Search(ctx, query, limit)
+}
@@ -0,0 +1,16 @@
+package proxyrepo
+
+import (
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+)
+
+// Версионирование операций; выборки и агрегаты уходят в хранилище как есть.
+type VersionedOperationRepo struct {
+	*VersionedRepo
+	operationReads
+}
+
+func NewVersionedOperationRepo(ops operationrepo.IOperationRepo, history historyrepo.IHistoryRepo) *VersionedOperationRepo {
+	return &VersionedOperationRepo{VersionedRepo: NewVersionedRepo(ops, history, historyrepo.OperationCodec), operationReads: operationReads{ops: ops}}
+}
@@ -0,0 +1,123 @@
+package proxyrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Пишет версию (снимки до и после) на каждое успешное изменение через репозиторий.
+// Изменение и версия идут одной единицей работы: в БД — одной транзакцией.
+// Снимок «до» читается из хранилища до изменения: хранилища отдают копии, правка фасада
+// в них не попадает, а чтение без области видимости находит и строку общего счёта.
+type VersionedRepo struct {
+	repo    repository.ICommonRepo
+	history historyrepo.IHistoryRepo
+	codec   historyrepo.Codec
+	now     func() time.Time
+
+	mu sync.Mutex // изменение и запись версии одной парой
+}
+
+func NewVersionedRepo(repo repository.ICommonRepo, history historyrepo.IHistoryRepo, codec historyrepo.Codec) *VersionedRepo {
+	return &VersionedRepo{repo: repo, history: history, codec: codec, now: time.Now}
+}
+
+func (p *VersionedRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	return p.repo.ByID(ctx, id)
+}
+
+func (p *VersionedRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	return p.repo.All(ctx)
+}
+
+func (p *VersionedRepo) Save(ctx context.Context, obj service.ICommonObject) error {
+	after, err := p.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return repository.Atomic(ctx, func(ctx context.Context) error {
+		if err := p.repo.Save(ctx, obj); err != nil {
+			return err
+		}
+		return p.record(ctx, obj.ID(), "I", nil, after)
+	})
+}
+
+func (p *VersionedRepo) Update(ctx context.Context, obj service.ICommonObject) error {
+	after, err := p.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return repository.Atomic(ctx, func(ctx context.Context) error {
+		before, err := p.before(ctx, obj.ID())
+		if err != nil {
+			return err
+		}
+		if err := p.repo.Update(ctx, obj); err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			return nil // ничего не поменялось — версию не пишем
+		}
+		return p.record(ctx, obj.ID(), "U", before, after)
+	})
+}
+
+func (p *VersionedRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return repository.Atomic(ctx, func(ctx context.Context) error {
+		before, err := p.before(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := p.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return p.record(ctx, id, "D", before, nil)
+	})
+}
+
+func (p *VersionedRepo) before(ctx context.Context, id service.ObjectID) (json.RawMessage, error) {
+	obj, err := p.repo.ByID(service.Unscoped(ctx), id)
+	if errors.Is(err, service.ErrNotFound) {
+		return nil, nil // строки нет — Update/Delete сами вернут ошибку
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.codec.Encode(obj)
+}
+
+// Версия получает владельца сущности: её историю увидит только он.
+func (p *VersionedRepo) record(ctx context.Context, id service.ObjectID, op string, before, after json.RawMessage) error {
+	snap := after
+	if len(snap) == 0 {
+		snap = before
+	}
+	owner, err := historyrepo.SnapshotOwner(snap)
+	if err != nil {
+		return err
+	}
+	return p.history.Append(ctx, &historyrepo.Version{
+		Entity:    p.codec.Entity,
+		EntityID:  id,
+		Op:        op,
+		Before:    before,
+		After:     after,
+		ChangedAt: p.now(),
+		OwnerID:   owner,
+	})
+}
@@ -0,0 +1,52 @@
+package proxyrepo
+
+import (
+	"context"
+	"time"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+type Invalidator interface {
+	Invalidate(id service.ObjectID)
+	InvalidateAll()
+}
+
+// Применяет изменения из ленты к кэшам таблиц; возвращает число обработанных изменений.
+func ApplyChanges(changes []repository.Change, caches map[string]Invalidator) int {
+	for _, ch := range changes {
+		if c, ok := caches[ch.Table]; ok {
+			c.Invalidate(ch.ID)
+		}
+	}
+	return len(changes)
+}
+
+// Опрашивает ленту изменений раз в interval, пока не отменён ctx.
+// Если ленту прочитать не удалось, кэши сбрасываются целиком: пропущенные изменения неизвестны.
+func Watch(ctx context.Context, feed repository.IChangeFeed, interval time.Duration, caches map[string]Invalidator, onErr func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changes, err := feed.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			for _, c := range caches {
+				c.InvalidateAll()
+			}
+			if onErr != nil {
+				onErr(err)
+			}
+			continue
+		}
+		ApplyChanges(changes, caches)
+	}
+}
@@ -0,0 +1,83 @@
+package reconciliationrepo
+
+import (
+	"context"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	reconciliation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Reconciliation"
+)
+
+// Хранилища, которые отбирают сверки счёта сами (в БД — по индексу account_id),
+// а не перебирают все сверки.
+type IAccountRepo interface {
+	ByAccount(ctx context.Context, accountID service.ObjectID) ([]service.ICommonObject, error)
+}
+
+type ReconciliationRepo struct {
+	repo map[service.ObjectID]*reconciliation.Reconciliation
+}
+
+func NewReconciliationRepo() *ReconciliationRepo {
+	return &ReconciliationRepo{make(map[service.ObjectID]*reconciliation.Reconciliation)}
+}
+
+func (r *ReconciliationRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	rc, ok := r.repo[id]
+	if !ok || !service.VisibleTo(ctx, rc) {
+		return nil, service.NotFound("reconciliation not found")
+	}
+	return service.CopyOf(rc), nil
+}
+
+func (r *ReconciliationRepo) Save(ctx context.Context, rc service.ICommonObject) error {
+	if _, ok := r.repo[rc.ID()]; ok {
+		return service.Conflict("reconciliation already saved")
+	}
+	i, ok := rc.(*reconciliation.Reconciliation)
+	if !ok {
+		return service.Invariant("invalid reconciliation type")
+	}
+	repository.Put(ctx, r.repo, rc.ID(), service.CopyOf(i).(*reconciliation.Reconciliation))
+	return nil
+}
+
+func (r *ReconciliationRepo) Update(ctx context.Context, rc service.ICommonObject) error {
+	if cur, ok := r.repo[rc.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("reconciliation not found")
+	}
+	i, ok := rc.(*reconciliation.Reconciliation)
+	if !ok {
+		return service.Invariant("invalid reconciliation type")
+	}
+	repository.Put(ctx, r.repo, rc.ID(), service.CopyOf(i).(*reconciliation.Reconciliation))
+	return nil
+}
+
+func (r *ReconciliationRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	recs := make([]service.ICommonObject, 0, len(r.repo))
+	for _, rc := range r.repo {
+		if service.VisibleTo(ctx, rc) {
+			recs = append(recs, service.CopyOf(rc))
+		}
+	}
+	return recs, nil
+}
+
+func (r *ReconciliationRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("reconciliation not found")
+	}
+	repository.Remove(ctx, r.repo, id)
+	return nil
+}
+
+func (r *ReconciliationRepo) ByAccount(ctx context.Context, accountID service.ObjectID) ([]service.ICommonObject, error) {
+	var out []service.ICommonObject
+	for _, rc := range r.repo {
+		if rc.AccountID() == accountID && service.VisibleTo(ctx, rc) {
+			out = append(out, service.CopyOf(rc))
+		}
+	}
+	return out, nil
+}
@@ -9,6 +9,26 @@ import (
 type ICommonRepo interface {
 	ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error)
 	Save(ctx context.Context, obj service.ICommonObject) error
+	Update(ctx context.Context, obj service.ICommonObject) error
 	All(ctx context.Context) ([]service.ICommonObject, error)
 	Delete(ctx context.Context, id service.ObjectID) error
 }
+
+// Запись об изменении строки в хранилище (в том числе сделанном другим процессом).
+type Change struct {
+	Seq   int64
+	Table string
+	ID    service.ObjectID
+	Op    string // "I", "U" или "D"
+}
+
+type IChangeFeed interface {
+	// Изменения, появившиеся с прошлого вызова.
+	Poll(ctx context.Context) ([]Change, error)
+}
+
+// Репозиторий, который может запретить правку объекта. Фасады, меняющие объекты на месте,
+// спрашивают его до правки.
+type IEditGuard interface {
+	CheckEditable(ctx context.Context, id service.ObjectID) error
+}
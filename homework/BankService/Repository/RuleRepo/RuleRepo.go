@@ -0,0 +1,67 @@
+package rulerepo
+
+import (
+	"context"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+)
+
+type RuleRepo struct {
+	repo map[service.ObjectID]*rule.Rule
+}
+
+func NewRuleRepo() *RuleRepo {
+	return &RuleRepo{make(map[service.ObjectID]*rule.Rule)}
+}
+
+func (r *RuleRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	rl, ok := r.repo[id]
+	if !ok || !service.VisibleTo(ctx, rl) {
+		return nil, service.NotFound("rule not found")
+	}
+	return service.CopyOf(rl), nil
+}
+
+func (r *RuleRepo) Save(ctx context.Context, rl service.ICommonObject) error {
+	if _, ok := r.repo[rl.ID()]; ok {
+		return service.Conflict("rule already saved")
+	}
+	i, ok := rl.(*rule.Rule)
+	if !ok {
+		return service.Invariant("invalid rule type")
+	}
+	repository.Put(ctx, r.repo, rl.ID(), service.CopyOf(i).(*rule.Rule))
+	return nil
+}
+
+func (r *RuleRepo) Update(ctx context.Context, rl service.ICommonObject) error {
+	if cur, ok := r.repo[rl.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("rule not found")
+	}
+	i, ok := rl.(*rule.Rule)
+	if !ok {
+		return service.Invariant("invalid rule type")
+	}
+	repository.Put(ctx, r.repo, rl.ID(), service.CopyOf(i).(*rule.Rule))
+	return nil
+}
+
+func (r *RuleRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	rules := make([]service.ICommonObject, 0, len(r.repo))
+	for _, rl := range r.repo {
+		if service.VisibleTo(ctx, rl) {
+			rules = append(rules, service.CopyOf(rl))
+		}
+	}
+	return rules, nil
+}
+
+func (r *RuleRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("rule not found")
+	}
+	repository.Remove(ctx, r.repo, id)
+	return nil
+}
@@ -0,0 +1,84 @@
+package sharerepo
+
+import (
+	"context"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	share "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Share"
+)
+
+// Хранилища, которые отбирают доступы получателя сами (в БД — по индексу user_id),
+// а не перебирают все доступы.
+type IGranteeRepo interface {
+	SharesFor(ctx context.Context, userID service.ObjectID) ([]service.ICommonObject, error)
+}
+
+type ShareRepo struct {
+	repo map[service.ObjectID]*share.AccountShare
+}
+
+func NewShareRepo() *ShareRepo {
+	return &ShareRepo{make(map[service.ObjectID]*share.AccountShare)}
+}
+
+func (r *ShareRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	sh, ok := r.repo[id]
+	if !ok || !service.VisibleTo(ctx, sh) {
+		return nil, service.NotFound("share not found")
+	}
+	return service.CopyOf(sh), nil
+}
+
+func (r *ShareRepo) Save(ctx context.Context, sh service.ICommonObject) error {
+	if _, ok := r.repo[sh.ID()]; ok {
+		return service.Conflict("share already saved")
+	}
+	i, ok := sh.(*share.AccountShare)
+	if !ok {
+		return service.Invariant("invalid share type")
+	}
+	repository.Put(ctx, r.repo, sh.ID(), service.CopyOf(i).(*share.AccountShare))
+	return nil
+}
+
+func (r *ShareRepo) Update(ctx context.Context, sh service.ICommonObject) error {
+	if cur, ok := r.repo[sh.ID()]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("share not found")
+	}
+	i, ok := sh.(*share.AccountShare)
+	if !ok {
+		return service.Invariant("invalid share type")
+	}
+	repository.Put(ctx, r.repo, sh.ID(), service.CopyOf(i).(*share.AccountShare))
+	return nil
+}
+
+func (r *ShareRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	shares := make([]service.ICommonObject, 0, len(r.repo))
+	for _, sh := range r.repo {
+		if service.VisibleTo(ctx, sh) {
+			shares = append(shares, service.CopyOf(sh))
+		}
+	}
+	return shares, nil
+}
+
+func (r *ShareRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if cur, ok := r.repo[id]; !ok || !service.WritableTo(ctx, cur) {
+		return service.NotFound("share not found")
+	}
+	repository.Remove(ctx, r.repo, id)
+	return nil
+}
+
+// Доступы, выданные пользователю userID; видны ему независимо от владельца счёта.
+func (r *ShareRepo) SharesFor(ctx context.Context, userID service.ObjectID) ([]service.ICommonObject, error) {
+	var out []service.ICommonObject
+	for _, sh := range r.repo {
+		if sh.UserID() == userID {
+			out = append(out, service.CopyOf(sh))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+)
+
+type unitKey struct{}
+
+// Единица работы: изменения хранилищ, сделанные с её контекстом, применяются все вместе или ни одно.
+// Хранилища в памяти записывают отмену каждого изменения (OnRollback), БД ведёт одну транзакцию
+// на всю единицу (Attach).
+type Unit struct {
+	ctx       context.Context
+	undo      []func()
+	resources map[any]*resource
+	order     []*resource
+}
+
+type resource struct {
+	value    any
+	commit   func() error
+	rollback func()
+}
+
+// Выполняет fn в единице работы. Вложенный вызов присоединяется к внешней единице.
+func Atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := UnitFrom(ctx); ok {
+		return fn(ctx)
+	}
+	u := &Unit{ctx: ctx, resources: make(map[any]*resource)}
+	err := fn(context.WithValue(ctx, unitKey{}, u))
+	if err == nil {
+		err = u.commit()
+	}
+	if err != nil {
+		u.rollback()
+	}
+	return err
+}
+
+func UnitFrom(ctx context.Context) (*Unit, bool) {
+	u, ok := ctx.Value(unitKey{}).(*Unit)
+	return u, ok
+}
+
+// Изменение в памяти будет отменено, если единица работы, в которой оно сделано, не завершится.
+// Вне единицы работы ничего не делает.
+func OnRollback(ctx context.Context, undo func()) {
+	if u, ok := UnitFrom(ctx); ok {
+		u.undo = append(u.undo, undo)
+	}
+}
+
+// Контекст, с которым открыта единица: транзакции живут, пока он не отменён.
+func (u *Unit) Context() context.Context { return u.ctx }
+
+// Ресурс единицы (например, транзакция БД), открытый раньше с тем же ключом.
+func (u *Unit) Resource(key any) (any, bool) {
+	r, ok := u.resources[key]
+	if !ok {
+		return nil, false
+	}
+	return r.value, true
+}
+
+// Подключает ресурс: commit вызывается при успешном завершении, rollback — при отмене.
+func (u *Unit) Attach(key, value any, commit func() error, rollback func()) {
+	r := &resource{value: value, commit: commit, rollback: rollback}
+	u.resources[key] = r
+	u.order = append(u.order, r)
+}
+
+func (u *Unit) commit() error {
+	for i, r := range u.order {
+		if err := r.commit(); err != nil {
+			// уже зафиксированные ресурсы не откатить; остальные откатываются вместе с памятью
+			u.order = u.order[i+1:]
+			return err
+		}
+	}
+	u.order = nil
+	return nil
+}
+
+func (u *Unit) rollback() {
+	for _, r := range u.order {
+		r.rollback()
+	}
+	for i := len(u.undo) - 1; i >= 0; i-- {
+		u.undo[i]()
+	}
+}
+
+// Запись в хранилище в памяти, отменяемая вместе с единицей работы.
+func Put[K comparable, V any](ctx context.Context, m map[K]V, k K, v V) {
+	prev, had := m[k]
+	m[k] = v
+	OnRollback(ctx, func() { restore(m, k, prev, had) })
+}
+
+func Remove[K comparable, V any](ctx context.Context, m map[K]V, k K) {
+	prev, had := m[k]
+	delete(m, k)
+	OnRollback(ctx, func() { restore(m, k, prev, had) })
+}
+
+func restore[K comparable, V any](m map[K]V, k K, prev V, had bool) {
+	if had {
+		m[k] = prev
+	} else {
+		delete(m, k)
+	}
+}
@@ -0,0 +1,65 @@
+package userrepo
+
+import (
+	"context"
+
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
+)
+
+type UserRepo struct {
+	repo map[service.ObjectID]*user.User
+}
+
+func NewUserRepo() *UserRepo {
+	return &UserRepo{make(map[service.ObjectID]*user.User)}
+}
+
+func (r *UserRepo) ByID(ctx context.Context, id service.ObjectID) (service.ICommonObject, error) {
+	u, ok := r.repo[id]
+	if !ok {
+		return nil, service.NotFound("user not found")
+	}
+	return service.CopyOf(u), nil
+}
+
+func (r *UserRepo) Save(ctx context.Context, u service.ICommonObject) error {
+	if _, ok := r.repo[u.ID()]; ok {
+		return service.Conflict("user already saved")
+	}
+	x, ok := u.(*user.User)
+	if !ok {
+		return service.Invariant("invalid user type")
+	}
+	repository.Put(ctx, r.repo, u.ID(), service.CopyOf(x).(*user.User))
+	return nil
+}
+
+func (r *UserRepo) Update(ctx context.Context, u service.ICommonObject) error {
+	if _, ok := r.repo[u.ID()]; !ok {
+		return service.NotFound("user not found")
+	}
+	x, ok := u.(*user.User)
+	if !ok {
+		return service.Invariant("invalid user type")
+	}
+	repository.Put(ctx, r.repo, u.ID(), service.CopyOf(x).(*user.User))
+	return nil
+}
+
+func (r *UserRepo) All(ctx context.Context) ([]service.ICommonObject, error) {
+	users := make([]service.ICommonObject, 0, len(r.repo))
+	for _, u := range r.repo {
+		users = append(users, service.CopyOf(u))
+	}
+	return users, nil
+}
+
+func (r *UserRepo) Delete(ctx context.Context, id service.ObjectID) error {
+	if _, ok := r.repo[id]; !ok {
+		return service.NotFound("user not found")
+	}
+	repository.Remove(ctx, r.repo, id)
+	return nil
+}
@@ -0,0 +1,261 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Найденные слова обрамляются этими маркерами (как StartSel/StopSel в ts_headline).
+const (
+	HighlightStart = "**"
+	HighlightStop  = "**"
+)
+
+type Field int
+
+const (
+	Description Field = iota
+	CategoryName
+	AccountName
+)
+
+// Вес поля в ранге: совпадение в описании важнее, чем в названии категории или счёта.
+var fieldWeights = [...]float64{Description: 1.0, CategoryName: 0.4, AccountName: 0.2}
+
+type Hit struct {
+	Operation   operation.IOperation
+	Rank        float64
+	Description string // описание с выделенными совпадениями
+	Category    string
+	Account     string
+}
+
+// Слова в нижнем регистре: последовательности букв и цифр (любой алфавит).
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Выделяет слова, начинающиеся с одного из термов запроса.
+func Highlight(text string, terms []string) string {
+	var (
+		b     strings.Builder
+		word  []rune
+		flush = func() {
+			if len(word) == 0 {
+				return
+			}
+			w := string(word)
+			if matchesAny(strings.ToLower(w), terms) {
+				b.WriteString(HighlightStart + w + HighlightStop)
+			} else {
+				b.WriteString(w)
+			}
+			word = word[:0]
+		}
+	)
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+func matchesAny(word string, terms []string) bool {
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) {
+			return true
+		}
+	}
+	return false
+}
+
+type document struct {
+	op       operation.IOperation
+	category string
+	account  string
+}
+
+type posting struct {
+	doc   service.ObjectID
+	field Field
+	tf    int
+}
+
+// Инвертированный индекс операций по описанию и названиям категории и счёта.
+// Словарь сортируется один раз перед поиском, а не на каждом Add.
+type Index struct {
+	docs     map[service.ObjectID]document
+	postings map[string][]posting
+	terms    []string // отсортированный словарь для поиска по префиксу
+	sorted   bool     // false — словарь изменился и будет пересобран при поиске
+}
+
+func NewIndex() *Index {
+	return &Index{docs: make(map[service.ObjectID]document), postings: make(map[string][]posting), sorted: true}
+}
+
+// Добавляет операцию или обновляет уже проиндексированную. Если тексты не изменились,
+// заменяется только сама операция.
+func (ix *Index) Add(op operation.IOperation, categoryName, accountName string) {
+	if d, ok := ix.docs[op.ID()]; ok {
+		if d.op.Description() == op.Description() && d.category == categoryName && d.account == accountName {
+			d.op = op
+			ix.docs[op.ID()] = d
+			return
+		}
+		ix.Remove(op.ID())
+	}
+	ix.docs[op.ID()] = document{op: op, category: categoryName, account: accountName}
+	for field, text := range map[Field]string{Description: op.Description(), CategoryName: categoryName, AccountName: accountName} {
+		counts := make(map[string]int)
+		for _, t := range Tokenize(text) {
+			counts[t]++
+		}
+		for t, tf := range counts {
+			if _, ok := ix.postings[t]; !ok {
+				ix.sorted = false
+			}
+			ix.postings[t] = append(ix.postings[t], posting{doc: op.ID(), field: field, tf: tf})
+		}
+	}
+}
+
+func (ix *Index) Remove(id service.ObjectID) {
+	d, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for _, text := range []string{d.op.Description(), d.category, d.account} {
+		for _, t := range Tokenize(text) {
+			ps, ok := ix.postings[t]
+			if !ok {
+				continue
+			}
+			kept := ps[:0]
+			for _, p := range ps {
+				if p.doc != id {
+					kept = append(kept, p)
+				}
+			}
+			if len(kept) == 0 {
+				delete(ix.postings, t)
+				ix.sorted = false
+			} else {
+				ix.postings[t] = kept
+			}
+		}
+	}
+}
+
+// ID проиндексированных операций.
+func (ix *Index) IDs() []service.ObjectID {
+	ids := make([]service.ObjectID, 0, len(ix.docs))
+	for id := range ix.docs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (ix *Index) Len() int { return len(ix.docs) }
+
+// Все слова запроса должны встретиться хотя бы в одном поле; каждое слово ищется по префиксу.
+func (ix *Index) Search(query string, limit int) []Hit {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	n := float64(len(ix.docs))
+	scores := make(map[service.ObjectID]float64)
+	for i, term := range terms {
+		matched := make(map[service.ObjectID]float64)
+		for _, t := range ix.expand(term) {
+			ps := ix.postings[t]
+			df := make(map[service.ObjectID]struct{}, len(ps))
+			for _, p := range ps {
+				df[p.doc] = struct{}{}
+			}
+			idf := math.Log(1 + n/float64(len(df)))
+			for _, p := range ps {
+				tf := float64(p.tf)
+				matched[p.doc] += fieldWeights[p.field] * tf / (tf + 1) * idf
+			}
+		}
+		if i == 0 {
+			scores = matched
+			continue
+		}
+		for id := range scores {
+			if s, ok := matched[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		d := ix.docs[id]
+		hits = append(hits, Hit{
+			Operation:   d.op,
+			Rank:        score,
+			Description: Highlight(d.op.Description(), terms),
+			Category:    Highlight(d.category, terms),
+			Account:     Highlight(d.account, terms),
+		})
+	}
+	SortHits(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Слова словаря, начинающиеся с term.
+func (ix *Index) expand(term string) []string {
+	if !ix.sorted {
+		ix.terms = ix.terms[:0]
+		for t := range ix.postings {
+			ix.terms = append(ix.terms, t)
+		}
+		sort.Strings(ix.terms)
+		ix.sorted = true
+	}
+	var out []string
+	for i := sort.SearchStrings(ix.terms, term); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], term); i++ {
+		out = append(out, ix.terms[i])
+	}
+	return out
+}
+
+// По убыванию ранга, при равенстве — сначала более свежие.
+func SortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].Operation.Date().After(hits[j].Operation.Date())
+	})
+}
+
+// tsquery для Postgres: термы из Tokenize, каждый с префиксным поиском; op — "&" или "|".
+func TSQuery(query, op string) string {
+	terms := Tokenize(query)
+	for i, t := range terms {
+		terms[i] = t + ":*"
+	}
+	return strings.Join(terms, " "+op+" ")
+}
@@ -0,0 +1,33 @@
+package service
+
+import "time"
+
+// Мягкое удаление: объект скрыт из списков, но остаётся для истории и аналитики.
+type IArchivable interface {
+	DeletedAt() time.Time // нулевое время — объект активен
+	IsArchived() bool
+}
+
+type Archivable struct {
+	deletedAt time.Time
+}
+
+func (a *Archivable) DeletedAt() time.Time { return a.deletedAt }
+func (a *Archivable) IsArchived() bool     { return !a.deletedAt.IsZero() }
+func (a *Archivable) Archive(at time.Time) { a.deletedAt = at }
+func (a *Archivable) Restore()             { a.deletedAt = time.Time{} }
+
+// deleted_at в файлах экспорта: RFC3339 или пустая строка для активных объектов.
+func FormatDeletedAt(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func ParseDeletedAt(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -1,14 +1,14 @@
 package bankaccount
 
 import (
-	"errors"
-
 	"github.com/google/uuid"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 )
 
 type IBankAccount interface {
 	service.ICommonObject
+	service.IOwned
+	service.IArchivable
 	Name() string
 	Balance() float64
 
@@ -17,6 +17,8 @@ type IBankAccount interface {
 }
 
 type BankAccount struct {
+	service.Owned
+	service.Archivable
 	id      service.ObjectID
 	name    string
 	balance float64
@@ -24,10 +26,10 @@ type BankAccount struct {
 
 func NewBankAccount(name string, balance float64) (*BankAccount, error) {
 	if balance < 0 {
-		return nil, errors.New("balance should be >= 0")
+		return nil, service.Invalid("balance", "balance should be >= 0")
 	}
 	if name == "" {
-		return nil, errors.New("account name cannot be empty")
+		return nil, service.Invalid("name", "account name cannot be empty")
 	}
 	return &BankAccount{
 		id:      service.ObjectID(uuid.New()),
@@ -38,10 +40,10 @@ func NewBankAccount(name string, balance float64) (*BankAccount, error) {
 
 func NewCopyBankAccount(id service.ObjectID, name string, balance float64) (*BankAccount, error) {
 	if balance < 0 {
-		return nil, errors.New("balance should be >= 0")
+		return nil, service.Invalid("balance", "balance should be >= 0")
 	}
 	if name == "" {
-		return nil, errors.New("account name cannot be empty")
+		return nil, service.Invalid("name", "account name cannot be empty")
 	}
 	return &BankAccount{
 		id:      id,
@@ -55,9 +57,14 @@ func (acc *BankAccount) Name() string           { return acc.name }
 func (acc *BankAccount) Balance() float64       { return acc.balance }
 func (acc *BankAccount) SetName(newName string) { acc.name = newName }
 
+func (acc *BankAccount) Copy() service.ICommonObject {
+	c := *acc
+	return &c
+}
+
 func (acc *BankAccount) SetBalance(newBalance float64) error {
 	if newBalance < 0 {
-		return errors.New("balance should be >= 0")
+		return service.Invalid("balance", "balance should be >= 0")
 	}
 	acc.balance = newBalance
 	return nil
@@ -1,8 +1,6 @@
 package category
 
 import (
-	"errors"
-
 	"github.com/google/uuid"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 )
@@ -27,47 +25,87 @@ func (ct CategoryType) String() string {
 
 type ICategory interface {
 	service.ICommonObject
+	service.IOwned
+	service.IArchivable
 	Name() string
 	Type() CategoryType
+	ParentID() service.ObjectID // нулевой id — корневая категория
 
 	SetName(newName string)
 }
 
 type Category struct {
-	id    service.ObjectID
-	name  string
-	ctype CategoryType
+	service.Owned
+	service.Archivable
+	id       service.ObjectID
+	name     string
+	ctype    CategoryType
+	parentID service.ObjectID
+}
+
+func optionalParent(parentID []service.ObjectID) service.ObjectID {
+	if len(parentID) > 0 {
+		return parentID[0]
+	}
+	return service.ObjectID{}
 }
 
-func NewCategory(name string, ctype CategoryType) (*Category, error) {
+func NewCategory(name string, ctype CategoryType, parentID ...service.ObjectID) (*Category, error) {
 	if name == "" {
-		return nil, errors.New("category name cannot be empty")
+		return nil, service.Invalid("name", "category name cannot be empty")
 	}
 	if ctype != Spending && ctype != Income {
-		return nil, errors.New("invalid category type")
+		return nil, service.Invalid("type", "invalid category type")
 	}
-	return &Category{
-		id:    service.ObjectID(uuid.New()),
-		name:  name,
-		ctype: ctype,
-	}, nil
+	c := &Category{
+		id:       service.ObjectID(uuid.New()),
+		name:     name,
+		ctype:    ctype,
+		parentID: optionalParent(parentID),
+	}
+	if c.parentID == c.id {
+		return nil, service.Invalid("parent_id", "category cannot be its own parent")
+	}
+	return c, nil
 }
 
-func NewCopyCategory(id service.ObjectID, name string, ctype CategoryType) (*Category, error) {
+func NewCopyCategory(id service.ObjectID, name string, ctype CategoryType, parentID ...service.ObjectID) (*Category, error) {
 	if name == "" {
-		return nil, errors.New("category name cannot be empty")
+		return nil, service.Invalid("name", "category name cannot be empty")
 	}
 	if ctype != Spending && ctype != Income {
-		return nil, errors.New("invalid category type")
+		return nil, service.Invalid("type", "invalid category type")
+	}
+	c := &Category{
+		id:       id,
+		name:     name,
+		ctype:    ctype,
+		parentID: optionalParent(parentID),
 	}
-	return &Category{
-		id:    id,
-		name:  name,
-		ctype: ctype,
-	}, nil
+	if c.parentID == c.id {
+		return nil, service.Invalid("parent_id", "category cannot be its own parent")
+	}
+	return c, nil
 }
 
-func (c *Category) ID() service.ObjectID   { return c.id }
-func (c *Category) Name() string           { return c.name }
-func (c *Category) Type() CategoryType     { return c.ctype }
-func (c *Category) SetName(newName string) { c.name = newName }
+func (c *Category) ID() service.ObjectID       { return c.id }
+func (c *Category) Name() string               { return c.name }
+func (c *Category) Type() CategoryType         { return c.ctype }
+func (c *Category) ParentID() service.ObjectID { return c.parentID }
+func (c *Category) SetName(newName string)     { c.name = newName }
+
+// Категории владельца видны тем, с кем он поделился счётом.
+func (c *Category) SharedReference() {}
+
+func (c *Category) SetParentID(parentID service.ObjectID) error {
+	if parentID == c.id {
+		return service.Invalid("parent_id", "category cannot be its own parent")
+	}
+	c.parentID = parentID
+	return nil
+}
+
+func (c *Category) Copy() service.ICommonObject {
+	cp := *c
+	return &cp
+}
@@ -0,0 +1,97 @@
+package category
+
+import (
+	"sort"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+var ErrCycle = service.Invariant("category hierarchy cycle")
+
+type Tree struct {
+	parent map[service.ObjectID]service.ObjectID
+}
+
+func NewTree(cats []ICategory) *Tree {
+	t := &Tree{parent: make(map[service.ObjectID]service.ObjectID, len(cats))}
+	for _, c := range cats {
+		t.parent[c.ID()] = c.ParentID()
+	}
+	return t
+}
+
+// Цепочка от категории до корня, сама категория — первой.
+func (t *Tree) Path(id service.ObjectID) ([]service.ObjectID, error) {
+	path := []service.ObjectID{id}
+	seen := map[service.ObjectID]bool{id: true}
+	for cur := t.parent[id]; cur != (service.ObjectID{}); cur = t.parent[cur] {
+		if seen[cur] {
+			return nil, ErrCycle
+		}
+		seen[cur] = true
+		path = append(path, cur)
+	}
+	return path, nil
+}
+
+// Проверяет, не замкнёт ли новый родитель цикл.
+func (t *Tree) CanSetParent(id, parentID service.ObjectID) error {
+	if parentID == (service.ObjectID{}) {
+		return nil
+	}
+	if id == parentID {
+		return ErrCycle
+	}
+	path, err := t.Path(parentID)
+	if err != nil {
+		return err
+	}
+	for _, p := range path {
+		if p == id {
+			return ErrCycle
+		}
+	}
+	return nil
+}
+
+func (t *Tree) Children(id service.ObjectID) []service.ObjectID {
+	var out []service.ObjectID
+	for c, p := range t.parent {
+		if p == id {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Предок на уровне level (0 — корень). Если категория выше этого уровня, возвращается она сама.
+func (t *Tree) AncestorAtLevel(id service.ObjectID, level int) (service.ObjectID, error) {
+	path, err := t.Path(id)
+	if err != nil {
+		return service.ObjectID{}, err
+	}
+	depth := len(path) - 1
+	if level >= depth {
+		return id, nil
+	}
+	return path[depth-level], nil
+}
+
+// Упорядочивает категории так, чтобы родитель шёл раньше детей (нужно при вставке с внешним ключом).
+func SortParentsFirst(objs []service.ICommonObject) {
+	var cats []ICategory
+	for _, o := range objs {
+		if c, ok := o.(ICategory); ok {
+			cats = append(cats, c)
+		}
+	}
+	t := NewTree(cats)
+	depth := func(o service.ICommonObject) int {
+		path, err := t.Path(o.ID())
+		if err != nil {
+			return 0
+		}
+		return len(path)
+	}
+	sort.SliceStable(objs, func(i, j int) bool { return depth(objs[i]) < depth(objs[j]) })
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Виды ошибок домена. Текст конкретной ошибки остаётся прежним, а вид проверяется через errors.Is;
+// интерфейс (CLI) переводит его в свой код.
+var (
+	ErrNotFound   = errors.New("not found")          // объекта нет или он чужой
+	ErrValidation = errors.New("validation failed")  // неверное значение поля, см. ValidationError
+	ErrConflict   = errors.New("conflict")           // дубликат или объект не в том состоянии
+	ErrInvariant  = errors.New("invariant violated") // изменение нарушило бы правило учёта
+)
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+func NotFound(format string, args ...any) error {
+	return &kindError{kind: ErrNotFound, err: fmt.Errorf(format, args...)}
+}
+
+func Conflict(format string, args ...any) error {
+	return &kindError{kind: ErrConflict, err: fmt.Errorf(format, args...)}
+}
+
+func Invariant(format string, args ...any) error {
+	return &kindError{kind: ErrInvariant, err: fmt.Errorf(format, args...)}
+}
+
+// Неверное значение поля; Field — имя поля во входных данных (name, amount, ...).
+type ValidationError struct {
+	Field string
+	err   error
+}
+
+func Invalid(field, format string, args ...any) error {
+	return &ValidationError{Field: field, err: fmt.Errorf(format, args...)}
+}
+
+func (e *ValidationError) Error() string   { return e.err.Error() }
+func (e *ValidationError) Unwrap() []error { return []error{ErrValidation, e.err} }
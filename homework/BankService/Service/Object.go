@@ -39,3 +39,16 @@ func (id *ObjectID) Scan(src any) error {
 		return fmt.Errorf("unsupported type for ObjectID: %T", src)
 	}
 }
+
+// Объект, который хранилища в памяти и кэш отдают копией: правка полученного объекта
+// не видна другим пользователям, пока Update не прошёл все проверки.
+type ICopyable interface {
+	Copy() ICommonObject
+}
+
+func CopyOf(obj ICommonObject) ICommonObject {
+	if c, ok := obj.(ICopyable); ok {
+		return c.Copy()
+	}
+	return obj
+}
@@ -0,0 +1,39 @@
+package operation
+
+import "time"
+
+// Модель остатка счёта. Баланс счёта — начальный остаток до первой операции: операции его не
+// меняют, поэтому остаток на любой момент — это начальный остаток плюс операции не позже него.
+// Выписка, сверка, журнал проводок, прогноз и ряды аналитики считают остатки через эти функции.
+
+// Сумма со знаком: приход увеличивает остаток, расход уменьшает.
+func SignedAmount(op IOperation) float64 {
+	if op.Type() == Income {
+		return op.Amount()
+	}
+	return -op.Amount()
+}
+
+// Остаток после всех операций не позже at. opening — баланс счёта, ops — его операции.
+// Нулевое at — начальный остаток, до первой операции.
+func BalanceAt(opening float64, ops []IOperation, at time.Time) float64 {
+	b := opening
+	if at.IsZero() {
+		return b
+	}
+	for _, op := range ops {
+		if !op.Date().After(at) {
+			b += SignedAmount(op)
+		}
+	}
+	return b
+}
+
+// Остаток после всех операций счёта.
+func CurrentBalance(opening float64, ops []IOperation) float64 {
+	b := opening
+	for _, op := range ops {
+		b += SignedAmount(op)
+	}
+	return b
+}
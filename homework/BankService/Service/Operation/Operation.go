@@ -1,7 +1,7 @@
 package operation
 
 import (
-	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,15 +17,21 @@ const (
 
 type IOperation interface {
 	service.ICommonObject
+	service.IOwned
 	Type() OperationType
 	BankAccountID() service.ObjectID
 	Amount() float64
 	Date() time.Time
 	Description() string
 	CategoryID() service.ObjectID
+	Tags() []string
+	HasTag(tag string) bool
+	Splits() []SplitLine
+	CreatedBy() service.ObjectID // нулевой — операция заведена владельцем до появления общих счетов
 }
 
 type Operation struct {
+	service.Owned
 	id            service.ObjectID
 	opType        OperationType
 	bankAccountID service.ObjectID
@@ -33,6 +39,9 @@ type Operation struct {
 	date          time.Time
 	description   string
 	categoryID    service.ObjectID
+	tags          []string
+	splits        []SplitLine
+	createdBy     service.ObjectID
 }
 
 func NewOperation(
@@ -44,7 +53,7 @@ func NewOperation(
 	description ...string,
 ) (*Operation, error) {
 	if amount < 0 {
-		return nil, fmt.Errorf("amount should be >= 0")
+		return nil, service.Invalid("amount", "amount should be >= 0")
 	}
 	desc := ""
 	if len(description) > 0 {
@@ -72,7 +81,7 @@ func NewCopyOperation(
 	description ...string,
 ) (*Operation, error) {
 	if amount < 0 {
-		return nil, fmt.Errorf("amount should be >= 0")
+		return nil, service.Invalid("amount", "amount should be >= 0")
 	}
 	desc := ""
 	if len(description) > 0 {
@@ -97,3 +106,77 @@ func (o *Operation) Amount() float64                 { return o.amount }
 func (o *Operation) Date() time.Time                 { return o.date }
 func (o *Operation) Description() string             { return o.description }
 func (o *Operation) CategoryID() service.ObjectID    { return o.categoryID }
+
+func (o *Operation) CreatedBy() service.ObjectID { return o.createdBy }
+
+func (o *Operation) SetCategoryID(categoryID service.ObjectID) { o.categoryID = categoryID }
+func (o *Operation) SetCreatedBy(userID service.ObjectID)      { o.createdBy = userID }
+
+// Автор операции: кто её создал, а для старых операций — владелец.
+func Author(op IOperation) service.ObjectID {
+	if id := op.CreatedBy(); id != (service.ObjectID{}) {
+		return id
+	}
+	owner, _ := service.OwnerOf(op)
+	return owner
+}
+
+func (o *Operation) Tags() []string {
+	out := make([]string, len(o.tags))
+	copy(out, o.tags)
+	return out
+}
+
+func (o *Operation) HasTag(tag string) bool {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return false
+	}
+	for _, t := range o.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Заменяет все метки операции.
+func (o *Operation) SetTags(tags ...string) error {
+	set := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		norm, err := NormalizeTag(t)
+		if err != nil {
+			return err
+		}
+		set[norm] = struct{}{}
+	}
+	o.tags = make([]string, 0, len(set))
+	for t := range set {
+		o.tags = append(o.tags, t)
+	}
+	sort.Strings(o.tags)
+	return nil
+}
+
+func (o *Operation) AddTags(tags ...string) error {
+	return o.SetTags(append(o.Tags(), tags...)...)
+}
+
+func (o *Operation) RemoveTag(tag string) {
+	tag, _ = NormalizeTag(tag)
+	out := o.tags[:0]
+	for _, t := range o.tags {
+		if t != tag {
+			out = append(out, t)
+		}
+	}
+	o.tags = out
+}
+
+// Метки и разбивка копируются: правка копии не задевает сохранённую операцию.
+func (o *Operation) Copy() service.ICommonObject {
+	c := *o
+	c.tags = append([]string(nil), o.tags...)
+	c.splits = append([]SplitLine(nil), o.splits...)
+	return &c
+}
@@ -0,0 +1,87 @@
+package operation
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Часть операции, отнесённая к своей категории: один чек — продукты и бытовая химия.
+type SplitLine struct {
+	CategoryID service.ObjectID
+	Amount     float64
+	Note       string
+}
+
+// Суммы сравниваются с точностью до копейки.
+const splitTolerance = 0.005
+
+func (o *Operation) Splits() []SplitLine {
+	out := make([]SplitLine, len(o.splits))
+	copy(out, o.splits)
+	return out
+}
+
+// Пустой список убирает разбивку; иначе сумма частей должна совпасть с суммой операции.
+func (o *Operation) SetSplits(lines []SplitLine) error {
+	if len(lines) == 0 {
+		o.splits = nil
+		return nil
+	}
+	var total float64
+	for i, l := range lines {
+		if l.CategoryID == (service.ObjectID{}) {
+			return service.Invalid("splits", "split line %d: category is required", i+1)
+		}
+		if l.Amount <= 0 {
+			return service.Invalid("splits", "split line %d: amount should be > 0", i+1)
+		}
+		total += l.Amount
+	}
+	if math.Abs(total-o.amount) > splitTolerance {
+		return service.Invariant("split lines add up to %.2f, operation amount is %.2f", total, o.amount)
+	}
+	o.splits = make([]SplitLine, len(lines))
+	copy(o.splits, lines)
+	return nil
+}
+
+// Суммы операции по категориям: по строкам разбивки или целиком на CategoryID.
+func CategoryAmounts(op IOperation) map[service.ObjectID]float64 {
+	splits := op.Splits()
+	if len(splits) == 0 {
+		return map[service.ObjectID]float64{op.CategoryID(): op.Amount()}
+	}
+	res := make(map[service.ObjectID]float64, len(splits))
+	for _, l := range splits {
+		res[l.CategoryID] += l.Amount
+	}
+	return res
+}
+
+var ErrSplitFormat = service.Invalid("splits", "split line should look like <category_id>:<amount>[:<note>]")
+
+// Разбирает строку вида "<category_id>:<amount>[:<note>]" (ввод в CLI).
+func ParseSplitLine(s string) (SplitLine, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 3)
+	if len(parts) < 2 {
+		return SplitLine{}, ErrSplitFormat
+	}
+	catID, err := uuid.Parse(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return SplitLine{}, ErrSplitFormat
+	}
+	amount, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return SplitLine{}, ErrSplitFormat
+	}
+	line := SplitLine{CategoryID: service.ObjectID(catID), Amount: amount}
+	if len(parts) == 3 {
+		line.Note = strings.TrimSpace(parts[2])
+	}
+	return line, nil
+}
@@ -0,0 +1,41 @@
+package operation
+
+import (
+	"strings"
+	"unicode"
+
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Метка хранится в нижнем регистре без ведущего '#': "#Vacation2026" -> "vacation2026".
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" {
+		return "", service.Invalid("tags", "tag cannot be empty")
+	}
+	for _, r := range tag {
+		if unicode.IsSpace(r) || r == ',' || r == ';' || r == '#' {
+			return "", service.Invalid("tags", "tag cannot contain spaces, ',', ';' or '#'")
+		}
+	}
+	return tag, nil
+}
+
+func ParseTagList(s string, sep string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func HasAllTags(op IOperation, tags []string) bool {
+	for _, t := range tags {
+		if !op.HasTag(t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Владелец данных, созданных до появления пользователей; миграция схемы отдаёт их пользователю "default".
+var LegacyOwnerID = ObjectID(uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+
+// Объект, принадлежащий пользователю. Нулевой владелец означает LegacyOwnerID.
+type IOwned interface {
+	OwnerID() ObjectID
+	SetOwnerID(id ObjectID)
+}
+
+type Owned struct {
+	ownerID ObjectID
+}
+
+func (o *Owned) OwnerID() ObjectID      { return o.ownerID }
+func (o *Owned) SetOwnerID(id ObjectID) { o.ownerID = id }
+
+// Уровень доступа к чужому счёту.
+type AccessLevel string
+
+const (
+	ReadAccess  AccessLevel = "read"  // счёт и его операции видны
+	WriteAccess AccessLevel = "write" // ещё и операции по счёту можно создавать, менять и удалять
+)
+
+func ParseAccessLevel(s string) (AccessLevel, error) {
+	switch l := AccessLevel(strings.ToLower(strings.TrimSpace(s))); l {
+	case ReadAccess, WriteAccess:
+		return l, nil
+	default:
+		return "", Invalid("level", "unknown access level %q (read, write)", s)
+	}
+}
+
+// Что видит пользователь: свои объекты и чужие счета, которыми с ним поделились.
+type Scope struct {
+	User     ObjectID
+	Accounts map[ObjectID]AccessLevel
+	Sharers  map[ObjectID]bool // владельцы этих счетов
+}
+
+// Объект, доступ к которому следует за доступом к его счёту (операция).
+type IAccountBound interface {
+	BankAccountID() ObjectID
+}
+
+// Справочник владельца (категории), который читают все, с кем он поделился счётом:
+// иначе операции общего счёта не с чем сопоставить.
+type ISharedReference interface {
+	SharedReference()
+}
+
+type ownerKey struct{}
+
+// Репозитории видят и меняют только строки владельца из контекста.
+// Системный доступ (загрузка кэша, журнал изменений) объявляется через Unscoped.
+func WithOwner(ctx context.Context, id ObjectID) context.Context {
+	return WithScope(ctx, Scope{User: id})
+}
+
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, ownerKey{}, s)
+}
+
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	s, ok := ctx.Value(ownerKey{}).(Scope)
+	return s, ok
+}
+
+func OwnerFrom(ctx context.Context) (ObjectID, bool) {
+	s, ok := ScopeFrom(ctx)
+	return s.User, ok
+}
+
+type systemKey struct{}
+
+// Снимает ограничение по владельцу: системная запись, например версии в истории.
+// Такой доступ объявляется явно: контекст без владельца и без этой отметки
+// в Postgres не видит ни одной строки.
+func Unscoped(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, systemKey{}, true)
+	if _, ok := OwnerFrom(ctx); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ownerKey{}, nil)
+}
+
+func IsUnscoped(ctx context.Context) bool {
+	v, _ := ctx.Value(systemKey{}).(bool)
+	return v
+}
+
+func OwnerOf(obj any) (ObjectID, bool) {
+	o, ok := obj.(IOwned)
+	if !ok {
+		return ObjectID{}, false
+	}
+	if id := o.OwnerID(); id != (ObjectID{}) {
+		return id, true
+	}
+	return LegacyOwnerID, true
+}
+
+// Контекст владельца объекта: запреты на операцию общего счёта (закрытые периоды, сверки)
+// берутся у владельца счёта, а не у того, кто её правит.
+func AsOwnerOf(ctx context.Context, obj any) context.Context {
+	if _, scoped := ScopeFrom(ctx); !scoped {
+		return ctx
+	}
+	if owner, ok := OwnerOf(obj); ok {
+		return WithOwner(ctx, owner)
+	}
+	return ctx
+}
+
+// Объект без владельца (не IOwned) виден всем.
+func VisibleTo(ctx context.Context, obj any) bool {
+	s, scoped := ScopeFrom(ctx)
+	if !scoped {
+		return true
+	}
+	owner, owned := OwnerOf(obj)
+	if !owned || owner == s.User {
+		return true
+	}
+	if b, ok := obj.(IAccountBound); ok {
+		_, shared := s.Accounts[b.BankAccountID()]
+		return shared
+	}
+	if _, ok := obj.(ISharedReference); ok {
+		return s.Sharers[owner]
+	}
+	if x, ok := obj.(interface{ ID() ObjectID }); ok {
+		_, shared := s.Accounts[x.ID()]
+		return shared
+	}
+	return false
+}
+
+// Менять чужой объект можно, только если это операция счёта с доступом на запись.
+func WritableTo(ctx context.Context, obj any) bool {
+	s, scoped := ScopeFrom(ctx)
+	if !scoped {
+		return true
+	}
+	owner, owned := OwnerOf(obj)
+	if !owned || owner == s.User {
+		return true
+	}
+	b, ok := obj.(IAccountBound)
+	return ok && s.Accounts[b.BankAccountID()] == WriteAccess
+}
@@ -0,0 +1,98 @@
+package period
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+type Action string
+
+const (
+	Close  Action = "close"
+	Reopen Action = "reopen"
+)
+
+// Запись журнала периода: закрытия и повторные открытия не удаляются.
+type LogEntry struct {
+	Action Action    `json:"action"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Учётный период — календарный месяц в UTC. Пока он закрыт, операции с датой в нём нельзя
+// создавать, менять и удалять. Состояние определяется последней записью журнала.
+type IPeriod interface {
+	service.ICommonObject
+	service.IOwned
+	Month() time.Time
+	End() time.Time
+	IsClosed() bool
+	Log() []LogEntry
+}
+
+type Period struct {
+	service.Owned
+	id    service.ObjectID
+	month time.Time
+	log   []LogEntry
+}
+
+func NewPeriod(month time.Time) *Period {
+	return &Period{id: service.ObjectID(uuid.New()), month: MonthStart(month)}
+}
+
+func NewCopyPeriod(id service.ObjectID, month time.Time, log []LogEntry) (*Period, error) {
+	for i, e := range log {
+		if e.Action != Close && e.Action != Reopen {
+			return nil, service.Invalid("log", "unknown period action: %s", e.Action)
+		}
+		if (e.Action == Close) == (i%2 == 1) {
+			return nil, service.Invariant("period log must alternate close and reopen")
+		}
+	}
+	return &Period{id: id, month: MonthStart(month), log: append([]LogEntry(nil), log...)}, nil
+}
+
+// Первое число месяца в UTC.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (p *Period) ID() service.ObjectID { return p.id }
+func (p *Period) Month() time.Time     { return p.month }
+func (p *Period) End() time.Time       { return p.month.AddDate(0, 1, 0) }
+
+func (p *Period) IsClosed() bool {
+	return len(p.log) > 0 && p.log[len(p.log)-1].Action == Close
+}
+
+func (p *Period) Log() []LogEntry { return append([]LogEntry(nil), p.log...) }
+
+func (p *Period) Close(at time.Time) error {
+	if p.IsClosed() {
+		return service.Conflict("period is already closed")
+	}
+	p.log = append(p.log, LogEntry{Action: Close, At: at})
+	return nil
+}
+
+// Повторное открытие требует причину — она остаётся в журнале.
+func (p *Period) Reopen(at time.Time, reason string) error {
+	if !p.IsClosed() {
+		return service.Conflict("period is not closed")
+	}
+	if reason == "" {
+		return service.Invalid("reason", "reopen reason cannot be empty")
+	}
+	p.log = append(p.log, LogEntry{Action: Reopen, At: at, Reason: reason})
+	return nil
+}
+
+func (p *Period) Copy() service.ICommonObject {
+	c := *p
+	c.log = p.Log()
+	return &c
+}
@@ -0,0 +1,87 @@
+package reconciliation
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Завершённая сверка счёта с выпиской банка на дату. Операции счёта по эту дату
+// и отмеченные при сверке операции больше нельзя менять.
+type IReconciliation interface {
+	service.ICommonObject
+	service.IOwned
+	AccountID() service.ObjectID
+	StatementDate() time.Time
+	StatementBalance() float64
+	LedgerBalance() float64
+	Items() []service.ObjectID
+	HasItem(id service.ObjectID) bool
+	CreatedAt() time.Time
+}
+
+type Reconciliation struct {
+	service.Owned
+	id               service.ObjectID
+	accountID        service.ObjectID
+	statementDate    time.Time
+	statementBalance float64
+	ledgerBalance    float64
+	items            []service.ObjectID // отсортированы по строке id
+	createdAt        time.Time
+}
+
+func NewReconciliation(accountID service.ObjectID, statementDate time.Time, statementBalance, ledgerBalance float64, items []service.ObjectID) (*Reconciliation, error) {
+	return NewCopyReconciliation(service.ObjectID(uuid.New()), accountID, statementDate, statementBalance, ledgerBalance, items, time.Now())
+}
+
+func NewCopyReconciliation(
+	id, accountID service.ObjectID,
+	statementDate time.Time,
+	statementBalance, ledgerBalance float64,
+	items []service.ObjectID,
+	createdAt time.Time,
+) (*Reconciliation, error) {
+	if accountID == (service.ObjectID{}) {
+		return nil, service.Invalid("account_id", "reconciliation account cannot be empty")
+	}
+	if statementDate.IsZero() {
+		return nil, service.Invalid("statement_date", "statement date cannot be empty")
+	}
+	sorted := append([]service.ObjectID(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+	return &Reconciliation{
+		id:               id,
+		accountID:        accountID,
+		statementDate:    statementDate,
+		statementBalance: statementBalance,
+		ledgerBalance:    ledgerBalance,
+		items:            sorted,
+		createdAt:        createdAt,
+	}, nil
+}
+
+func (r *Reconciliation) ID() service.ObjectID        { return r.id }
+func (r *Reconciliation) AccountID() service.ObjectID { return r.accountID }
+func (r *Reconciliation) StatementDate() time.Time    { return r.statementDate }
+func (r *Reconciliation) StatementBalance() float64   { return r.statementBalance }
+func (r *Reconciliation) LedgerBalance() float64      { return r.ledgerBalance }
+func (r *Reconciliation) CreatedAt() time.Time        { return r.createdAt }
+
+func (r *Reconciliation) Items() []service.ObjectID {
+	return append([]service.ObjectID(nil), r.items...)
+}
+
+func (r *Reconciliation) HasItem(id service.ObjectID) bool {
+	s := id.String()
+	i := sort.Search(len(r.items), func(i int) bool { return r.items[i].String() >= s })
+	return i < len(r.items) && r.items[i] == id
+}
+
+func (r *Reconciliation) Copy() service.ICommonObject {
+	c := *r
+	c.items = append([]service.ObjectID(nil), r.items...)
+	return &c
+}
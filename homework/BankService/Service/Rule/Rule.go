@@ -0,0 +1,123 @@
+package rule
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+)
+
+// Пустые поля условия не проверяются.
+type Conditions struct {
+	DescriptionRegex    string
+	DescriptionContains string
+	MinAmount           *float64
+	MaxAmount           *float64
+	AccountID           *service.ObjectID
+	OpType              *operation.OperationType
+}
+
+type IRule interface {
+	service.ICommonObject
+	service.IOwned
+	Name() string
+	Priority() int
+	Conditions() Conditions
+	CategoryID() service.ObjectID
+	Matches(op operation.IOperation) bool
+}
+
+type Rule struct {
+	service.Owned
+	id         service.ObjectID
+	name       string
+	priority   int
+	cond       Conditions
+	categoryID service.ObjectID
+	re         *regexp.Regexp
+}
+
+func NewRule(name string, priority int, cond Conditions, categoryID service.ObjectID) (*Rule, error) {
+	return NewCopyRule(service.ObjectID(uuid.New()), name, priority, cond, categoryID)
+}
+
+func NewCopyRule(id service.ObjectID, name string, priority int, cond Conditions, categoryID service.ObjectID) (*Rule, error) {
+	if name == "" {
+		return nil, service.Invalid("name", "rule name cannot be empty")
+	}
+	if categoryID == (service.ObjectID{}) {
+		return nil, service.Invalid("category_id", "rule category cannot be empty")
+	}
+	if cond.MinAmount != nil && cond.MaxAmount != nil && *cond.MinAmount > *cond.MaxAmount {
+		return nil, service.Invalid("max_amount", "min amount should be <= max amount")
+	}
+	if cond.OpType != nil && *cond.OpType != operation.Spending && *cond.OpType != operation.Income {
+		return nil, service.Invalid("op_type", "invalid operation type")
+	}
+	r := &Rule{id: id, name: name, priority: priority, cond: cond, categoryID: categoryID}
+	if cond.DescriptionRegex != "" {
+		re, err := regexp.Compile("(?i)" + cond.DescriptionRegex)
+		if err != nil {
+			return nil, service.Invalid("description_regex", "invalid description regex: %w", err)
+		}
+		r.re = re
+	}
+	return r, nil
+}
+
+func (r *Rule) ID() service.ObjectID         { return r.id }
+func (r *Rule) Name() string                 { return r.name }
+func (r *Rule) Priority() int                { return r.priority }
+func (r *Rule) Conditions() Conditions       { return r.cond }
+func (r *Rule) CategoryID() service.ObjectID { return r.categoryID }
+
+func (r *Rule) Matches(op operation.IOperation) bool {
+	c := r.cond
+	if c.AccountID != nil && op.BankAccountID() != *c.AccountID {
+		return false
+	}
+	if c.OpType != nil && op.Type() != *c.OpType {
+		return false
+	}
+	if c.MinAmount != nil && op.Amount() < *c.MinAmount {
+		return false
+	}
+	if c.MaxAmount != nil && op.Amount() > *c.MaxAmount {
+		return false
+	}
+	if c.DescriptionContains != "" && !strings.Contains(strings.ToLower(op.Description()), strings.ToLower(c.DescriptionContains)) {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(op.Description()) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) Copy() service.ICommonObject {
+	c := *r
+	c.cond = r.cond.copy()
+	return &c
+}
+
+func (c Conditions) copy() Conditions {
+	if c.MinAmount != nil {
+		v := *c.MinAmount
+		c.MinAmount = &v
+	}
+	if c.MaxAmount != nil {
+		v := *c.MaxAmount
+		c.MaxAmount = &v
+	}
+	if c.AccountID != nil {
+		v := *c.AccountID
+		c.AccountID = &v
+	}
+	if c.OpType != nil {
+		v := *c.OpType
+		c.OpType = &v
+	}
+	return c
+}
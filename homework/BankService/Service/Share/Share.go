@@ -0,0 +1,67 @@
+package share
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Доступ другого пользователя к счёту. Владелец доступа — владелец счёта:
+// только он выдаёт и отзывает доступ; на один счёт у пользователя не больше одного доступа.
+type IAccountShare interface {
+	service.ICommonObject
+	service.IOwned
+	AccountID() service.ObjectID
+	UserID() service.ObjectID
+	Level() service.AccessLevel
+	CreatedAt() time.Time
+
+	SetLevel(level service.AccessLevel) error
+}
+
+type AccountShare struct {
+	service.Owned
+	id        service.ObjectID
+	accountID service.ObjectID
+	userID    service.ObjectID
+	level     service.AccessLevel
+	createdAt time.Time
+}
+
+func NewAccountShare(accountID, userID service.ObjectID, level service.AccessLevel, createdAt time.Time) (*AccountShare, error) {
+	return NewCopyAccountShare(service.ObjectID(uuid.New()), accountID, userID, level, createdAt)
+}
+
+func NewCopyAccountShare(id, accountID, userID service.ObjectID, level service.AccessLevel, createdAt time.Time) (*AccountShare, error) {
+	if accountID == (service.ObjectID{}) {
+		return nil, service.Invalid("account_id", "share account cannot be empty")
+	}
+	if userID == (service.ObjectID{}) {
+		return nil, service.Invalid("user_id", "share user cannot be empty")
+	}
+	s := &AccountShare{id: id, accountID: accountID, userID: userID, createdAt: createdAt}
+	if err := s.SetLevel(level); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *AccountShare) ID() service.ObjectID        { return s.id }
+func (s *AccountShare) AccountID() service.ObjectID { return s.accountID }
+func (s *AccountShare) UserID() service.ObjectID    { return s.userID }
+func (s *AccountShare) Level() service.AccessLevel  { return s.level }
+func (s *AccountShare) CreatedAt() time.Time        { return s.createdAt }
+
+func (s *AccountShare) SetLevel(level service.AccessLevel) error {
+	if _, err := service.ParseAccessLevel(string(level)); err != nil {
+		return err
+	}
+	s.level = level
+	return nil
+}
+
+func (s *AccountShare) Copy() service.ICommonObject {
+	c := *s
+	return &c
+}
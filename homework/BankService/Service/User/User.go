@@ -0,0 +1,91 @@
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+)
+
+// Имя пользователя по умолчанию — владельца данных, созданных до появления пользователей.
+const DefaultName = "default"
+
+// Роль определяет, какие действия доступны: каждая следующая включает предыдущие.
+type Role string
+
+const (
+	Viewer Role = "viewer" // только чтение, отчёты и экспорт
+	Editor Role = "editor" // ведение счетов, категорий и операций
+	Admin  Role = "admin"  // удаление счетов, импорт, периоды, пользователи
+)
+
+var roleRank = map[Role]int{Viewer: 1, Editor: 2, Admin: 3}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleRank[r]; !ok {
+		return "", service.Invalid("role", "unknown role %q (viewer, editor, admin)", s)
+	}
+	return r, nil
+}
+
+// Роль r позволяет то, что требует required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+type IUser interface {
+	service.ICommonObject
+	Name() string
+	Role() Role
+	// Пустой хеш — пароль ещё не задан (пользователь default до первого входа).
+	PasswordHash() string
+	CreatedAt() time.Time
+
+	SetRole(r Role) error
+	SetPasswordHash(hash string)
+}
+
+type User struct {
+	id           service.ObjectID
+	name         string
+	role         Role
+	passwordHash string
+	createdAt    time.Time
+}
+
+func NewUser(name string, role Role, createdAt time.Time) (*User, error) {
+	return NewCopyUser(service.ObjectID(uuid.New()), name, role, "", createdAt)
+}
+
+func NewCopyUser(id service.ObjectID, name string, role Role, passwordHash string, createdAt time.Time) (*User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, service.Invalid("name", "user name cannot be empty")
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+	return &User{id: id, name: name, role: role, passwordHash: passwordHash, createdAt: createdAt}, nil
+}
+
+func (u *User) ID() service.ObjectID        { return u.id }
+func (u *User) Name() string                { return u.name }
+func (u *User) Role() Role                  { return u.role }
+func (u *User) PasswordHash() string        { return u.passwordHash }
+func (u *User) CreatedAt() time.Time        { return u.createdAt }
+func (u *User) SetPasswordHash(hash string) { u.passwordHash = hash }
+
+func (u *User) SetRole(r Role) error {
+	if _, err := ParseRole(string(r)); err != nil {
+		return err
+	}
+	u.role = r
+	return nil
+}
+
+func (u *User) Copy() service.ICommonObject {
+	c := *u
+	return &c
+}
@@ -1,6 +1,7 @@
 package timer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -15,9 +16,9 @@ func NewTimerDecorator(cmd command.Command) *TimerDecorator {
 	return &TimerDecorator{command: cmd}
 }
 
-func (t *TimerDecorator) Execute() error {
+func (t *TimerDecorator) Execute(ctx context.Context) error {
 	start := time.Now()
-	err := t.command.Execute()
+	err := t.command.Execute(ctx)
 	fmt.Printf("Command executed in %v\n", time.Since(start))
 	return err
 }
@@ -3,96 +3,308 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/google/uuid"
 
+	anomaly "github.com/ilyaytrewq/kpo-sb/homework/BankService/Anomaly"
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
 	commandpkg "github.com/ilyaytrewq/kpo-sb/homework/BankService/Command"
 	csvexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/CsvExporter"
 	jsonexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/JsonExporter"
+	parquetexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/ParquetExporter"
 	yamlexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/YamlExporter"
+	importer "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer"
 	csvimporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer/CsvImporter"
 	jsonimporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer/JsonImporter"
 	yamlimporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer/YamlImporter"
+	dedupe "github.com/ilyaytrewq/kpo-sb/homework/BankService/Dedupe"
 	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+	journal "github.com/ilyaytrewq/kpo-sb/homework/BankService/Journal"
+	ledger "github.com/ilyaytrewq/kpo-sb/homework/BankService/Ledger"
+	periods "github.com/ilyaytrewq/kpo-sb/homework/BankService/Periods"
+	reconcile "github.com/ilyaytrewq/kpo-sb/homework/BankService/Reconcile"
+	report "github.com/ilyaytrewq/kpo-sb/homework/BankService/Report"
 	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	// bankaccountrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/BankAccountRepo"
 	// categoryrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/CategoryRepo"
 	// operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
 	dbrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/DBRepo"
 	postgresrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/DBRepo/PostgresRepo"
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
+	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
 	proxyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ProxyRepo"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
+	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
 	timer "github.com/ilyaytrewq/kpo-sb/homework/BankService/Timer"
 )
 
 func main() {
 	in := bufio.NewReader(os.Stdin)
+	// корневой контекст: Ctrl-C в меню отменяет его, и main возвращается, закрывая ресурсы через defer
+	root, stop := context.WithCancel(context.Background())
+	defer stop()
 
 	// bankRepo := bankaccountrepo.NewBankAccountRepo()
 	// catRepo := categoryrepo.NewCategoryRepo()
 	// opRepo := operationrepo.NewOperationRepo()
 
-	user := getEnv("DB_USER", "bankservice")
+	dbUser := getEnv("DB_USER", "bankservice")
 	pass := getEnv("DB_PASSWORD", "password")
 	name := getEnv("DB_NAME", "bankservice")
 	host := getEnv("DB_HOST", "db")
 	port := getEnv("DB_PORT", "5432")
 
 	postgreRepo := postgresrepo.NewPostgresRepo()
-	if err := postgreRepo.Init(user, pass, name, host, port); err != nil {
+	if err := postgreRepo.Init(dbUser, pass, name, host, port); err != nil {
 		fmt.Println("error initializing postgres repo:", err)
 		return
 	}
 	defer postgreRepo.Close()
+	// запросы без своего срока прерываются через DB_QUERY_TIMEOUT; 0 — без ограничения
+	dbrepo.SetQueryTimeout(readDurationEnv("DB_QUERY_TIMEOUT", 30*time.Second))
 
-	bankRepo := dbrepo.NewBankAccountDBRepo(postgreRepo.DB())
-	catRepo := dbrepo.NewCategoryDBRepo(postgreRepo.DB())
-	opRepo := dbrepo.NewOperationDBRepo(postgreRepo.DB())
+	var (
+		bankRepo repository.ICommonRepo       = dbrepo.NewBankAccountDBRepo(postgreRepo.DB())
+		catRepo  repository.ICommonRepo       = dbrepo.NewCategoryDBRepo(postgreRepo.DB())
+		opRepo   operationrepo.IOperationRepo = dbrepo.NewOperationDBRepo(postgreRepo.DB())
+		ledgerDB *ledger.Ledger
+	)
+	ruleRepo := dbrepo.NewRuleDBRepo(postgreRepo.DB())
 
-	ctx := context.Background()
-	bankCached, err := proxyrepo.NewCachedRepo(ctx, bankRepo)
+	// STORAGE_MODE=ledger — счета, категории и операции хранятся журналом событий в Postgres,
+	// ledger-file — в файлах каталога LEDGER_DIR; по умолчанию — обычные таблицы
+	switch mode := getEnv("STORAGE_MODE", "tables"); mode {
+	case "tables":
+	case "ledger", "ledger-file":
+		var (
+			store ledger.IEventStore
+			snaps ledger.ISnapshotStore
+			err   error
+		)
+		if mode == "ledger" {
+			store, snaps = dbrepo.NewLedgerEventStore(postgreRepo.DB()), dbrepo.NewLedgerSnapshotStore(postgreRepo.DB())
+		} else {
+			dir := getEnv("LEDGER_DIR", "ledger")
+			store, err = ledger.NewFileEventStore(filepath.Join(dir, "events.jsonl"))
+			snaps = ledger.NewFileSnapshotStore(filepath.Join(dir, "snapshot.json"))
+		}
+		if err == nil {
+			ledgerDB, err = ledger.Open(root, store, snaps, readIntEnv("LEDGER_SNAPSHOT_EVERY", 500))
+		}
+		if err != nil {
+			fmt.Println("error opening event ledger:", err)
+			return
+		}
+		bankRepo, catRepo, opRepo = ledgerDB.Accounts(), ledgerDB.Categories(), ledgerDB.Operations()
+	default:
+		fmt.Println("error: unknown STORAGE_MODE", mode)
+		return
+	}
+
+	// каждое изменение через репозитории пишет версию в entity_versions
+	historyRepo := dbrepo.NewHistoryDBRepo(postgreRepo.DB())
+	bankVersioned := proxyrepo.NewVersionedRepo(bankRepo, historyRepo, historyrepo.AccountCodec)
+	catVersioned := proxyrepo.NewVersionedRepo(catRepo, historyRepo, historyrepo.CategoryCodec)
+	opVersioned := proxyrepo.NewVersionedOperationRepo(opRepo, historyRepo)
+
+	// загрузка кэшей и лента изменений — системный доступ ко строкам всех пользователей
+	ctx, stopWatch := context.WithCancel(service.Unscoped(root))
+	defer stopWatch()
+	cacheOpts := proxyrepo.Options{
+		TTL:     readDurationEnv("CACHE_TTL", 0),
+		MaxSize: readIntEnv("CACHE_MAX_SIZE", 0),
+	}
+	caches := make(map[string]proxyrepo.Invalidator)
+	bankCached, err := proxyrepo.NewCachedRepo(ctx, bankVersioned, cacheOpts)
 	if err != nil {
 		fmt.Println("warn: bank proxy init failed:", err)
 	}
-	catCached, err := proxyrepo.NewCachedRepo(ctx, catRepo)
+	catCached, err := proxyrepo.NewCachedRepo(ctx, catVersioned, cacheOpts)
 	if err != nil {
 		fmt.Println("warn: category proxy init failed:", err)
 	}
+	opCached, err := proxyrepo.NewCachedOperationRepo(ctx, opVersioned, cacheOpts)
+	if err != nil {
+		fmt.Println("warn: operation proxy init failed:", err)
+	}
 
-	var bankFacadeRepo repository.ICommonRepo = bankRepo
+	// общие для всех пользователей цепочки; сессия пользователя оборачивает их в OwnedRepo
+	var bankShared repository.ICommonRepo = bankVersioned
 	if bankCached != nil {
-		bankFacadeRepo = bankCached
+		bankShared = bankCached
+		caches["bank_accounts"] = bankCached
 	}
-	var catFacadeRepo repository.ICommonRepo = catRepo
+	var catShared repository.ICommonRepo = catVersioned
 	if catCached != nil {
-		catFacadeRepo = catCached
+		catShared = catCached
+		caches["categories"] = catCached
+	}
+	var opStore operationrepo.IOperationRepo = opVersioned
+	if opCached != nil {
+		opStore = opCached
+		caches["operations"] = opCached
+	}
+	// операции в сверенных и закрытых периодах нельзя создавать, менять и удалять
+	recRepo := dbrepo.NewReconciliationDBRepo(postgreRepo.DB())
+	periodRepo := dbrepo.NewPeriodDBRepo(postgreRepo.DB())
+	// доступы к общим счетам читаются без кэша: отзыв виден сразу и в других процессах
+	shareRepo := dbrepo.NewShareDBRepo(postgreRepo.DB())
+	opShared := proxyrepo.NewLockedOperationRepo(opStore, proxyrepo.OperationGuards{
+		reconcile.NewLocks(recRepo),
+		periods.NewLocks(periodRepo),
+	})
+	// изменения из других процессов и прямые правки в БД сбрасывают устаревшие записи кэшей
+	if feed, err := dbrepo.NewChangeFeed(ctx, postgreRepo.DB()); err != nil {
+		fmt.Println("warn: change feed init failed, caches will not see external changes:", err)
+	} else {
+		go proxyrepo.Watch(ctx, feed, readDurationEnv("CACHE_POLL_INTERVAL", 2*time.Second), caches, func(err error) {
+			fmt.Println("warn: change feed:", err)
+		})
+	}
+
+	// AUTH_SECRET подписывает токены; без него ключ случайный и токены живут до перезапуска
+	secret := []byte(os.Getenv("AUTH_SECRET"))
+	if len(secret) == 0 {
+		fmt.Println("warn: AUTH_SECRET is not set, issued tokens are valid until restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			printError(err)
+			return
+		}
+	}
+	tokens, err := auth.NewTokens(secret, readDurationEnv("AUTH_TOKEN_TTL", 12*time.Hour))
+	if err != nil {
+		printError(err)
+		return
+	}
+	userF, err := facade.NewUserFacade(ctx, dbrepo.NewUserDBRepo(postgreRepo.DB()), tokens)
+	if err != nil {
+		fmt.Println("error initializing users:", err)
+		return
+	}
+	// BOOTSTRAP_SECRET — код, которым задаётся первый пароль default; иначе нужен код от администратора
+	userF.SetBootstrapSecret(os.Getenv("BOOTSTRAP_SECRET"))
+
+	// Фасады сессии видят только данные текущего пользователя; при смене пользователя пересоздаются.
+	var (
+		currentUser    user.IUser
+		sessionCtx     context.Context
+		sessionAccess  *proxyrepo.Access
+		bankFacadeRepo repository.ICommonRepo
+		catFacadeRepo  repository.ICommonRepo
+		opFacadeRepo   operationrepo.IOperationRepo
+		bankF          *facade.BankAccountFacade
+		catF           *facade.CategoryFacade
+		opF            *facade.OperationFacade
+		ruleF          *facade.RuleFacade
+		suggestF       *facade.SuggestionFacade
+		analyticsF     *facade.AnalyticsFacade
+		reportF        *facade.ReportFacade
+		forecastF      *facade.ForecastFacade
+		anomalyF       *facade.AnomalyFacade
+		searchF        *facade.SearchFacade
+		historyF       *facade.HistoryFacade
+		journalF       *facade.JournalFacade
+		recF           *facade.ReconciliationFacade
+		periodF        *facade.PeriodFacade
+		shareF         *facade.ShareFacade
+	)
+	openSession := func(u user.IUser) {
+		currentUser = u
+		sessionCtx = auth.WithPrincipal(root, u)
+		owner := u.ID()
+		access := proxyrepo.NewAccess(owner, shareRepo)
+		sessionAccess = access
+		// роли проверяются и здесь, под фасадами: счета удаляет и периоды закрывает только администратор
+		bankFacadeRepo = proxyrepo.NewOwnedRepo(bankShared, access).WithRoles(proxyrepo.Roles{Read: user.Viewer, Write: user.Editor, Delete: user.Admin})
+		catFacadeRepo = proxyrepo.NewOwnedRepo(catShared, access)
+		opFacadeRepo = proxyrepo.NewOwnedOperationRepo(opShared, access, bankFacadeRepo, catFacadeRepo)
+		ownedRules := proxyrepo.NewOwnedRepo(ruleRepo, access)
+		ownedRecs := proxyrepo.NewOwnedRepo(recRepo, access)
+		ownedPeriods := proxyrepo.NewOwnedRepo(periodRepo, access).WithRoles(proxyrepo.Roles{Read: user.Viewer, Write: user.Admin, Delete: user.Admin})
+
+		bankF = facade.NewBankAccountFacade(bankFacadeRepo)
+		bankF.SetOperationRepo(opFacadeRepo)
+		catF = facade.NewCategoryFacade(catFacadeRepo)
+		catF.SetOperationRepo(opFacadeRepo)
+		opF = facade.NewOperationFacade(opFacadeRepo)
+		ruleF = facade.NewRuleFacade(ownedRules, opFacadeRepo)
+		opF.SetCategorizer(ruleF.Categorizer())
+		opF.SetAccountRepo(bankFacadeRepo)
+		suggestF = facade.NewSuggestionFacade(opFacadeRepo, catFacadeRepo, userModelPath(getEnv("CATEGORY_MODEL_PATH", "category_model.json"), owner))
+		analyticsF = facade.NewAnalyticsFacade(opFacadeRepo)
+		analyticsF.SetAccountRepo(bankFacadeRepo)
+		reportF = facade.NewReportFacade(bankFacadeRepo, catFacadeRepo, opFacadeRepo)
+		forecastF = facade.NewForecastFacade(bankFacadeRepo, opFacadeRepo)
+		anomalyF = facade.NewAnomalyFacade(opF, anomaly.NewWriterNotifier(os.Stdout))
+		// полнотекстовый поиск Postgres идёт мимо кэша, прямо в хранилище
+		searchF = facade.NewSearchFacade(bankFacadeRepo, catFacadeRepo, proxyrepo.NewOwnedOperationRepo(opRepo, access, bankFacadeRepo, catFacadeRepo))
+		historyF = facade.NewHistoryFacade(proxyrepo.NewOwnedHistoryRepo(historyRepo, access))
+		journalF = facade.NewJournalFacade(bankFacadeRepo, catFacadeRepo, opFacadeRepo)
+		recF = facade.NewReconciliationFacade(bankFacadeRepo, opFacadeRepo, ownedRecs)
+		periodF = facade.NewPeriodFacade(ownedPeriods, opFacadeRepo)
+		shareF = facade.NewShareFacade(bankFacadeRepo, proxyrepo.NewOwnedRepo(shareRepo, access), owner)
+	}
+
+	// BANK_TOKEN или BANK_USER/BANK_PASSWORD — вход без вопросов; иначе имя и пароль спрашиваются
+	u, err := login(ctx, in, userF, os.Getenv("BANK_TOKEN"), os.Getenv("BANK_USER"), os.Getenv("BANK_PASSWORD"))
+	if err != nil {
+		printError(err)
+		return
+	}
+	openSession(u)
+
+	dupPolicy, err := dedupe.ParsePolicy(getEnv("IMPORT_DUPLICATES", string(dedupe.PolicyAsk)))
+	if err != nil {
+		fmt.Println("warn:", err, "- using ask")
+		dupPolicy = dedupe.PolicyAsk
 	}
+	dedupeOpts := dedupe.DefaultOptions
+	dedupeOpts.DateTolerance = readDurationEnv("DEDUPE_DATE_TOLERANCE", dedupeOpts.DateTolerance)
 
-	bankF := facade.NewBankAccountFacade(bankFacadeRepo)
-	catF := facade.NewCategoryFacade(catFacadeRepo)
-	opF := facade.NewOperationFacade(opRepo)
-	analyticsF := facade.NewAnalyticsFacade(opRepo)
+	// Ctrl-C отменяет контекст текущей команды: запросы к БД, импорт и экспорт прерываются,
+	// а CLI возвращается в меню. В самом меню Ctrl-C, как и раньше, завершает программу.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	cmds := &interrupter{}
+	go func() {
+		for range interrupts {
+			if !cmds.interrupt() {
+				stop()
+			}
+		}
+	}()
 
 	fmt.Println("Bank Service CLI. Type a number and press Enter.")
 	for {
-		fmt.Println("\nMenu:")
+		cmds.end()
+		fmt.Printf("\nMenu (user %s, %s):\n", currentUser.Name(), currentUser.Role())
 		fmt.Println(" 1) Create account (timed)")
 		fmt.Println(" 2) List accounts")
-		fmt.Println(" 3) Delete account")
+		fmt.Println(" 3) Archive account")
 		fmt.Println(" 4) Create category")
 		fmt.Println(" 5) List categories")
 		fmt.Println(" 6) Create operation")
 		fmt.Println(" 7) List operations")
 		fmt.Println(" 8) Export accounts (csv/json/yaml)")
 		fmt.Println(" 9) Export categories (csv/json/yaml)")
-		fmt.Println("10) Export operations (csv/json/yaml)")
+		fmt.Println("10) Export operations (csv/json/yaml/parquet)")
 		fmt.Println("11) Analytics: income/expense delta")
 		fmt.Println("12) Analytics: group by category")
 		fmt.Println("13) Import accounts (csv/json/yaml)")
@@ -103,101 +315,177 @@ func main() {
 		fmt.Println("18) Update account balance")
 		fmt.Println("19) Get category by ID")
 		fmt.Println("20) Update category name")
-		fmt.Println("21) Delete category")
+		fmt.Println("21) Archive category")
 		fmt.Println("22) Get operation by ID")
 		fmt.Println("23) Delete operation")
+		fmt.Println("24) Export operations to parquet partitions (account/month)")
+		fmt.Println("25) Monthly account statement (html/md)")
+		fmt.Println("26) Analytics: time series (day/week/month/year)")
+		fmt.Println("27) Analytics: cash-flow forecast")
+		fmt.Println("28) Analytics: detect unusual operations")
+		fmt.Println("29) Create categorization rule")
+		fmt.Println("30) List categorization rules")
+		fmt.Println("31) Delete categorization rule")
+		fmt.Println("32) Recategorize operations by rules")
+		fmt.Println("33) Retrain category suggestion model")
+		fmt.Println("34) Suggest category for description")
+		fmt.Println("35) Set category parent")
+		fmt.Println("36) Analytics: group by category rolled up to tree level")
+		fmt.Println("37) Tag / untag operation")
+		fmt.Println("38) Analytics: totals by tags")
+		fmt.Println("39) Split operation across categories")
+		fmt.Println("40) Search operations (filters, sorting, pages)")
+		fmt.Println("41) Full-text search (description, category, account)")
+		fmt.Println("42) List archived accounts and categories")
+		fmt.Println("43) Restore archived account")
+		fmt.Println("44) Restore archived category")
+		fmt.Println("45) Purge archived account (deletes its operations)")
+		fmt.Println("46) Show change history (account/category/operation)")
+		fmt.Println("47) Show account or category as of a past time")
+		fmt.Println("48) Show event stream of an entity (ledger mode)")
+		fmt.Println("49) Take ledger snapshot (ledger mode)")
+		fmt.Println("50) Books: chart of accounts and trial balance")
+		fmt.Println("51) Books: general ledger for an account")
+		fmt.Println("52) Books: balance sheet")
+		fmt.Println("53) Books: profit and loss")
+		fmt.Println("54) Reconcile: ledger balance at date")
+		fmt.Println("55) Reconcile: account against bank statement")
+		fmt.Println("56) Reconcile: list reconciliations")
+		fmt.Println("57) Periods: list")
+		fmt.Println("58) Periods: close month")
+		fmt.Println("59) Periods: reopen month")
+		fmt.Println("60) Find and merge duplicate operations")
+		fmt.Println("61) Log in as another user")
+		fmt.Println("62) Users: list")
+		fmt.Println("63) Users: create")
+		fmt.Println("64) Users: change role")
+		fmt.Println("65) Change my password")
+		fmt.Println("66) Issue API token")
+		fmt.Println("67) Share account with user (read/write)")
+		fmt.Println("68) Revoke account access")
+		fmt.Println("69) List shared accounts")
+		fmt.Println("70) Users: issue password setup code")
+		fmt.Println("71) Purge archived category (only if no operations use it)")
 		fmt.Println(" 0) Exit")
 		fmt.Print("> ")
-		choice, _ := in.ReadString('\n')
-		choice = strings.TrimSpace(choice)
+		choice, ok := readChoice(root, in)
+		if !ok {
+			fmt.Println()
+			return
+		}
+		// права проверяются до вызова фасадов
+		if err := auth.Require(sessionCtx, requiredRole(choice)); err != nil {
+			printError(err)
+			continue
+		}
+		// область видимости (свои и общие счета) собирается один раз на команду, а не на каждый запрос
+		ctx, err := sessionAccess.Scope(cmds.begin(sessionCtx))
+		if err != nil {
+			printError(err)
+			continue
+		}
 		switch choice {
 		case "1":
 			name := readString(in, "Account name: ")
 			bal := readFloat(in, "Initial balance: ")
 			cmd := &commandpkg.CreateAccountCommand{Facade: bankF, Name: name, Balance: bal}
 			timed := timer.NewTimerDecorator(cmd)
-			if err := timed.Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timed.Execute(ctx); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("created account:", uuid.UUID(cmd.CreatedID).String())
 			}
 		case "2":
-			accs, err := bankF.ListAllAccounts()
+			accs, err := bankF.ListAllAccounts(ctx)
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			for _, a := range accs {
-				fmt.Printf("%s | %s | %.2f\n", uuid.UUID(a.ID()).String(), a.Name(), a.Balance())
+				fmt.Printf("%s | %s | %.2f%s\n", uuid.UUID(a.ID()).String(), a.Name(), a.Balance(), sharedMark(ctx, userF, currentUser, a))
 			}
 		case "3":
 			id := readUUID(in, "Account ID (uuid): ")
-			if err := bankF.DeleteAccount(service.ObjectID(id)); err != nil {
-				fmt.Println("error:", err)
+			if err := bankF.DeleteAccount(ctx, service.ObjectID(id)); err != nil {
+				printError(err)
 			} else {
-				fmt.Println("deleted")
+				fmt.Println("archived (operations are kept; restore or purge it later)")
 			}
 		case "4":
 			name := readString(in, "Category name: ")
 			t := readInt(in, "Type (0=Spending,1=Income): ")
 			ccmd := &commandpkg.CreateCategoryCommand{Facade: catF, Name: name, Type: category.CategoryType(t)}
-			if err := ccmd.Execute(); err != nil {
-				fmt.Println("error:", err)
+			if parent := readOptionalUUID(in, "Parent category ID (optional): "); parent != nil {
+				ccmd.ParentID = service.ObjectID(*parent)
+			}
+			if err := ccmd.Execute(ctx); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("created category:", uuid.UUID(ccmd.CreatedID).String())
 			}
 		case "5":
-			cats, err := catF.ListAllCategories()
+			cats, err := catF.ListAllCategories(ctx)
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			for _, c := range cats {
-				fmt.Printf("%s | %s | %d\n", uuid.UUID(c.ID()).String(), c.Name(), int(c.Type()))
+				parent := "-"
+				if c.ParentID() != (service.ObjectID{}) {
+					parent = uuid.UUID(c.ParentID()).String()
+				}
+				fmt.Printf("%s | %s | %d | parent=%s\n", uuid.UUID(c.ID()).String(), c.Name(), int(c.Type()), parent)
 			}
 		case "6":
 			t := readInt(in, "Type (0=Spending,1=Income): ")
 			accID := readUUID(in, "Account ID: ")
 			amount := readFloat(in, "Amount: ")
 			date := readTime(in, "Date (RFC3339): ")
-			catID := readUUID(in, "Category ID: ")
+			catID := readOptionalUUID(in, "Category ID (empty = by rules): ")
 			descr := readString(in, "Description (optional): ")
+			tags := operation.ParseTagList(readString(in, "Tags (comma separated, optional): "), ",")
+			var categoryID service.ObjectID
+			if catID != nil {
+				categoryID = service.ObjectID(*catID)
+			}
 			ocmd := &commandpkg.AddOperationCommand{
 				Facade:      opF,
 				Type:        operation.OperationType(t),
 				AccountID:   service.ObjectID(accID),
 				Amount:      amount,
 				Date:        date,
-				CategoryID:  service.ObjectID(catID),
+				CategoryID:  categoryID,
 				Description: descr,
+				Tags:        tags,
 			}
-			if err := ocmd.Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := ocmd.Execute(ctx); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("created operation:", uuid.UUID(ocmd.CreatedID).String())
 			}
 		case "7":
-			ops, err := opF.ListAllOperations()
+			tags := operation.ParseTagList(readString(in, "Filter by tags (comma separated, empty = all): "), ",")
+			ops, err := opF.ListOperationsByTags(ctx, tags...)
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			for _, o := range ops {
-				fmt.Printf("%s | %d | %.2f | %s | %s\n", uuid.UUID(o.ID()).String(), int(o.Type()), o.Amount(), o.Date().Format(time.RFC3339), o.Description())
+				fmt.Printf("%s | %d | %.2f | %s | %s | %s\n", uuid.UUID(o.ID()).String(), int(o.Type()), o.Amount(), o.Date().Format(time.RFC3339), o.Description(), formatTags(o.Tags()))
 			}
 		case "8":
 			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
 			path := readString(in, "File path: ")
-			data, _ := bankFacadeRepo.All(context.Background())
-			cmd := commandpkg.CommandFunc(func() error {
+			data, _ := bankFacadeRepo.All(ctx)
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
 				var err error
 				switch format {
 				case "csv":
-					err = csvexporter.NewCSVBankAccountExporter(path).Export(data)
+					err = csvexporter.NewCSVBankAccountExporter(path).Export(ctx, data)
 				case "json":
-					err = jsonexporter.NewJSONBankAccountExporter(path).Export(data)
+					err = jsonexporter.NewJSONBankAccountExporter(path).Export(ctx, data)
 				case "yaml":
-					err = yamlexporter.NewYAMLBankAccountExporter(path).Export(data)
+					err = yamlexporter.NewYAMLBankAccountExporter(path).Export(ctx, data)
 				default:
 					fmt.Println("unknown format")
 				}
@@ -206,22 +494,22 @@ func main() {
 				}
 				return err
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(cmd).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "9":
 			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
 			path := readString(in, "File path: ")
-			data, _ := catFacadeRepo.All(context.Background())
-			cmd := commandpkg.CommandFunc(func() error {
+			data, _ := catFacadeRepo.All(ctx)
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
 				var err error
 				switch format {
 				case "csv":
-					err = csvexporter.NewCSVCategoryExporter(path).Export(data)
+					err = csvexporter.NewCSVCategoryExporter(path).Export(ctx, data)
 				case "json":
-					err = jsonexporter.NewJSONCategoryExporter(path).Export(data)
+					err = jsonexporter.NewJSONCategoryExporter(path).Export(ctx, data)
 				case "yaml":
-					err = yamlexporter.NewYAMLCategoryExporter(path).Export(data)
+					err = yamlexporter.NewYAMLCategoryExporter(path).Export(ctx, data)
 				default:
 					fmt.Println("unknown format")
 				}
@@ -230,22 +518,24 @@ func main() {
 				}
 				return err
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(cmd).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "10":
-			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
+			format := strings.ToLower(readString(in, "Format (csv/json/yaml/parquet): "))
 			path := readString(in, "File path: ")
-			data, _ := opRepo.All(context.Background())
-			cmd := commandpkg.CommandFunc(func() error {
+			data, _ := opFacadeRepo.All(ctx)
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
 				var err error
 				switch format {
 				case "csv":
-					err = csvexporter.NewCSVOperationExporter(path).Export(data)
+					err = csvexporter.NewCSVOperationExporter(path).Export(ctx, data)
 				case "json":
-					err = jsonexporter.NewJSONOperationExporter(path).Export(data)
+					err = jsonexporter.NewJSONOperationExporter(path).Export(ctx, data)
 				case "yaml":
-					err = yamlexporter.NewYAMLOperationExporter(path).Export(data)
+					err = yamlexporter.NewYAMLOperationExporter(path).Export(ctx, data)
+				case "parquet":
+					err = parquetexporter.NewParquetOperationExporter(path).Export(ctx, data)
 				default:
 					fmt.Println("unknown format")
 				}
@@ -254,16 +544,21 @@ func main() {
 				}
 				return err
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(cmd).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "11":
 			accID := readUUID(in, "Account ID: ")
 			from := readTime(in, "From (RFC3339): ")
 			to := readTime(in, "To (RFC3339): ")
-			inc, exp, delta, err := analyticsF.IncomeExpenseDelta(service.ObjectID(accID), from, to)
+			af, err := analyticsByAuthor(ctx, in, userF, analyticsF)
+			if err != nil {
+				printError(err)
+				break
+			}
+			inc, exp, delta, err := af.IncomeExpenseDelta(ctx, service.ObjectID(accID), from, to)
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 			} else {
 				fmt.Printf("income=%.2f expense=%.2f delta=%.2f\n", inc, exp, delta)
 			}
@@ -271,9 +566,14 @@ func main() {
 			accID := readUUID(in, "Account ID: ")
 			from := readTime(in, "From (RFC3339): ")
 			to := readTime(in, "To (RFC3339): ")
-			m, err := analyticsF.GroupByCategory(service.ObjectID(accID), from, to)
+			af, err := analyticsByAuthor(ctx, in, userF, analyticsF)
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
+				break
+			}
+			m, err := af.GroupByCategory(ctx, service.ObjectID(accID), from, to)
+			if err != nil {
+				printError(err)
 				break
 			}
 			for k, v := range m {
@@ -282,9 +582,9 @@ func main() {
 		case "13":
 			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
 			path := readString(in, "File path: ")
-			cmd := commandpkg.CommandFunc(func() error {
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
 				var imp interface {
-					Read() error
+					Read(ctx context.Context) error
 					Data() repository.ICommonRepo
 				}
 				switch format {
@@ -299,13 +599,13 @@ func main() {
 					fmt.Println("unknown format")
 					return nil
 				}
-				if err := imp.Read(); err != nil {
+				if err := imp.Read(ctx); err != nil {
 					return err
 				}
-				objs, _ := imp.Data().All(context.Background())
+				objs, _ := imp.Data().All(ctx)
 				added, failed := 0, 0
 				for _, obj := range objs {
-					if e := bankFacadeRepo.Save(context.Background(), obj); e != nil {
+					if e := bankFacadeRepo.Save(ctx, obj); e != nil {
 						failed++
 					} else {
 						added++
@@ -314,15 +614,15 @@ func main() {
 				fmt.Printf("imported: %d, skipped: %d\n", added, failed)
 				return nil
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(commandpkg.NewRoleDecorator(cmd, user.Admin)).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "14":
 			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
 			path := readString(in, "File path: ")
-			cmd := commandpkg.CommandFunc(func() error {
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
 				var imp interface {
-					Read() error
+					Read(ctx context.Context) error
 					Data() repository.ICommonRepo
 				}
 				switch format {
@@ -337,13 +637,14 @@ func main() {
 					fmt.Println("unknown format")
 					return nil
 				}
-				if err := imp.Read(); err != nil {
+				if err := imp.Read(ctx); err != nil {
 					return err
 				}
-				objs, _ := imp.Data().All(context.Background())
+				objs, _ := imp.Data().All(ctx)
+				category.SortParentsFirst(objs)
 				added, failed := 0, 0
 				for _, obj := range objs {
-					if e := catFacadeRepo.Save(context.Background(), obj); e != nil {
+					if e := catF.ImportCategory(ctx, obj); e != nil {
 						failed++
 					} else {
 						added++
@@ -352,17 +653,14 @@ func main() {
 				fmt.Printf("imported: %d, skipped: %d\n", added, failed)
 				return nil
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(commandpkg.NewRoleDecorator(cmd, user.Admin)).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "15":
 			format := strings.ToLower(readString(in, "Format (csv/json/yaml): "))
 			path := readString(in, "File path: ")
-			cmd := commandpkg.CommandFunc(func() error {
-				var imp interface {
-					Read() error
-					Data() repository.ICommonRepo
-				}
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error {
+				var imp *importer.BaseImporter
 				switch format {
 				case "csv":
 					imp = csvimporter.NewCSVOperationImporter(path)
@@ -375,29 +673,41 @@ func main() {
 					fmt.Println("unknown format")
 					return nil
 				}
-				if err := imp.Read(); err != nil {
+				imp.AddHook(ruleF.Categorizer())
+				dupHook := dedupe.NewImportHook(opFacadeRepo, dupPolicy, dedupeOpts)
+				dupHook.Ask = func(op, match operation.IOperation) bool {
+					fmt.Printf("Possible duplicate:\n  new:      %s\n  existing: %s\n", describeOperation(op), describeOperation(match))
+					return strings.ToLower(readString(in, "Import anyway? (y/N): ")) == "y"
+				}
+				imp.AddHook(dupHook)
+				if err := imp.Read(ctx); err != nil {
 					return err
 				}
-				objs, _ := imp.Data().All(context.Background())
-				added, failed := 0, 0
+				if dupHook.Found > 0 {
+					fmt.Printf("possible duplicates: %d, not imported: %d (policy %s)\n", dupHook.Found, imp.Skipped(), dupPolicy)
+				}
+				objs, _ := imp.Data().All(ctx)
+				added, failed, locked := 0, 0, 0
 				for _, obj := range objs {
-					if e := opRepo.Save(context.Background(), obj); e != nil {
+					if e := opFacadeRepo.Save(ctx, obj); errors.Is(e, periods.ErrClosed) || errors.Is(e, reconcile.ErrLocked) {
+						locked++
+					} else if e != nil {
 						failed++
 					} else {
 						added++
 					}
 				}
-				fmt.Printf("imported: %d, skipped: %d\n", added, failed)
+				fmt.Printf("imported: %d, skipped: %d, refused in closed periods: %d\n", added, failed, locked)
 				return nil
 			})
-			if err := timer.NewTimerDecorator(cmd).Execute(); err != nil {
-				fmt.Println("error:", err)
+			if err := timer.NewTimerDecorator(commandpkg.NewRoleDecorator(cmd, user.Admin)).Execute(ctx); err != nil {
+				printError(err)
 			}
 		case "16":
 			id := readUUID(in, "Account ID (uuid): ")
-			a, err := bankF.GetAccount(service.ObjectID(id))
+			a, err := bankF.GetAccount(ctx, service.ObjectID(id))
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			fmt.Printf("ID=%s | name=%s | balance=%.2f\n",
@@ -406,8 +716,8 @@ func main() {
 		case "17":
 			id := readUUID(in, "Account ID (uuid): ")
 			newName := readString(in, "New name: ")
-			if err := bankF.UpdateAccountName(service.ObjectID(id), newName); err != nil {
-				fmt.Println("error:", err)
+			if err := bankF.UpdateAccountName(ctx, service.ObjectID(id), newName); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("ok")
 			}
@@ -415,17 +725,17 @@ func main() {
 		case "18":
 			id := readUUID(in, "Account ID (uuid): ")
 			newBal := readFloat(in, "New balance: ")
-			if err := bankF.UpdateAccountBalance(service.ObjectID(id), newBal); err != nil {
-				fmt.Println("error:", err)
+			if err := bankF.UpdateAccountBalance(ctx, service.ObjectID(id), newBal); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("ok")
 			}
 
 		case "19":
 			id := readUUID(in, "Category ID (uuid): ")
-			c, err := catF.GetCategory(service.ObjectID(id))
+			c, err := catF.GetCategory(ctx, service.ObjectID(id))
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			fmt.Printf("ID=%s | name=%s | type=%d\n",
@@ -434,26 +744,32 @@ func main() {
 		case "20":
 			id := readUUID(in, "Category ID (uuid): ")
 			newName := readString(in, "New name: ")
-			if err := catF.UpdateCategoryName(service.ObjectID(id), newName); err != nil {
-				fmt.Println("error:", err)
+			if err := catF.UpdateCategoryName(ctx, service.ObjectID(id), newName); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("ok")
 			}
 
 		case "21":
 			id := readUUID(in, "Category ID (uuid): ")
-			if err := catF.DeleteCategory(service.ObjectID(id)); err != nil {
-				fmt.Println("error:", err)
+			var err error
+			if strings.ToLower(readString(in, "Move child categories to parent? (y/n): ")) == "y" {
+				err = catF.DeleteCategoryReparent(ctx, service.ObjectID(id))
 			} else {
-				fmt.Println("deleted")
+				err = catF.DeleteCategory(ctx, service.ObjectID(id))
+			}
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("archived")
 			}
 
 		// 22) Get operation by ID
 		case "22":
 			id := readUUID(in, "Operation ID (uuid): ")
-			o, err := opF.GetOperation(service.ObjectID(id))
+			o, err := opF.GetOperation(ctx, service.ObjectID(id))
 			if err != nil {
-				fmt.Println("error:", err)
+				printError(err)
 				break
 			}
 			fmt.Printf("ID=%s | type=%d | account=%s | amount=%.2f | ts=%s | cat=%s | descr=%s\n",
@@ -465,73 +781,1275 @@ func main() {
 				uuid.UUID(o.CategoryID()).String(),
 				o.Description(),
 			)
+			fmt.Println("tags:", formatTags(o.Tags()))
+			for i, l := range o.Splits() {
+				fmt.Printf("  split %d: cat=%s | amount=%.2f | note=%s\n", i+1, uuid.UUID(l.CategoryID).String(), l.Amount, l.Note)
+			}
 
 		case "23":
 			id := readUUID(in, "Operation ID (uuid): ")
-			if err := opF.DeleteOperation(service.ObjectID(id)); err != nil {
-				fmt.Println("error:", err)
+			if err := opF.DeleteOperation(ctx, service.ObjectID(id)); err != nil {
+				printError(err)
 			} else {
 				fmt.Println("deleted")
 			}
 
-		case "0":
-			fmt.Println("Bye!")
-			return
-		default:
-			fmt.Println("Unknown choice")
-		}
-	}
-}
+		case "24":
+			dir := readString(in, "Output directory: ")
+			data, _ := opFacadeRepo.All(ctx)
+			exp := parquetexporter.NewParquetPartitionedOperationExporter(dir)
+			cmd := commandpkg.CommandFunc(func(ctx context.Context) error { return exp.Export(ctx, data) })
+			if err := timer.NewTimerDecorator(cmd).Execute(ctx); err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("partitions written: %d, unchanged: %d\n", exp.Written, exp.Unchanged)
+			}
 
-func readString(in *bufio.Reader, prompt string) string {
-	fmt.Print(prompt)
-	s, _ := in.ReadString('\n')
-	return strings.TrimSpace(s)
-}
+		case "25":
+			accID := readUUID(in, "Account ID: ")
+			month := readMonth(in, "Month (YYYY-MM): ")
+			format := strings.ToLower(readString(in, "Format (html/md): "))
+			path := readString(in, "File path: ")
+			tmpl := readString(in, "Template path (optional): ")
+			st, err := reportF.MonthlyStatement(ctx, service.ObjectID(accID), month.Year(), month.Month(), time.Local)
+			if err != nil {
+				printError(err)
+				break
+			}
+			switch format {
+			case "html":
+				err = report.NewHTMLStatementExporter(path, tmpl).Export(ctx, st)
+			case "md":
+				err = report.NewMarkdownStatementExporter(path, tmpl).Export(ctx, st)
+			default:
+				err = fmt.Errorf("unknown format %q", format)
+			}
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("opening=%.2f closing=%.2f operations=%d\n", st.OpeningBalance, st.ClosingBalance, len(st.Lines))
+			}
 
-func readFloat(in *bufio.Reader, prompt string) float64 {
-	for {
-		s := readString(in, prompt)
-		if v, err := strconv.ParseFloat(s, 64); err == nil {
-			return v
-		}
-		fmt.Println("Invalid float, try again")
-	}
-}
+		case "26":
+			accID := readOptionalUUID(in, "Account ID (empty = all accounts): ")
+			from := readTime(in, "From (RFC3339): ")
+			to := readTime(in, "To (RFC3339): ")
+			g, err := operationrepo.ParseGranularity(strings.ToLower(readString(in, "Granularity (day/week/month/year): ")))
+			if err != nil {
+				printError(err)
+				break
+			}
+			loc := readLocation(in, "Timezone (IANA, empty = UTC): ")
+			var accPtr *service.ObjectID
+			if accID != nil {
+				id := service.ObjectID(*accID)
+				accPtr = &id
+			}
+			points, err := analyticsF.TimeSeries(ctx, accPtr, from, to, g, loc)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, p := range points {
+				fmt.Printf("%s | income=%.2f expense=%.2f net=%.2f cumulative net=%.2f balance=%.2f\n",
+					p.Start.Format(time.RFC3339), p.Income, p.Expense, p.Net, p.CumulativeNet, p.Balance)
+			}
 
-func readInt(in *bufio.Reader, prompt string) int {
-	for {
-		s := readString(in, prompt)
-		if v, err := strconv.Atoi(s); err == nil {
-			return v
-		}
-		fmt.Println("Invalid int, try again")
-	}
-}
+		case "27":
+			accID := readUUID(in, "Account ID: ")
+			horizon := readInt(in, "Horizon (days): ")
+			history := readInt(in, "History window (days, 0 = default): ")
+			path := readString(in, "JSON file path (optional): ")
+			fcmd := &commandpkg.ForecastCommand{
+				Facade:      forecastF,
+				AccountID:   service.ObjectID(accID),
+				HorizonDays: horizon,
+				HistoryDays: history,
+				Filepath:    path,
+			}
+			if err := fcmd.Execute(ctx); err != nil {
+				printError(err)
+				break
+			}
+			fc := fcmd.Result
+			for _, r := range fc.Recurring {
+				fmt.Printf("recurring: %q %.2f every %d days\n", r.Description, r.Amount, r.IntervalDays)
+			}
+			for _, c := range fc.Categories {
+				fmt.Printf("category %s: %.2f per day\n", uuid.UUID(c.CategoryID), c.DailyNet)
+			}
+			for _, p := range fc.Points {
+				fmt.Printf("%s | expected=%.2f [%.2f; %.2f]\n", p.Date.Format("2006-01-02"), p.Expected, p.Lower, p.Upper)
+			}
+			if fc.FirstNegative != nil {
+				fmt.Println("warning: balance is expected to go negative on", fc.FirstNegative.Format("2006-01-02"))
+			} else if fc.LowerNegative != nil {
+				fmt.Println("warning: balance may go negative from", fc.LowerNegative.Format("2006-01-02"))
+			}
 
-func readUUID(in *bufio.Reader, prompt string) uuid.UUID {
-	for {
-		s := readString(in, prompt)
-		if id, err := uuid.Parse(strings.TrimSpace(s)); err == nil {
-			return id
-		}
-		fmt.Println("Invalid uuid, try again")
-	}
-}
+		case "28":
+			accID := readUUID(in, "Account ID: ")
+			from := readTime(in, "From (RFC3339): ")
+			to := readTime(in, "To (RFC3339): ")
+			found, err := anomalyF.Scan(ctx, service.ObjectID(accID), from, to)
+			if err != nil {
+				printError(err)
+			}
+			fmt.Printf("anomalies found: %d\n", len(found))
 
-func readTime(in *bufio.Reader, prompt string) time.Time {
-	for {
-		s := readString(in, prompt)
-		if t, err := time.Parse(time.RFC3339, s); err == nil {
-			return t
-		}
-		fmt.Println("Invalid time, expected RFC3339, try again")
-	}
-}
+		case "29":
+			name := readString(in, "Rule name: ")
+			priority := readInt(in, "Priority (lower runs first): ")
+			catID := readUUID(in, "Category ID: ")
+			cond := rule.Conditions{
+				DescriptionRegex:    readString(in, "Description regex (optional): "),
+				DescriptionContains: readString(in, "Description contains (optional): "),
+				MinAmount:           readOptionalFloat(in, "Min amount (optional): "),
+				MaxAmount:           readOptionalFloat(in, "Max amount (optional): "),
+			}
+			if accID := readOptionalUUID(in, "Account ID (optional): "); accID != nil {
+				id := service.ObjectID(*accID)
+				cond.AccountID = &id
+			}
+			if t := readString(in, "Type (0=Spending,1=Income, optional): "); t != "" {
+				v, err := strconv.Atoi(t)
+				if err != nil {
+					fmt.Println("error: invalid type")
+					break
+				}
+				opType := operation.OperationType(v)
+				cond.OpType = &opType
+			}
+			id, err := ruleF.CreateRule(ctx, name, priority, cond, service.ObjectID(catID))
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("created rule:", uuid.UUID(id).String())
+			}
 
-func getEnv(key, def string) string {
-	if v, ok := os.LookupEnv(key); ok {
-		return v
-	}
-	return def
+		case "30":
+			rules, err := ruleF.ListRules(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, r := range rules {
+				c := r.Conditions()
+				fmt.Printf("%s | %d | %s | regex=%q contains=%q -> %s\n",
+					uuid.UUID(r.ID()).String(), r.Priority(), r.Name(), c.DescriptionRegex, c.DescriptionContains, uuid.UUID(r.CategoryID()).String())
+			}
+
+		case "31":
+			id := readUUID(in, "Rule ID (uuid): ")
+			if err := ruleF.DeleteRule(ctx, service.ObjectID(id)); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("deleted")
+			}
+
+		case "32":
+			only := strings.ToLower(readString(in, "Only uncategorized? (y/n): ")) == "y"
+			rcmd := &commandpkg.RecategorizeCommand{Facade: ruleF, OnlyUncategorized: only}
+			if err := timer.NewTimerDecorator(rcmd).Execute(ctx); err != nil {
+				printError(err)
+			}
+			fmt.Printf("recategorized: %d\n", rcmd.Changed)
+
+		case "33":
+			scmd := commandpkg.CommandFunc(func(ctx context.Context) error {
+				n, err := suggestF.Retrain(ctx)
+				if err == nil {
+					fmt.Printf("model trained on %d operations\n", n)
+				}
+				return err
+			})
+			if err := timer.NewTimerDecorator(scmd).Execute(ctx); err != nil {
+				printError(err)
+			}
+
+		case "34":
+			descr := readString(in, "Description: ")
+			t := readInt(in, "Type (0=Spending,1=Income): ")
+			suggestions, err := suggestF.Suggest(ctx, descr, operation.OperationType(t), 3)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, sg := range suggestions {
+				name := "?"
+				if c, err := catF.GetCategory(ctx, sg.CategoryID); err == nil {
+					name = c.Name()
+				}
+				fmt.Printf("%s | %s | confidence=%.2f\n", uuid.UUID(sg.CategoryID).String(), name, sg.Confidence)
+			}
+
+		case "35":
+			id := readUUID(in, "Category ID (uuid): ")
+			var parentID service.ObjectID
+			if parent := readOptionalUUID(in, "Parent category ID (empty = root): "); parent != nil {
+				parentID = service.ObjectID(*parent)
+			}
+			if err := catF.SetCategoryParent(ctx, service.ObjectID(id), parentID); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("ok")
+			}
+
+		case "36":
+			accID := readUUID(in, "Account ID: ")
+			from := readTime(in, "From (RFC3339): ")
+			to := readTime(in, "To (RFC3339): ")
+			level := readInt(in, "Tree level (0 = top-level categories): ")
+			tree, err := catF.Tree(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			m, err := analyticsF.GroupByCategoryRollup(ctx, service.ObjectID(accID), from, to, tree, level)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for k, v := range m {
+				name := "?"
+				if c, err := catF.GetCategory(ctx, k); err == nil {
+					name = c.Name()
+				}
+				fmt.Printf("%s | %s -> %.2f\n", uuid.UUID(k).String(), name, v)
+			}
+
+		case "37":
+			id := service.ObjectID(readUUID(in, "Operation ID (uuid): "))
+			add := operation.ParseTagList(readString(in, "Tags to add (comma separated): "), ",")
+			remove := operation.ParseTagList(readString(in, "Tags to remove (comma separated): "), ",")
+			err := opF.TagOperation(ctx, id, add...)
+			if err == nil && len(remove) > 0 {
+				err = opF.UntagOperation(ctx, id, remove...)
+			}
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("ok")
+			}
+
+		case "38":
+			var accountID *service.ObjectID
+			if id := readOptionalUUID(in, "Account ID (empty = all accounts): "); id != nil {
+				acc := service.ObjectID(*id)
+				accountID = &acc
+			}
+			from := readTime(in, "From (RFC3339): ")
+			to := readTime(in, "To (RFC3339): ")
+			tags := operation.ParseTagList(readString(in, "Tags (comma separated, all must match): "), ",")
+			inc, exp, delta, err := analyticsF.IncomeExpenseDeltaByTags(ctx, accountID, from, to, tags...)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("income=%.2f expense=%.2f delta=%.2f\n", inc, exp, delta)
+			m, err := analyticsF.GroupByCategoryByTags(ctx, accountID, from, to, tags...)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for k, v := range m {
+				fmt.Printf("%s -> %.2f\n", uuid.UUID(k).String(), v)
+			}
+
+		case "39":
+			id := service.ObjectID(readUUID(in, "Operation ID (uuid): "))
+			fmt.Println("Enter split lines as <category_id>:<amount>[:<note>], empty line to finish (no lines = remove split)")
+			var lines []operation.SplitLine
+			for {
+				s := readString(in, fmt.Sprintf("Line %d: ", len(lines)+1))
+				if s == "" {
+					break
+				}
+				line, err := operation.ParseSplitLine(s)
+				if err != nil {
+					printError(err)
+					continue
+				}
+				lines = append(lines, line)
+			}
+			if err := opF.SplitOperation(ctx, id, lines); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("ok")
+			}
+
+		case "40":
+			q := operationrepo.Query{
+				AccountIDs:  readUUIDList(in, "Account IDs (comma separated, empty = all): "),
+				CategoryIDs: readUUIDList(in, "Category IDs (comma separated, empty = all): "),
+				MinAmount:   readOptionalFloat(in, "Min amount (optional): "),
+				MaxAmount:   readOptionalFloat(in, "Max amount (optional): "),
+				From:        readOptionalTime(in, "From (RFC3339, optional): "),
+				To:          readOptionalTime(in, "To (RFC3339, optional): "),
+				Text:        readString(in, "Description contains (optional): "),
+				Tags:        operation.ParseTagList(readString(in, "Tags (comma separated, optional): "), ","),
+				Sort:        operationrepo.SortField(readString(in, "Sort by (date/amount, default date): ")),
+				Desc:        strings.EqualFold(readString(in, "Descending? (y/N): "), "y"),
+				Limit:       20,
+			}
+			switch readString(in, "Type (0=Spending,1=Income, empty = any): ") {
+			case "0":
+				t := operation.Spending
+				q.Type = &t
+			case "1":
+				t := operation.Income
+				q.Type = &t
+			}
+			for {
+				ops, next, err := opF.QueryOperations(ctx, q)
+				if err != nil {
+					printError(err)
+					break
+				}
+				for _, o := range ops {
+					fmt.Printf("%s | %d | %.2f | %s | %s | %s\n", uuid.UUID(o.ID()).String(), int(o.Type()), o.Amount(), o.Date().Format(time.RFC3339), o.Description(), formatTags(o.Tags()))
+				}
+				if next == "" || !strings.EqualFold(readString(in, "Next page? (y/N): "), "y") {
+					break
+				}
+				q.After = next
+			}
+
+		case "41":
+			text := readString(in, "Search text: ")
+			hits, err := searchF.Search(ctx, text, 20)
+			if err != nil {
+				printError(err)
+				break
+			}
+			if len(hits) == 0 {
+				fmt.Println("nothing found")
+			}
+			for _, h := range hits {
+				o := h.Operation
+				fmt.Printf("%.3f | %s | %s | %.2f | %s | cat=%s | acc=%s\n",
+					h.Rank, uuid.UUID(o.ID()).String(), o.Date().Format(time.RFC3339), o.Amount(), h.Description, h.Category, h.Account)
+			}
+
+		case "42":
+			accs, err := bankF.ListArchivedAccounts(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, a := range accs {
+				fmt.Printf("account  | %s | %s | %.2f | archived %s\n", uuid.UUID(a.ID()).String(), a.Name(), a.Balance(), a.DeletedAt().Format(time.RFC3339))
+			}
+			cats, err := catF.ListArchivedCategories(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, c := range cats {
+				fmt.Printf("category | %s | %s | %d | archived %s\n", uuid.UUID(c.ID()).String(), c.Name(), int(c.Type()), c.DeletedAt().Format(time.RFC3339))
+			}
+
+		case "43":
+			id := readUUID(in, "Account ID (uuid): ")
+			if err := bankF.RestoreAccount(ctx, service.ObjectID(id)); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("restored")
+			}
+
+		case "44":
+			id := readUUID(in, "Category ID (uuid): ")
+			if err := catF.RestoreCategory(ctx, service.ObjectID(id)); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("restored")
+			}
+
+		case "45":
+			pcmd := &commandpkg.PurgeAccountCommand{
+				Facade:    bankF,
+				AccountID: service.ObjectID(readUUID(in, "Account ID (uuid): ")),
+				Confirm: func(acc bankaccount.IBankAccount, ops int) bool {
+					fmt.Printf("Account %q and %d operation(s) will be deleted permanently.\n", acc.Name(), ops)
+					return readString(in, "Type the account name to confirm: ") == acc.Name()
+				},
+			}
+			if err := pcmd.Execute(ctx); err != nil {
+				printError(err)
+			} else if pcmd.Cancelled {
+				fmt.Println("cancelled")
+			} else {
+				fmt.Printf("purged, %d operation(s) deleted\n", pcmd.Purged)
+			}
+
+		case "46":
+			kind := strings.ToLower(readString(in, "Entity (account/category/operation): "))
+			id := service.ObjectID(readUUID(in, "ID (uuid): "))
+			var (
+				versions []historyrepo.Version
+				err      error
+			)
+			switch kind {
+			case "account":
+				versions, err = historyF.AccountHistory(ctx, id)
+			case "category":
+				versions, err = historyF.CategoryHistory(ctx, id)
+			case "operation":
+				versions, err = historyF.OperationHistory(ctx, id)
+			default:
+				err = fmt.Errorf("unknown entity %q", kind)
+			}
+			if err != nil {
+				printError(err)
+				break
+			}
+			if len(versions) == 0 {
+				fmt.Println("no history")
+			}
+			for _, v := range versions {
+				fmt.Printf("v%d | %s | %s\n", v.Version, v.Op, v.ChangedAt.Format(time.RFC3339))
+				changes, err := historyrepo.Diff(v.Before, v.After)
+				if err != nil {
+					fmt.Println("   error:", err)
+					continue
+				}
+				for _, c := range changes {
+					fmt.Printf("   %s: %s -> %s\n", c.Field, orDash(c.Old), orDash(c.New))
+				}
+			}
+
+		case "47":
+			kind := strings.ToLower(readString(in, "Entity (account/category): "))
+			id := service.ObjectID(readUUID(in, "ID (uuid): "))
+			at := readTime(in, "As of (RFC3339): ")
+			switch kind {
+			case "account":
+				acc, err := historyF.AccountAsOf(ctx, id, at)
+				if err != nil {
+					printError(err)
+					break
+				}
+				fmt.Printf("%s | %s | %.2f", uuid.UUID(acc.ID()).String(), acc.Name(), acc.Balance())
+				if acc.IsArchived() {
+					fmt.Print(" | archived")
+				}
+				fmt.Println()
+			case "category":
+				c, err := historyF.CategoryAsOf(ctx, id, at)
+				if err != nil {
+					printError(err)
+					break
+				}
+				fmt.Printf("%s | %s | %d", uuid.UUID(c.ID()).String(), c.Name(), int(c.Type()))
+				if c.IsArchived() {
+					fmt.Print(" | archived")
+				}
+				fmt.Println()
+			default:
+				fmt.Println("error: unknown entity", kind)
+			}
+
+		case "48":
+			if ledgerDB == nil {
+				fmt.Println("error: available only with STORAGE_MODE=ledger or ledger-file")
+				break
+			}
+			id := service.ObjectID(readUUID(in, "ID (uuid): "))
+			if !ownsEntity(ctx, id, bankFacadeRepo, catFacadeRepo, opFacadeRepo) {
+				fmt.Println("error: not found")
+				break
+			}
+			events, err := ledgerDB.Stream(ctx, id)
+			if err != nil {
+				printError(err)
+				break
+			}
+			if len(events) == 0 {
+				fmt.Println("no events")
+			}
+			for _, e := range events {
+				fmt.Printf("#%d | %s | %s | %s\n", e.Seq, e.RecordedAt.Format(time.RFC3339), e.Type, orDash(string(e.Data)))
+			}
+
+		case "49":
+			if ledgerDB == nil {
+				fmt.Println("error: available only with STORAGE_MODE=ledger or ledger-file")
+				break
+			}
+			snapshot := commandpkg.NewRoleDecorator(commandpkg.CommandFunc(ledgerDB.Snapshot), user.Admin)
+			if err := snapshot.Execute(ctx); err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("snapshot taken at event #%d\n", ledgerDB.Seq())
+			}
+
+		case "50":
+			books, err := journalF.Build(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			tb := books.TrialBalance(readOptionalTime(in, "As of (RFC3339, empty = all entries): "))
+			fmt.Printf("%-5s | %-9s | %-30s | %12s | %12s\n", "Code", "Kind", "Account", "Debit", "Credit")
+			for _, row := range tb.Rows {
+				fmt.Printf("%-5s | %-9s | %-30s | %12s | %12s\n", row.Account.Code, row.Account.Kind, row.Account.Name, row.Debit, row.Credit)
+			}
+			fmt.Printf("%-50s | %12s | %12s\n", "Total", tb.TotalDebit, tb.TotalCredit)
+			fmt.Printf("%d journal entries, all balanced; trial balance balanced: %v\n", len(books.Entries), tb.Balanced())
+
+		case "51":
+			books, err := journalF.Build(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, a := range books.Chart.Accounts() {
+				fmt.Printf("%s %s (%s)\n", a.Code, a.Name, a.Kind)
+			}
+			gl, err := books.GeneralLedger(
+				readString(in, "Account code: "),
+				readOptionalTime(in, "From (RFC3339, empty = beginning): "),
+				readOptionalTime(in, "To (RFC3339, empty = no limit): "),
+			)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("%s %s, opening %s\n", gl.Account.Code, gl.Account.Name, gl.Opening)
+			for _, l := range gl.Lines {
+				date := l.Date.Format("2006-01-02")
+				if l.Date.IsZero() {
+					date = "opening   " // начальный остаток действует с начала учёта
+				}
+				fmt.Printf("#%d | %s | %-30s | %12s | %12s | %12s\n", l.Entry, date, l.Description, l.Debit, l.Credit, l.Balance)
+			}
+			fmt.Printf("closing %s\n", gl.Closing)
+
+		case "52":
+			books, err := journalF.Build(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			bs := books.BalanceSheet(readOptionalTime(in, "As of (RFC3339, empty = all entries): "))
+			for _, part := range []struct {
+				title string
+				rows  []journal.ReportRow
+			}{{"Assets", bs.Assets}, {"Liabilities", bs.Liabilities}, {"Equity", bs.Equity}} {
+				fmt.Println(part.title + ":")
+				for _, r := range part.rows {
+					fmt.Printf("  %-5s %-30s %12s\n", r.Account.Code, r.Account.Name, r.Amount)
+				}
+			}
+			fmt.Printf("Total assets: %s, liabilities and equity: %s\n", bs.TotalAssets, bs.TotalLiabilitiesAndEquity)
+
+		case "53":
+			books, err := journalF.Build(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			pl := books.ProfitAndLoss(
+				readOptionalTime(in, "From (RFC3339, empty = beginning): "),
+				readOptionalTime(in, "To (RFC3339, empty = no limit): "),
+			)
+			fmt.Println("Income:")
+			for _, r := range pl.Income {
+				fmt.Printf("  %-5s %-30s %12s\n", r.Account.Code, r.Account.Name, r.Amount)
+			}
+			fmt.Println("Expenses:")
+			for _, r := range pl.Expenses {
+				fmt.Printf("  %-5s %-30s %12s\n", r.Account.Code, r.Account.Name, r.Amount)
+			}
+			fmt.Printf("Total income %s, expenses %s, net %s\n", pl.TotalIncome, pl.TotalExpenses, pl.NetIncome)
+
+		case "54":
+			id := service.ObjectID(readUUID(in, "Account ID (uuid): "))
+			at := readTime(in, "Date (RFC3339): ")
+			bal, err := recF.LedgerBalanceAt(ctx, id, at)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("Ledger balance at %s: %.2f\n", at.Format(time.RFC3339), bal)
+
+		case "55":
+			id := service.ObjectID(readUUID(in, "Account ID (uuid): "))
+			var st reconcile.Statement
+			if path := readString(in, "Statement CSV date,amount,description[,balance] (empty = balance only): "); path != "" {
+				f, err := os.Open(path)
+				if err != nil {
+					printError(err)
+					break
+				}
+				st, err = reconcile.ParseStatementCSV(f)
+				f.Close()
+				if err != nil {
+					printError(err)
+					break
+				}
+			}
+			date := readTime(in, "Statement date (RFC3339): ")
+			balance := st.Balance
+			if st.HasBalance {
+				fmt.Printf("Statement balance from file: %.2f\n", balance)
+			} else {
+				balance = readFloat(in, "Statement balance: ")
+			}
+			sess, err := recF.Start(ctx, id, date, balance, st.Lines)
+			if err != nil {
+				printError(err)
+				break
+			}
+			printReconciliation(sess)
+			for done := false; !done; {
+				cmd := strings.Fields(readString(in, "m <op id> = mark, u <op id> = unmark, l = list, f = finish, q = quit: "))
+				if len(cmd) == 0 {
+					continue
+				}
+				switch cmd[0] {
+				case "m", "u":
+					if len(cmd) < 2 {
+						fmt.Println("operation id is required")
+						continue
+					}
+					opID, err := uuid.Parse(cmd[1])
+					if err != nil {
+						fmt.Println("invalid uuid")
+						continue
+					}
+					if cmd[0] == "u" {
+						sess.Unmark(service.ObjectID(opID))
+					} else if err := sess.Mark(service.ObjectID(opID)); err != nil {
+						printError(err)
+						continue
+					}
+					fmt.Printf("Cleared %.2f, difference %.2f\n", sess.Cleared(), sess.Difference())
+				case "l":
+					printReconciliation(sess)
+				case "f":
+					rec, err := recF.Finish(ctx, sess)
+					if err != nil {
+						printError(err)
+						continue
+					}
+					fmt.Printf("Reconciled %d operation(s) through %s, reconciliation %s\n",
+						len(rec.Items()), rec.StatementDate().Format(time.RFC3339), rec.ID().String())
+					done = true
+				case "q":
+					done = true
+				}
+			}
+
+		case "56":
+			recs, err := recF.ListReconciliations(ctx, service.ObjectID(readUUID(in, "Account ID (uuid): ")))
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, r := range recs {
+				fmt.Printf("- %s | through %s | statement %.2f | ledger %.2f | %d operation(s)\n",
+					r.ID().String(), r.StatementDate().Format(time.RFC3339), r.StatementBalance(), r.LedgerBalance(), len(r.Items()))
+			}
+
+		case "57":
+			list, err := periodF.ListPeriods(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, p := range list {
+				state := "open"
+				if p.Closed {
+					state = "closed"
+				}
+				fmt.Printf("- %s | %-6s | %d operation(s)\n", p.Month.Format("2006-01"), state, p.Operations)
+				for _, e := range p.Log {
+					fmt.Printf("    %s %s %s\n", e.At.Format(time.RFC3339), e.Action, e.Reason)
+				}
+			}
+
+		case "58":
+			if err := periodF.ClosePeriod(ctx, readMonth(in, "Month (YYYY-MM): ")); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("closed")
+			}
+
+		case "59":
+			month := readMonth(in, "Month (YYYY-MM): ")
+			if err := periodF.ReopenPeriod(ctx, month, readString(in, "Reason: ")); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("reopened")
+			}
+
+		case "60":
+			dcmd := &commandpkg.DedupeCommand{
+				Facade:  opF,
+				Options: dedupeOpts,
+				Confirm: func(group []operation.IOperation) bool {
+					fmt.Println("Duplicates (the first one is kept):")
+					for _, op := range group {
+						fmt.Println("  " + describeOperation(op))
+					}
+					return strings.ToLower(readString(in, "Merge? (y/N): ")) == "y"
+				},
+			}
+			if err := dcmd.Execute(ctx); err != nil {
+				printError(err)
+			}
+			fmt.Printf("groups found: %d, operations merged: %d\n", dcmd.Groups, dcmd.Merged)
+
+		case "61":
+			u, err := login(ctx, in, userF, "", "", "")
+			if err != nil {
+				printError(err)
+				break
+			}
+			openSession(u)
+			fmt.Println("logged in as", u.Name())
+
+		case "62":
+			users, err := userF.ListUsers(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			for _, u := range users {
+				mark := " "
+				if u.ID() == currentUser.ID() {
+					mark = "*"
+				}
+				fmt.Printf("%s %s | %s | %s | %s\n", mark, u.ID().String(), u.Name(), u.Role(), u.CreatedAt().Format(time.RFC3339))
+			}
+
+		case "63":
+			name := readString(in, "User name: ")
+			role, err := user.ParseRole(readString(in, "Role (viewer/editor/admin): "))
+			if err != nil {
+				printError(err)
+				break
+			}
+			u, err := userF.CreateUser(ctx, name, readNewPassword(in), role)
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("created user:", u.ID().String())
+			}
+
+		case "64":
+			id := service.ObjectID(readUUID(in, "User ID (uuid): "))
+			role, err := user.ParseRole(readString(in, "New role (viewer/editor/admin): "))
+			if err == nil {
+				err = userF.SetRole(ctx, id, role)
+			}
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Println("role changed")
+			if id == currentUser.ID() {
+				if u, err := userF.GetUser(ctx, id); err == nil {
+					openSession(u)
+				}
+			}
+
+		case "65":
+			current := readString(in, "Current password: ")
+			if err := userF.ChangePassword(ctx, currentUser.ID(), current, readNewPassword(in)); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("password changed")
+			}
+
+		case "66":
+			token, err := userF.IssueToken(ctx, currentUser.ID())
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Println(token)
+
+		case "67":
+			accID := service.ObjectID(readUUID(in, "Account ID (uuid): "))
+			u, err := userF.FindUser(ctx, readString(in, "User name: "))
+			if err != nil {
+				printError(err)
+				break
+			}
+			level, err := service.ParseAccessLevel(readString(in, "Access (read/write): "))
+			if err == nil {
+				_, err = shareF.ShareAccount(ctx, accID, u.ID(), level)
+			}
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("account shared with %s (%s)\n", u.Name(), level)
+			}
+
+		case "68":
+			accID := service.ObjectID(readUUID(in, "Account ID (uuid): "))
+			u, err := userF.FindUser(ctx, readString(in, "User name: "))
+			if err == nil {
+				err = shareF.RevokeShare(ctx, accID, u.ID())
+			}
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("access revoked")
+			}
+
+		case "69":
+			shares, err := shareF.ListShares(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Println("Shared by me:")
+			for _, sh := range shares {
+				name := sh.UserID().String()
+				if u, err := userF.GetUser(ctx, sh.UserID()); err == nil {
+					name = u.Name()
+				}
+				fmt.Printf("  %s -> %s | %s | %s\n", sh.AccountID().String(), name, sh.Level(), sh.CreatedAt().Format(time.RFC3339))
+			}
+			accs, err := bankF.ListAllAccounts(ctx)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Println("Shared with me:")
+			for _, a := range accs {
+				if mark := sharedMark(ctx, userF, currentUser, a); mark != "" {
+					fmt.Printf("  %s | %s | %.2f%s\n", a.ID().String(), a.Name(), a.Balance(), mark)
+				}
+			}
+
+		case "70":
+			u, err := userF.FindUser(ctx, readString(in, "User name: "))
+			if err != nil {
+				printError(err)
+				break
+			}
+			code, err := userF.IssueSetupCode(ctx, u.ID())
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("one-time password setup code for %s (valid %s):\n%s\n", u.Name(), facade.SetupCodeTTL, code)
+
+		case "71":
+			id := service.ObjectID(readUUID(in, "Category ID (uuid): "))
+			cat, err := catF.GetCategory(ctx, id)
+			if err != nil {
+				printError(err)
+				break
+			}
+			if readString(in, "Type the category name to confirm: ") != cat.Name() {
+				fmt.Println("cancelled")
+				break
+			}
+			if err := catF.PurgeCategory(ctx, id); err != nil {
+				printError(err)
+			} else {
+				fmt.Println("purged")
+			}
+
+		case "0":
+			fmt.Println("Bye!")
+			return
+		default:
+			fmt.Println("Unknown choice")
+		}
+	}
+}
+
+// Вход по токену или по имени и паролю. Пользователь без пароля (default при первом запуске)
+// задаёт его, только предъявив код установки: BOOTSTRAP_SECRET или код от администратора.
+func login(ctx context.Context, in *bufio.Reader, users *facade.UserFacade, token, name, password string) (user.IUser, error) {
+	if token != "" {
+		return users.Authenticate(ctx, token)
+	}
+	if name == "" {
+		name = readString(in, "User name: ")
+	}
+	if password == "" {
+		password = readString(in, "Password: ")
+	}
+	u, err := users.Login(ctx, name, password)
+	if !errors.Is(err, facade.ErrPasswordNotSet) {
+		return u, err
+	}
+	fmt.Printf("User %s has no password yet. Set one now.\n", u.Name())
+	code := readString(in, "Setup code (BOOTSTRAP_SECRET or code from an admin): ")
+	return users.SetPasswordWithCode(ctx, name, code, readNewPassword(in))
+}
+
+func readNewPassword(in *bufio.Reader) string {
+	for {
+		p := readString(in, "New password (8+ characters): ")
+		if readString(in, "Repeat password: ") == p {
+			return p
+		}
+		fmt.Println("Passwords do not match, try again")
+	}
+}
+
+// Роль для каждого пункта меню. Пункт, которого здесь нет, требует администратора:
+// новое действие без явной роли не становится доступным всем. Фасады и репозитории
+// проверяют роли ещё раз, меню лишь отказывает раньше, до ввода параметров.
+var menuRoles = map[string]user.Role{
+	"0": user.Viewer, "2": user.Viewer, "5": user.Viewer, "7": user.Viewer, "8": user.Viewer,
+	"9": user.Viewer, "10": user.Viewer, "11": user.Viewer, "12": user.Viewer, "16": user.Viewer,
+	"19": user.Viewer, "22": user.Viewer, "24": user.Viewer, "25": user.Viewer, "26": user.Viewer,
+	"27": user.Viewer, "28": user.Viewer, "30": user.Viewer, "34": user.Viewer, "36": user.Viewer,
+	"38": user.Viewer, "40": user.Viewer, "41": user.Viewer, "42": user.Viewer, "46": user.Viewer,
+	"47": user.Viewer, "48": user.Viewer, "50": user.Viewer, "51": user.Viewer, "52": user.Viewer,
+	"53": user.Viewer, "54": user.Viewer, "56": user.Viewer, "57": user.Viewer, "61": user.Viewer,
+	"65": user.Viewer, "66": user.Viewer, "69": user.Viewer,
+
+	"1": user.Editor, "4": user.Editor, "6": user.Editor,
+	"17": user.Editor, "18": user.Editor, "20": user.Editor, "21": user.Editor, "23": user.Editor,
+	"29": user.Editor, "31": user.Editor, "32": user.Editor, "33": user.Editor, "35": user.Editor,
+	"37": user.Editor, "39": user.Editor, "44": user.Editor, "55": user.Editor, "60": user.Editor,
+	"67": user.Editor, "68": user.Editor,
+
+	// удаление счетов, импорт, периоды, журнал и пользователи
+	"3": user.Admin, "13": user.Admin, "14": user.Admin, "15": user.Admin,
+	"43": user.Admin, "45": user.Admin, "49": user.Admin, "58": user.Admin, "59": user.Admin,
+	"62": user.Admin, "63": user.Admin, "64": user.Admin, "70": user.Admin, "71": user.Admin,
+}
+
+func requiredRole(choice string) user.Role {
+	if r, ok := menuRoles[choice]; ok {
+		return r
+	}
+	return user.Admin
+}
+
+// Сущность видна пользователю через один из его репозиториев.
+func ownsEntity(ctx context.Context, id service.ObjectID, repos ...repository.ICommonRepo) bool {
+	for _, r := range repos {
+		if _, err := r.ByID(ctx, id); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Код ошибки в CLI по её виду; ошибки вне таксономии (файлы, БД) печатаются без кода.
+func errorCode(err error) string {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return "invalid " + invalid.Field
+	case errors.Is(err, service.ErrNotFound):
+		return "not found"
+	case errors.Is(err, service.ErrConflict):
+		return "conflict"
+	case errors.Is(err, service.ErrInvariant):
+		return "invariant"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, auth.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrBadCredentials),
+		errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
+		return "unauthenticated"
+	default:
+		return ""
+	}
+}
+
+// Контекст команды, которую прерывает Ctrl-C.
+type interrupter struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (i *interrupter) begin(parent context.Context) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	return ctx
+}
+
+func (i *interrupter) end() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+}
+
+// false — команда не выполняется.
+func (i *interrupter) interrupt() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel == nil {
+		return false
+	}
+	i.cancel()
+	return true
+}
+
+func printError(err error) {
+	if code := errorCode(err); code != "" {
+		fmt.Printf("error [%s]: %v\n", code, err)
+		return
+	}
+	fmt.Println("error:", err)
+}
+
+// Пометка чужого счёта в списках: чей он.
+func sharedMark(ctx context.Context, users *facade.UserFacade, current user.IUser, obj service.ICommonObject) string {
+	owner, ok := service.OwnerOf(obj)
+	if !ok || owner == current.ID() {
+		return ""
+	}
+	name := owner.String()
+	if u, err := users.GetUser(ctx, owner); err == nil {
+		name = u.Name()
+	}
+	return " (shared by " + name + ")"
+}
+
+// Аналитика по операциям одного автора, если он указан.
+func analyticsByAuthor(ctx context.Context, in *bufio.Reader, users *facade.UserFacade, a *facade.AnalyticsFacade) (*facade.AnalyticsFacade, error) {
+	name := readString(in, "Author user name (empty = all): ")
+	if name == "" {
+		return a, nil
+	}
+	u, err := users.FindUser(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return a.ByAuthor(u.ID()), nil
+}
+
+// У каждого пользователя своя модель подсказок: она обучается на его операциях.
+func userModelPath(path string, owner service.ObjectID) string {
+	if owner == service.LegacyOwnerID {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + owner.String() + ext
+}
+
+func describeOperation(op operation.IOperation) string {
+	return fmt.Sprintf("%s | %s | %10.2f | %s", op.ID().String(), op.Date().Format(time.RFC3339), operation.SignedAmount(op), op.Description())
+}
+
+func printReconciliation(s *reconcile.Session) {
+	fmt.Printf("Start %.2f, ledger at date %.2f, statement %.2f, cleared %.2f, difference %.2f\n",
+		s.StartBalance, s.LedgerBalance, s.StatementBalance, s.Cleared(), s.Difference())
+	fmt.Println("Operations:")
+	for _, op := range s.Candidates {
+		mark := " "
+		if s.IsMarked(op.ID()) {
+			mark = "x"
+		}
+		fmt.Printf("  [%s] %s | %s | %10.2f | %s\n",
+			mark, op.ID().String(), op.Date().Format(time.RFC3339), operation.SignedAmount(op), op.Description())
+	}
+	if len(s.Result.MissingInBooks) > 0 {
+		fmt.Println("In statement, not in books:")
+		for _, l := range s.Result.MissingInBooks {
+			fmt.Printf("  %s | %10.2f | %s\n", l.Date.Format("2006-01-02"), l.Amount, l.Description)
+		}
+	}
+	if len(s.Result.ExtraInBooks) > 0 {
+		fmt.Println("In books, not in statement:")
+		for _, op := range s.Result.ExtraInBooks {
+			fmt.Printf("  %s | %s | %10.2f\n", op.ID().String(), op.Date().Format("2006-01-02"), operation.SignedAmount(op))
+		}
+	}
+}
+
+// Ждёт выбор пункта меню; отмена ctx прерывает ожидание, ok == false.
+// Незавершённое чтение остаётся в горутине: после отмены программа завершается.
+func readChoice(ctx context.Context, in *bufio.Reader) (choice string, ok bool) {
+	line := make(chan string, 1)
+	go func() {
+		s, _ := in.ReadString('\n')
+		line <- s
+	}()
+	select {
+	case s := <-line:
+		return strings.TrimSpace(s), true
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+func readString(in *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+	s, _ := in.ReadString('\n')
+	return strings.TrimSpace(s)
+}
+
+func readFloat(in *bufio.Reader, prompt string) float64 {
+	for {
+		s := readString(in, prompt)
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+		fmt.Println("Invalid float, try again")
+	}
+}
+
+func readOptionalFloat(in *bufio.Reader, prompt string) *float64 {
+	for {
+		s := readString(in, prompt)
+		if s == "" {
+			return nil
+		}
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return &v
+		}
+		fmt.Println("Invalid float, try again")
+	}
+}
+
+func readInt(in *bufio.Reader, prompt string) int {
+	for {
+		s := readString(in, prompt)
+		if v, err := strconv.Atoi(s); err == nil {
+			return v
+		}
+		fmt.Println("Invalid int, try again")
+	}
+}
+
+func readUUID(in *bufio.Reader, prompt string) uuid.UUID {
+	for {
+		s := readString(in, prompt)
+		if id, err := uuid.Parse(strings.TrimSpace(s)); err == nil {
+			return id
+		}
+		fmt.Println("Invalid uuid, try again")
+	}
+}
+
+func readOptionalUUID(in *bufio.Reader, prompt string) *uuid.UUID {
+	for {
+		s := readString(in, prompt)
+		if s == "" {
+			return nil
+		}
+		if id, err := uuid.Parse(s); err == nil {
+			return &id
+		}
+		fmt.Println("Invalid uuid, try again")
+	}
+}
+
+func readLocation(in *bufio.Reader, prompt string) *time.Location {
+	for {
+		s := readString(in, prompt)
+		if s == "" {
+			return time.UTC
+		}
+		if loc, err := time.LoadLocation(s); err == nil {
+			return loc
+		}
+		fmt.Println("Invalid timezone, try again")
+	}
+}
+
+func readTime(in *bufio.Reader, prompt string) time.Time {
+	for {
+		s := readString(in, prompt)
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		fmt.Println("Invalid time, expected RFC3339, try again")
+	}
+}
+
+func readOptionalTime(in *bufio.Reader, prompt string) time.Time {
+	for {
+		s := readString(in, prompt)
+		if s == "" {
+			return time.Time{}
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		fmt.Println("Invalid time, expected RFC3339, try again")
+	}
+}
+
+func readUUIDList(in *bufio.Reader, prompt string) []service.ObjectID {
+	for {
+		var (
+			ids []service.ObjectID
+			bad bool
+		)
+		for _, s := range strings.Split(readString(in, prompt), ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				bad = true
+				break
+			}
+			ids = append(ids, service.ObjectID(id))
+		}
+		if !bad {
+			return ids
+		}
+		fmt.Println("Invalid uuid in list, try again")
+	}
+}
+
+func readMonth(in *bufio.Reader, prompt string) time.Time {
+	for {
+		s := readString(in, prompt)
+		if t, err := time.Parse("2006-01", s); err == nil {
+			return t
+		}
+		fmt.Println("Invalid month, expected YYYY-MM, try again")
+	}
+}
+
+func readDurationEnv(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(getEnv(key, "")); err == nil {
+		return d
+	}
+	return def
+}
+
+func readIntEnv(key string, def int) int {
+	if n, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return n
+	}
+	return def
+}
+
+func getEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
 }
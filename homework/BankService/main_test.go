@@ -2,26 +2,59 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	anomaly "github.com/ilyaytrewq/kpo-sb/homework/BankService/Anomaly"
+	auth "github.com/ilyaytrewq/kpo-sb/homework/BankService/Auth"
 	commandpkg "github.com/ilyaytrewq/kpo-sb/homework/BankService/Command"
 	csvexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/CsvExporter"
 	jsonexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/JsonExporter"
+	parquetexporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Exporter/ParquetExporter"
+	importer "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer"
 	csvimporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer/CsvImporter"
 	jsonimporter "github.com/ilyaytrewq/kpo-sb/homework/BankService/DataIO/Importer/JsonImporter"
+	dedupe "github.com/ilyaytrewq/kpo-sb/homework/BankService/Dedupe"
 	facade "github.com/ilyaytrewq/kpo-sb/homework/BankService/Facade"
+	forecast "github.com/ilyaytrewq/kpo-sb/homework/BankService/Forecast"
+	journal "github.com/ilyaytrewq/kpo-sb/homework/BankService/Journal"
+	ledger "github.com/ilyaytrewq/kpo-sb/homework/BankService/Ledger"
+	periods "github.com/ilyaytrewq/kpo-sb/homework/BankService/Periods"
+	reconcile "github.com/ilyaytrewq/kpo-sb/homework/BankService/Reconcile"
+	report "github.com/ilyaytrewq/kpo-sb/homework/BankService/Report"
+	repository "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository"
 	bankaccountrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/BankAccountRepo"
 	categoryrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/CategoryRepo"
+	dbrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/DBRepo"
+	postgresrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/DBRepo/PostgresRepo"
+	historyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/HistoryRepo"
 	operationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/OperationRepo"
+	periodrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/PeriodRepo"
+	proxyrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ProxyRepo"
+	reconciliationrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ReconciliationRepo"
+	rulerepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/RuleRepo"
+	sharerepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/ShareRepo"
+	userrepo "github.com/ilyaytrewq/kpo-sb/homework/BankService/Repository/UserRepo"
 	service "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service"
 	bankaccount "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/BankAccount"
 	category "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Category"
 	operation "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Operation"
+	period "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Period"
+	rule "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/Rule"
+	user "github.com/ilyaytrewq/kpo-sb/homework/BankService/Service/User"
 	timer "github.com/ilyaytrewq/kpo-sb/homework/BankService/Timer"
+	"github.com/parquet-go/parquet-go"
 )
 
 // ---------- Domain factories validation ----------
@@ -45,6 +78,7 @@ func TestFactoriesValidation(t *testing.T) {
 
 // ---------- OperationRepo filtering + Analytics ----------
 func TestOperationRepoSliceAndAnalytics(t *testing.T) {
+	ctx := context.Background()
 	opRepo := operationrepo.NewOperationRepo()
 	accID := service.ObjectID(uuid.New())
 	catID := service.ObjectID(uuid.New())
@@ -54,13 +88,13 @@ func TestOperationRepoSliceAndAnalytics(t *testing.T) {
 	op2, _ := operation.NewOperation(operation.Spending, accID, 40, now.Add(-30*time.Minute), catID)
 	// outside period (before)
 	op3, _ := operation.NewOperation(operation.Income, accID, 55, now.Add(-10*time.Hour), catID)
-	_ = opRepo.Save(context.Background(), op1)
-	_ = opRepo.Save(context.Background(), op2)
-	_ = opRepo.Save(context.Background(), op3)
+	_ = opRepo.Save(ctx, op1)
+	_ = opRepo.Save(ctx, op2)
+	_ = opRepo.Save(ctx, op3)
 
 	from := now.Add(-2 * time.Hour)
 	to := now
-	slice, err := opRepo.SliceByAccountAndPeriod(context.Background(), accID, from, to)
+	slice, err := opRepo.SliceByAccountAndPeriod(ctx, accID, from, to)
 	if err != nil {
 		t.Fatalf("slice error: %v", err)
 	}
@@ -69,7 +103,7 @@ func TestOperationRepoSliceAndAnalytics(t *testing.T) {
 	}
 
 	analytics := facade.NewAnalyticsFacade(opRepo)
-	inc, exp, delta, err := analytics.IncomeExpenseDelta(accID, from, to)
+	inc, exp, delta, err := analytics.IncomeExpenseDelta(ctx, accID, from, to)
 	if err != nil {
 		t.Fatalf("analytics error: %v", err)
 	}
@@ -80,12 +114,13 @@ func TestOperationRepoSliceAndAnalytics(t *testing.T) {
 
 // ---------- Exporter & Importer roundtrip (JSON) ----------
 func TestJSONExportImportAccounts(t *testing.T) {
+	ctx := context.Background()
 	repo := bankaccountrepo.NewBankAccountRepo()
 	acc, _ := bankaccount.NewBankAccount("Main", 123.45)
-	if err := repo.Save(context.Background(), acc); err != nil {
+	if err := repo.Save(ctx, acc); err != nil {
 		t.Fatalf("save err: %v", err)
 	}
-	data, _ := repo.All(context.Background())
+	data, _ := repo.All(ctx)
 
 	tmpFile, err := os.CreateTemp(t.TempDir(), "acc*.json")
 	if err != nil {
@@ -94,7 +129,7 @@ func TestJSONExportImportAccounts(t *testing.T) {
 	path := tmpFile.Name()
 	tmpFile.Close()
 
-	if err := jsonexporter.NewJSONBankAccountExporter(path).Export(data); err != nil {
+	if err := jsonexporter.NewJSONBankAccountExporter(path).Export(ctx, data); err != nil {
 		t.Fatalf("export err: %v", err)
 	}
 	raw, err := os.ReadFile(path)
@@ -107,10 +142,10 @@ func TestJSONExportImportAccounts(t *testing.T) {
 
 	// import back
 	imp := jsonimporter.NewJSONBankAccountImporter(path)
-	if err := imp.Read(); err != nil {
+	if err := imp.Read(ctx); err != nil {
 		t.Fatalf("import read err: %v", err)
 	}
-	imported, _ := imp.Data().All(context.Background())
+	imported, _ := imp.Data().All(ctx)
 	if len(imported) != 1 {
 		t.Fatalf("expected 1 imported account, got %d", len(imported))
 	}
@@ -118,21 +153,22 @@ func TestJSONExportImportAccounts(t *testing.T) {
 
 // ---------- CSV exporter/importer basic test ----------
 func TestCSVExportImportCategories(t *testing.T) {
+	ctx := context.Background()
 	repo := categoryrepo.NewCategoryRepo()
 	cat, _ := category.NewCategory("Food", category.Spending)
-	_ = repo.Save(context.Background(), cat)
-	data, _ := repo.All(context.Background())
+	_ = repo.Save(ctx, cat)
+	data, _ := repo.All(ctx)
 	tmpFile, _ := os.CreateTemp(t.TempDir(), "cat*.csv")
 	path := tmpFile.Name()
 	tmpFile.Close()
-	if err := csvexporter.NewCSVCategoryExporter(path).Export(data); err != nil {
+	if err := csvexporter.NewCSVCategoryExporter(path).Export(ctx, data); err != nil {
 		t.Fatalf("csv export err: %v", err)
 	}
 	imp := csvimporter.NewCSVCategoryImporter(path)
-	if err := imp.Read(); err != nil {
+	if err := imp.Read(ctx); err != nil {
 		t.Fatalf("csv import err: %v", err)
 	}
-	imported, _ := imp.Data().All(context.Background())
+	imported, _ := imp.Data().All(ctx)
 	if len(imported) != 1 {
 		t.Fatalf("expected 1 imported category, got %d", len(imported))
 	}
@@ -140,18 +176,19 @@ func TestCSVExportImportCategories(t *testing.T) {
 
 // ---------- Timer decorator test ----------
 func TestTimerDecorator(t *testing.T) {
+	ctx := context.Background()
 	bankRepo := bankaccountrepo.NewBankAccountRepo()
 	fac := facade.NewBankAccountFacade(bankRepo)
 	cmd := &commandpkg.CreateAccountCommand{Facade: fac, Name: "X", Balance: 10}
 	timed := timer.NewTimerDecorator(cmd)
 	start := time.Now()
-	if err := timed.Execute(); err != nil {
+	if err := timed.Execute(ctx); err != nil {
 		t.Fatalf("timed execute error: %v", err)
 	}
 	if time.Since(start) <= 0 {
 		t.Fatalf("expected positive duration")
 	}
-	all, _ := bankRepo.All(context.Background())
+	all, _ := bankRepo.All(ctx)
 	if len(all) != 1 {
 		t.Fatalf("expected created account in repo")
 	}
@@ -159,6 +196,7 @@ func TestTimerDecorator(t *testing.T) {
 
 // ---------- OperationFacade.GetOperationsByPeriod ----------
 func TestOperationFacade_GetOperationsByPeriod(t *testing.T) {
+	ctx := context.Background()
 	opRepo := operationrepo.NewOperationRepo()
 	fac := facade.NewOperationFacade(opRepo)
 
@@ -169,13 +207,13 @@ func TestOperationFacade_GetOperationsByPeriod(t *testing.T) {
 	in1, _ := operation.NewOperation(operation.Income, accID, 10, now.Add(-2*time.Hour), catID)
 	in2, _ := operation.NewOperation(operation.Spending, accID, 5, now, catID)
 	out1, _ := operation.NewOperation(operation.Spending, accID, 3, now.Add(-3*time.Hour), catID)
-	_ = opRepo.Save(context.Background(), in1)
-	_ = opRepo.Save(context.Background(), in2)
-	_ = opRepo.Save(context.Background(), out1)
+	_ = opRepo.Save(ctx, in1)
+	_ = opRepo.Save(ctx, in2)
+	_ = opRepo.Save(ctx, out1)
 
 	from := now.Add(-2 * time.Hour)
 	to := now
-	got, err := fac.GetOperationsByPeriod(accID, from, to)
+	got, err := fac.GetOperationsByPeriod(ctx, accID, from, to)
 	if err != nil {
 		t.Fatalf("GetOperationsByPeriod error: %v", err)
 	}
@@ -186,6 +224,7 @@ func TestOperationFacade_GetOperationsByPeriod(t *testing.T) {
 
 // ---------- Analytics: GroupByCategory ----------
 func TestAnalytics_GroupByCategory(t *testing.T) {
+	ctx := context.Background()
 	opRepo := operationrepo.NewOperationRepo()
 	accID := service.ObjectID(uuid.New())
 	catA := service.ObjectID(uuid.New())
@@ -195,12 +234,12 @@ func TestAnalytics_GroupByCategory(t *testing.T) {
 	a1, _ := operation.NewOperation(operation.Income, accID, 10, now.Add(-30*time.Minute), catA)
 	a2, _ := operation.NewOperation(operation.Spending, accID, 5, now.Add(-20*time.Minute), catA)
 	b1, _ := operation.NewOperation(operation.Spending, accID, 7, now.Add(-10*time.Minute), catB)
-	_ = opRepo.Save(context.Background(), a1)
-	_ = opRepo.Save(context.Background(), a2)
-	_ = opRepo.Save(context.Background(), b1)
+	_ = opRepo.Save(ctx, a1)
+	_ = opRepo.Save(ctx, a2)
+	_ = opRepo.Save(ctx, b1)
 
 	analytics := facade.NewAnalyticsFacade(opRepo)
-	m, err := analytics.GroupByCategory(accID, now.Add(-1*time.Hour), now)
+	m, err := analytics.GroupByCategory(ctx, accID, now.Add(-1*time.Hour), now)
 	if err != nil {
 		t.Fatalf("group error: %v", err)
 	}
@@ -211,6 +250,7 @@ func TestAnalytics_GroupByCategory(t *testing.T) {
 
 // ---------- Analytics: SplitByCategoryType ----------
 func TestAnalytics_SplitByCategoryType(t *testing.T) {
+	ctx := context.Background()
 	opRepo := operationrepo.NewOperationRepo()
 	accID := service.ObjectID(uuid.New())
 	catInc := service.ObjectID(uuid.New())
@@ -219,16 +259,16 @@ func TestAnalytics_SplitByCategoryType(t *testing.T) {
 	inc1, _ := operation.NewOperation(operation.Income, accID, 11, now.Add(-15*time.Minute), catInc)
 	exp1, _ := operation.NewOperation(operation.Spending, accID, 4, now.Add(-14*time.Minute), catExp)
 	exp2, _ := operation.NewOperation(operation.Spending, accID, 6, now.Add(-13*time.Minute), catExp)
-	_ = opRepo.Save(context.Background(), inc1)
-	_ = opRepo.Save(context.Background(), exp1)
-	_ = opRepo.Save(context.Background(), exp2)
+	_ = opRepo.Save(ctx, inc1)
+	_ = opRepo.Save(ctx, exp1)
+	_ = opRepo.Save(ctx, exp2)
 
 	analytics := facade.NewAnalyticsFacade(opRepo)
 	cats := map[service.ObjectID]category.CategoryType{
 		catInc: category.Income,
 		catExp: category.Spending,
 	}
-	split, err := analytics.SplitByCategoryType(accID, now.Add(-1*time.Hour), now, cats)
+	split, err := analytics.SplitByCategoryType(ctx, accID, now.Add(-1*time.Hour), now, cats)
 	if err != nil {
 		t.Fatalf("split error: %v", err)
 	}
@@ -239,3 +279,2596 @@ func TestAnalytics_SplitByCategoryType(t *testing.T) {
 		t.Fatalf("unexpected spending sum: %v", split[category.Spending])
 	}
 }
+
+// ---------- Parquet partitioned export ----------
+func TestParquetPartitionedExport(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	accID := service.ObjectID(uuid.New())
+	catID := service.ObjectID(uuid.New())
+	nov := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	dec := time.Date(2025, 12, 3, 9, 30, 0, 0, time.UTC)
+	op1, _ := operation.NewOperation(operation.Spending, accID, 12.34, nov, catID, "coffee")
+	op2, _ := operation.NewOperation(operation.Income, accID, 1000, dec, catID)
+	_ = opRepo.Save(ctx, op1)
+	_ = opRepo.Save(ctx, op2)
+
+	dir := t.TempDir()
+	data, _ := opRepo.All(ctx)
+	exp := parquetexporter.NewParquetPartitionedOperationExporter(dir)
+	if err := exp.Export(ctx, data); err != nil {
+		t.Fatalf("export err: %v", err)
+	}
+	if exp.Written != 2 {
+		t.Fatalf("expected 2 partitions written, got %d", exp.Written)
+	}
+
+	rows, err := parquet.ReadFile[parquetexporter.OperationRow](parquetexporter.PartitionPath(dir, accID, "2025-11"))
+	if err != nil {
+		t.Fatalf("read partition err: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Amount != 1234 || rows[0].Date != nov.UnixMicro() || rows[0].ID != uuid.UUID(op1.ID()) {
+		t.Fatalf("unexpected partition content: %+v", rows)
+	}
+
+	// only the December partition changes
+	op3, _ := operation.NewOperation(operation.Spending, accID, 5, dec.Add(time.Hour), catID)
+	_ = opRepo.Save(ctx, op3)
+	data, _ = opRepo.All(ctx)
+	if err := exp.Export(ctx, data); err != nil {
+		t.Fatalf("second export err: %v", err)
+	}
+	if exp.Written != 1 || exp.Unchanged != 1 {
+		t.Fatalf("expected 1 written and 1 unchanged partition, got %d/%d", exp.Written, exp.Unchanged)
+	}
+
+	// a stray file makes the partition dirty; rewriting clears it
+	decDir := filepath.Dir(parquetexporter.PartitionPath(dir, accID, "2025-12"))
+	stray := filepath.Join(decDir, "part-old.parquet")
+	if err := os.WriteFile(stray, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Export(ctx, data); err != nil {
+		t.Fatalf("third export err: %v", err)
+	}
+	if exp.Written != 1 || exp.Unchanged != 1 {
+		t.Fatalf("expected the dirty partition rewritten, got %d/%d", exp.Written, exp.Unchanged)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Fatalf("stale file survived the rewrite: %v", err)
+	}
+
+	// the November partition loses its only operation and is removed
+	_ = opRepo.Delete(ctx, op1.ID())
+	data, _ = opRepo.All(ctx)
+	if err := exp.Export(ctx, data); err != nil {
+		t.Fatalf("fourth export err: %v", err)
+	}
+	if exp.Removed != 1 || exp.Unchanged != 1 {
+		t.Fatalf("expected 1 removed and 1 unchanged partition, got %d/%d", exp.Removed, exp.Unchanged)
+	}
+	novDir := filepath.Dir(parquetexporter.PartitionPath(dir, accID, "2025-11"))
+	if _, err := os.Stat(novDir); !os.IsNotExist(err) {
+		t.Fatalf("empty partition dir survived: %v", err)
+	}
+}
+
+// ---------- Monthly statement report ----------
+func TestReport_MonthlyStatement(t *testing.T) {
+	ctx := context.Background()
+	bankRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+
+	// balance is the opening balance before every operation below
+	acc, _ := bankaccount.NewBankAccount("Main", 250)
+	_ = bankRepo.Save(ctx, acc)
+	food, _ := category.NewCategory("Food", category.Spending)
+	salary, _ := category.NewCategory("Salary", category.Income)
+	_ = catRepo.Save(ctx, food)
+	_ = catRepo.Save(ctx, salary)
+
+	nov := func(day int) time.Time { return time.Date(2025, 11, day, 12, 0, 0, 0, time.UTC) }
+	ops := []*operation.Operation{}
+	o1, _ := operation.NewOperation(operation.Income, acc.ID(), 1000, nov(1), salary.ID(), "salary")
+	o2, _ := operation.NewOperation(operation.Spending, acc.ID(), 150, nov(5), food.ID(), "groceries")
+	o3, _ := operation.NewOperation(operation.Spending, acc.ID(), 50, nov(20), food.ID(), "cafe")
+	o4, _ := operation.NewOperation(operation.Income, acc.ID(), 100, time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC), salary.ID())
+	ops = append(ops, o1, o2, o3, o4)
+	for _, o := range ops {
+		_ = opRepo.Save(ctx, o)
+	}
+
+	rf := facade.NewReportFacade(bankRepo, catRepo, opRepo)
+	st, err := rf.MonthlyStatement(ctx, acc.ID(), 2025, time.November, time.UTC)
+	if err != nil {
+		t.Fatalf("statement error: %v", err)
+	}
+	if st.ClosingBalance != 1050 || st.OpeningBalance != 250 {
+		t.Fatalf("unexpected balances opening=%v closing=%v", st.OpeningBalance, st.ClosingBalance)
+	}
+	if len(st.Lines) != 3 || st.Lines[0].CategoryName != "Salary" || st.Lines[2].Balance != 1050 {
+		t.Fatalf("unexpected lines: %+v", st.Lines)
+	}
+	for _, b := range st.Breakdown {
+		if b.Name == "Food" && b.Percent != 100 {
+			t.Fatalf("expected Food to be 100%% of spending, got %v", b.Percent)
+		}
+	}
+
+	dir := t.TempDir()
+	htmlPath := dir + "/statement.html"
+	if err := report.NewHTMLStatementExporter(htmlPath, "").Export(ctx, st); err != nil {
+		t.Fatalf("html export err: %v", err)
+	}
+	raw, _ := os.ReadFile(htmlPath)
+	if !strings.Contains(string(raw), "<svg") || !strings.Contains(string(raw), "groceries") {
+		t.Fatalf("html report is missing chart or operations")
+	}
+
+	tmplPath := dir + "/custom.tmpl"
+	_ = os.WriteFile(tmplPath, []byte("{{.AccountName}}: {{money .ClosingBalance}}"), 0644)
+	mdPath := dir + "/statement.md"
+	if err := report.NewMarkdownStatementExporter(mdPath, tmplPath).Export(ctx, st); err != nil {
+		t.Fatalf("md export err: %v", err)
+	}
+	raw, _ = os.ReadFile(mdPath)
+	if string(raw) != "Main: 1050.00" {
+		t.Fatalf("custom template not applied: %q", string(raw))
+	}
+
+	// operations created in the app do not change the stored balance: it stays the opening one
+	wallet, _ := facade.NewBankAccountFacade(bankRepo).CreateAccount(ctx, "Wallet", 100)
+	opF := facade.NewOperationFacade(opRepo)
+	if _, err := opF.CreateOperation(ctx, operation.Spending, wallet, 30, nov(10), food.ID(), "lunch"); err != nil {
+		t.Fatalf("create operation: %v", err)
+	}
+	st, err = rf.MonthlyStatement(ctx, wallet, 2025, time.November, time.UTC)
+	if err != nil || st.OpeningBalance != 100 || st.ClosingBalance != 70 {
+		t.Fatalf("statement after a new expense: opening=%v closing=%v (%v)", st.OpeningBalance, st.ClosingBalance, err)
+	}
+	if st, _ = rf.MonthlyStatement(ctx, wallet, 2025, time.December, time.UTC); st.OpeningBalance != 70 || st.ClosingBalance != 70 {
+		t.Fatalf("next month should open with the closing balance: opening=%v closing=%v", st.OpeningBalance, st.ClosingBalance)
+	}
+}
+
+// ---------- Analytics: TimeSeries ----------
+func TestAnalytics_TimeSeries(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	bankRepo := bankaccountrepo.NewBankAccountRepo()
+	acc, _ := bankaccount.NewBankAccount("Main", 1000)
+	otherAcc, _ := bankaccount.NewBankAccount("Other", 0)
+	_ = bankRepo.Save(ctx, acc)
+	_ = bankRepo.Save(ctx, otherAcc)
+	accID := acc.ID()
+	catID := service.ObjectID(uuid.New())
+	msk := time.FixedZone("MSK", 3*60*60)
+	// before the period: counts towards the opening balance only
+	early, _ := operation.NewOperation(operation.Income, accID, 200, time.Date(2025, 10, 20, 10, 0, 0, 0, time.UTC), catID)
+	// 2025-11-02 22:30 UTC is already Monday 2025-11-03 in MSK
+	o1, _ := operation.NewOperation(operation.Income, accID, 100, time.Date(2025, 11, 2, 22, 30, 0, 0, time.UTC), catID)
+	o2, _ := operation.NewOperation(operation.Spending, accID, 30, time.Date(2025, 11, 5, 10, 0, 0, 0, time.UTC), catID)
+	o3, _ := operation.NewOperation(operation.Spending, accID, 20, time.Date(2025, 11, 19, 10, 0, 0, 0, time.UTC), catID)
+	other, _ := operation.NewOperation(operation.Income, otherAcc.ID(), 500, time.Date(2025, 11, 5, 10, 0, 0, 0, time.UTC), catID)
+	for _, o := range []*operation.Operation{early, o1, o2, o3, other} {
+		_ = opRepo.Save(ctx, o)
+	}
+
+	analytics := facade.NewAnalyticsFacade(opRepo)
+	analytics.SetAccountRepo(bankRepo)
+	from := time.Date(2025, 11, 3, 0, 0, 0, 0, msk)
+	to := time.Date(2025, 11, 23, 23, 59, 59, 0, msk)
+	points, err := analytics.TimeSeries(ctx, &accID, from, to, operationrepo.Week, msk)
+	if err != nil {
+		t.Fatalf("time series error: %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("expected 3 weekly buckets, got %d", len(points))
+	}
+	if points[0].Income != 100 || points[0].Expense != 30 || points[0].Net != 70 {
+		t.Fatalf("unexpected first bucket: %+v", points[0])
+	}
+	if points[1].Net != 0 || points[1].CumulativeNet != 70 {
+		t.Fatalf("expected empty second bucket carrying the cumulative net, got %+v", points[1])
+	}
+	if points[2].CumulativeNet != 50 || !points[2].Start.Equal(time.Date(2025, 11, 17, 0, 0, 0, 0, msk)) {
+		t.Fatalf("unexpected last bucket: %+v", points[2])
+	}
+	// остаток: 1000 на счёте, +200 до периода, затем накопленный Net
+	if points[0].Balance != 1270 || points[1].Balance != 1270 || points[2].Balance != 1250 {
+		t.Fatalf("expected running balance 1270/1270/1250, got %+v", points)
+	}
+
+	all, err := analytics.TimeSeries(ctx, nil, from, to, operationrepo.Month, msk)
+	if err != nil {
+		t.Fatalf("time series error: %v", err)
+	}
+	if len(all) != 1 || all[0].Income != 600 || all[0].Balance != 1750 {
+		t.Fatalf("unexpected all-accounts series: %+v", all)
+	}
+}
+
+// ---------- Cash-flow forecast ----------
+func TestForecast_RecurringAndNegativeBalance(t *testing.T) {
+	accID := service.ObjectID(uuid.New())
+	rent := service.ObjectID(uuid.New())
+	food := service.ObjectID(uuid.New())
+	now := time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+	from := now.AddDate(0, 0, -90)
+
+	var history []operation.IOperation
+	for _, day := range []time.Time{
+		time.Date(2025, 9, 5, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 10, 5, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 11, 5, 10, 0, 0, 0, time.UTC),
+	} {
+		op, _ := operation.NewOperation(operation.Spending, accID, 500, day, rent, "Rent")
+		history = append(history, op)
+	}
+	// irregular spending: 9 per day on average
+	for i, d := 0, 0; d < 90; i, d = i+1, d+10 {
+		amount := 40.0
+		if i%2 == 1 {
+			amount = 140
+		}
+		if i == 8 {
+			amount = 90
+		}
+		op, _ := operation.NewOperation(operation.Spending, accID, amount, from.AddDate(0, 0, d).Add(time.Hour), food, "shop")
+		history = append(history, op)
+	}
+
+	fc := forecast.Project(accID, 700, history, from, now, 30)
+	if len(fc.Recurring) != 1 || fc.Recurring[0].Amount != 500 || !fc.Recurring[0].Monthly {
+		t.Fatalf("expected monthly rent to be detected, got %+v", fc.Recurring)
+	}
+	if len(fc.Points) != 30 {
+		t.Fatalf("expected 30 points, got %d", len(fc.Points))
+	}
+	if fc.DailyNet != -9 {
+		t.Fatalf("expected -9 average daily net, got %v", fc.DailyNet)
+	}
+	if fc.FirstNegative == nil || fc.FirstNegative.Month() != time.December {
+		t.Fatalf("expected negative balance in December, got %v", fc.FirstNegative)
+	}
+	last := fc.Points[len(fc.Points)-1]
+	if !(last.Lower <= last.Expected && last.Expected <= last.Upper) {
+		t.Fatalf("expected value outside of confidence band: %+v", last)
+	}
+
+	// ровно horizon точек; платёж в последний день горизонта попадает в последнюю точку
+	due := time.Date(2025, 11, 5, 10, 0, 0, 0, time.UTC)
+	for _, h := range []int{1, 7, 30} {
+		if p := forecast.Project(accID, 700, history[:3], due.AddDate(0, 0, -90), due, h).Points; len(p) != h {
+			t.Fatalf("horizon %d: expected %d points, got %d", h, h, len(p))
+		}
+	}
+	edge := forecast.Project(accID, 700, history[:3], due.AddDate(0, 0, -90), due, 30)
+	if d := edge.Points[29].Expected - edge.Points[28].Expected; d != -500 {
+		t.Fatalf("expected rent due on the last day in the last point, got change %.2f", d)
+	}
+
+	// нерегулярные траты считаются по категориям, прогноз складывает средние
+	gift, _ := operation.NewOperation(operation.Income, accID, 90, from.AddDate(0, 0, 40), service.ObjectID(uuid.New()), "gift")
+	split := forecast.Project(accID, 700, append(history, gift), from, now, 30)
+	if len(split.Categories) != 2 || split.Categories[0].CategoryID != food || split.Categories[0].DailyNet != -9 || split.DailyNet != -8 {
+		t.Fatalf("expected food -9 and gift +1 per day, got %v total %+v", split.DailyNet, split.Categories)
+	}
+
+	// платёж в последний день месяца не сползает: 31 марта -> 30 апреля -> 31 мая
+	var monthEnd []operation.IOperation
+	for _, day := range []time.Time{
+		time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 28, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 31, 10, 0, 0, 0, time.UTC),
+	} {
+		op, _ := operation.NewOperation(operation.Spending, accID, 500, day, rent, "Rent")
+		monthEnd = append(monthEnd, op)
+	}
+	april := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	me := forecast.Project(accID, 2000, monthEnd, april.AddDate(0, 0, -90), april, 62)
+	if len(me.Recurring) != 1 || me.Recurring[0].DayOfMonth != 31 {
+		t.Fatalf("expected a monthly payment on the 31st, got %+v", me.Recurring)
+	}
+	for _, idx := range []int{29, 60} {
+		if d := me.Points[idx].Expected - me.Points[idx-1].Expected; d != -500 {
+			t.Fatalf("expected rent on %s, got change %.2f", me.Points[idx].Date.Format("2006-01-02"), d)
+		}
+	}
+	if next := me.Recurring[0].Next(time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC)); next.Month() != time.February || next.Day() != 28 {
+		t.Fatalf("expected Jan 31 -> Feb 28, got %v", next)
+	}
+
+	raw, err := forecast.FormatJSON(fc)
+	if err != nil || !strings.Contains(string(raw), "\"series\"") {
+		t.Fatalf("unexpected json: %v %s", err, raw)
+	}
+}
+
+// ---------- Anomaly detection ----------
+type recordingNotifier struct{ got []anomaly.Anomaly }
+
+func (n *recordingNotifier) Notify(a anomaly.Anomaly) error {
+	n.got = append(n.got, a)
+	return nil
+}
+
+func TestAnomalyFacade_Scan(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	opF := facade.NewOperationFacade(opRepo)
+	accID := service.ObjectID(uuid.New())
+	food := service.ObjectID(uuid.New())
+	travel := service.ObjectID(uuid.New())
+	base := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, amount := range []float64{20, 25, 22, 30, 18, 24, 21} {
+		if _, err := opF.CreateOperation(ctx, operation.Spending, accID, amount, base.AddDate(0, 0, i*3), food, "shop"); err != nil {
+			t.Fatalf("create op: %v", err)
+		}
+	}
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	huge, _ := opF.CreateOperation(ctx, operation.Spending, accID, 400, from.Add(24*time.Hour), food, "shop")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, accID, 22, from.Add(48*time.Hour), food, "Shop")
+	dup, _ := opF.CreateOperation(ctx, operation.Spending, accID, 22, from.Add(50*time.Hour), food, "shop ")
+	trip, _ := opF.CreateOperation(ctx, operation.Spending, accID, 23, from.Add(72*time.Hour), travel, "train")
+
+	n := &recordingNotifier{}
+	af := facade.NewAnomalyFacade(opF, n)
+	found, err := af.Scan(ctx, accID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+	kinds := make(map[service.ObjectID]anomaly.Kind)
+	for _, a := range found {
+		kinds[a.OperationID] = a.Kind
+	}
+	if kinds[huge] != anomaly.Outlier || kinds[dup] != anomaly.Duplicate || kinds[trip] != anomaly.NewCategory {
+		t.Fatalf("unexpected anomalies: %+v", found)
+	}
+	if len(found) != 3 || len(n.got) != 3 {
+		t.Fatalf("expected 3 anomalies notified, got %d/%d: %+v", len(found), len(n.got), found)
+	}
+
+	// поздние крупные траты не прячут выброс задним числом
+	for i := 0; i < 12; i++ {
+		_, _ = opF.CreateOperation(ctx, operation.Spending, accID, 400+float64(i), from.AddDate(0, 2, i), food, "new flat")
+	}
+	found, _ = af.Scan(ctx, accID, from, from.AddDate(0, 1, 0))
+	outlier := false
+	for _, a := range found {
+		if a.OperationID == huge && a.Kind == anomaly.Outlier {
+			outlier = true
+		}
+	}
+	if !outlier {
+		t.Fatalf("later operations changed the baseline of an earlier one: %+v", found)
+	}
+
+	// повтор списания, сделанного за час до начала периода, тоже дубликат
+	other := service.ObjectID(uuid.New())
+	_, _ = opF.CreateOperation(ctx, operation.Spending, other, 15, from.Add(-time.Hour), travel, "taxi")
+	again, _ := opF.CreateOperation(ctx, operation.Spending, other, 15, from.Add(time.Hour), travel, "Taxi")
+	found, err = af.Scan(ctx, other, from, from.AddDate(0, 1, 0))
+	if err != nil || len(found) != 1 || found[0].OperationID != again || found[0].Kind != anomaly.Duplicate {
+		t.Fatalf("expected a duplicate across the period start, got %+v (%v)", found, err)
+	}
+}
+
+// ---------- Rule-based categorization ----------
+func TestRules_Categorization(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	rules := rulerepo.NewRuleRepo()
+	ruleF := facade.NewRuleFacade(rules, opRepo)
+	opF := facade.NewOperationFacade(opRepo)
+	opF.SetCategorizer(ruleF.Categorizer())
+
+	accID := service.ObjectID(uuid.New())
+	subs := service.ObjectID(uuid.New())
+	food := service.ObjectID(uuid.New())
+	big := service.ObjectID(uuid.New())
+	spending := operation.Spending
+	limit := 1000.0
+	if _, err := ruleF.CreateRule(ctx, "netflix", 1, rule.Conditions{DescriptionRegex: `netflix|spotify`, OpType: &spending}, subs); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if _, err := ruleF.CreateRule(ctx, "shop", 5, rule.Conditions{DescriptionContains: "shop"}, food); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if _, err := ruleF.CreateRule(ctx, "big shop", 2, rule.Conditions{DescriptionContains: "shop", MinAmount: &limit}, big); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if _, err := ruleF.CreateRule(ctx, "bad", 1, rule.Conditions{DescriptionRegex: "("}, food); err == nil {
+		t.Fatalf("expected invalid regex error")
+	}
+
+	now := time.Now()
+	id1, err := opF.CreateOperation(ctx, operation.Spending, accID, 9.99, now, service.ObjectID{}, "NETFLIX.COM")
+	if err != nil {
+		t.Fatalf("create op: %v", err)
+	}
+	id2, _ := opF.CreateOperation(ctx, operation.Spending, accID, 1500, now, service.ObjectID{}, "Big Shop")
+	id3, _ := opF.CreateOperation(ctx, operation.Spending, accID, 15, now, service.ObjectID{}, "corner shop")
+	if _, err := opF.CreateOperation(ctx, operation.Spending, accID, 15, now, service.ObjectID{}, "unknown"); err == nil {
+		t.Fatalf("expected error when no rule matches")
+	}
+	for id, want := range map[service.ObjectID]service.ObjectID{id1: subs, id2: big, id3: food} {
+		op, _ := opF.GetOperation(ctx, id)
+		if op.CategoryID() != want {
+			t.Fatalf("operation %q got category %s, want %s", op.Description(), op.CategoryID(), want)
+		}
+	}
+
+	// import without category_id
+	path := t.TempDir() + "/ops.csv"
+	csvData := "id,type,bank_account_id,amount,date,description,category_id\n" +
+		uuid.NewString() + ",0," + accID.String() + ",4.99," + now.Format(time.RFC3339) + ",Spotify,\n" +
+		uuid.NewString() + ",0," + accID.String() + ",3," + now.Format(time.RFC3339) + ",unknown,\n"
+	_ = os.WriteFile(path, []byte(csvData), 0644)
+	imp := csvimporter.NewCSVOperationImporter(path)
+	imp.AddHook(ruleF.Categorizer())
+	if err := imp.Read(ctx); err == nil || !strings.Contains(err.Error(), "no categorization rule matched") {
+		t.Fatalf("expected the row without a matching rule to be rejected, got %v", err)
+	}
+	imported, _ := imp.Data().All(ctx)
+	if len(imported) != 1 || imported[0].(operation.IOperation).CategoryID() != subs {
+		t.Fatalf("imported operation was not categorized")
+	}
+
+	// re-run over history after adding a higher-priority rule
+	if _, err := ruleF.CreateRule(ctx, "corner", 0, rule.Conditions{DescriptionContains: "corner"}, subs); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	changed, err := ruleF.Recategorize(ctx, false)
+	if err != nil || changed != 1 {
+		t.Fatalf("expected 1 recategorized operation, got %d (%v)", changed, err)
+	}
+	op3, _ := opF.GetOperation(ctx, id3)
+	if op3.CategoryID() != subs {
+		t.Fatalf("recategorize did not update operation")
+	}
+
+	// сбой на одной операции откатывает весь прогон; пропускаются только закрытые операции
+	misc := service.ObjectID(uuid.New())
+	if _, err := ruleF.CreateRule(ctx, "all shops", -1, rule.Conditions{DescriptionContains: "shop"}, misc); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	for _, repo := range []repository.ICommonRepo{
+		&lockedOps{ICommonRepo: opRepo, locked: id2, err: errors.New("storage unavailable")},
+		failingUpdate{opRepo, id2},
+	} {
+		if _, err := facade.NewRuleFacade(rules, repo).Recategorize(ctx, false); err == nil {
+			t.Fatalf("expected recategorize to fail")
+		}
+		if op, _ := opF.GetOperation(ctx, id3); op.CategoryID() != subs {
+			t.Fatalf("failed recategorize kept a change: %s", op.CategoryID())
+		}
+	}
+	changed, err = facade.NewRuleFacade(rules, &lockedOps{ICommonRepo: opRepo, locked: id2}).Recategorize(ctx, false)
+	if err != nil || changed != 1 {
+		t.Fatalf("expected the closed operation to be skipped, got %d (%v)", changed, err)
+	}
+	if op2, _ := opF.GetOperation(ctx, id2); op2.CategoryID() != big {
+		t.Fatalf("closed operation was recategorized")
+	}
+}
+
+// ---------- Learned category suggestions ----------
+func TestSuggestionFacade_TrainAndPersist(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	catF := facade.NewCategoryFacade(catRepo)
+	accID := service.ObjectID(uuid.New())
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	transport, _ := catF.CreateCategory(ctx, "Transport", category.Spending)
+	now := time.Now()
+	for _, d := range []string{"Pyaterochka groceries", "Lenta groceries", "Pyaterochka store", "bakery"} {
+		op, _ := operation.NewOperation(operation.Spending, accID, 10, now, food, d)
+		_ = opRepo.Save(ctx, op)
+	}
+	for _, d := range []string{"Metro ticket", "Taxi ride", "metro card top-up"} {
+		op, _ := operation.NewOperation(operation.Spending, accID, 10, now, transport, d)
+		_ = opRepo.Save(ctx, op)
+	}
+	unlabelled, _ := operation.NewOperation(operation.Spending, accID, 10, now, service.ObjectID{}, "metro")
+	_ = opRepo.Save(ctx, unlabelled)
+	// операция общего счёта с категорией его владельца: пользователь её категорию не видит
+	foreign := service.ObjectID(uuid.New())
+	for _, d := range []string{"Metro salary", "metro bonus", "metro"} {
+		op, _ := operation.NewOperation(operation.Spending, accID, 10, now, foreign, d)
+		_ = opRepo.Save(ctx, op)
+	}
+
+	modelPath := t.TempDir() + "/model.json"
+	sf := facade.NewSuggestionFacade(opRepo, catRepo, modelPath)
+	if _, err := sf.Suggest(ctx, "metro", operation.Spending, 1); err == nil {
+		t.Fatalf("expected error before training")
+	}
+	n, err := sf.Retrain(ctx)
+	if err != nil || n != 7 {
+		t.Fatalf("expected training on 7 labelled operations, got %d (%v)", n, err)
+	}
+
+	// fresh facade reads the persisted model
+	loaded := facade.NewSuggestionFacade(opRepo, catRepo, modelPath)
+	got, err := loaded.Suggest(ctx, "METRO ticket", operation.Spending, 2)
+	if err != nil {
+		t.Fatalf("suggest error: %v", err)
+	}
+	if len(got) != 2 || got[0].CategoryID != transport || got[0].Confidence <= 0.5 {
+		t.Fatalf("unexpected suggestions: %+v", got)
+	}
+	for _, s := range got {
+		if s.CategoryID == foreign {
+			t.Fatalf("suggested a category the user cannot see: %+v", got)
+		}
+	}
+	got, _ = loaded.Suggest(ctx, "pyaterochka", operation.Spending, 1)
+	if len(got) != 1 || got[0].CategoryID != food {
+		t.Fatalf("unexpected suggestions: %+v", got)
+	}
+}
+
+// ---------- Hierarchical categories ----------
+func TestCategoryHierarchy_RollupAndDelete(t *testing.T) {
+	ctx := context.Background()
+	catRepo := categoryrepo.NewCategoryRepo()
+	catF := facade.NewCategoryFacade(catRepo)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	groceries, _ := catF.CreateCategory(ctx, "Groceries", category.Spending, food)
+	restaurants, _ := catF.CreateCategory(ctx, "Restaurants", category.Spending, food)
+	coffee, err := catF.CreateCategory(ctx, "Coffee", category.Spending, restaurants)
+	if err != nil {
+		t.Fatalf("create nested category: %v", err)
+	}
+	salary, _ := catF.CreateCategory(ctx, "Salary", category.Income)
+	if _, err := catF.CreateCategory(ctx, "Bonus", category.Spending, salary); err == nil {
+		t.Fatalf("expected error for parent of different type")
+	}
+	if err := catF.SetCategoryParent(ctx, food, coffee); err == nil {
+		t.Fatalf("expected cycle to be rejected")
+	}
+
+	opRepo := operationrepo.NewOperationRepo()
+	accID := service.ObjectID(uuid.New())
+	now := time.Now()
+	for catID, amount := range map[service.ObjectID]float64{groceries: 100, restaurants: 40, coffee: 5} {
+		op, _ := operation.NewOperation(operation.Spending, accID, amount, now.Add(-time.Minute), catID)
+		_ = opRepo.Save(ctx, op)
+	}
+	tree, _ := catF.Tree(ctx)
+	analytics := facade.NewAnalyticsFacade(opRepo)
+	top, err := analytics.GroupByCategoryRollup(ctx, accID, now.Add(-time.Hour), now, tree, 0)
+	if err != nil {
+		t.Fatalf("rollup error: %v", err)
+	}
+	if len(top) != 1 || top[food] != 145 {
+		t.Fatalf("unexpected level-0 rollup: %v", top)
+	}
+	second, _ := analytics.GroupByCategoryRollup(ctx, accID, now.Add(-time.Hour), now, tree, 1)
+	if second[groceries] != 100 || second[restaurants] != 45 {
+		t.Fatalf("unexpected level-1 rollup: %v", second)
+	}
+
+	if err := catF.DeleteCategory(ctx, restaurants); err == nil {
+		t.Fatalf("expected delete of a parent category to be rejected")
+	}
+	// сбой архивирования откатывает уже перенесённых детей
+	broken := facade.NewCategoryFacade(failingUpdate{catRepo, restaurants})
+	if err := broken.DeleteCategoryReparent(ctx, restaurants); err == nil {
+		t.Fatalf("expected reparent delete to fail")
+	}
+	if c, _ := catF.GetCategory(ctx, coffee); c.ParentID() != restaurants {
+		t.Fatalf("failed reparent delete moved coffee to %v", c.ParentID())
+	}
+	if err := catF.DeleteCategoryReparent(ctx, restaurants); err != nil {
+		t.Fatalf("reparent delete error: %v", err)
+	}
+	c, _ := catF.GetCategory(ctx, coffee)
+	if c.ParentID() != food {
+		t.Fatalf("expected coffee to move under food")
+	}
+
+	// parent_id survives a CSV roundtrip
+	data, _ := catRepo.All(ctx)
+	path := t.TempDir() + "/cats.csv"
+	if err := csvexporter.NewCSVCategoryExporter(path).Export(ctx, data); err != nil {
+		t.Fatalf("csv export err: %v", err)
+	}
+	imp := csvimporter.NewCSVCategoryImporter(path)
+	if err := imp.Read(ctx); err != nil {
+		t.Fatalf("csv import err: %v", err)
+	}
+	obj, err := imp.Data().ByID(ctx, coffee)
+	if err != nil || obj.(category.ICategory).ParentID() != food {
+		t.Fatalf("parent_id lost on import: %v", err)
+	}
+
+	// импорт проверяет родителя так же, как CreateCategory
+	target := facade.NewCategoryFacade(categoryrepo.NewCategoryRepo())
+	objs, _ := imp.Data().All(ctx)
+	category.SortParentsFirst(objs)
+	for _, o := range objs {
+		if err := target.ImportCategory(ctx, o); err != nil {
+			t.Fatalf("import %q: %v", o.(category.ICategory).Name(), err)
+		}
+	}
+	wrongType, _ := category.NewCategory("Bonus", category.Income, food)
+	if err := target.ImportCategory(ctx, wrongType); !errors.Is(err, service.ErrInvariant) {
+		t.Fatalf("expected parent of another type to be rejected, got %v", err)
+	}
+	orphan, _ := category.NewCategory("Orphan", category.Spending, service.ObjectID(uuid.New()))
+	if err := target.ImportCategory(ctx, orphan); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected missing parent to be rejected, got %v", err)
+	}
+}
+
+func TestOperationTags_FilterAnalyticsAndRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	opF := facade.NewOperationFacade(opRepo)
+	acc1, acc2 := service.ObjectID(uuid.New()), service.ObjectID(uuid.New())
+	catID := service.ObjectID(uuid.New())
+	now := time.Now()
+
+	taxi, err := opF.CreateTaggedOperation(ctx, operation.Spending, acc1, 30, now.Add(-time.Hour), catID, "taxi", []string{"#Reimbursable", "trip"})
+	if err != nil {
+		t.Fatalf("create tagged operation: %v", err)
+	}
+	hotel, _ := opF.CreateTaggedOperation(ctx, operation.Spending, acc2, 120, now.Add(-2*time.Hour), catID, "hotel", []string{"reimbursable"})
+	if _, err := opF.CreateOperation(ctx, operation.Spending, acc1, 15, now.Add(-time.Hour), catID, "lunch"); err != nil {
+		t.Fatalf("create operation: %v", err)
+	}
+	if _, err := opF.CreateTaggedOperation(ctx, operation.Spending, acc1, 1, now, catID, "bad", []string{"two words"}); err == nil {
+		t.Fatalf("expected invalid tag to be rejected")
+	}
+
+	tagged, err := opF.ListOperationsByTags(ctx, "reimbursable")
+	if err != nil || len(tagged) != 2 {
+		t.Fatalf("expected 2 reimbursable operations, got %d (%v)", len(tagged), err)
+	}
+	both, _ := opF.ListOperationsByTags(ctx, "reimbursable", "#TRIP")
+	if len(both) != 1 || both[0].ID() != taxi {
+		t.Fatalf("expected only taxi to have both tags, got %v", both)
+	}
+
+	analytics := facade.NewAnalyticsFacade(opRepo)
+	_, exp, _, err := analytics.IncomeExpenseDeltaByTags(ctx, nil, now.Add(-24*time.Hour), now, "reimbursable")
+	if err != nil || exp != 150 {
+		t.Fatalf("expected reimbursable spending 150, got %.2f (%v)", exp, err)
+	}
+	_, exp, _, _ = analytics.IncomeExpenseDeltaByTags(ctx, &acc1, now.Add(-24*time.Hour), now, "reimbursable")
+	if exp != 30 {
+		t.Fatalf("expected reimbursable spending 30 on account 1, got %.2f", exp)
+	}
+
+	if err := opF.UntagOperation(ctx, hotel, "reimbursable"); err != nil {
+		t.Fatalf("untag: %v", err)
+	}
+	if tagged, _ := opF.ListOperationsByTags(ctx, "reimbursable"); len(tagged) != 1 {
+		t.Fatalf("expected 1 reimbursable operation after untag, got %d", len(tagged))
+	}
+
+	path := t.TempDir() + "/ops.csv"
+	data, _ := opRepo.All(ctx)
+	if err := csvexporter.NewCSVOperationExporter(path).Export(ctx, data); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	imp := csvimporter.NewCSVOperationImporter(path)
+	if err := imp.Read(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	obj, err := imp.Data().ByID(ctx, taxi)
+	if err != nil {
+		t.Fatalf("imported taxi not found: %v", err)
+	}
+	if got := obj.(operation.IOperation).Tags(); len(got) != 2 || got[0] != "reimbursable" || got[1] != "trip" {
+		t.Fatalf("tags lost in csv round trip: %v", got)
+	}
+}
+
+func TestOperationSplits_AnalyticsAndRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	opF := facade.NewOperationFacade(opRepo)
+	accID := service.ObjectID(uuid.New())
+	groceries, household, salary := service.ObjectID(uuid.New()), service.ObjectID(uuid.New()), service.ObjectID(uuid.New())
+	now := time.Now()
+
+	receipt, _ := opF.CreateOperation(ctx, operation.Spending, accID, 100, now.Add(-time.Hour), groceries, "supermarket")
+	if _, err := opF.CreateOperation(ctx, operation.Income, accID, 500, now.Add(-time.Hour), salary, "salary"); err != nil {
+		t.Fatalf("create operation: %v", err)
+	}
+	bad := []operation.SplitLine{{CategoryID: groceries, Amount: 70}, {CategoryID: household, Amount: 20}}
+	if err := opF.SplitOperation(ctx, receipt, bad); err == nil {
+		t.Fatalf("expected split lines not adding up to the total to be rejected")
+	}
+	lines := []operation.SplitLine{{CategoryID: groceries, Amount: 70.5}, {CategoryID: household, Amount: 29.5, Note: "detergent"}}
+	if err := opF.SplitOperation(ctx, receipt, lines); err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	analytics := facade.NewAnalyticsFacade(opRepo)
+	byCat, err := analytics.GroupByCategory(ctx, accID, now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("group by category: %v", err)
+	}
+	if byCat[groceries] != 70.5 || byCat[household] != 29.5 || byCat[salary] != 500 {
+		t.Fatalf("split lines not counted per category: %v", byCat)
+	}
+	types := map[service.ObjectID]category.CategoryType{groceries: category.Spending, salary: category.Income}
+	byType, _ := analytics.SplitByCategoryType(ctx, accID, now.Add(-24*time.Hour), now, types)
+	if byType[category.Spending] != 70.5 || byType[category.Income] != 500 {
+		t.Fatalf("unexpected split by category type: %v", byType)
+	}
+
+	data, _ := opRepo.All(ctx)
+	dir := t.TempDir()
+	if err := csvexporter.NewCSVOperationExporter(dir+"/ops.csv").Export(ctx, data); err != nil {
+		t.Fatalf("csv export: %v", err)
+	}
+	if err := jsonexporter.NewJSONOperationExporter(dir+"/ops.json").Export(ctx, data); err != nil {
+		t.Fatalf("json export: %v", err)
+	}
+	for _, imp := range []interface {
+		Read(ctx context.Context) error
+		Data() repository.ICommonRepo
+	}{csvimporter.NewCSVOperationImporter(dir + "/ops.csv"), jsonimporter.NewJSONOperationImporter(dir + "/ops.json")} {
+		if err := imp.Read(ctx); err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		obj, err := imp.Data().ByID(ctx, receipt)
+		if err != nil {
+			t.Fatalf("imported receipt not found: %v", err)
+		}
+		got := obj.(operation.IOperation).Splits()
+		if len(got) != 2 || got[1].CategoryID != household || got[1].Amount != 29.5 || got[1].Note != "detergent" {
+			t.Fatalf("splits lost in round trip: %+v", got)
+		}
+	}
+}
+
+func TestOperationQuery_FiltersSortingAndPages(t *testing.T) {
+	ctx := context.Background()
+	opRepo := operationrepo.NewOperationRepo()
+	opF := facade.NewOperationFacade(opRepo)
+	acc1, acc2 := service.ObjectID(uuid.New()), service.ObjectID(uuid.New())
+	food, fun := service.ObjectID(uuid.New()), service.ObjectID(uuid.New())
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		if _, err := opF.CreateOperation(ctx, operation.Spending, acc1, float64(10*(i+1)), base.AddDate(0, 0, i), food, fmt.Sprintf("Grocery store #%d", i)); err != nil {
+			t.Fatalf("create operation: %v", err)
+		}
+	}
+	_, _ = opF.CreateOperation(ctx, operation.Spending, acc2, 35, base, food, "grocery on another account")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, acc1, 45, base, fun, "cinema")
+	_, _ = opF.CreateOperation(ctx, operation.Income, acc1, 1000, base, food, "grocery refund")
+
+	spending := operation.Spending
+	minAmount := 20.0
+	q := operationrepo.Query{
+		AccountIDs:  []service.ObjectID{acc1},
+		CategoryIDs: []service.ObjectID{food},
+		Type:        &spending,
+		MinAmount:   &minAmount,
+		To:          base.AddDate(0, 0, 5),
+		Text:        "GROCERY",
+		Sort:        operationrepo.SortByAmount,
+		Desc:        true,
+		Limit:       2,
+	}
+	var amounts []float64
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("pagination does not terminate")
+		}
+		ops, next, err := opF.QueryOperations(ctx, q)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		for _, o := range ops {
+			amounts = append(amounts, o.Amount())
+		}
+		if next == "" {
+			break
+		}
+		q.After = next
+	}
+	want := []float64{60, 50, 40, 30, 20}
+	if fmt.Sprint(amounts) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, amounts)
+	}
+
+	q.After = ""
+	first, next, _ := opF.QueryOperations(ctx, q)
+	q.Desc = false
+	q.After = next
+	if _, _, err := opF.QueryOperations(ctx, q); err == nil || len(first) != 2 {
+		t.Fatalf("expected cursor from a different sort order to be rejected")
+	}
+
+	// Normalize не меняет срез меток вызывающего
+	tags := []string{"#Trip", "Work"}
+	tagged := operationrepo.Query{Tags: tags}
+	if _, err := tagged.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if tags[0] != "#Trip" || tags[1] != "Work" || tagged.Tags[0] != "trip" {
+		t.Fatalf("caller tags changed to %v, normalized %v", tags, tagged.Tags)
+	}
+	// повтор метки после нормализации отбрасывается: в Postgres COUNT(DISTINCT tag) иначе не сойдётся
+	repeated := operationrepo.Query{Tags: []string{"#Trip", "trip", "work"}}
+	if _, err := repeated.Normalize(); err != nil || len(repeated.Tags) != 2 || repeated.Tags[0] != "trip" || repeated.Tags[1] != "work" {
+		t.Fatalf("repeated tag not deduplicated: %v (%v)", repeated.Tags, err)
+	}
+}
+
+func TestSearchFacade_RankedWithHighlights(t *testing.T) {
+	ctx := context.Background()
+	bankRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	card, _ := facade.NewBankAccountFacade(bankRepo).CreateAccount(ctx, "Tinkoff card", 0)
+	catF := facade.NewCategoryFacade(catRepo)
+	subs, _ := catF.CreateCategory(ctx, "Subscriptions", category.Spending)
+	food, _ := catF.CreateCategory(ctx, "Еда", category.Spending)
+	opF := facade.NewOperationFacade(opRepo)
+	now := time.Now()
+	netflix, _ := opF.CreateOperation(ctx, operation.Spending, card, 9.99, now.AddDate(0, -6, 0), subs, "NETFLIX.COM monthly, netflix premium")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, card, 12, now.AddDate(0, -1, 0), subs, "Spotify")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, card, 30, now, food, "Пятёрочка, продукты")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, card, 5, now, food, "Netflix gift card for a friend")
+
+	sf := facade.NewSearchFacade(bankRepo, catRepo, opRepo)
+	hits, err := sf.Search(ctx, "netfl", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Operation.ID() != netflix {
+		t.Fatalf("expected the subscription to rank first among 2 hits, got %+v", hits)
+	}
+	if hits[0].Description != "**NETFLIX**.COM monthly, **netflix** premium" {
+		t.Fatalf("unexpected highlight: %q", hits[0].Description)
+	}
+
+	// слова запроса могут совпасть в разных полях: описание и название категории
+	hits, _ = sf.Search(ctx, "spotify subscr", 10)
+	if len(hits) != 1 || hits[0].Category != "**Subscriptions**" {
+		t.Fatalf("expected a match across description and category, got %+v", hits)
+	}
+	hits, _ = sf.Search(ctx, "ПРОДУКТ еда", 10)
+	if len(hits) != 1 || hits[0].Description != "Пятёрочка, **продукты**" {
+		t.Fatalf("expected a cyrillic match, got %+v", hits)
+	}
+	if hits, _ = sf.Search(ctx, "netflix tinkoff", 10); len(hits) != 2 {
+		t.Fatalf("expected account name to be searchable, got %d hits", len(hits))
+	}
+	if hits, _ = sf.Search(ctx, "hulu", 10); len(hits) != 0 {
+		t.Fatalf("expected no hits, got %d", len(hits))
+	}
+
+	// индекс хранится между запросами и догоняет правки, новые и удалённые операции
+	_ = catF.UpdateCategoryName(ctx, subs, "Streaming")
+	_ = opF.DeleteOperation(ctx, netflix)
+	_, _ = opF.CreateOperation(ctx, operation.Spending, card, 7, now, subs, "Hulu")
+	if hits, _ = sf.Search(ctx, "netflix", 10); len(hits) != 1 {
+		t.Fatalf("expected the deleted operation to leave the index, got %d hits", len(hits))
+	}
+	if hits, _ = sf.Search(ctx, "hulu stream", 10); len(hits) != 1 {
+		t.Fatalf("expected the new operation under the renamed category, got %d hits", len(hits))
+	}
+	if hits, _ = sf.Search(ctx, "subscr", 10); len(hits) != 0 {
+		t.Fatalf("expected the old category name to be gone, got %d hits", len(hits))
+	}
+}
+
+type fakeChangeFeed struct {
+	mu      sync.Mutex
+	pending []repository.Change
+}
+
+func (f *fakeChangeFeed) push(ch repository.Change) {
+	f.mu.Lock()
+	f.pending = append(f.pending, ch)
+	f.mu.Unlock()
+}
+
+func (f *fakeChangeFeed) Poll(ctx context.Context) ([]repository.Change, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := f.pending
+	f.pending = nil
+	return out, nil
+}
+
+func TestCachedRepo_CoherenceTTLAndLRU(t *testing.T) {
+	ctx := context.Background()
+	db := operationrepo.NewOperationRepo()
+	accID, catID := service.ObjectID(uuid.New()), service.ObjectID(uuid.New())
+	now := time.Now()
+	first, _ := operation.NewOperation(operation.Spending, accID, 10, now.Add(-time.Hour), catID, "first")
+	_ = db.Save(ctx, first)
+
+	cached, err := proxyrepo.NewCachedOperationRepo(ctx, db)
+	if err != nil {
+		t.Fatalf("cache init: %v", err)
+	}
+	slice, _ := cached.SliceByAccountAndPeriod(ctx, accID, now.Add(-24*time.Hour), now)
+	if len(slice) != 1 {
+		t.Fatalf("expected 1 operation in slice, got %d", len(slice))
+	}
+
+	// другой процесс пишет в хранилище в обход кэша
+	second, _ := operation.NewOperation(operation.Spending, accID, 20, now.Add(-time.Minute), catID, "second")
+	_ = db.Save(ctx, second)
+	if all, _ := cached.All(ctx); len(all) != 1 {
+		t.Fatalf("expected stale cache before invalidation, got %d rows", len(all))
+	}
+	feed := &fakeChangeFeed{}
+	watchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go proxyrepo.Watch(watchCtx, feed, 5*time.Millisecond, map[string]proxyrepo.Invalidator{"operations": cached}, nil)
+	feed.push(repository.Change{Table: "operations", ID: second.ID(), Op: "I"})
+	deadline := time.Now().Add(time.Second)
+	for {
+		slice, _ = cached.SliceByAccountAndPeriod(ctx, accID, now.Add(-24*time.Hour), now)
+		if len(slice) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("change feed did not invalidate the period slice")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if all, _ := cached.All(ctx); len(all) != 2 {
+		t.Fatalf("expected All to reload after invalidation, got %d rows", len(all))
+	}
+
+	// TTL: прямое удаление из хранилища становится видно после истечения срока
+	ttlCache, _ := proxyrepo.NewCachedRepo(ctx, db, proxyrepo.Options{TTL: 20 * time.Millisecond})
+	_ = db.Delete(ctx, first.ID())
+	if _, err := ttlCache.ByID(ctx, first.ID()); err != nil {
+		t.Fatalf("expected cached row before TTL expiry: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := ttlCache.ByID(ctx, first.ID()); err == nil {
+		t.Fatalf("expected expired row to be re-read and found missing")
+	}
+
+	// LRU: в кэше не больше MaxSize строк, но All по-прежнему отдаёт всю таблицу
+	for i := 0; i < 3; i++ {
+		op, _ := operation.NewOperation(operation.Income, accID, float64(i+1), now, catID)
+		_ = db.Save(ctx, op)
+	}
+	lru, _ := proxyrepo.NewCachedRepo(ctx, db, proxyrepo.Options{MaxSize: 2})
+	if lru.Len() != 2 {
+		t.Fatalf("expected 2 cached rows, got %d", lru.Len())
+	}
+	if all, _ := lru.All(ctx); len(all) != 4 {
+		t.Fatalf("expected All to read through for an incomplete cache, got %d rows", len(all))
+	}
+}
+
+// Считает полные чтения хранилища; during вызывается посреди чтения.
+type countingAll struct {
+	repository.ICommonRepo
+	calls  int
+	during func()
+}
+
+func (r *countingAll) All(ctx context.Context) ([]service.ICommonObject, error) {
+	r.calls++
+	out, err := r.ICommonRepo.All(ctx)
+	if r.during != nil {
+		r.during()
+	}
+	return out, err
+}
+
+func TestCachedRepo_EchoAndStaleReload(t *testing.T) {
+	ctx := context.Background()
+	db := &countingAll{ICommonRepo: bankaccountrepo.NewBankAccountRepo()}
+	cached, _ := proxyrepo.NewCachedRepo(ctx, db)
+	acc, _ := bankaccount.NewBankAccount("Card", 10)
+	if err := cached.Save(ctx, acc); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// эхо своей записи и чужая вставка из ленты перечитываются по id, без полной загрузки
+	cached.Invalidate(acc.ID())
+	foreign, _ := bankaccount.NewBankAccount("Other", 5)
+	_ = db.ICommonRepo.Save(ctx, foreign)
+	cached.Invalidate(foreign.ID())
+	if all, _ := cached.All(ctx); len(all) != 2 || db.calls != 1 {
+		t.Fatalf("expected 2 rows without a full reload, got %d rows and %d reloads", len(all), db.calls)
+	}
+
+	// загрузка, во время которой пришла инвалидация, не сохраняет устаревший результат
+	cached.InvalidateAll()
+	db.during = func() { cached.Invalidate(acc.ID()) }
+	_, _ = cached.All(ctx)
+	db.during = nil
+	_, _ = cached.All(ctx)
+	if db.calls != 3 {
+		t.Fatalf("expected the stale reload to be discarded, got %d reloads", db.calls)
+	}
+}
+
+func TestSoftDelete_ArchiveRestorePurge(t *testing.T) {
+	ctx := auth.System(context.Background())
+	accRepo := bankaccountrepo.NewBankAccountRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	bankF := facade.NewBankAccountFacade(accRepo)
+	bankF.SetOperationRepo(opRepo)
+	catF := facade.NewCategoryFacade(categoryrepo.NewCategoryRepo())
+
+	accID, _ := bankF.CreateAccount(ctx, "Old card", 0)
+	catID, _ := catF.CreateCategory(ctx, "Travel", category.Spending)
+	now := time.Now()
+	for _, amount := range []float64{30, 70} {
+		op, _ := operation.NewOperation(operation.Spending, accID, amount, now.Add(-time.Minute), catID)
+		_ = opRepo.Save(ctx, op)
+	}
+
+	if _, err := bankF.PurgeAccount(ctx, accID); err == nil {
+		t.Fatalf("expected purge of an active account to be rejected")
+	}
+	if err := bankF.DeleteAccount(ctx, accID); err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	if err := catF.DeleteCategory(ctx, catID); err != nil {
+		t.Fatalf("archive category error: %v", err)
+	}
+	if accs, _ := bankF.ListAllAccounts(ctx); len(accs) != 0 {
+		t.Fatalf("expected archived account to be hidden, got %d", len(accs))
+	}
+	if cats, _ := catF.ListArchivedCategories(ctx); len(cats) != 1 {
+		t.Fatalf("expected 1 archived category, got %d", len(cats))
+	}
+	if err := bankF.UpdateAccountName(ctx, accID, "New"); err == nil {
+		t.Fatalf("expected edit of an archived account to be rejected")
+	}
+	opF := facade.NewOperationFacade(opRepo)
+	opF.SetAccountRepo(accRepo)
+	if _, err := opF.CreateOperation(ctx, operation.Spending, accID, 5, now, catID); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected a new operation on an archived account to be rejected, got %v", err)
+	}
+	// история операций архивного счёта остаётся в аналитике
+	_, expense, _, err := facade.NewAnalyticsFacade(opRepo).IncomeExpenseDelta(ctx, accID, now.Add(-time.Hour), now)
+	if err != nil || expense != 100 {
+		t.Fatalf("expected archived history in analytics, got %.2f (%v)", expense, err)
+	}
+
+	// deleted_at переживает выгрузку в CSV
+	data, _ := accRepo.All(ctx)
+	path := t.TempDir() + "/accounts.csv"
+	if err := csvexporter.NewCSVBankAccountExporter(path).Export(ctx, data); err != nil {
+		t.Fatalf("csv export err: %v", err)
+	}
+	imp := csvimporter.NewCSVBankAccountImporter(path)
+	if err := imp.Read(ctx); err != nil {
+		t.Fatalf("csv import err: %v", err)
+	}
+	obj, err := imp.Data().ByID(ctx, accID)
+	if err != nil || !obj.(bankaccount.IBankAccount).IsArchived() {
+		t.Fatalf("deleted_at lost on import: %v", err)
+	}
+
+	if err := bankF.RestoreAccount(ctx, accID); err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+	if accs, _ := bankF.ListAllAccounts(ctx); len(accs) != 1 {
+		t.Fatalf("expected restored account to be listed")
+	}
+	_ = bankF.DeleteAccount(ctx, accID)
+
+	// закрытая операция останавливает удаление до первой записи
+	opObjs, _ := opRepo.All(ctx)
+	guarded := facade.NewBankAccountFacade(accRepo)
+	guarded.SetOperationRepo(&lockedOps{ICommonRepo: opRepo, locked: opObjs[len(opObjs)-1].ID()})
+	if _, err := guarded.PurgeAccount(ctx, accID); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("purge with a locked operation: %v", err)
+	}
+	// сбой на удалении счёта откатывает уже удалённые операции
+	failing := facade.NewBankAccountFacade(failingDelete{accRepo})
+	failing.SetOperationRepo(opRepo)
+	if _, err := failing.PurgeAccount(ctx, accID); err == nil {
+		t.Fatalf("expected purge to fail")
+	}
+	if ops, _ := opRepo.All(ctx); len(ops) != 2 {
+		t.Fatalf("failed purge left %d of 2 operations", len(ops))
+	}
+
+	cmd := &commandpkg.PurgeAccountCommand{Facade: bankF, AccountID: accID,
+		Confirm: func(acc bankaccount.IBankAccount, ops int) bool { return ops == 2 }}
+	if err := cmd.Execute(ctx); err != nil || cmd.Cancelled || cmd.Purged != 2 {
+		t.Fatalf("unexpected purge result: purged=%d cancelled=%v err=%v", cmd.Purged, cmd.Cancelled, err)
+	}
+	if ops, _ := opRepo.All(ctx); len(ops) != 0 {
+		t.Fatalf("expected operations to be purged, %d left", len(ops))
+	}
+	if _, err := bankF.GetAccount(ctx, accID); err == nil {
+		t.Fatalf("expected purged account to be gone")
+	}
+}
+
+func TestSoftDelete_PurgeCategory(t *testing.T) {
+	ctx := auth.System(context.Background())
+	opRepo := operationrepo.NewOperationRepo()
+	catF := facade.NewCategoryFacade(categoryrepo.NewCategoryRepo())
+	catF.SetOperationRepo(opRepo)
+	travel, _ := catF.CreateCategory(ctx, "Travel", category.Spending)
+	hotels, _ := catF.CreateCategory(ctx, "Hotels", category.Spending, travel)
+	op, _ := operation.NewOperation(operation.Spending, service.ObjectID(uuid.New()), 50, time.Now(), travel)
+	_ = opRepo.Save(ctx, op)
+
+	if err := catF.PurgeCategory(ctx, travel); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected purge of an active category to be rejected, got %v", err)
+	}
+	_ = catF.DeleteCategory(ctx, hotels)
+	_ = catF.DeleteCategory(ctx, travel)
+	if err := catF.PurgeCategory(ctx, travel); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected purge of a category with children to be rejected, got %v", err)
+	}
+	if err := catF.PurgeCategory(ctx, hotels); err != nil {
+		t.Fatalf("purge unused category: %v", err)
+	}
+	if err := catF.PurgeCategory(ctx, travel); err == nil || !strings.Contains(err.Error(), "used by 1 operation") {
+		t.Fatalf("expected purge of a used category to be rejected, got %v", err)
+	}
+	_ = opRepo.Delete(ctx, op.ID())
+	if err := catF.PurgeCategory(ctx, travel); err != nil {
+		t.Fatalf("purge category: %v", err)
+	}
+	if _, err := catF.GetCategory(ctx, travel); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected purged category to be gone, got %v", err)
+	}
+}
+
+// Хранилище с одной закрытой для правки строкой; err подменяет periods.ErrClosed.
+type lockedOps struct {
+	repository.ICommonRepo
+	locked service.ObjectID
+	err    error
+}
+
+func (r *lockedOps) CheckEditable(ctx context.Context, id service.ObjectID) error {
+	if id == r.locked {
+		if r.err != nil {
+			return r.err
+		}
+		return periods.ErrClosed
+	}
+	return nil
+}
+
+type failingDelete struct {
+	repository.ICommonRepo
+}
+
+func (failingDelete) Delete(ctx context.Context, id service.ObjectID) error {
+	return errors.New("storage unavailable")
+}
+
+// Update объекта id падает, остальные проходят.
+type failingUpdate struct {
+	repository.ICommonRepo
+	id service.ObjectID
+}
+
+func (r failingUpdate) Update(ctx context.Context, obj service.ICommonObject) error {
+	if obj.ID() == r.id {
+		return errors.New("storage unavailable")
+	}
+	return r.ICommonRepo.Update(ctx, obj)
+}
+
+func TestVersionedRepo_HistoryAndAsOf(t *testing.T) {
+	ctx := context.Background()
+	history := historyrepo.NewHistoryRepo()
+	bankF := facade.NewBankAccountFacade(proxyrepo.NewVersionedRepo(bankaccountrepo.NewBankAccountRepo(), history, historyrepo.AccountCodec))
+	catF := facade.NewCategoryFacade(proxyrepo.NewVersionedRepo(categoryrepo.NewCategoryRepo(), history, historyrepo.CategoryCodec))
+	opRepo := proxyrepo.NewVersionedOperationRepo(operationrepo.NewOperationRepo(), history)
+	historyF := facade.NewHistoryFacade(history)
+
+	accID, _ := bankF.CreateAccount(ctx, "Card", 100)
+	catID, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	time.Sleep(time.Millisecond)
+	beforeRename := time.Now()
+	time.Sleep(time.Millisecond)
+	_ = bankF.UpdateAccountName(ctx, accID, "Main card")
+	_ = catF.UpdateCategoryName(ctx, catID, "Groceries")
+	// повторное сохранение без изменений версию не добавляет
+	_ = bankF.UpdateAccountName(ctx, accID, "Main card")
+
+	versions, _ := historyF.AccountHistory(ctx, accID)
+	if len(versions) != 2 || versions[0].Op != "I" || versions[1].Op != "U" || versions[1].Version != 2 {
+		t.Fatalf("unexpected account history: %+v", versions)
+	}
+	changes, err := historyrepo.Diff(versions[1].Before, versions[1].After)
+	if err != nil || len(changes) != 1 || changes[0].Field != "name" || changes[0].Old != `"Card"` {
+		t.Fatalf("unexpected diff: %+v (%v)", changes, err)
+	}
+	acc, err := historyF.AccountAsOf(ctx, accID, beforeRename)
+	if err != nil || acc.Name() != "Card" {
+		t.Fatalf("expected old name as of %v: %v", beforeRename, err)
+	}
+	if acc, _ := historyF.AccountAsOf(ctx, accID, time.Now()); acc.Name() != "Main card" {
+		t.Fatalf("expected current name, got %q", acc.Name())
+	}
+	if c, err := historyF.CategoryAsOf(ctx, catID, beforeRename); err != nil || c.Name() != "Food" {
+		t.Fatalf("expected old category name: %v", err)
+	}
+	if _, err := historyF.AccountAsOf(ctx, accID, beforeRename.Add(-time.Hour)); err == nil {
+		t.Fatalf("expected no account before it was created")
+	}
+
+	// исправление суммы операции сохраняет старое значение
+	opF := facade.NewOperationFacade(opRepo)
+	opID, err := opF.CreateOperation(ctx, operation.Spending, accID, 40, time.Now(), catID)
+	if err != nil {
+		t.Fatalf("create operation: %v", err)
+	}
+	obj, _ := opRepo.ByID(ctx, opID)
+	fixed, _ := operation.NewCopyOperation(opID, operation.Spending, accID, 45, obj.(operation.IOperation).Date(), catID)
+	_ = fixed.SetTags("fixed")
+	if err := opRepo.Update(ctx, fixed); err != nil {
+		t.Fatalf("update operation: %v", err)
+	}
+	_ = opRepo.Delete(ctx, opID)
+	opVersions, _ := historyF.OperationHistory(ctx, opID)
+	if len(opVersions) != 3 || opVersions[2].Op != "D" || opVersions[2].After != nil {
+		t.Fatalf("unexpected operation history: %+v", opVersions)
+	}
+	old, err := historyrepo.OperationCodec.Decode(opVersions[1].Before)
+	if err != nil || old.(operation.IOperation).Amount() != 40 {
+		t.Fatalf("expected amount 40 before correction: %v", err)
+	}
+	if _, err := historyF.OperationAsOf(ctx, opID, time.Now()); err == nil {
+		t.Fatalf("expected deleted operation to be reported")
+	}
+
+	// версия пишется вместе с изменением: не записалась версия — не записалось и изменение
+	accs := bankaccountrepo.NewBankAccountRepo()
+	cash, _ := bankaccount.NewBankAccount("Cash", 5)
+	_ = accs.Save(ctx, cash)
+	broken := proxyrepo.NewVersionedRepo(accs, failingHistory{history}, historyrepo.AccountCodec)
+	renamed := service.CopyOf(cash).(*bankaccount.BankAccount)
+	renamed.SetName("Wallet")
+	if err := broken.Update(ctx, renamed); err == nil {
+		t.Fatalf("expected update to fail with the history")
+	}
+	if got, _ := accs.ByID(ctx, cash.ID()); got.(bankaccount.IBankAccount).Name() != "Cash" {
+		t.Fatalf("update kept without its version")
+	}
+	// строка без истории: снимок «до» берётся из хранилища
+	if err := proxyrepo.NewVersionedRepo(accs, history, historyrepo.AccountCodec).Update(ctx, renamed); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	cashVersions, _ := historyF.AccountHistory(ctx, cash.ID())
+	if len(cashVersions) != 1 || !strings.Contains(string(cashVersions[0].Before), `"Cash"`) {
+		t.Fatalf("unexpected before snapshot: %+v", cashVersions)
+	}
+}
+
+type failingHistory struct {
+	historyrepo.IHistoryRepo
+}
+
+func (failingHistory) Append(ctx context.Context, v *historyrepo.Version) error {
+	return errors.New("history unavailable")
+}
+
+func TestLedger_ReplayStreamAndSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func(every int) *ledger.Ledger {
+		store, err := ledger.NewFileEventStore(dir + "/events.jsonl")
+		if err != nil {
+			t.Fatalf("open event store: %v", err)
+		}
+		l, err := ledger.Open(ctx, store, ledger.NewFileSnapshotStore(dir+"/snapshot.json"), every)
+		if err != nil {
+			t.Fatalf("open ledger: %v", err)
+		}
+		return l
+	}
+
+	l := open(0)
+	bankF := facade.NewBankAccountFacade(l.Accounts())
+	bankF.SetOperationRepo(l.Operations())
+	catF := facade.NewCategoryFacade(l.Categories())
+	opF := facade.NewOperationFacade(l.Operations())
+
+	accID, _ := bankF.CreateAccount(ctx, "Card", 100)
+	_ = bankF.UpdateAccountName(ctx, accID, "Main card")
+	_ = bankF.UpdateAccountBalance(ctx, accID, 250)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	opID, err := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 40, time.Now(), food, "market", []string{"home"})
+	if err != nil {
+		t.Fatalf("create operation: %v", err)
+	}
+	tmpID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 5, time.Now(), food)
+	if err := opF.DeleteOperation(ctx, tmpID); err != nil {
+		t.Fatalf("delete operation: %v", err)
+	}
+	if err := bankF.UpdateAccountBalance(ctx, accID, -1); err == nil {
+		t.Fatalf("expected negative balance to be rejected")
+	}
+
+	stream, _ := l.Stream(ctx, accID)
+	var types []string
+	for _, e := range stream {
+		types = append(types, string(e.Type))
+	}
+	if strings.Join(types, ",") != "AccountOpened,AccountRenamed,AccountBalanceChanged" {
+		t.Fatalf("unexpected account stream: %v", types)
+	}
+
+	// новый процесс собирает то же состояние из журнала
+	replayed := open(3)
+	acc, err := replayed.Accounts().ByID(ctx, accID)
+	if err != nil || acc.(bankaccount.IBankAccount).Name() != "Main card" || acc.(bankaccount.IBankAccount).Balance() != 250 {
+		t.Fatalf("account not rebuilt: %v", err)
+	}
+	ops, _ := replayed.Operations().All(ctx)
+	if len(ops) != 1 || ops[0].ID() != opID || !ops[0].(operation.IOperation).HasTag("home") {
+		t.Fatalf("operations not rebuilt: %v", ops)
+	}
+	seq := replayed.Seq()
+
+	// при открытии с LEDGER_SNAPSHOT_EVERY=3 снимок сохранён; следующий старт читает только новые события
+	_ = facade.NewCategoryFacade(replayed.Categories()).UpdateCategoryName(ctx, food, "Groceries")
+	snap, err := ledger.NewFileSnapshotStore(dir + "/snapshot.json").LoadSnapshot(ctx)
+	if err != nil || snap == nil || snap.Seq != seq || len(snap.Accounts) != 1 {
+		t.Fatalf("expected snapshot at seq %d: %+v (%v)", seq, snap, err)
+	}
+	cat, err := open(0).Categories().ByID(ctx, food)
+	if err != nil || cat.(category.ICategory).Name() != "Groceries" {
+		t.Fatalf("expected event after snapshot to be replayed: %v", err)
+	}
+
+	// проекции и журнал — одна единица работы: откат не оставляет ни правки, ни события
+	current := open(0)
+	seq = current.Seq()
+	err = repository.Atomic(ctx, func(ctx context.Context) error {
+		if err := facade.NewBankAccountFacade(current.Accounts()).UpdateAccountName(ctx, accID, "Rolled back"); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	acc, _ = current.Accounts().ByID(ctx, accID)
+	if err == nil || current.Seq() != seq || acc.(bankaccount.IBankAccount).Name() != "Main card" {
+		t.Fatalf("rolled back rename: seq %d, name %q (%v)", current.Seq(), acc.(bankaccount.IBankAccount).Name(), err)
+	}
+	if reopened := open(0); reopened.Seq() != seq {
+		t.Fatalf("rolled back event reached the journal: seq %d, want %d", reopened.Seq(), seq)
+	}
+	// чужую строку журнал, как и остальные хранилища, не меняет и не удаляет
+	stranger := service.WithOwner(ctx, service.ObjectID(uuid.New()))
+	accObj, _ := current.Accounts().ByID(ctx, accID)
+	stolen := accObj.(*bankaccount.BankAccount)
+	stolen.SetName("Stolen")
+	if err := current.Accounts().Update(stranger, stolen); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("foreign update: %v", err)
+	}
+	if err := current.Operations().Delete(stranger, opID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("foreign delete: %v", err)
+	}
+	if current.Seq() != seq {
+		t.Fatalf("foreign writes reached the journal: seq %d, want %d", current.Seq(), seq)
+	}
+
+	store, _ := ledger.NewFileEventStore(dir + "/events.jsonl")
+	broken, _ := ledger.Open(ctx, failingAppend{store}, nil, 0)
+	if _, err := facade.NewBankAccountFacade(broken.Accounts()).CreateAccount(ctx, "Lost", 1); err == nil {
+		t.Fatalf("expected append to fail")
+	}
+	if accs, _ := broken.Accounts().All(ctx); len(accs) != 1 {
+		t.Fatalf("projection kept an account without its event: %d accounts", len(accs))
+	}
+}
+
+type failingAppend struct {
+	ledger.IEventStore
+}
+
+func (failingAppend) Append(ctx context.Context, events []ledger.Event) error {
+	return errors.New("journal unavailable")
+}
+
+func TestJournal_DoubleEntryReports(t *testing.T) {
+	ctx := context.Background()
+	accRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	bankF := facade.NewBankAccountFacade(accRepo)
+	catF := facade.NewCategoryFacade(catRepo)
+	opF := facade.NewOperationFacade(opRepo)
+
+	accID, _ := bankF.CreateAccount(ctx, "Card", 1000)
+	salary, _ := catF.CreateCategory(ctx, "Salary", category.Income)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	household, _ := catF.CreateCategory(ctx, "Household", category.Spending)
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	_, _ = opF.CreateOperation(ctx, operation.Income, accID, 500, day, salary, "salary")
+	receiptID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 120.01, day.Add(24*time.Hour), food, "receipt")
+	// части округляются до 80.00 и 40.00 — копейка должна попасть в самую крупную часть
+	if err := opF.SplitOperation(ctx, receiptID, []operation.SplitLine{{CategoryID: food, Amount: 80.004}, {CategoryID: household, Amount: 40.004}}); err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	books, err := facade.NewJournalFacade(accRepo, catRepo, opRepo).Build(ctx)
+	if err != nil {
+		t.Fatalf("build journal: %v", err)
+	}
+	if len(books.Entries) != 3 || len(books.Check()) != 0 {
+		t.Fatalf("expected 3 balanced entries, got %d (%v)", len(books.Entries), books.Check())
+	}
+	asset, _ := books.Chart.BySource(accID)
+	if asset.Kind != journal.Asset {
+		t.Fatalf("bank account should map to an asset account")
+	}
+	tb := books.TrialBalance(time.Time{})
+	if !tb.Balanced() {
+		t.Fatalf("trial balance does not balance: %s vs %s", tb.TotalDebit, tb.TotalCredit)
+	}
+	gl, _ := books.GeneralLedger(asset.Code, time.Time{}, time.Time{})
+	if gl.Closing != journal.AmountOf(1379.99) || gl.Lines[0].Balance != journal.AmountOf(1000) {
+		t.Fatalf("unexpected asset ledger: opening line %s, closing %s", gl.Lines[0].Balance, gl.Closing)
+	}
+	foodAcc, _ := books.Chart.BySource(food)
+	if foodGL, _ := books.GeneralLedger(foodAcc.Code, time.Time{}, time.Time{}); foodGL.Closing != journal.AmountOf(80.01) {
+		t.Fatalf("expected rounding cent on the largest split, got %s", foodGL.Closing)
+	}
+
+	pl := books.ProfitAndLoss(day, day.Add(48*time.Hour))
+	if pl.TotalIncome != journal.AmountOf(500) || pl.TotalExpenses != journal.AmountOf(120.01) || pl.NetIncome.String() != "379.99" {
+		t.Fatalf("unexpected P&L: %+v", pl)
+	}
+	bs := books.BalanceSheet(time.Time{})
+	if !bs.Balanced() || bs.TotalAssets != journal.AmountOf(1379.99) {
+		t.Fatalf("balance sheet does not balance: %s vs %s", bs.TotalAssets, bs.TotalLiabilitiesAndEquity)
+	}
+	// остаток до первой операции — один и тот же в журнале и в сверке
+	before := books.BalanceSheet(day.Add(-time.Hour))
+	ledgerBefore, _ := facade.NewReconciliationFacade(accRepo, opRepo, reconciliationrepo.NewReconciliationRepo()).LedgerBalanceAt(ctx, accID, day.Add(-time.Hour))
+	if before.TotalAssets != journal.AmountOf(1000) || before.TotalAssets != journal.AmountOf(ledgerBefore) {
+		t.Fatalf("journal and reconciliation disagree before the first operation: %s vs %.2f", before.TotalAssets, ledgerBefore)
+	}
+
+	broken := journal.Entry{Number: 9, Lines: []journal.Line{{Account: asset.Code, Debit: 100}, {Account: foodAcc.Code, Credit: 99}}}
+	if broken.Validate() == nil {
+		t.Fatalf("expected unbalanced entry to fail the invariant")
+	}
+
+	// новые счета и категории, стоящие раньше по имени, не сдвигают номера существующих
+	_, _ = bankF.CreateAccount(ctx, "AAA savings", 10)
+	_, _ = catF.CreateCategory(ctx, "AAA cafe", category.Spending)
+	later, err := facade.NewJournalFacade(accRepo, catRepo, opRepo).Build(ctx)
+	if err != nil {
+		t.Fatalf("rebuild journal: %v", err)
+	}
+	if a, _ := later.Chart.BySource(accID); a.Code != asset.Code {
+		t.Fatalf("asset code moved from %s to %s", asset.Code, a.Code)
+	}
+	if a, _ := later.Chart.BySource(food); a.Code != foodAcc.Code {
+		t.Fatalf("category code moved from %s to %s", foodAcc.Code, a.Code)
+	}
+
+	// операция из приложения не меняет баланс счёта: он и есть начальный остаток
+	wallet, _ := bankF.CreateAccount(ctx, "Wallet", 100)
+	_, _ = opF.CreateOperation(ctx, operation.Spending, wallet, 30, day, food, "lunch")
+	later, _ = facade.NewJournalFacade(accRepo, catRepo, opRepo).Build(ctx)
+	walletAcc, _ := later.Chart.BySource(wallet)
+	walletGL, _ := later.GeneralLedger(walletAcc.Code, time.Time{}, time.Time{})
+	if len(walletGL.Lines) != 2 || walletGL.Lines[0].Balance != journal.AmountOf(100) || walletGL.Closing != journal.AmountOf(70) {
+		t.Fatalf("unexpected wallet ledger: %+v", walletGL)
+	}
+
+	many := make([]bankaccount.IBankAccount, 999)
+	for i := range many {
+		many[i], _ = bankaccount.NewBankAccount(fmt.Sprintf("Card %d", i), 0)
+	}
+	if _, err := journal.NewChart(many, nil); err == nil {
+		t.Fatalf("expected a full asset range to be rejected")
+	}
+}
+
+// ---------- Statement reconciliation ----------
+func TestReconciliation_MatchMarkAndLock(t *testing.T) {
+	ctx := context.Background()
+	accRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	recRepo := reconciliationrepo.NewReconciliationRepo()
+	locked := proxyrepo.NewLockedOperationRepo(opRepo, reconcile.NewLocks(recRepo))
+	bankF := facade.NewBankAccountFacade(accRepo)
+	catF := facade.NewCategoryFacade(catRepo)
+	opF := facade.NewOperationFacade(locked)
+	recF := facade.NewReconciliationFacade(accRepo, locked, recRepo)
+
+	accID, _ := bankF.CreateAccount(ctx, "Card", 1000)
+	salary, _ := catF.CreateCategory(ctx, "Salary", category.Income)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	_, _ = opF.CreateOperation(ctx, operation.Income, accID, 500, day(1), salary, "salary")
+	shopID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 120, day(3), food, "shop")
+	chequeID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 30, day(5), food, "cheque")
+	_, _ = opF.CreateOperation(ctx, operation.Spending, accID, 9.99, day(6), food, "cafe")
+	laterID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 45, day(20), food, "later")
+
+	st, err := reconcile.ParseStatementCSV(strings.NewReader(
+		"date,amount,description,balance\n" +
+			"2026-03-02,500,SALARY,1500.00\n" +
+			"2026-03-04,-120,SHOP,1380.00\n" +
+			"2026-03-06T09:00:00Z,-9.99,CAFE,1370.01\n" +
+			"2026-03-08,-15,BANK FEE,1355.01\n"))
+	if err != nil || len(st.Lines) != 4 || !st.HasBalance || st.Balance != 1355.01 {
+		t.Fatalf("unexpected statement: %+v, %v", st, err)
+	}
+	at := day(10)
+	if bal, _ := recF.LedgerBalanceAt(ctx, accID, at); bal != 1340.01 {
+		t.Fatalf("ledger balance at date should exclude later operations, got %.2f", bal)
+	}
+
+	sess, err := recF.Start(ctx, accID, at, st.Balance, st.Lines)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if len(sess.Result.Matched) != 3 || len(sess.Result.MissingInBooks) != 1 || len(sess.Result.ExtraInBooks) != 1 {
+		t.Fatalf("unexpected match: %+v", sess.Result)
+	}
+	if sess.Result.ExtraInBooks[0].ID() != chequeID || sess.Result.MissingInBooks[0].Description != "BANK FEE" {
+		t.Fatalf("cheque should be extra and the fee missing: %+v", sess.Result)
+	}
+	if sess.StartBalance != 1000 || sess.Difference() != -15 {
+		t.Fatalf("start %.2f, difference %.2f", sess.StartBalance, sess.Difference())
+	}
+	if _, err := recF.Finish(ctx, sess); err == nil {
+		t.Fatalf("unbalanced reconciliation should not finish")
+	}
+	if err := sess.Mark(laterID); err == nil {
+		t.Fatalf("operation after statement date cannot be marked")
+	}
+
+	// банк не удержал комиссию: сверяем с исправленным остатком без неё
+	sess, _ = recF.Start(ctx, accID, at, 1370.01, st.Lines[:3])
+	sess.Unmark(shopID)
+	if sess.Balanced() {
+		t.Fatalf("unmarked shop should leave a difference")
+	}
+	_ = sess.Mark(shopID)
+	rec, err := recF.Finish(ctx, sess)
+	if err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if len(rec.Items()) != 3 || rec.HasItem(chequeID) || rec.LedgerBalance() != 1340.01 {
+		t.Fatalf("unexpected reconciliation: items %d, ledger %.2f", len(rec.Items()), rec.LedgerBalance())
+	}
+
+	if err := opF.TagOperation(ctx, shopID, "groceries"); !errors.Is(err, reconcile.ErrLocked) {
+		t.Fatalf("reconciled operation must be locked, got %v", err)
+	}
+	if op, _ := opF.GetOperation(ctx, shopID); op.HasTag("groceries") {
+		t.Fatalf("refused edit must not change the operation")
+	}
+	if err := opF.DeleteOperation(ctx, chequeID); !errors.Is(err, reconcile.ErrLocked) {
+		t.Fatalf("operation inside reconciled period must be locked, got %v", err)
+	}
+	if _, err := opF.CreateOperation(ctx, operation.Spending, accID, 1, day(9), food, "backdated"); !errors.Is(err, reconcile.ErrLocked) {
+		t.Fatalf("backdated operation must be refused, got %v", err)
+	}
+	if err := opF.TagOperation(ctx, laterID, "ok"); err != nil {
+		t.Fatalf("operation after the period should stay editable: %v", err)
+	}
+
+	if _, err := recF.Start(ctx, accID, day(9), 0, nil); err == nil {
+		t.Fatalf("period already reconciled")
+	}
+	next, err := recF.Start(ctx, accID, day(31), 1000, nil)
+	if err != nil {
+		t.Fatalf("next start: %v", err)
+	}
+	if next.StartBalance != 1370.01 || len(next.Candidates) != 2 {
+		t.Fatalf("uncleared cheque should carry over: start %.2f, %d candidate(s)", next.StartBalance, len(next.Candidates))
+	}
+
+	// верная выписка по счёту с операцией из приложения сходится и закрывается
+	wallet, _ := bankF.CreateAccount(ctx, "Wallet", 100)
+	_, _ = opF.CreateOperation(ctx, operation.Spending, wallet, 30, day(2), food, "lunch")
+	ws, err := recF.Start(ctx, wallet, day(4), 70, []reconcile.StatementLine{{Date: day(2), Amount: -30, Description: "LUNCH"}})
+	if err != nil || ws.StartBalance != 100 || ws.LedgerBalance != 70 || !ws.Balanced() {
+		t.Fatalf("wallet session: start %.2f, ledger %.2f, balanced %v (%v)", ws.StartBalance, ws.LedgerBalance, ws.Balanced(), err)
+	}
+	if _, err := recF.Finish(ctx, ws); err != nil {
+		t.Fatalf("correct statement should finish: %v", err)
+	}
+}
+
+// ---------- Accounting periods ----------
+// Считают полные выборки.
+type countingPeriods struct {
+	*periodrepo.PeriodRepo
+	all int
+}
+
+func (c *countingPeriods) All(ctx context.Context) ([]service.ICommonObject, error) {
+	c.all++
+	return c.PeriodRepo.All(ctx)
+}
+
+type countingRecs struct {
+	*reconciliationrepo.ReconciliationRepo
+	all int
+}
+
+func (c *countingRecs) All(ctx context.Context) ([]service.ICommonObject, error) {
+	c.all++
+	return c.ReconciliationRepo.All(ctx)
+}
+
+func TestPeriodClose_LocksAndReopen(t *testing.T) {
+	ctx := auth.System(context.Background())
+	accRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	periodRepo := periodrepo.NewPeriodRepo()
+	// запись операции находит период и сверки выборкой, а не перебором всех строк
+	lockPeriods := &countingPeriods{PeriodRepo: periodRepo}
+	lockRecs := &countingRecs{ReconciliationRepo: reconciliationrepo.NewReconciliationRepo()}
+	locked := proxyrepo.NewLockedOperationRepo(opRepo, proxyrepo.OperationGuards{
+		periods.NewLocks(lockPeriods), reconcile.NewLocks(lockRecs),
+	})
+	bankF := facade.NewBankAccountFacade(accRepo)
+	catF := facade.NewCategoryFacade(catRepo)
+	opF := facade.NewOperationFacade(locked)
+	periodF := facade.NewPeriodFacade(periodRepo, locked)
+
+	accID, _ := bankF.CreateAccount(ctx, "Card", 100)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	jan := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	opID, _ := opF.CreateOperation(ctx, operation.Spending, accID, 10, jan, food, "lunch")
+
+	if err := periodF.ClosePeriod(ctx, time.Now()); err == nil {
+		t.Fatalf("current month cannot be closed")
+	}
+	if err := periodF.ClosePeriod(ctx, jan); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := periodF.ClosePeriod(ctx, jan); err == nil {
+		t.Fatalf("closing twice should fail")
+	}
+
+	if _, err := opF.CreateOperation(ctx, operation.Spending, accID, 5, jan.AddDate(0, 0, 10), food, "late"); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("create in closed period: %v", err)
+	}
+	if err := opF.DeleteOperation(ctx, opID); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("delete in closed period: %v", err)
+	}
+	imported, _ := operation.NewOperation(operation.Spending, accID, 7, jan, food, "imported")
+	if err := locked.Save(ctx, imported); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("import into closed period: %v", err)
+	}
+	if _, err := opF.CreateOperation(ctx, operation.Spending, accID, 5, jan.AddDate(0, 1, 0), food, "february"); err != nil {
+		t.Fatalf("open period should accept operations: %v", err)
+	}
+	if lockPeriods.all != 0 || lockRecs.all != 0 {
+		t.Fatalf("locks read whole tables: periods %d, reconciliations %d", lockPeriods.all, lockRecs.all)
+	}
+
+	if err := periodF.ReopenPeriod(ctx, jan, ""); err == nil {
+		t.Fatalf("reopen requires a reason")
+	}
+	if err := periodF.ReopenPeriod(ctx, jan, "wrong receipt"); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := opF.DeleteOperation(ctx, opID); err != nil {
+		t.Fatalf("delete after reopen: %v", err)
+	}
+	if err := periodF.ClosePeriod(ctx, jan); err != nil {
+		t.Fatalf("close again: %v", err)
+	}
+
+	list, err := periodF.ListPeriods(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if list[0].Month.Format("2006-01") != "2026-01" || !list[0].Closed || list[0].Operations != 0 {
+		t.Fatalf("unexpected first period: %+v", list[0])
+	}
+	if log := list[0].Log; len(log) != 3 || log[1].Action != period.Reopen || log[1].Reason != "wrong receipt" {
+		t.Fatalf("reopen should be recorded: %+v", log)
+	}
+	if list[1].Closed || list[1].Operations != 1 {
+		t.Fatalf("february should be open with one operation: %+v", list[1])
+	}
+}
+
+// ---------- Duplicate detection ----------
+func TestDedupe_ImportPolicyAndMerge(t *testing.T) {
+	ctx := context.Background()
+	if s := dedupe.Similarity("COFFEE SHOP #123", "Coffee shop 123"); s != 1 {
+		t.Fatalf("case and punctuation should not matter, got %.2f", s)
+	}
+	if s := dedupe.Similarity("Coffee shop", "Taxi ride"); s >= dedupe.DefaultOptions.MinSimilarity {
+		t.Fatalf("unrelated descriptions look similar: %.2f", s)
+	}
+	// цепочка a~b~c при далёких a и c: в группе только похожие на якорь a
+	chainAcc := service.ObjectID(uuid.New())
+	var chain []operation.IOperation
+	for i := 0; i < 3; i++ {
+		op, _ := operation.NewOperation(operation.Spending, chainAcc, 9.99, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i)*40*time.Hour), service.ObjectID{}, "Book store")
+		chain = append(chain, op)
+	}
+	groups := dedupe.FindGroups([]operation.IOperation{chain[2], chain[0], chain[1]}, dedupe.DefaultOptions)
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].ID() != chain[0].ID() || groups[0][1].ID() != chain[1].ID() {
+		t.Fatalf("non-transitive grouping: %v", groups)
+	}
+
+	accRepo := bankaccountrepo.NewBankAccountRepo()
+	catRepo := categoryrepo.NewCategoryRepo()
+	opRepo := operationrepo.NewOperationRepo()
+	bankF := facade.NewBankAccountFacade(accRepo)
+	catF := facade.NewCategoryFacade(catRepo)
+	opF := facade.NewOperationFacade(opRepo)
+	accID, _ := bankF.CreateAccount(ctx, "Card", 100)
+	food, _ := catF.CreateCategory(ctx, "Food", category.Spending)
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	keepID, _ := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 4.5, day, food, "Coffee Shop Moscow", []string{"morning"})
+
+	// та же покупка из второй выписки пришла на день позже и с другим id
+	path := t.TempDir() + "/ops.csv"
+	csvData := "id,type,bank_account_id,amount,date,description,category_id\n" +
+		uuid.NewString() + ",0," + accID.String() + ",4.50," + day.Add(24*time.Hour).Format(time.RFC3339) + ",COFFEE SHOP MOSCOW,\n" +
+		uuid.NewString() + ",0," + accID.String() + ",4.50," + day.Format(time.RFC3339) + ",Taxi,\n"
+	_ = os.WriteFile(path, []byte(csvData), 0644)
+	read := func(policy dedupe.Policy, ask func(op, match operation.IOperation) bool) (*importer.BaseImporter, *dedupe.ImportHook) {
+		imp := csvimporter.NewCSVOperationImporter(path)
+		hook := dedupe.NewImportHook(opRepo, policy, dedupe.DefaultOptions)
+		hook.Ask = ask
+		imp.AddHook(hook)
+		if err := imp.Read(ctx); err != nil {
+			t.Fatalf("import (%s): %v", policy, err)
+		}
+		return imp, hook
+	}
+
+	imp, hook := read(dedupe.PolicySkip, nil)
+	if objs, _ := imp.Data().All(ctx); len(objs) != 1 || imp.Skipped() != 1 || hook.Found != 1 {
+		t.Fatalf("skip: imported %d, skipped %d, found %d", len(objs), imp.Skipped(), hook.Found)
+	}
+	asked := 0
+	imp, _ = read(dedupe.PolicyAsk, func(op, match operation.IOperation) bool {
+		asked++
+		return match.ID() != keepID
+	})
+	if objs, _ := imp.Data().All(ctx); asked != 1 || len(objs) != 1 {
+		t.Fatalf("ask: asked %d times, imported %d", asked, len(objs))
+	}
+	imp, _ = read(dedupe.PolicyFlag, nil)
+	objs, _ := imp.Data().All(ctx)
+	if len(objs) != 2 {
+		t.Fatalf("flag should import everything, got %d", len(objs))
+	}
+	for _, obj := range objs {
+		op := obj.(operation.IOperation)
+		if op.HasTag(dedupe.FlagTag) != (op.Description() == "COFFEE SHOP MOSCOW") {
+			t.Fatalf("only the duplicate should be flagged: %q %v", op.Description(), op.Tags())
+		}
+		_ = opRepo.Save(ctx, obj)
+	}
+
+	// повтор внутри одного файла ловится, даже если в хранилище такой операции нет
+	twice := t.TempDir() + "/twice.csv"
+	row := func() string {
+		return uuid.NewString() + ",0," + accID.String() + ",12.00," + day.Format(time.RFC3339) + ",Bakery,\n"
+	}
+	_ = os.WriteFile(twice, []byte("id,type,bank_account_id,amount,date,description,category_id\n"+row()+row()), 0644)
+	twiceImp := csvimporter.NewCSVOperationImporter(twice)
+	twiceImp.AddHook(dedupe.NewImportHook(operationrepo.NewOperationRepo(), dedupe.PolicySkip, dedupe.DefaultOptions))
+	if err := twiceImp.Read(ctx); err != nil {
+		t.Fatalf("import twice: %v", err)
+	}
+	if objs, _ := twiceImp.Data().All(ctx); len(objs) != 1 || twiceImp.Skipped() != 1 {
+		t.Fatalf("in-file duplicate: imported %d, skipped %d", len(objs), twiceImp.Skipped())
+	}
+
+	dcmd := &commandpkg.DedupeCommand{
+		Facade:  opF,
+		Options: dedupe.DefaultOptions,
+		Confirm: func(group []operation.IOperation) bool { return group[0].ID() == keepID },
+	}
+	if err := dcmd.Execute(ctx); err != nil {
+		t.Fatalf("dedupe: %v", err)
+	}
+	if dcmd.Groups != 1 || dcmd.Merged != 1 {
+		t.Fatalf("expected one group merged, got %d groups, %d merged", dcmd.Groups, dcmd.Merged)
+	}
+	all, _ := opF.ListAllOperations(ctx)
+	keep, _ := opF.GetOperation(ctx, keepID)
+	if len(all) != 2 || keep.HasTag(dedupe.FlagTag) || !keep.HasTag("morning") {
+		t.Fatalf("after merge: %d operations, kept tags %v", len(all), keep.Tags())
+	}
+
+	// повторный id в списке не ломает слияние, а сбой удаления откатывает перенос меток
+	x, _ := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 3, day, food, "Kiosk", []string{"x"})
+	y, _ := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 3, day, food, "Kiosk", []string{"y"})
+	z, _ := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 3, day, food, "Kiosk", []string{"z"})
+	if err := opF.MergeDuplicates(ctx, x, []service.ObjectID{y, y}, dedupe.DefaultOptions); err != nil {
+		t.Fatalf("merge with a repeated id: %v", err)
+	}
+	if err := facade.NewOperationFacade(failingDelete{opRepo}).MergeDuplicates(ctx, x, []service.ObjectID{z}, dedupe.DefaultOptions); err == nil {
+		t.Fatalf("expected merge to fail")
+	}
+	if kept, _ := opF.GetOperation(ctx, x); kept.HasTag("z") {
+		t.Fatalf("failed merge kept the moved tags: %v", kept.Tags())
+	}
+	if _, err := opF.GetOperation(ctx, z); err != nil {
+		t.Fatalf("failed merge lost the duplicate: %v", err)
+	}
+
+	// слить можно только дубликаты keep: другая сумма или другой счёт — ошибка, ничего не удалено
+	pricier, _ := opF.CreateTaggedOperation(ctx, operation.Spending, accID, 300, day, food, "Kiosk", nil)
+	income, _ := opF.CreateTaggedOperation(ctx, operation.Income, accID, 3, day, food, "Kiosk", nil)
+	for _, id := range []service.ObjectID{pricier, income} {
+		if err := opF.MergeDuplicates(ctx, x, []service.ObjectID{z, id}, dedupe.DefaultOptions); !errors.Is(err, service.ErrValidation) {
+			t.Fatalf("expected validation error for a non-duplicate, got %v", err)
+		}
+		if _, err := opF.GetOperation(ctx, id); err != nil {
+			t.Fatalf("rejected merge deleted an operation: %v", err)
+		}
+	}
+	if _, err := opF.GetOperation(ctx, z); err != nil {
+		t.Fatalf("rejected merge deleted the duplicate: %v", err)
+	}
+}
+
+func TestMultiUser_Isolation(t *testing.T) {
+	ctx := auth.System(context.Background())
+	// счета — через версии и общий кэш, как в CLI: разделы кэша и история не смешивают пользователей
+	history := historyrepo.NewHistoryRepo()
+	accShared, err := proxyrepo.NewCachedRepo(ctx, proxyrepo.NewVersionedRepo(bankaccountrepo.NewBankAccountRepo(), history, historyrepo.AccountCodec))
+	if err != nil {
+		t.Fatalf("cache: %v", err)
+	}
+	catShared := categoryrepo.NewCategoryRepo()
+	periodShared := periodrepo.NewPeriodRepo()
+	opShared := proxyrepo.NewLockedOperationRepo(operationrepo.NewOperationRepo(), proxyrepo.OperationGuards{periods.NewLocks(periodShared)})
+	tokens, _ := auth.NewTokens([]byte(strings.Repeat("k", 32)), time.Hour)
+	userF, err := facade.NewUserFacade(ctx, userrepo.NewUserRepo(), tokens)
+	if err != nil {
+		t.Fatalf("users: %v", err)
+	}
+	if _, err := userF.FindUser(ctx, "default"); err != nil {
+		t.Fatalf("default user should exist: %v", err)
+	}
+	alice, _ := userF.CreateUser(ctx, "alice", "alice-password", user.Editor)
+	bob, _ := userF.CreateUser(ctx, "bob", "bob-password", user.Editor)
+	if _, err := userF.CreateUser(ctx, "Alice", "alice-password", user.Editor); err == nil {
+		t.Fatalf("user names should be unique")
+	}
+
+	type session struct {
+		accs, cats repository.ICommonRepo
+		ops        operationrepo.IOperationRepo
+		bankF      *facade.BankAccountFacade
+		catF       *facade.CategoryFacade
+		opF        *facade.OperationFacade
+		periodF    *facade.PeriodFacade
+	}
+	open := func(owner service.ObjectID) session {
+		access := proxyrepo.NewAccess(owner, nil)
+		s := session{accs: proxyrepo.NewOwnedRepo(accShared, access), cats: proxyrepo.NewOwnedRepo(catShared, access)}
+		s.ops = proxyrepo.NewOwnedOperationRepo(opShared, access, s.accs, s.cats)
+		s.bankF = facade.NewBankAccountFacade(s.accs)
+		s.catF = facade.NewCategoryFacade(s.cats)
+		s.opF = facade.NewOperationFacade(s.ops)
+		s.periodF = facade.NewPeriodFacade(proxyrepo.NewOwnedRepo(periodShared, access), s.ops)
+		return s
+	}
+	a, b := open(alice.ID()), open(bob.ID())
+
+	jan := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	aAcc, _ := a.bankF.CreateAccount(ctx, "Alice card", 100)
+	aCat, _ := a.catF.CreateCategory(ctx, "Food", category.Spending)
+	aOp, err := a.opF.CreateOperation(ctx, operation.Spending, aAcc, 10, jan, aCat, "lunch")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	bAcc, _ := b.bankF.CreateAccount(ctx, "Bob card", 50)
+
+	if accs, _ := b.bankF.ListAllAccounts(ctx); len(accs) != 1 || accs[0].ID() != bAcc {
+		t.Fatalf("bob should see only his account: %v", accs)
+	}
+	if _, err := b.bankF.GetAccount(ctx, aAcc); err == nil {
+		t.Fatalf("bob read alice's account")
+	}
+	if err := b.bankF.UpdateAccountName(ctx, aAcc, "stolen"); err == nil {
+		t.Fatalf("bob renamed alice's account")
+	}
+	if err := b.opF.DeleteOperation(ctx, aOp); err == nil {
+		t.Fatalf("bob deleted alice's operation")
+	}
+	if ops, _ := b.ops.All(ctx); len(ops) != 0 {
+		t.Fatalf("bob sees foreign operations: %d", len(ops))
+	}
+	if page, _ := b.ops.(operationrepo.IQueryRepo).Query(ctx, operationrepo.Query{}); len(page.Items) != 0 {
+		t.Fatalf("query leaks foreign operations: %d", len(page.Items))
+	}
+	// ссылка на чужую категорию отклоняется репозиторием
+	if _, err := b.opF.CreateOperation(ctx, operation.Spending, bAcc, 5, jan, aCat, "foreign category"); err == nil {
+		t.Fatalf("operation with alice's category should be refused")
+	}
+	acc, _ := a.bankF.GetAccount(ctx, aAcc)
+	if err := b.accs.Update(ctx, acc); err == nil {
+		t.Fatalf("update of a foreign object should be refused")
+	}
+	if acc.OwnerID() != alice.ID() {
+		t.Fatalf("owner not stamped: %v", acc.OwnerID())
+	}
+
+	// закрытый месяц одного пользователя не запирает операции другого
+	if err := a.periodF.ClosePeriod(ctx, jan); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	bCat, _ := b.catF.CreateCategory(ctx, "Food", category.Spending)
+	if _, err := b.opF.CreateOperation(ctx, operation.Spending, bAcc, 5, jan, bCat, "bob lunch"); err != nil {
+		t.Fatalf("bob's january is open: %v", err)
+	}
+	if _, err := a.opF.CreateOperation(ctx, operation.Spending, aAcc, 5, jan, aCat, "late"); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("alice's january is closed: %v", err)
+	}
+
+	// системный доступ (без владельца) видит всех
+	if all, _ := accShared.All(ctx); len(all) != 2 {
+		t.Fatalf("unscoped repo should see all accounts: %d", len(all))
+	}
+	// раздел кэша bob загружен с его областью: строка alice туда не попадает и после её правки
+	_ = a.bankF.UpdateAccountName(ctx, aAcc, "Alice main")
+	if accs, _ := b.bankF.ListAllAccounts(ctx); len(accs) != 1 || accs[0].ID() != bAcc {
+		t.Fatalf("bob's cache partition leaks: %v", accs)
+	}
+
+	// история видна только владельцу сущности
+	histOf := func(owner service.ObjectID) *facade.HistoryFacade {
+		return facade.NewHistoryFacade(proxyrepo.NewOwnedHistoryRepo(history, proxyrepo.NewAccess(owner, nil)))
+	}
+	if vs, _ := histOf(alice.ID()).AccountHistory(ctx, aAcc); len(vs) != 2 || vs[0].OwnerID != alice.ID() {
+		t.Fatalf("alice's history: %+v", vs)
+	}
+	if vs, _ := histOf(bob.ID()).AccountHistory(ctx, aAcc); len(vs) != 0 {
+		t.Fatalf("bob reads alice's history: %d versions", len(vs))
+	}
+	if _, err := histOf(bob.ID()).AccountAsOf(ctx, aAcc, time.Now()); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("bob reads alice's account as of now: %v", err)
+	}
+}
+
+func TestSharedAccounts_PermissionsAuthorsRevoke(t *testing.T) {
+	ctx := auth.System(context.Background())
+	// счета идут через общий кэш, как в CLI: он не должен отдавать пользователям один и тот же объект
+	accShared, err := proxyrepo.NewCachedRepo(ctx, bankaccountrepo.NewBankAccountRepo())
+	if err != nil {
+		t.Fatalf("cache: %v", err)
+	}
+	catShared := categoryrepo.NewCategoryRepo()
+	periodShared := periodrepo.NewPeriodRepo()
+	opShared := proxyrepo.NewLockedOperationRepo(operationrepo.NewOperationRepo(), proxyrepo.OperationGuards{periods.NewLocks(periodShared)})
+	shares := &countingShares{ShareRepo: sharerepo.NewShareRepo()}
+	tokens, _ := auth.NewTokens([]byte(strings.Repeat("k", 32)), time.Hour)
+	userF, _ := facade.NewUserFacade(ctx, userrepo.NewUserRepo(), tokens)
+	alice, _ := userF.CreateUser(ctx, "alice", "alice-password", user.Editor)
+	bob, _ := userF.CreateUser(ctx, "bob", "bob-password", user.Editor)
+
+	type session struct {
+		accs       repository.ICommonRepo
+		ops        operationrepo.IOperationRepo
+		bankF      *facade.BankAccountFacade
+		opF        *facade.OperationFacade
+		analyticsF *facade.AnalyticsFacade
+		periodF    *facade.PeriodFacade
+		shareF     *facade.ShareFacade
+	}
+	open := func(owner service.ObjectID) session {
+		access := proxyrepo.NewAccess(owner, shares)
+		s := session{accs: proxyrepo.NewOwnedRepo(accShared, access)}
+		s.ops = proxyrepo.NewOwnedOperationRepo(opShared, access, s.accs, proxyrepo.NewOwnedRepo(catShared, access))
+		s.bankF = facade.NewBankAccountFacade(s.accs)
+		s.opF = facade.NewOperationFacade(s.ops)
+		s.analyticsF = facade.NewAnalyticsFacade(s.ops)
+		s.periodF = facade.NewPeriodFacade(proxyrepo.NewOwnedRepo(periodShared, access), s.ops)
+		s.shareF = facade.NewShareFacade(s.accs, proxyrepo.NewOwnedRepo(shares, access), owner)
+		return s
+	}
+	a, b := open(alice.ID()), open(bob.ID())
+
+	jan := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	aAcc, _ := a.bankF.CreateAccount(ctx, "Family card", 100)
+	aCat, _ := facade.NewCategoryFacade(proxyrepo.NewOwnedRepo(catShared, proxyrepo.NewAccess(alice.ID(), shares))).CreateCategory(ctx, "Food", category.Spending)
+	aOp, err := a.opF.CreateOperation(ctx, operation.Spending, aAcc, 10, jan, aCat, "alice lunch")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := b.shareF.ShareAccount(ctx, aAcc, bob.ID(), service.ReadAccess); err == nil {
+		t.Fatalf("only the owner can share an account")
+	}
+
+	// чтение: счёт и операции видны, писать нельзя
+	if _, err := a.shareF.ShareAccount(ctx, aAcc, bob.ID(), service.ReadAccess); err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	if accs, _ := b.bankF.ListAllAccounts(ctx); len(accs) != 1 || accs[0].ID() != aAcc {
+		t.Fatalf("bob should see the shared account: %v", accs)
+	}
+	if ops, _ := b.ops.All(ctx); len(ops) != 1 {
+		t.Fatalf("bob should see operations of the shared account: %d", len(ops))
+	}
+	if _, err := b.opF.CreateOperation(ctx, operation.Spending, aAcc, 5, jan, aCat, "read only"); err == nil {
+		t.Fatalf("read access should not allow new operations")
+	}
+	if err := b.opF.DeleteOperation(ctx, aOp); err == nil {
+		t.Fatalf("read access should not allow deletes")
+	}
+	// отказ случается до правки: владелец не видит ни метки, ни нового имени
+	if err := b.opF.TagOperation(ctx, aOp, "bob-was-here"); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("read access should not allow tags: %v", err)
+	}
+	if op, _ := a.opF.GetOperation(ctx, aOp); len(op.Tags()) != 0 {
+		t.Fatalf("refused tag leaked to the owner: %v", op.Tags())
+	}
+	if err := b.bankF.UpdateAccountName(ctx, aAcc, "mine now"); err == nil {
+		t.Fatalf("read access should not allow renaming the account")
+	}
+	if acc, _ := a.bankF.GetAccount(ctx, aAcc); acc.Name() != "Family card" {
+		t.Fatalf("refused rename leaked to the owner: %q", acc.Name())
+	}
+
+	// запись: операция принадлежит владельцу счёта, автор — bob
+	if _, err := a.shareF.ShareAccount(ctx, aAcc, bob.ID(), service.WriteAccess); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if list, _ := a.shareF.ListShares(ctx); len(list) != 1 || list[0].Level() != service.WriteAccess {
+		t.Fatalf("re-sharing should change the level: %v", list)
+	}
+	bOp, err := b.opF.CreateOperation(ctx, operation.Spending, aAcc, 7, jan, aCat, "bob groceries")
+	if err != nil {
+		t.Fatalf("write access: %v", err)
+	}
+	op, err := a.opF.GetOperation(ctx, bOp)
+	if err != nil {
+		t.Fatalf("alice should see bob's operation: %v", err)
+	}
+	if op.OwnerID() != alice.ID() || op.CreatedBy() != bob.ID() {
+		t.Fatalf("owner %v, author %v", op.OwnerID(), op.CreatedBy())
+	}
+	if err := b.bankF.UpdateAccountName(ctx, aAcc, "mine now"); err == nil {
+		t.Fatalf("write access covers operations, not the account itself")
+	}
+	if err := a.bankF.UpdateAccountBalance(ctx, aAcc, 150); err != nil {
+		t.Fatalf("owner update: %v", err)
+	}
+	if acc, _ := a.bankF.GetAccount(ctx, aAcc); acc.Name() != "Family card" || acc.Balance() != 150 {
+		t.Fatalf("owner's next update saved a refused change: %q %.2f", acc.Name(), acc.Balance())
+	}
+	// закрытый месяц владельца запирает и операции получателя
+	if err := a.periodF.ClosePeriod(ctx, jan); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := b.opF.DeleteOperation(ctx, bOp); !errors.Is(err, periods.ErrClosed) {
+		t.Fatalf("owner's closed month should lock bob's edits: %v", err)
+	}
+
+	// аналитика по автору
+	from, to := jan.AddDate(0, 0, -1), jan.AddDate(0, 0, 1)
+	if _, exp, _, _ := a.analyticsF.IncomeExpenseDelta(ctx, aAcc, from, to); exp != 17 {
+		t.Fatalf("all authors expense = %.2f", exp)
+	}
+	if _, exp, _, _ := a.analyticsF.ByAuthor(bob.ID()).IncomeExpenseDelta(ctx, aAcc, from, to); exp != 7 {
+		t.Fatalf("bob's expense = %.2f", exp)
+	}
+	if _, exp, _, _ := a.analyticsF.ByAuthor(alice.ID()).IncomeExpenseDelta(ctx, aAcc, from, to); exp != 10 {
+		t.Fatalf("alice's expense = %.2f", exp)
+	}
+	if page, _ := a.ops.(operationrepo.IQueryRepo).Query(ctx, operationrepo.Query{AuthorIDs: []service.ObjectID{bob.ID()}}); len(page.Items) != 1 {
+		t.Fatalf("query by author: %d", len(page.Items))
+	}
+
+	// отзыв: счёт пропадает из списков и выгрузок
+	if err := a.shareF.RevokeShare(ctx, aAcc, bob.ID()); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if accs, _ := b.bankF.ListAllAccounts(ctx); len(accs) != 0 {
+		t.Fatalf("revoked account is still listed: %v", accs)
+	}
+	path := filepath.Join(t.TempDir(), "ops.json")
+	data, _ := b.ops.All(ctx)
+	if err := jsonexporter.NewJSONOperationExporter(path).Export(ctx, data); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if raw, _ := os.ReadFile(path); strings.Contains(string(raw), "groceries") {
+		t.Fatalf("revoked operations exported: %s", raw)
+	}
+	if err := a.shareF.RevokeShare(ctx, aAcc, bob.ID()); err == nil {
+		t.Fatalf("second revoke should fail")
+	}
+
+	// область видимости: доступы ищутся по получателю и, уже собранные в контексте, не перечитываются
+	access := proxyrepo.NewAccess(bob.ID(), shares)
+	before := shares.lookups
+	scoped, err := access.Scope(ctx)
+	if err != nil || shares.lookups != before+1 {
+		t.Fatalf("scope lookup: %v, %d lookups", err, shares.lookups-before)
+	}
+	bobScoped := facade.NewBankAccountFacade(proxyrepo.NewOwnedRepo(accShared, access))
+	for i := 0; i < 3; i++ {
+		if _, err := bobScoped.ListAllAccounts(scoped); err != nil {
+			t.Fatalf("list: %v", err)
+		}
+	}
+	if shares.lookups != before+1 {
+		t.Fatalf("scope recomputed per call: %d lookups", shares.lookups-before)
+	}
+}
+
+func TestAuth_PasswordsTokensAndRoles(t *testing.T) {
+	ctx := context.Background()
+	tokens, err := auth.NewTokens([]byte(strings.Repeat("s", 32)), time.Hour)
+	if err != nil {
+		t.Fatalf("tokens: %v", err)
+	}
+	users, err := facade.NewUserFacade(ctx, userrepo.NewUserRepo(), tokens)
+	if err != nil {
+		t.Fatalf("users: %v", err)
+	}
+
+	// default ещё без пароля: вход требует его задать
+	def, err := users.Login(ctx, "default", "anything")
+	if !errors.Is(err, facade.ErrPasswordNotSet) {
+		t.Fatalf("default user should need a password: %v", err)
+	}
+	// без кода установки пароль default перехватить нельзя
+	if _, err := users.SetPasswordWithCode(ctx, "default", "", "attacker-password"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("first password without a code: %v", err)
+	}
+	users.SetBootstrapSecret("bootstrap-secret")
+	if _, err := users.SetPasswordWithCode(ctx, "default", "wrong-secret", "attacker-password"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("first password with a wrong secret: %v", err)
+	}
+	if _, err := users.SetPasswordWithCode(ctx, "default", "bootstrap-secret", "short"); err == nil {
+		t.Fatalf("short password accepted")
+	}
+	if _, err := users.SetPasswordWithCode(ctx, "default", "bootstrap-secret", "admin-password"); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	// секрет первого входа годится только пока пароля нет
+	if _, err := users.SetPasswordWithCode(ctx, "default", "bootstrap-secret", "attacker-password"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("bootstrap secret reused: %v", err)
+	}
+	if _, err := users.Login(ctx, "default", "admin-password"); err != nil {
+		t.Fatalf("login after setup: %v", err)
+	}
+	if u, _ := users.GetUser(ctx, def.ID()); strings.Contains(u.PasswordHash(), "admin-password") || !strings.HasPrefix(u.PasswordHash(), "pbkdf2-sha256$") {
+		t.Fatalf("password is not hashed: %q", u.PasswordHash())
+	}
+
+	// пользователями управляет администратор — проверка в фасаде, а не только в меню
+	if _, err := users.CreateUser(ctx, "vera", "viewer-password", user.Viewer); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("anonymous created a user: %v", err)
+	}
+	defCtx := auth.WithPrincipal(ctx, def)
+	viewer, err := users.CreateUser(defCtx, "vera", "viewer-password", user.Viewer)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := users.Login(ctx, "vera", "wrong-password"); !errors.Is(err, auth.ErrBadCredentials) {
+		t.Fatalf("wrong password: %v", err)
+	}
+	if _, err := users.Login(ctx, "nobody", "viewer-password"); !errors.Is(err, auth.ErrBadCredentials) {
+		t.Fatalf("unknown user: %v", err)
+	}
+	if u, err := users.Login(ctx, "VERA", "viewer-password"); err != nil || u.ID() != viewer.ID() {
+		t.Fatalf("login: %v", err)
+	}
+
+	if _, err := users.IssueToken(auth.WithPrincipal(ctx, viewer), def.ID()); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("viewer issued a token for the admin: %v", err)
+	}
+	token, err := users.IssueToken(auth.WithPrincipal(ctx, viewer), viewer.ID())
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if u, err := users.Authenticate(ctx, token); err != nil || u.ID() != viewer.ID() {
+		t.Fatalf("authenticate: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"`+viewer.ID().String()+`","role":"admin","exp":9999999999}`)) + "." + parts[2]
+	if _, err := users.Authenticate(ctx, forged); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("forged token accepted: %v", err)
+	}
+	other, _ := auth.NewTokens([]byte(strings.Repeat("x", 32)), time.Hour)
+	if _, err := other.Verify(token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("token signed with another key accepted: %v", err)
+	}
+	expired, _ := auth.NewTokens([]byte(strings.Repeat("s", 32)), -time.Minute)
+	old, _ := expired.Issue(viewer)
+	if _, err := tokens.Verify(old); !errors.Is(err, auth.ErrTokenExpired) {
+		t.Fatalf("expired token: %v", err)
+	}
+
+	ctx = auth.WithPrincipal(ctx, viewer)
+	if err := auth.Require(ctx, user.Viewer); err != nil {
+		t.Fatalf("viewer can read: %v", err)
+	}
+	if err := auth.Require(ctx, user.Editor); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("viewer cannot edit: %v", err)
+	}
+	if err := auth.Require(context.Background(), user.Viewer); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("anonymous: %v", err)
+	}
+	if err := users.SetRole(ctx, viewer.ID(), user.Admin); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("viewer changed own role: %v", err)
+	}
+	// неизвестный пункт меню требует администратора
+	if requiredRole("999") != user.Admin || requiredRole("2") != user.Viewer {
+		t.Fatalf("menu roles: unknown %s, list %s", requiredRole("999"), requiredRole("2"))
+	}
+	// репозиторий сессии проверяет роль сам: viewer не пишет, даже минуя меню и фасад
+	viewerRepo := proxyrepo.NewOwnedRepo(bankaccountrepo.NewBankAccountRepo(), proxyrepo.NewAccess(viewer.ID(), nil))
+	if _, err := facade.NewBankAccountFacade(viewerRepo).CreateAccount(ctx, "Cash", 10); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("viewer created an account: %v", err)
+	}
+	if _, err := facade.NewBankAccountFacade(viewerRepo).ListAllAccounts(ctx); err != nil {
+		t.Fatalf("viewer reads accounts: %v", err)
+	}
+	if err := users.SetRole(defCtx, viewer.ID(), user.Admin); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	// роль берётся из хранилища, старый токен даёт уже новые права
+	u, _ := users.Authenticate(ctx, token)
+	if err := auth.Require(auth.WithPrincipal(context.Background(), u), user.Admin); err != nil {
+		t.Fatalf("promoted user: %v", err)
+	}
+	// сброс пароля: одноразовый код выдаёт только администратор
+	if _, err := users.IssueSetupCode(auth.WithPrincipal(context.Background(), viewer), def.ID()); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("viewer issued a setup code: %v", err)
+	}
+	veraCtx := auth.WithPrincipal(context.Background(), u)
+	code, err := users.IssueSetupCode(veraCtx, def.ID())
+	if err != nil {
+		t.Fatalf("issue setup code: %v", err)
+	}
+	if _, err := users.Authenticate(ctx, code); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("setup code accepted as a login token: %v", err)
+	}
+	if _, err := users.SetPasswordWithCode(ctx, "vera", code, "stolen-password"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("setup code used for another user: %v", err)
+	}
+	if _, err := users.SetPasswordWithCode(ctx, "default", code, "new-admin-password"); err != nil {
+		t.Fatalf("reset with setup code: %v", err)
+	}
+	if _, err := users.SetPasswordWithCode(ctx, "default", code, "third-password"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("setup code reused: %v", err)
+	}
+	if err := users.ChangePassword(defCtx, def.ID(), "admin-password", "other-password"); !errors.Is(err, auth.ErrBadCredentials) {
+		t.Fatalf("change with a stale password: %v", err)
+	}
+	if err := users.ChangePassword(defCtx, def.ID(), "new-admin-password", "other-password"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+
+	if err := users.SetRole(veraCtx, def.ID(), user.Viewer); err != nil {
+		t.Fatalf("demote one of two admins: %v", err)
+	}
+	if err := users.SetRole(veraCtx, viewer.ID(), user.Editor); err == nil {
+		t.Fatalf("last admin demoted")
+	}
+}
+
+func TestErrors_Taxonomy(t *testing.T) {
+	ctx := auth.System(context.Background())
+
+	// конструкторы: проверка полей, текст ошибки прежний
+	_, err := bankaccount.NewBankAccount("", 10)
+	var invalid *service.ValidationError
+	if !errors.Is(err, service.ErrValidation) || !errors.As(err, &invalid) || invalid.Field != "name" {
+		t.Fatalf("empty name: %v", err)
+	}
+	if err.Error() != "account name cannot be empty" {
+		t.Fatalf("message changed: %q", err.Error())
+	}
+	if _, err := operation.NewOperation(operation.Spending, service.ObjectID(uuid.New()), -1, time.Now(), service.ObjectID(uuid.New())); !errors.As(err, &invalid) || invalid.Field != "amount" {
+		t.Fatalf("negative amount: %v", err)
+	}
+	op, _ := operation.NewOperation(operation.Spending, service.ObjectID(uuid.New()), 10, time.Now(), service.ObjectID(uuid.New()))
+	if err := op.SetSplits([]operation.SplitLine{{CategoryID: service.ObjectID(uuid.New()), Amount: 3}}); !errors.Is(err, service.ErrInvariant) {
+		t.Fatalf("split sum: %v", err)
+	}
+
+	// все репозитории в памяти отвечают одинаково
+	repos := map[string]repository.ICommonRepo{
+		"accounts":   bankaccountrepo.NewBankAccountRepo(),
+		"categories": categoryrepo.NewCategoryRepo(),
+		"operations": operationrepo.NewOperationRepo(),
+		"periods":    periodrepo.NewPeriodRepo(),
+		"rules":      rulerepo.NewRuleRepo(),
+		"shares":     sharerepo.NewShareRepo(),
+		"users":      userrepo.NewUserRepo(),
+	}
+	for name, r := range repos {
+		if _, err := r.ByID(ctx, service.ObjectID(uuid.New())); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("%s ByID: %v", name, err)
+		}
+		if err := r.Delete(ctx, service.ObjectID(uuid.New())); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("%s Delete: %v", name, err)
+		}
+	}
+	accs := repos["accounts"]
+	acc, _ := bankaccount.NewBankAccount("Main", 10)
+	_ = accs.Save(ctx, acc)
+	if err := accs.Save(ctx, acc); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("second save: %v", err)
+	}
+	if err := accs.Save(ctx, op); !errors.Is(err, service.ErrInvariant) {
+		t.Fatalf("wrong type: %v", err)
+	}
+
+	// фасады и запреты
+	bankF := facade.NewBankAccountFacade(accs)
+	_ = bankF.DeleteAccount(ctx, acc.ID())
+	if err := bankF.DeleteAccount(ctx, acc.ID()); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("archive twice: %v", err)
+	}
+	if !errors.Is(periods.ErrClosed, service.ErrInvariant) || !errors.Is(reconcile.ErrLocked, service.ErrInvariant) {
+		t.Fatalf("locks should be invariant violations")
+	}
+	if _, err := (&operationrepo.Query{Limit: -1}).Normalize(); !errors.As(err, &invalid) || invalid.Field != "limit" {
+		t.Fatalf("query limit: %v", err)
+	}
+	owned := proxyrepo.NewOwnedRepo(accs, proxyrepo.NewAccess(service.ObjectID(uuid.New()), nil))
+	if _, err := owned.ByID(ctx, acc.ID()); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("foreign account: %v", err)
+	}
+}
+
+// Save — только вставка, Update — только существующая строка; в памяти и в Postgres одинаково.
+// Postgres проверяется, если задан TEST_DB_HOST (остальные параметры — как у main).
+func TestRepo_SaveUpdateSameOnBothBackends(t *testing.T) {
+	ctx := auth.System(context.Background())
+	backends := map[string]func() (repository.ICommonRepo, repository.ICommonRepo){
+		"memory": func() (repository.ICommonRepo, repository.ICommonRepo) {
+			return bankaccountrepo.NewBankAccountRepo(), userrepo.NewUserRepo()
+		},
+	}
+	if host := os.Getenv("TEST_DB_HOST"); host != "" {
+		pg := postgresrepo.NewPostgresRepo()
+		if err := pg.Init(getEnv("DB_USER", "bankservice"), getEnv("DB_PASSWORD", "password"),
+			getEnv("DB_NAME", "bankservice"), host, getEnv("DB_PORT", "5432")); err != nil {
+			t.Fatalf("postgres: %v", err)
+		}
+		defer pg.Close()
+		backends["postgres"] = func() (repository.ICommonRepo, repository.ICommonRepo) {
+			return dbrepo.NewBankAccountDBRepo(pg.DB()), dbrepo.NewUserDBRepo(pg.DB())
+		}
+	} else {
+		t.Log("TEST_DB_HOST not set, postgres skipped")
+	}
+
+	for name, open := range backends {
+		accs, users := open()
+
+		acc, _ := bankaccount.NewBankAccount("Main", 10)
+		if err := accs.Update(ctx, acc); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("%s: update before save: %v", name, err)
+		}
+		if err := accs.Save(ctx, acc); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+		acc.SetName("Renamed")
+		if err := accs.Save(ctx, acc); !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("%s: second save: %v", name, err)
+		}
+		if got, _ := accs.ByID(ctx, acc.ID()); got.(bankaccount.IBankAccount).Name() != "Main" {
+			t.Fatalf("%s: second save overwrote the row", name)
+		}
+		if err := accs.Update(ctx, acc); err != nil {
+			t.Fatalf("%s: update: %v", name, err)
+		}
+		if got, _ := accs.ByID(ctx, acc.ID()); got.(bankaccount.IBankAccount).Name() != "Renamed" {
+			t.Fatalf("%s: update not stored", name)
+		}
+		_ = accs.Delete(ctx, acc.ID())
+
+		u, _ := user.NewUser("contract-"+uuid.NewString()[:8], user.Viewer, time.Now())
+		if err := users.Update(ctx, u); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("%s: update unsaved user: %v", name, err)
+		}
+		if err := users.Save(ctx, u); err != nil {
+			t.Fatalf("%s: save user: %v", name, err)
+		}
+		if err := users.Save(ctx, u); !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("%s: second user save: %v", name, err)
+		}
+		_ = users.Delete(ctx, u.ID())
+	}
+}
+
+// Контекст без владельца и без service.Unscoped не включает app.bypass: политики RLS не отдают ничего.
+func TestDBScope_UnscopedContextSeesNoRows(t *testing.T) {
+	owner := service.ObjectID(uuid.New())
+	rls := &rlsDriver{rows: [][]driver.Value{
+		{uuid.NewString(), "Main", 10.0, nil, owner.String()},
+		{uuid.NewString(), "Other", 5.0, nil, uuid.NewString()},
+	}}
+	name := "rls-" + uuid.NewString()
+	sql.Register(name, rls)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	accs := dbrepo.NewBankAccountDBRepo(db)
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"forgotten scope", context.Background(), 0},
+		{"owner", service.WithOwner(context.Background(), owner), 1},
+		{"system", service.Unscoped(context.Background()), 2},
+		{"system job", auth.System(context.Background()), 2},
+	} {
+		objs, err := accs.All(tc.ctx)
+		if err != nil || len(objs) != tc.want {
+			t.Fatalf("%s: %d rows, want %d (%v)", tc.name, len(objs), tc.want, err)
+		}
+	}
+}
+
+// "Local" Postgres в AT TIME ZONE не принимает: хранилище подставляет IANA-имя зоны процесса.
+func TestDBTimeSeries_LocalZoneResolvedToIANAName(t *testing.T) {
+	t.Setenv("TZ", "Asia/Tokyo")
+	rls := &rlsDriver{}
+	name := "rls-" + uuid.NewString()
+	sql.Register(name, rls)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ops := dbrepo.NewOperationDBRepo(db)
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	if _, err := ops.TimeSeries(context.Background(), nil, from, to, operationrepo.Day, time.Local); err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+	if len(rls.args) < 2 || rls.args[1].Value != "Asia/Tokyo" {
+		t.Fatalf("expected Asia/Tokyo as the zone argument, got %v", rls.args)
+	}
+	if _, err := ops.TimeSeries(context.Background(), nil, from, to, operationrepo.Day, time.FixedZone("XYZ", 3600)); !errors.Is(err, service.ErrValidation) {
+		t.Fatalf("expected validation error for an unknown zone, got %v", err)
+	}
+}
+
+// Драйвер с политикой owner_isolation: строка видна при app.bypass = 'on' или своём owner_id.
+// Настройки задаёт set_config в транзакции запроса.
+type rlsDriver struct {
+	rows [][]driver.Value    // id, name, balance, deleted_at, owner_id
+	args []driver.NamedValue // аргументы последнего запроса
+}
+
+func (d *rlsDriver) Open(string) (driver.Conn, error) { return &rlsConn{d: d}, nil }
+
+type rlsConn struct {
+	d            *rlsDriver
+	user, bypass string
+}
+
+func (c *rlsConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *rlsConn) Close() error                        { return nil }
+func (c *rlsConn) Begin() (driver.Tx, error)           { c.user, c.bypass = "", ""; return c, nil }
+func (c *rlsConn) Commit() error                       { return nil }
+func (c *rlsConn) Rollback() error                     { return nil }
+
+func (c *rlsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.Contains(query, "set_config") {
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	c.user, _ = args[0].Value.(string)
+	c.bypass, _ = args[1].Value.(string)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *rlsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.args = args
+	var visible [][]driver.Value
+	for _, r := range c.d.rows {
+		if c.bypass == "on" || r[4] == c.user {
+			visible = append(visible, r)
+		}
+	}
+	return &rlsRows{rows: visible}, nil
+}
+
+type rlsRows struct {
+	rows [][]driver.Value
+}
+
+func (r *rlsRows) Columns() []string {
+	return []string{"id", "name", "balance", "deleted_at", "owner_id"}
+}
+func (r *rlsRows) Close() error { return nil }
+func (r *rlsRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestContext_CancelImportExportAndCommands(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	acc, _ := bankaccount.NewBankAccount("Main", 10)
+	path := filepath.Join(dir, "accounts.json")
+	if err := jsonexporter.NewJSONBankAccountExporter(path).Export(ctx, []service.ICommonObject{acc}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	// отменённый экспорт не пишет файл, отменённый импорт ничего не сохраняет
+	skipped := filepath.Join(dir, "skipped.json")
+	if err := jsonexporter.NewJSONBankAccountExporter(skipped).Export(cancelled, []service.ICommonObject{acc}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled export: %v", err)
+	}
+	if _, err := os.Stat(skipped); !os.IsNotExist(err) {
+		t.Fatalf("cancelled export wrote a file: %v", err)
+	}
+	imp := jsonimporter.NewJSONBankAccountImporter(path)
+	if err := imp.Read(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled import: %v", err)
+	}
+	if objs, _ := imp.Data().All(ctx); len(objs) != 0 {
+		t.Fatalf("cancelled import saved %d objects", len(objs))
+	}
+	if err := imp.Read(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	// контекст доходит до команды через декоратор; ошибка отмены получает свой код в CLI
+	cmd := commandpkg.CommandFunc(func(ctx context.Context) error { return ctx.Err() })
+	err := timer.NewTimerDecorator(cmd).Execute(cancelled)
+	if !errors.Is(err, context.Canceled) || errorCode(err) != "cancelled" {
+		t.Fatalf("cancelled command: %v (%q)", err, errorCode(err))
+	}
+
+	// Ctrl-C отменяет только выполняемую команду
+	cmds := &interrupter{}
+	if cmds.interrupt() {
+		t.Fatalf("interrupt without a running command")
+	}
+	running := cmds.begin(ctx)
+	if !cmds.interrupt() || !errors.Is(running.Err(), context.Canceled) {
+		t.Fatalf("running command not cancelled: %v", running.Err())
+	}
+	cmds.end()
+	if cmds.interrupt() {
+		t.Fatalf("interrupt after the command finished")
+	}
+}
+
+// Считает выборки доступов по получателю.
+type countingShares struct {
+	*sharerepo.ShareRepo
+	lookups int
+}
+
+func (c *countingShares) SharesFor(ctx context.Context, userID service.ObjectID) ([]service.ICommonObject, error) {
+	c.lookups++
+	return c.ShareRepo.SharesFor(ctx, userID)
+}